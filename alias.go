@@ -0,0 +1,102 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AliasRoute is a logical route with a template per locale, such as
+// `/en/about-us` and `/de/ueber-uns`, whose templates have the same params.
+type AliasRoute struct {
+	locales  []string
+	matchers map[string]*Matcher
+	paths    map[string]func(interface{}) (string, error)
+}
+
+// AliasResult is the result of an AliasRoute match.
+type AliasResult struct {
+	// locale of the matched template
+	Locale string
+
+	*MatchResult
+}
+
+// NewAliasRoute creates an AliasRoute from the templates by locale. The
+// templates must declare the same param names, in any order.
+func NewAliasRoute(templates map[string]string, options *Options) (*AliasRoute, error) {
+	if len(templates) == 0 {
+		return nil, errors.New("no alias templates")
+	}
+
+	r := &AliasRoute{
+		matchers: make(map[string]*Matcher, len(templates)),
+		paths:    make(map[string]func(interface{}) (string, error), len(templates)),
+	}
+	for locale := range templates {
+		r.locales = append(r.locales, locale)
+	}
+	sort.Strings(r.locales)
+
+	var names []string
+	for i, locale := range r.locales {
+		template := templates[locale]
+		m, err := NewMatcher(template, options)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %v", locale, err)
+		}
+		toPath, err := Compile(template, options)
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %v", locale, err)
+		}
+		r.matchers[locale], r.paths[locale] = m, toPath
+
+		localeNames := make([]string, len(m.tokens))
+		for j, token := range m.tokens {
+			localeNames[j] = fmt.Sprintf("%v", token.Name)
+		}
+		sort.Strings(localeNames)
+		if i == 0 {
+			names = localeNames
+		} else if strings.Join(localeNames, "\x00") != strings.Join(names, "\x00") {
+			return nil, fmt.Errorf("alias %q has the params %v, but alias %q has %v",
+				locale, localeNames, r.locales[0], names)
+		}
+	}
+
+	return r, nil
+}
+
+// Locales returns the sorted locales of the route.
+func (r *AliasRoute) Locales() []string {
+	return append([]string(nil), r.locales...)
+}
+
+// Match matches the pathname with the templates in the order of their
+// locales, returning nil if none matches.
+func (r *AliasRoute) Match(pathname string) (*AliasResult, error) {
+	for _, locale := range r.locales {
+		result, err := r.matchers[locale].find(pathname)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			return &AliasResult{Locale: locale, MatchResult: result}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Build returns the path of the template of the locale with the params.
+func (r *AliasRoute) Build(locale string, params interface{}) (string, error) {
+	toPath, ok := r.paths[locale]
+	if !ok {
+		return "", fmt.Errorf("unknown locale %q", locale)
+	}
+	return toPath(params)
+}
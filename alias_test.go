@@ -0,0 +1,98 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAliasRoute(t *testing.T) {
+	route, err := NewAliasRoute(map[string]string{
+		"en": "/en/about-us/:section?",
+		"de": "/de/ueber-uns/:section?",
+		"fr": "/fr/:section?/a-propos",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should match any alias", func(t *testing.T) {
+		tests := []struct {
+			pathname string
+			expect   *AliasResult
+		}{
+			{"/en/about-us", &AliasResult{Locale: "en", MatchResult: &MatchResult{Path: "/en/about-us", Params: m{},
+				Groups: []string{"/en/about-us", ""}}}},
+			{"/de/ueber-uns/team", &AliasResult{Locale: "de",
+				MatchResult: &MatchResult{Path: "/de/ueber-uns/team", Params: m{"section": "team"},
+					Groups: []string{"/de/ueber-uns/team", "team"}}}},
+			{"/fr/equipe/a-propos", &AliasResult{Locale: "fr",
+				MatchResult: &MatchResult{Path: "/fr/equipe/a-propos", Params: m{"section": "equipe"},
+					Groups: []string{"/fr/equipe/a-propos", "equipe"}}}},
+			{"/de/about-us", nil},
+		}
+		for _, test := range tests {
+			result, err := route.Match(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Errorf("%s: "+testErrorFormat, test.pathname, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should build the path of a locale", func(t *testing.T) {
+		tests := [][3]interface{}{
+			{"en", m{"section": "team"}, "/en/about-us/team"},
+			{"de", nil, "/de/ueber-uns"},
+			{"fr", m{"section": "equipe"}, "/fr/equipe/a-propos"},
+		}
+		for _, test := range tests {
+			path, err := route.Build(test[0].(string), test[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test[2] {
+				t.Errorf(testErrorFormat, path, test[2])
+			}
+		}
+
+		if _, err := route.Build("it", nil); err == nil || err.Error() != `unknown locale "it"` {
+			t.Errorf(testErrorFormat, err, `unknown locale "it"`)
+		}
+	})
+
+	t.Run("should list the locales", func(t *testing.T) {
+		if locales := route.Locales(); !reflect.DeepEqual(locales, []string{"de", "en", "fr"}) {
+			t.Errorf(testErrorFormat, locales, []string{"de", "en", "fr"})
+		}
+	})
+
+	t.Run("should reject invalid aliases", func(t *testing.T) {
+		tests := []struct {
+			templates map[string]string
+			message   string
+		}{
+			{map[string]string{"en": "/en/users/:id", "de": "/de/benutzer/:userId"},
+				`alias "en" has the params [id], but alias "de" has [userId]`},
+			{map[string]string{"en": "/en/:a/:b", "de": "/de/:b"}, `alias "en" has the params [a b], but alias "de" has [b]`},
+			{map[string]string{"en": "/en/:id(", "de": "/de"}, `alias "en": unbalanced pattern at 7`},
+			{map[string]string{}, "no alias templates"},
+		}
+		for _, test := range tests {
+			_, err := NewAliasRoute(test.templates, nil)
+			if err == nil || err.Error() != test.message {
+				t.Errorf(testErrorFormat, err, test.message)
+			}
+		}
+
+		// The order of the params doesn't matter.
+		if _, err := NewAliasRoute(map[string]string{"en": "/:a/:b", "de": "/:b/x/:a"}, nil); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"container/list"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cache memoizes the functions returned by Match and Compile, keyed by the
+// path and the options. It is safe for concurrent use, and evicts the least
+// recently used entry once it holds more than `maxEntries` functions.
+//
+// Functions can't be compared, so options holding one, such as `Encode`,
+// `Decode`, the `Constraints`, the `Hooks` or an Encoder or Decoder which is a
+// function, are not cached, the function is built on every call instead. An
+// Engine, Encoder or Decoder which is a pointer is keyed by its address.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	entries    map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	value interface{}
+}
+
+// NewCache creates a Cache holding at most `maxEntries` functions, zero or
+// less means no limit.
+func NewCache(maxEntries int) *Cache {
+	return &Cache{maxEntries: maxEntries, ll: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Match is like the package level Match but returns the cached function for
+// the same path and options.
+func (c *Cache) Match(path string, options *Options) (func(string) (*MatchResult, error), error) {
+	key, ok := cacheKey("match", path, options)
+	if ok {
+		if v, ok := c.get(key); ok {
+			return v.(func(string) (*MatchResult, error)), nil
+		}
+	}
+
+	fn, err := Match(path, options)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		c.add(key, fn)
+	}
+	return fn, nil
+}
+
+// Compile is like the package level Compile but returns the cached function
+// for the same path and options.
+func (c *Cache) Compile(path string, options *Options) (func(interface{}) (string, error), error) {
+	key, ok := cacheKey("compile", path, options)
+	if ok {
+		if v, ok := c.get(key); ok {
+			return v.(func(interface{}) (string, error)), nil
+		}
+	}
+
+	fn, err := Compile(path, options)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		c.add(key, fn)
+	}
+	return fn, nil
+}
+
+// Len returns the number of cached functions.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *Cache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*cacheEntry).value, true
+	}
+	return nil, false
+}
+
+func (c *Cache) add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*cacheEntry).value = value
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&cacheEntry{key: key, value: value})
+	if c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.entries, e.Value.(*cacheEntry).key)
+	}
+}
+
+// Returns the canonical key of the path and options, pointer fields are
+// dereferenced. The second result is false when the options hold a function
+// and can't be cached.
+func cacheKey(kind, path string, options *Options) (string, bool) {
+	var b strings.Builder
+	b.WriteString(kind)
+	b.WriteByte(0)
+	b.WriteString(path)
+	if options == nil {
+		b.WriteString(zeroOptionsKey)
+		return b.String(), true
+	}
+	if !writeKey(&b, reflect.ValueOf(options).Elem()) {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// The key of the zero options, which nil options are the same as.
+var zeroOptionsKey = func() string {
+	var b strings.Builder
+	writeKey(&b, reflect.ValueOf(Options{}))
+	return b.String()
+}()
+
+// Writes the value of the options field to the key. The pointers held by an
+// interface, such as a stateful Engine, are keyed by their address, the other
+// pointers by the value they point to. It returns false for the values which
+// can't be keyed, such as functions and channels.
+func writeKey(b *strings.Builder, v reflect.Value) bool {
+	b.WriteByte(0)
+	switch v.Kind() {
+	case reflect.Func:
+		return v.IsNil()
+	case reflect.Ptr:
+		if v.IsNil() {
+			return true
+		}
+		b.WriteByte('&')
+		return writeKey(b, v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		e := v.Elem()
+		b.WriteString(e.Type().String())
+		if e.Kind() == reflect.Ptr {
+			b.WriteString("@" + strconv.FormatUint(uint64(e.Pointer()), 16))
+			return true
+		}
+		return e.Type().Comparable() && writeKey(b, e)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !writeKey(b, v.Field(i)) {
+				return false
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		b.WriteString(strconv.Itoa(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if !writeKey(b, v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		for _, key := range v.MapKeys() {
+			var k strings.Builder
+			if !writeKey(&k, key) {
+				return false
+			}
+			keys = append(keys, k.String())
+			values[k.String()] = v.MapIndex(key)
+		}
+		sort.Strings(keys)
+		b.WriteString(strconv.Itoa(len(keys)))
+		for _, key := range keys {
+			b.WriteString(key)
+			if !writeKey(b, values[key]) {
+				return false
+			}
+		}
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	t.Run("should return the cached function", func(t *testing.T) {
+		cache := NewCache(10)
+		match1, err := cache.Match("/user/:id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match2, err := cache.Match("/user/:id", &Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.ValueOf(match1).Pointer() != reflect.ValueOf(match2).Pointer() {
+			t.Error("expect the cached match function")
+		}
+
+		result, err := match2("/user/123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/user/123", Index: 0, Params: m{"id": "123"}}
+		if !expect.equals(result) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+
+		toPath, err := cache.Compile("/user/:id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := toPath(m{"id": 123})
+		if err != nil || path != "/user/123" {
+			t.Errorf(testErrorFormat, path, "/user/123")
+		}
+		if cache.Len() != 2 {
+			t.Errorf(testErrorFormat, cache.Len(), 2)
+		}
+	})
+
+	t.Run("should key by options", func(t *testing.T) {
+		cache := NewCache(10)
+		trueValue := true
+		for _, o := range []*Options{
+			nil,
+			{Sensitive: true},
+			{End: &falseValue},
+			{End: &trueValue},
+			{Limits: &Limits{MaxTokens: 2}},
+			{Limits: &Limits{MaxTokens: 3}},
+		} {
+			if _, err := cache.Match("/test", o); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if cache.Len() != 6 {
+			t.Errorf(testErrorFormat, cache.Len(), 6)
+		}
+
+		if _, err := cache.Match("/test", &Options{End: new(bool)}); err != nil {
+			t.Fatal(err)
+		}
+		if cache.Len() != 6 {
+			t.Errorf(testErrorFormat, cache.Len(), 6)
+		}
+	})
+
+	t.Run("should not cache options with functions", func(t *testing.T) {
+		cache := NewCache(10)
+		options := &Options{Decode: decodeURIComponent}
+		match, err := cache.Match("/:foo", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := match("/caf%C3%A9")
+		if err != nil || result.Params["foo"] != "café" {
+			t.Errorf(testErrorFormat, result, "café")
+		}
+		if cache.Len() != 0 {
+			t.Errorf(testErrorFormat, cache.Len(), 0)
+		}
+	})
+
+	t.Run("should key the engines", func(t *testing.T) {
+		cache := NewCache(10)
+		first, second := &recordingEngine{}, &recordingEngine{}
+		for _, o := range []*Options{
+			{Engine: scaledEngine{scale: 1}},
+			{Engine: scaledEngine{scale: 1}},
+			{Engine: scaledEngine{scale: 2}},
+			{Engine: first},
+			{Engine: first},
+			{Engine: second},
+		} {
+			if _, err := cache.Match("/:id", o); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if cache.Len() != 4 {
+			t.Errorf(testErrorFormat, cache.Len(), 4)
+		}
+	})
+
+	t.Run("should not cache constraints closing over different values", func(t *testing.T) {
+		cache := NewCache(10)
+		maxLen := func(n int) *Options {
+			return &Options{Constraints: map[string]func(string) bool{
+				"id": func(value string) bool { return len(value) <= n },
+			}}
+		}
+		short, err := cache.Match("/:id", maxLen(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		long, err := cache.Match("/:id", maxLen(5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, err := short("/abcd"); err != nil || result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+		if result, err := long("/abcd"); err != nil || result == nil {
+			t.Errorf(testErrorFormat, result, "/abcd")
+		}
+		if cache.Len() != 0 {
+			t.Errorf(testErrorFormat, cache.Len(), 0)
+		}
+	})
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		cache := NewCache(10)
+		if _, err := cache.Compile("/:foo(", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+		if cache.Len() != 0 {
+			t.Errorf(testErrorFormat, cache.Len(), 0)
+		}
+	})
+
+	t.Run("should evict the least recently used entry", func(t *testing.T) {
+		cache := NewCache(2)
+		a1, _ := cache.Match("/a", nil)
+		cache.Match("/b", nil)
+		cache.Match("/a", nil)
+		cache.Match("/c", nil)
+		if cache.Len() != 2 {
+			t.Errorf(testErrorFormat, cache.Len(), 2)
+		}
+		if _, ok := cache.get(mustCacheKey("match", "/b")); ok {
+			t.Error("expect /b to be evicted")
+		}
+		a2, _ := cache.Match("/a", nil)
+		if reflect.ValueOf(a1).Pointer() != reflect.ValueOf(a2).Pointer() {
+			t.Error("expect /a to be kept")
+		}
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		cache := NewCache(8)
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					path := "/" + strconv.Itoa((i+j)%12) + "/:id"
+					match, err := cache.Match(path, nil)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if result, _ := match(path[:len(path)-3] + "x"); result == nil {
+						t.Errorf(testErrorFormat, result, path)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		if cache.Len() != 8 {
+			t.Errorf(testErrorFormat, cache.Len(), 8)
+		}
+	})
+}
+
+func BenchmarkCache(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Match("/users/:id/posts/:post", nil)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		cache := NewCache(100)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cache.Match("/users/:id/posts/:post", nil)
+		}
+	})
+}
+
+func mustCacheKey(kind, path string) string {
+	key, _ := cacheKey(kind, path, nil)
+	return key
+}
+
+// scaledEngine is an Engine value with unexported fields.
+type scaledEngine struct {
+	scale float32
+	flags [2]uint8
+}
+
+func (e scaledEngine) Compile(pattern string, ignoreCase bool) (Pattern, error) {
+	return StdEngine{}.Compile(pattern, ignoreCase)
+}
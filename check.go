@@ -0,0 +1,137 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// OptionsError lists the problems of invalid or contradictory options, see
+// Options.Check.
+type OptionsError struct {
+	Problems []string
+}
+
+func (e *OptionsError) Error() string {
+	return "invalid options: " + strings.Join(e.Problems, "; ")
+}
+
+// Check returns an *OptionsError listing the problems of the options, or nil
+// if there are none. It's called by Parse and PathToRegexp, so that the
+// options which could never produce a working route fail early:
+//
+//   - a negative MaxRegexpLen, MaxPathLen, MaxRepeats, MatchTimeout, ResultCache or limit
+//   - an unknown Compat, Encoding or DuplicateDelimiters value
+//   - RegexFlags with ExplicitCapture, IgnorePatternWhitespace or RightToLeft,
+//     which change the groups or the syntax of the generated regexps
+//   - QueryParams or MatrixParams with a Compat mode, which doesn't parse them
+//   - MatrixParams with `;` in the Delimiter, as the params are separated by `;`
+//   - a param listed in both SensitiveParams and InsensitiveParams
+//   - a Prefixes character which is template syntax, such as `:` or `(`, as
+//     it can never be a literal preceding a param
+//   - a `-` in the Delimiter or EndsWith, which would make a range of the
+//     characters around it in the character class of the regexp
+//   - an EndsWith character in the Delimiter other than `?` and `#`, as every
+//     segment would end the path
+//   - a Prefixes character which is `#` and in the Delimiter, as it ends the
+//     path rather than starting a segment
+//
+// The `?` and `#` of the Delimiter end the path, as EndsWith does, and its
+// other characters separate the segments, as the Prefixes do, so the default
+// Delimiter overlaps both.
+func (o *Options) Check() error {
+	if o == nil {
+		return nil
+	}
+
+	var problems []string
+	if o.MaxRegexpLen < 0 {
+		problems = append(problems, fmt.Sprintf("MaxRegexpLen is negative: %d", o.MaxRegexpLen))
+	}
+	if o.MaxPathLen < 0 {
+		problems = append(problems, fmt.Sprintf("MaxPathLen is negative: %d", o.MaxPathLen))
+	}
+	if o.MaxRepeats < 0 {
+		problems = append(problems, fmt.Sprintf("MaxRepeats is negative: %d", o.MaxRepeats))
+	}
+	if o.MatchTimeout < 0 {
+		problems = append(problems, fmt.Sprintf("MatchTimeout is negative: %v", o.MatchTimeout))
+	}
+	if o.ResultCache < 0 {
+		problems = append(problems, fmt.Sprintf("ResultCache is negative: %d", o.ResultCache))
+	}
+	if o.Limits != nil {
+		for _, limit := range []struct {
+			name  string
+			value int
+		}{
+			{"MaxTemplateLen", o.Limits.MaxTemplateLen},
+			{"MaxTokens", o.Limits.MaxTokens},
+			{"MaxPatternLen", o.Limits.MaxPatternLen},
+		} {
+			if limit.value < 0 {
+				problems = append(problems, fmt.Sprintf("Limits.%s is negative: %d", limit.name, limit.value))
+			}
+		}
+	}
+	if o.Compat < CompatNone || o.Compat > CompatExpress4 {
+		problems = append(problems, fmt.Sprintf("unknown Compat %d", o.Compat))
+	}
+	if o.Encoding < EncodingNone || o.Encoding > EncodingURI {
+		problems = append(problems, fmt.Sprintf("unknown Encoding %d", o.Encoding))
+	}
+	if o.DuplicateDelimiters < DuplicateDelimitersAllow || o.DuplicateDelimiters > DuplicateDelimitersCollapse {
+		problems = append(problems, fmt.Sprintf("unknown DuplicateDelimiters %d", o.DuplicateDelimiters))
+	}
+	if unsupported := o.RegexFlags & (regexp2.ExplicitCapture | regexp2.IgnorePatternWhitespace | regexp2.RightToLeft); unsupported != 0 {
+		problems = append(problems, fmt.Sprintf("RegexFlags has flags changing the groups or the syntax of the generated regexps: %#x", int(unsupported)))
+	}
+	if o.Compat != CompatNone && (o.QueryParams || o.MatrixParams) {
+		problems = append(problems, "QueryParams and MatrixParams don't apply with a Compat mode")
+	}
+	if o.MatrixParams && strings.ContainsRune(anyString(o.Delimiter, defaultDelimiter), ';') {
+		problems = append(problems, "MatrixParams can't be used with `;` in the Delimiter")
+	}
+	for _, name := range o.SensitiveParams {
+		for _, v := range o.InsensitiveParams {
+			if v == name {
+				problems = append(problems, fmt.Sprintf("param %q is both in SensitiveParams and InsensitiveParams", name))
+			}
+		}
+	}
+	if o.Prefixes != nil {
+		syntax := `:(){}*+?\`
+		if o.LiteralBackslash {
+			syntax = `:(){}*+?`
+		}
+		if i := strings.IndexAny(*o.Prefixes, syntax); i >= 0 {
+			problems = append(problems, fmt.Sprintf("Prefixes has the template syntax character %q",
+				(*o.Prefixes)[i]))
+		}
+	}
+	delimiter := anyString(o.Delimiter, defaultDelimiter)
+	if delimiterSequence(o) == "" && strings.ContainsRune(delimiter, '-') {
+		problems = append(problems, "Delimiter has `-`, which makes a range in the regexp")
+	}
+	if strings.ContainsRune(o.EndsWith, '-') {
+		problems = append(problems, "EndsWith has `-`, which makes a range in the regexp")
+	}
+	for _, c := range o.EndsWith {
+		if c != '?' && c != '#' && strings.ContainsRune(delimiter, c) {
+			problems = append(problems, fmt.Sprintf("EndsWith has the Delimiter character %q, which ends every segment", c))
+		}
+	}
+	if o.Prefixes != nil && strings.ContainsRune(*o.Prefixes, '#') && strings.ContainsRune(delimiter, '#') {
+		problems = append(problems, "Prefixes has the Delimiter character '#', which ends the path")
+	}
+
+	if len(problems) > 0 {
+		return &OptionsError{Problems: problems}
+	}
+	return nil
+}
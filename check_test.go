@@ -0,0 +1,91 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionsCheck(t *testing.T) {
+	prefixes := func(str string) *string {
+		return &str
+	}
+	tests := []struct {
+		options  *Options
+		problems []string
+	}{
+		{nil, nil},
+		{&Options{}, nil},
+		{&Options{MaxRegexpLen: 10, MatchTimeout: time.Second, Limits: &Limits{MaxTokens: 4}}, nil},
+		{&Options{MaxRegexpLen: -1}, []string{"MaxRegexpLen is negative: -1"}},
+		{&Options{MaxPathLen: -1}, []string{"MaxPathLen is negative: -1"}},
+		{&Options{MaxRepeats: -1}, []string{"MaxRepeats is negative: -1"}},
+		{&Options{ResultCache: -1}, []string{"ResultCache is negative: -1"}},
+		{&Options{MatchTimeout: -time.Second}, []string{"MatchTimeout is negative: -1s"}},
+		{&Options{Limits: &Limits{MaxTemplateLen: -1, MaxPatternLen: -2}},
+			[]string{"Limits.MaxTemplateLen is negative: -1", "Limits.MaxPatternLen is negative: -2"}},
+		{&Options{Compat: CompatExpress4, Encoding: EncodingURI}, nil},
+		{&Options{Compat: 7, Encoding: -1}, []string{"unknown Compat 7", "unknown Encoding -1"}},
+		{&Options{QueryParams: true, MatrixParams: true}, nil},
+		{&Options{Compat: CompatExpress4, QueryParams: true},
+			[]string{"QueryParams and MatrixParams don't apply with a Compat mode"}},
+		{&Options{MatrixParams: true, Delimiter: "/"}, nil},
+		{&Options{MatrixParams: true, Delimiter: "/;"},
+			[]string{"MatrixParams can't be used with `;` in the Delimiter"}},
+		{&Options{Prefixes: prefixes("./$"), Delimiter: "/", EndsWith: "?"}, nil},
+		{&Options{Delimiter: "/", EndsWith: "/?"},
+			[]string{"EndsWith has the Delimiter character '/', which ends every segment"}},
+		{&Options{EndsWith: "?#"}, nil},
+		{&Options{Delimiter: "/.", EndsWith: "."},
+			[]string{"EndsWith has the Delimiter character '.', which ends every segment"}},
+		{&Options{Prefixes: prefixes("#"), Delimiter: "/"}, nil},
+		{&Options{Prefixes: prefixes("./#")},
+			[]string{"Prefixes has the Delimiter character '#', which ends the path"}},
+		{&Options{Delimiter: "/-"}, []string{"Delimiter has `-`, which makes a range in the regexp"}},
+		{&Options{Delimiter: "--"}, nil},
+		{&Options{EndsWith: "?-"}, []string{"EndsWith has `-`, which makes a range in the regexp"}},
+		{&Options{SensitiveParams: []string{"id", "0"}, InsensitiveParams: []string{"0"}},
+			[]string{"param \"0\" is both in SensitiveParams and InsensitiveParams"}},
+		{&Options{Prefixes: prefixes("\\")}, []string{"Prefixes has the template syntax character '\\\\'"}},
+		{&Options{Prefixes: prefixes("\\"), LiteralBackslash: true}, nil},
+		{&Options{Prefixes: prefixes("/:")}, []string{"Prefixes has the template syntax character ':'"}},
+	}
+	for _, test := range tests {
+		t.Run("should check "+inspect(test.options), func(t *testing.T) {
+			err := test.options.Check()
+			if test.problems == nil {
+				if err != nil {
+					t.Errorf(testErrorFormat, err, nil)
+				}
+				return
+			}
+			expect := &OptionsError{Problems: test.problems}
+			if !reflect.DeepEqual(err, expect) {
+				t.Errorf(testErrorFormat, err, expect)
+			}
+		})
+	}
+
+	t.Run("should fail fast", func(t *testing.T) {
+		options := &Options{MaxRegexpLen: -1}
+		if _, err := Parse("/:id", options); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if _, err := Compile("/:id", options); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if _, err := Match([]string{"/a", "/b"}, options); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if _, err := PathToRegexp([]Token{{Name: "id", Pattern: "x"}}, nil, options); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if _, err := Match("/:id", &Options{MaxRegexpLen: -1, Engine: StdEngine{}}); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
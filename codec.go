@@ -0,0 +1,39 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+// Encoder encodes the values of the params for the path function, see
+// `Options.Encoder`.
+type Encoder interface {
+	// EncodeParam returns the encoded value of the token, elem being the
+	// index of the value among the values of a repeated param, 0 otherwise.
+	// An error is returned by the path function.
+	EncodeParam(value string, t Token, elem int) (string, error)
+}
+
+// Decoder decodes the values of the params for the match function, see
+// `Options.Decoder`.
+type Decoder interface {
+	// DecodeParam returns the decoded value of the token, elem being the
+	// index of the value among the values of a repeated param, 0 otherwise.
+	// An error is returned by the match function.
+	DecodeParam(value string, t Token, elem int) (string, error)
+}
+
+// EncodeFunc adapts an `Options.Encode` function to the Encoder interface.
+type EncodeFunc func(uri string, token interface{}) string
+
+// EncodeParam calls the function with the token.
+func (f EncodeFunc) EncodeParam(value string, t Token, elem int) (string, error) {
+	return f(value, t), nil
+}
+
+// DecodeFunc adapts an `Options.Decode` function to the Decoder interface.
+type DecodeFunc func(str string, token interface{}) (string, error)
+
+// DecodeParam calls the function with the token.
+func (f DecodeFunc) DecodeParam(value string, t Token, elem int) (string, error) {
+	return f(value, t)
+}
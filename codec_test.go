@@ -0,0 +1,118 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// indexCodec appends the index of the element to the values, and fails on
+// the value `bad`.
+type indexCodec struct{}
+
+func (indexCodec) EncodeParam(value string, t Token, elem int) (string, error) {
+	if value == "bad" {
+		return "", fmt.Errorf("can't encode %q of %q", value, t.Name)
+	}
+	return value + "-" + strconv.Itoa(elem), nil
+}
+
+func (indexCodec) DecodeParam(value string, t Token, elem int) (string, error) {
+	if value == "bad" {
+		return "", fmt.Errorf("can't decode %q of %q", value, t.Name)
+	}
+	return value + "-" + strconv.Itoa(elem), nil
+}
+
+func TestEncoder(t *testing.T) {
+	t.Run("should give the index of the values", func(t *testing.T) {
+		toPath := MustCompile("/files/:path+/:name", &Options{Encoder: indexCodec{}, Validate: &falseValue})
+		path, err := toPath(m{"path": []string{"a", "b", "c"}, "name": "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "/files/a-0/b-1/c-2/x-0"; path != expect {
+			t.Errorf(testErrorFormat, path, expect)
+		}
+	})
+
+	t.Run("should return the errors of the encoder", func(t *testing.T) {
+		toPath := MustCompile("/files/:path+", &Options{Encoder: indexCodec{}})
+		_, err := toPath(m{"path": []string{"a", "bad"}})
+		if expect := `can't encode "bad" of "path"`; err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should take precedence over Encode", func(t *testing.T) {
+		options := &Options{Encoder: EncodeFunc(encodeURIComponent), Encode: func(uri string, token interface{}) string {
+			return "encoded"
+		}}
+		path, err := MustCompile("/:name", options)(m{"name": "c d"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "/c%20d"; path != expect {
+			t.Errorf(testErrorFormat, path, expect)
+		}
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("should give the index of the values", func(t *testing.T) {
+		result, err := MustMatch("/files/:path+/:name", &Options{Decoder: indexCodec{}})("/files/a/b/c/x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := map[interface{}]interface{}{"path": []string{"a-0", "b-1", "c-2"}, "name": "x-0"}
+		if !reflect.DeepEqual(result.Params, expect) {
+			t.Errorf(testErrorFormat, result.Params, expect)
+		}
+	})
+
+	t.Run("should return the errors of the decoder", func(t *testing.T) {
+		_, err := MustMatch("/files/:path+", &Options{Decoder: indexCodec{}})("/files/a/bad")
+		if expect := `can't decode "bad" of "path"`; err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should take precedence over Decode", func(t *testing.T) {
+		failing := errors.New("decode")
+		options := &Options{Decoder: DecodeFunc(decodeURIComponent), Decode: func(str string, token interface{}) (string, error) {
+			if token == nil {
+				return str, nil
+			}
+			return "", failing
+		}}
+		result, err := MustMatch("/:name", options)("/c%20d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "c d"; result.Params["name"] != expect {
+			t.Errorf(testErrorFormat, result.Params["name"], expect)
+		}
+		if expect := "/c%20d"; result.DecodedPath != expect {
+			t.Errorf(testErrorFormat, result.DecodedPath, expect)
+		}
+	})
+
+	t.Run("should not cache the functions", func(t *testing.T) {
+		c := NewCache(4)
+		if _, err := c.Match("/:name", &Options{Decoder: DecodeFunc(decodeURIComponent)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Match("/:name", &Options{Decoder: indexCodec{}}); err != nil {
+			t.Fatal(err)
+		}
+		if c.Len() != 1 {
+			t.Errorf(testErrorFormat, c.Len(), 1)
+		}
+	})
+}
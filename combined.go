@@ -0,0 +1,235 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// CombinedMatcher matches many routes with a regexp combining all of them,
+// which is faster than running the regexp of each route in turn. Only the
+// matcher of the matched route runs to decode the params.
+//
+// When several routes match, the first listed route wins. With `Options.Start`
+// set to false, a route matching earlier in the pathname wins over the routes
+// listed before it. When the matcher of the route rejects the pathname after
+// its regexp, e.g. for a constraint, the other routes are tried in the order
+// they're listed.
+type CombinedMatcher struct {
+	re       *regexp2.Regexp
+	chunks   []*regexp2.Regexp
+	pattern  Pattern
+	source   string
+	groups   []int
+	matchers []*Matcher
+}
+
+// CombinedResult is the result of a CombinedMatcher match.
+type CombinedResult struct {
+	// index of the matched route
+	RouteIndex int
+
+	*MatchResult
+}
+
+// NewCombinedMatcher creates a CombinedMatcher from the route templates, a
+// *CompileError is returned for the first route which fails to compile.
+func NewCombinedMatcher(routes []string, options *Options) (*CombinedMatcher, error) {
+	c := &CombinedMatcher{matchers: make([]*Matcher, len(routes))}
+	for i, route := range routes {
+		m, err := NewMatcher(route, options)
+		if err != nil {
+			return nil, &CompileError{Index: i, Path: route, Err: err}
+		}
+		c.matchers[i] = m
+	}
+
+	if options != nil && options.Engine != nil {
+		// Each route is wrapped in a group, which tells the route that matched.
+		var b strings.Builder
+		group := 1
+		c.groups = make([]int, len(c.matchers))
+		for i, m := range c.matchers {
+			if i > 0 {
+				b.WriteString("|")
+			}
+			source := m.source
+			if options.BindDuplicates {
+				source = shiftBackreferences(source, group)
+			}
+			writeStrings(&b, "(", source, ")")
+			c.groups[i] = group
+			group += len(m.tokens) + 1
+		}
+
+		c.source = b.String()
+		pattern, err := options.Engine.Compile(c.source, ignoreCase(options))
+		if err != nil {
+			return nil, err
+		}
+		c.pattern = pattern
+		return c, nil
+	}
+
+	// regexp2 builds all the groups of a match, so only the groups telling the
+	// route that matched are captured, and the routes are split in chunks
+	// which are combined again to keep the number of groups low. The standard
+	// library engine gets slow with many alternatives, it isn't used.
+	var sources []string
+	var chunkGroups []int
+	for i := 0; i < len(c.matchers); i += combinedChunkLen {
+		chunk := c.matchers[i:]
+		if len(chunk) > combinedChunkLen {
+			chunk = chunk[:combinedChunkLen]
+		}
+		routes, groups, total := make([]string, len(chunk)), make([]int, len(chunk)), 0
+		for j, m := range chunk {
+			routes[j], groups[j] = m.source, len(m.tokens)
+			total += groups[j]
+		}
+		routes = shiftAlternatives(routes, groups, options)
+		re, err := compileAlternatives(routes, options)
+		if err != nil {
+			return nil, err
+		}
+		c.chunks = append(c.chunks, re)
+		sources = append(sources, strings.Join(routes, "|"))
+		chunkGroups = append(chunkGroups, total)
+	}
+
+	re, err := compileAlternatives(shiftAlternatives(sources, chunkGroups, options), options)
+	if err != nil {
+		return nil, err
+	}
+	c.re, c.source = re, re.String()
+	return c, nil
+}
+
+// The number of routes combined in a chunk.
+const combinedChunkLen = 16
+
+// Shifts the backreferences of `Options.BindDuplicates` in each source by the
+// groups of the sources before it, the groups of each source being numbered
+// after them in the alternation.
+func shiftAlternatives(sources []string, groups []int, options *Options) []string {
+	if options == nil || !options.BindDuplicates {
+		return sources
+	}
+	shifted, offset := make([]string, len(sources)), 0
+	for i, source := range sources {
+		shifted[i] = shiftBackreferences(source, offset)
+		offset += groups[i]
+	}
+	return shifted
+}
+
+// Compiles the alternation of the sources, capturing a group for each source
+// only, unless the backreferences of `Options.BindDuplicates` need the groups
+// of the sources.
+func compileAlternatives(sources []string, options *Options) (*regexp2.Regexp, error) {
+	var b strings.Builder
+	for i, source := range sources {
+		if i > 0 {
+			b.WriteString("|")
+		}
+		writeStrings(&b, "(?<r", strconv.Itoa(i), ">", source, ")")
+	}
+
+	opt := flags(options)
+	if options == nil || !options.BindDuplicates {
+		opt |= regexp2.ExplicitCapture
+	}
+	re, err := regexp2.Compile(b.String(), opt)
+	if err != nil {
+		return nil, err
+	}
+	if options != nil && options.MatchTimeout > 0 {
+		re.MatchTimeout = options.MatchTimeout
+	}
+	return re, nil
+}
+
+// Returns the index of the first alternative matching the pathname, or -1.
+func matchAlternative(re *regexp2.Regexp, pathname string) (int, error) {
+	m, err := re.FindStringMatch(pathname)
+	if err != nil {
+		return -1, matchTimeoutError(err)
+	}
+	if m == nil {
+		return -1, nil
+	}
+	for i := 0; ; i++ {
+		group := m.GroupByName("r" + strconv.Itoa(i))
+		if group == nil {
+			return -1, nil
+		}
+		if len(group.Captures) > 0 {
+			return i, nil
+		}
+	}
+}
+
+// Match matches the pathname, returning nil if none of the routes matches.
+func (c *CombinedMatcher) Match(pathname string) (*CombinedResult, error) {
+	route := -1
+	if c.re != nil {
+		chunk, err := matchAlternative(c.re, pathname)
+		if err != nil || chunk < 0 {
+			return nil, err
+		}
+		i, err := matchAlternative(c.chunks[chunk], pathname)
+		if err != nil || i < 0 {
+			return nil, err
+		}
+		route = chunk*combinedChunkLen + i
+	} else {
+		m, err := c.pattern.Find(pathname)
+		if err != nil || m == nil {
+			return nil, err
+		}
+		for i, group := range c.groups {
+			if m.Indexes[group] >= 0 {
+				route = i
+				break
+			}
+		}
+		if route < 0 {
+			return nil, nil
+		}
+	}
+
+	result, err := c.matchers[route].find(pathname)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return &CombinedResult{RouteIndex: route, MatchResult: result}, nil
+	}
+
+	// The matcher of the route rejected the pathname after its regexp, e.g.
+	// for a constraint or the query params, the other routes are tried in
+	// turn.
+	for i, m := range c.matchers {
+		if i == route {
+			continue
+		}
+		result, err := m.find(pathname)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			return &CombinedResult{RouteIndex: i, MatchResult: result}, nil
+		}
+	}
+	return nil, nil
+}
+
+// RouteString returns the source of the combined regexp.
+func (c *CombinedMatcher) RouteString() string {
+	return c.source
+}
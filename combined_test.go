@@ -0,0 +1,205 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCombinedMatcher(t *testing.T) {
+	t.Run("should match like the matcher of each route", func(t *testing.T) {
+		routes := []string{"/users", "/users/:id(\\d+)", "/users/:id/posts/:post?", "/files/:path*", "/:page"}
+		c, err := NewCombinedMatcher(routes, &Options{Decode: decodeURIComponent})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pathnames := []string{"/users", "/USERS/", "/users/42", "/users/abc/posts", "/users/abc/posts/hello%20world",
+			"/files", "/files/a/b/c", "/about", "/a/b/c", ""}
+		for _, pathname := range pathnames {
+			var expect *CombinedResult
+			for i, route := range routes {
+				match := MustMatch(route, &Options{Decode: decodeURIComponent})
+				result, err := match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result != nil {
+					expect = &CombinedResult{RouteIndex: i, MatchResult: result}
+					break
+				}
+			}
+
+			result, err := c.Match(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, expect) {
+				t.Errorf("%s: "+testErrorFormat, inspect(pathname), result, expect)
+			}
+		}
+	})
+
+	t.Run("should let the first listed route win", func(t *testing.T) {
+		c, err := NewCombinedMatcher([]string{"/:page", "/about"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := c.Match("/about")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &CombinedResult{RouteIndex: 0,
+			MatchResult: &MatchResult{Path: "/about", Params: m{"page": "about"}, Groups: []string{"/about", "about"}}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+
+		c, err = NewCombinedMatcher([]string{"/about", "/:page"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err = c.Match("/about")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect = &CombinedResult{RouteIndex: 0,
+			MatchResult: &MatchResult{Path: "/about", Params: m{}, Groups: []string{"/about"}}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should let the first listed route win across chunks", func(t *testing.T) {
+		routes := make([]string, 40)
+		for i := range routes {
+			routes[i] = "/r" + strconv.Itoa(i)
+		}
+		routes[35] = "/:page"
+		c, err := NewCombinedMatcher(routes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for pathname, expect := range map[string]int{"/r3": 3, "/r20": 20, "/r38": 35, "/R34": 34} {
+			result, err := c.Match(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || result.RouteIndex != expect {
+				t.Errorf("%s: "+testErrorFormat, pathname, result, expect)
+			}
+		}
+		if result, err := c.Match("/a/b"); result != nil || err != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should try the other routes when a route rejects the pathname", func(t *testing.T) {
+		routes := make([]string, 20)
+		for i := range routes {
+			routes[i] = "/r" + strconv.Itoa(i) + "/:x"
+		}
+		routes[0], routes[18] = "/files/:name([a-z0-9%]+)", "/files/:path(.*)"
+		options := &Options{ValidateMatch: true, DecodeValues: true}
+		for _, o := range []*Options{options, {ValidateMatch: true, DecodeValues: true, Engine: Regexp2Engine{}}} {
+			c, err := NewCombinedMatcher(routes, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for pathname, expect := range map[string]int{"/files/a%61": 0, "/files/a%2Fb": 18} {
+				result, err := c.Match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result == nil || result.RouteIndex != expect {
+					t.Errorf("%s: "+testErrorFormat, pathname, result, expect)
+				}
+			}
+		}
+	})
+
+	t.Run("should bind the duplicates in the combined regexp", func(t *testing.T) {
+		routes := make([]string, 20)
+		for i := range routes {
+			routes[i] = "/r" + strconv.Itoa(i) + "/:x/:y"
+		}
+		routes[17], routes[18] = "/compare/:lang/:lang", "/compare/:a/:b"
+		for _, o := range []*Options{{BindDuplicates: true}, {BindDuplicates: true, Engine: Regexp2Engine{}}} {
+			c, err := NewCombinedMatcher(routes, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for pathname, expect := range map[string]int{"/compare/go/go": 17, "/compare/go/js": 18, "/r3/a/b": 3} {
+				result, err := c.Match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result == nil || result.RouteIndex != expect {
+					t.Errorf("%s: "+testErrorFormat, pathname, result, expect)
+				}
+			}
+		}
+	})
+
+	t.Run("should match with an engine", func(t *testing.T) {
+		c, err := NewCombinedMatcher([]string{"/a/:x", "/b/:y"}, &Options{Engine: StdEngine{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := c.Match("/b/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &CombinedResult{RouteIndex: 1,
+			MatchResult: &MatchResult{Path: "/b/1", Params: m{"y": "1"}, Groups: []string{"/b/1", "1"}}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should return the error of the failed route", func(t *testing.T) {
+		_, err := NewCombinedMatcher([]string{"/a", "/:b(", "/c"}, nil)
+		var compileErr *CompileError
+		if !errors.As(err, &compileErr) || compileErr.Index != 1 || compileErr.Path != "/:b(" {
+			t.Errorf(testErrorFormat, err, "a *CompileError of route 1")
+		}
+	})
+}
+
+func BenchmarkCombinedMatcher(b *testing.B) {
+	routes := make([]string, 200)
+	for i := range routes {
+		routes[i] = "/resource" + strconv.Itoa(i) + "/:id"
+	}
+	pathname := "/resource199/123"
+
+	b.Run("combined", func(b *testing.B) {
+		c, _ := NewCombinedMatcher(routes, nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Match(pathname)
+		}
+	})
+	b.Run("loop", func(b *testing.B) {
+		matchers := make([]*Matcher, len(routes))
+		for i, route := range routes {
+			matchers[i], _ = NewMatcher(route, nil)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, matcher := range matchers {
+				if result, _ := matcher.Match(pathname); result != nil {
+					break
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compat selects the template syntax and the matching of another router.
+type Compat int
+
+const (
+	// CompatNone uses the syntax of this package.
+	CompatNone Compat = iota
+
+	// CompatExpress4 reproduces the routes of Express 4: the characters of a
+	// template other than `/`, `.`, the params and `*` are regexp syntax, so
+	// `/ab?cd` matches `/acd` and `/ab+cd` matches `/abbcd`, a `*` matches
+	// anything as an unnamed param, a group is an unnamed param unless it
+	// follows a `/`, and a param is `:name`, optionally followed by a
+	// `(pattern)` and a `?`. Only a trailing `/` is optional when not Strict,
+	// and the params are decoded with DecodeURIComponent when Decode is nil.
+	// Start, Delimiter, EndsWith and Prefixes don't apply, and the templates
+	// can't be compiled to path functions.
+	CompatExpress4
+)
+
+// Parses an Express 4 template. The params, the wildcards and the groups are
+// tokens, the other characters being kept as written in the strings, where
+// they are regexp syntax except `/` and `.`.
+func express4Parse(str string) ([]interface{}, error) {
+	var result []interface{}
+	var text strings.Builder
+	key := 0
+
+	flush := func() {
+		if text.Len() > 0 {
+			result = append(result, text.String())
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(str); {
+		switch c := str[i]; {
+		case c == '\\':
+			end := len(str)
+			if i+1 < len(str) {
+				end = nextChar(str, i+1)
+			}
+			text.WriteString(str[i:end])
+			i = end
+		case c == ':' && i+1 < len(str) && isNameByte(str[i+1]):
+			j := i + 1
+			for j < len(str) && isNameByte(str[j]) {
+				j++
+			}
+			token := Token{Name: str[i+1 : j]}
+
+			// The `/` and `.` before the param are its prefix.
+			prefix := text.String()
+			if strings.HasSuffix(prefix, ".") && !isEscaped(prefix, len(prefix)-1) {
+				token.Prefix = "."
+				prefix = prefix[:len(prefix)-1]
+			}
+			if strings.HasSuffix(prefix, "/") && !isEscaped(prefix, len(prefix)-1) {
+				token.Prefix = "/" + token.Prefix
+				prefix = prefix[:len(prefix)-1]
+			}
+			text.Reset()
+			text.WriteString(prefix)
+			flush()
+
+			token.Pattern = `[^\/` + express4Pattern(strings.TrimPrefix(token.Prefix, "/")) + `]+?`
+			if j < len(str) && str[j] == '(' {
+				end, err := express4Group(str, j)
+				if err != nil {
+					return nil, err
+				}
+				token.Pattern = express4Pattern(str[j+1 : end-1])
+				j = end
+			}
+			if j < len(str) && str[j] == '?' {
+				token.Modifier = "?"
+				j++
+			}
+			result = append(result, token)
+			i = j
+		case c == '*':
+			flush()
+			result = append(result, Token{Name: key, Pattern: ".*"})
+			key++
+			i++
+		case c == '(':
+			end, err := express4Group(str, i)
+			if err != nil {
+				return nil, err
+			}
+			prefix := text.String()
+			if strings.HasPrefix(str[i:], "(?") ||
+				strings.HasSuffix(prefix, "/") && !isEscaped(prefix, len(prefix)-1) {
+				// A group following a `/` doesn't capture.
+				text.WriteString(str[i:end])
+				i = end
+				break
+			}
+
+			flush()
+			token := Token{Name: key, Pattern: express4Pattern(str[i+1 : end-1])}
+			key++
+			if end < len(str) && str[end] == '?' {
+				token.Modifier = "?"
+				end++
+			}
+			result = append(result, token)
+			i = end
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return result, nil
+}
+
+// Returns the end of the group starting at `(` in the template.
+func express4Group(str string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unbalanced pattern at %d", start)
+}
+
+// Returns the regexp of template characters: `/` and `.` are literals, a `*`
+// matches anything, and the nested groups don't capture so that the groups
+// match the tokens.
+func express4Pattern(str string) string {
+	var b strings.Builder
+	for i := 0; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '\\':
+			b.WriteByte(c)
+			if i+1 < len(str) {
+				i++
+				b.WriteByte(str[i])
+			}
+		case '/', '.':
+			writeStrings(&b, `\`, string(c))
+		case '*':
+			b.WriteString("(?:.*)")
+		case '(':
+			b.WriteString("(")
+			if !isCapturingGroup(str[i+1:]) {
+				break
+			}
+			b.WriteString("?:")
+			if i+1 < len(str) && str[i+1] == '?' {
+				// Skip the name of a named group.
+				i += strings.IndexAny(str[i+3:], ">'") + 3
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Returns the regexp source of the tokens of an Express 4 template, as built
+// by its `path-to-regexp` 0.1.
+func express4Source(str string, rawTokens []interface{}, tokens *[]Token, options *Options) string {
+	var route strings.Builder
+	route.WriteString("^")
+	for _, token := range rawTokens {
+		if str, ok := token.(string); ok {
+			route.WriteString(express4Pattern(str))
+			continue
+		}
+
+		token := token.(Token)
+		if tokens != nil {
+			*tokens = append(*tokens, token)
+		}
+		if _, ok := token.Index(); ok {
+			writeStrings(&route, "(", token.Pattern, ")", token.Modifier)
+			continue
+		}
+
+		slash, format := "", ""
+		if strings.HasPrefix(token.Prefix, "/") {
+			slash = `\/`
+		}
+		if strings.HasSuffix(token.Prefix, ".") {
+			format = `\.`
+		}
+		if token.Modifier == "?" {
+			writeStrings(&route, "(?:", format, slash, "(", token.Pattern, "))?")
+		} else {
+			writeStrings(&route, slash, "(?:", format, "(", token.Pattern, "))")
+		}
+	}
+
+	strict := options != nil && options.Strict
+	end := options == nil || options.End == nil || *options.End
+	trailing := strings.HasSuffix(str, "/")
+	if !strict {
+		if trailing {
+			route.WriteString("?")
+		} else {
+			route.WriteString(`\/?`)
+		}
+	}
+	if end {
+		route.WriteString("$")
+	} else if !strict || !trailing {
+		route.WriteString(`(?=\/|$)`)
+	}
+	return route.String()
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompatExpress4(t *testing.T) {
+	express := &Options{Compat: CompatExpress4}
+
+	t.Run("should match like the examples of the Express docs", func(t *testing.T) {
+		// The route paths and the request URLs of the "Routing" guide of
+		// Express 4, a nil result meaning no match.
+		tests := []struct {
+			template string
+			pathname string
+			params   m
+		}{
+			{"/", "/", m{}},
+			{"/about", "/about", m{}},
+			{"/about", "/about/", m{}},
+			{"/about", "/about/team", nil},
+			{"/random.text", "/random.text", m{}},
+			{"/random.text", "/randomAtext", nil},
+			{"/ab?cd", "/acd", m{}},
+			{"/ab?cd", "/abcd", m{}},
+			{"/ab?cd", "/abbcd", nil},
+			{"/ab+cd", "/abcd", m{}},
+			{"/ab+cd", "/abbcd", m{}},
+			{"/ab+cd", "/abbbcd", m{}},
+			{"/ab+cd", "/acd", nil},
+			{"/ab*cd", "/abcd", m{0: ""}},
+			{"/ab*cd", "/abxcd", m{0: "x"}},
+			{"/ab*cd", "/abRANDOMcd", m{0: "RANDOM"}},
+			{"/ab*cd", "/ab123cd", m{0: "123"}},
+			{"/ab*cd", "/abce", nil},
+			{"/ab(cd)?e", "/abe", m{}},
+			{"/ab(cd)?e", "/abcde", m{0: "cd"}},
+			{"/ab(cd)?e", "/abcdcde", nil},
+			{"/users/:userId/books/:bookId", "/users/34/books/8989", m{"userId": "34", "bookId": "8989"}},
+			{"/flights/:from-:to", "/flights/LAX-SFO", m{"from": "LAX", "to": "SFO"}},
+			{"/plantae/:genus.:species", "/plantae/Prunus.persica", m{"genus": "Prunus", "species": "persica"}},
+			{"/user/:userId(\\d+)", "/user/42", m{"userId": "42"}},
+			{"/user/:userId(\\d+)", "/user/abc", nil},
+			{"/books/:id?", "/books", m{}},
+			{"/books/:id?", "/books/1", m{"id": "1"}},
+			{"/files/*", "/files/a/b.txt", m{0: "a/b.txt"}},
+			{"/:file.:ext?", "/app.js", m{"file": "app", "ext": "js"}},
+			{"/:file.:ext?", "/app", m{"file": "app"}},
+			{"/user/:name", "/USER/Caf%C3%A9", m{"name": "Café"}},
+		}
+		for _, test := range tests {
+			match, err := Match(test.template, express)
+			if err != nil {
+				t.Fatalf("%s: %v", test.template, err)
+			}
+			got, err := match(test.pathname)
+			if err != nil {
+				t.Fatalf("%s: %v", test.template, err)
+			}
+			if test.params == nil {
+				if got != nil {
+					t.Errorf("%s %s: "+testErrorFormat, test.template, test.pathname, got, nil)
+				}
+				continue
+			}
+			if got == nil || !reflect.DeepEqual(got.Params, map[interface{}]interface{}(test.params)) {
+				t.Errorf("%s %s: "+testErrorFormat, test.template, test.pathname, got, test.params)
+			}
+		}
+	})
+
+	t.Run("should build the regexp of Express", func(t *testing.T) {
+		tests := []struct {
+			template string
+			options  *Options
+			source   string
+		}{
+			{"/users/:id", express, `^\/users\/(?:([^\/]+?))\/?$`},
+			{"/users/", express, `^\/users\/?$`},
+			{"/users/:id", &Options{Compat: CompatExpress4, Strict: true}, `^\/users\/(?:([^\/]+?))$`},
+			{"/users/", &Options{Compat: CompatExpress4, Strict: true}, `^\/users\/$`},
+			{"/users", &Options{Compat: CompatExpress4, End: &falseValue}, `^\/users\/?(?=\/|$)`},
+			{"/users/", &Options{Compat: CompatExpress4, Strict: true, End: &falseValue}, `^\/users\/`},
+			{"/:file.:ext?", express, `^\/(?:([^\/]+?))(?:\.([^\/\.]+?))?\/?$`},
+			{"/(api|v1)/*", express, `^\/(?:api|v1)\/(.*)\/?$`},
+		}
+		for _, test := range tests {
+			re, err := PathToRegexp(test.template, nil, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if re.String() != test.source {
+				t.Errorf("%s: "+testErrorFormat, test.template, re.String(), test.source)
+			}
+		}
+	})
+
+	t.Run("should parse the params and the wildcards", func(t *testing.T) {
+		tokens, err := Parse("/ab(cd)?e/:id(\\d+)/*", express)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []interface{}{
+			"/ab",
+			Token{Name: 0, Pattern: "cd", Modifier: "?"},
+			"e",
+			Token{Name: "id", Prefix: "/", Pattern: "\\d+"},
+			"/",
+			Token{Name: 1, Pattern: ".*"},
+		}
+		if !reflect.DeepEqual(tokens, expect) {
+			t.Errorf(testErrorFormat, tokens, expect)
+		}
+	})
+
+	t.Run("should keep the groups aligned with the tokens", func(t *testing.T) {
+		match := MustMatch("/:id((a)+b*)", express)
+		result, err := match("/aabx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}{"id": "aabx"}) {
+			t.Errorf(testErrorFormat, result, m{"id": "aabx"})
+		}
+	})
+
+	t.Run("should keep the default matching without the option", func(t *testing.T) {
+		result, err := MustMatch("/about", nil)("/about#")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil {
+			t.Errorf(testErrorFormat, result, "a match")
+		}
+		result, err = MustMatch("/about", express)("/about#")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should not compile Express templates", func(t *testing.T) {
+		if _, err := Compile("/users/:id", express); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+
+	t.Run("should reject unbalanced groups", func(t *testing.T) {
+		if _, err := Parse("/users/:id(\\d+", express); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// The key of the match result in a context, unexported so that other packages
+// can't collide with it.
+type paramsKey struct{}
+
+// ContextWithParams returns a copy of the context holding the match result,
+// which replaces the one of an outer match.
+func ContextWithParams(ctx context.Context, result *MatchResult) context.Context {
+	return context.WithValue(ctx, paramsKey{}, result)
+}
+
+// ParamsFromContext returns the match result held by the context.
+func ParamsFromContext(ctx context.Context) (*MatchResult, bool) {
+	result, ok := ctx.Value(paramsKey{}).(*MatchResult)
+	return result, ok && result != nil
+}
+
+// Params returns the match result held by the context of the request, or nil.
+func Params(req *http.Request) *MatchResult {
+	result, _ := ParamsFromContext(req.Context())
+	return result
+}
+
+// ParamsHandler is a middleware matching the escaped path of the requests,
+// which calls the next handler with the match result in the context of the
+// request.
+type ParamsHandler struct {
+	// Matcher matches the escaped path of the requests.
+	Matcher *Matcher
+
+	// Next handles the matched requests.
+	Next http.Handler
+
+	// NotFound handles the requests which don't match, they're passed to Next
+	// unchanged when nil.
+	NotFound http.Handler
+}
+
+// WithParams creates a ParamsHandler matching the template, unmatched requests
+// are passed to next unless NotFound is set, e.g. to http.NotFoundHandler(). It
+// panics if the template is invalid.
+func WithParams(template string, options *Options, next http.Handler) *ParamsHandler {
+	m, err := NewMatcher(template, options)
+	if err != nil {
+		panic(err)
+	}
+	return &ParamsHandler{Matcher: m, Next: next}
+}
+
+// ServeHTTP matches the request and calls the next handler.
+func (h *ParamsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	result, err := h.Matcher.Match(req.URL.EscapedPath())
+	switch {
+	case err == nil && result != nil:
+		h.Next.ServeHTTP(w, req.WithContext(ContextWithParams(req.Context(), result)))
+	case h.NotFound != nil:
+		h.NotFound.ServeHTTP(w, req)
+	default:
+		h.Next.ServeHTTP(w, req)
+	}
+}
+
+// MatchContext is like Match but gives up when the context is done, e.g. when
+// the deadline of a request expires, returning an error wrapping the error of
+// the context. The match runs in the calling goroutine and the context is
+// checked before and after it, as a regexp execution can't be interrupted:
+// set `Options.MatchTimeout` to bound it.
+func (m *Matcher) MatchContext(ctx context.Context, pathname string) (*MatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %q", err, pathname)
+	}
+	result, err := m.Match(pathname)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("%w: %q", ctxErr, pathname)
+	}
+	return result, err
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParamsFromContext(t *testing.T) {
+	t.Run("should return the match result of the context", func(t *testing.T) {
+		result := &MatchResult{Path: "/users/42", Params: m{"id": "42"}}
+		got, ok := ParamsFromContext(ContextWithParams(context.Background(), result))
+		if !ok || got != result {
+			t.Errorf(testErrorFormat, got, result)
+		}
+
+		if got, ok := ParamsFromContext(context.Background()); ok || got != nil {
+			t.Errorf(testErrorFormat, got, nil)
+		}
+		if got, ok := ParamsFromContext(ContextWithParams(context.Background(), nil)); ok || got != nil {
+			t.Errorf(testErrorFormat, got, nil)
+		}
+	})
+
+	t.Run("should not collide with other keys", func(t *testing.T) {
+		type otherKey struct{}
+		ctx := context.WithValue(context.Background(), otherKey{}, &MatchResult{Path: "/other"})
+		if got, ok := ParamsFromContext(ctx); ok || got != nil {
+			t.Errorf(testErrorFormat, got, nil)
+		}
+	})
+}
+
+func TestWithParams(t *testing.T) {
+	var results []*MatchResult
+	record := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		results = append(results, Params(req))
+	})
+	serve := func(h http.Handler, target string) *httptest.ResponseRecorder {
+		results = nil
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	t.Run("should put the match result in the context", func(t *testing.T) {
+		serve(WithParams("/users/:id", &Options{Decode: decodeURIComponent}, record), "/users/a%2Fb")
+		expect := []*MatchResult{{Path: "/users/a%2Fb", Params: m{"id": "a/b"}, DecodedPath: "/users/a/b",
+			Groups: []string{"/users/a%2Fb", "a%2Fb"}}}
+		if !reflect.DeepEqual(results, expect) {
+			t.Errorf(testErrorFormat, results, expect)
+		}
+	})
+
+	t.Run("should pass unmatched requests through", func(t *testing.T) {
+		w := serve(WithParams("/users/:id", nil, record), "/posts/1")
+		if w.Code != http.StatusOK || !reflect.DeepEqual(results, []*MatchResult{nil}) {
+			t.Errorf(testErrorFormat, results, []*MatchResult{nil})
+		}
+	})
+
+	t.Run("should reply not found when configured", func(t *testing.T) {
+		h := WithParams("/users/:id", nil, record)
+		h.NotFound = http.NotFoundHandler()
+		w := serve(h, "/posts/1")
+		if w.Code != http.StatusNotFound || results != nil {
+			t.Errorf(testErrorFormat, w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("should let an inner match override an outer one", func(t *testing.T) {
+		inner := WithParams("/users/:id/posts/:post", nil, record)
+		outer := WithParams("/users/:id", &Options{End: &falseValue}, http.HandlerFunc(
+			func(w http.ResponseWriter, req *http.Request) {
+				results = append(results, Params(req))
+				inner.ServeHTTP(w, req)
+			}))
+
+		serve(outer, "/users/1/posts/2")
+		expect := []*MatchResult{
+			{Path: "/users/1", Params: m{"id": "1"}, Groups: []string{"/users/1", "1"}},
+			{Path: "/users/1/posts/2", Params: m{"id": "1", "post": "2"}, Groups: []string{"/users/1/posts/2", "1", "2"}},
+		}
+		if !reflect.DeepEqual(results, expect) {
+			t.Errorf(testErrorFormat, results, expect)
+		}
+
+		// The outer match is kept when the inner one fails.
+		serve(outer, "/users/1/comments")
+		outerResult := &MatchResult{Path: "/users/1", Params: m{"id": "1"}, Groups: []string{"/users/1", "1"}}
+		expect = []*MatchResult{outerResult, outerResult}
+		if !reflect.DeepEqual(results, expect) {
+			t.Errorf(testErrorFormat, results, expect)
+		}
+	})
+
+	t.Run("should panic on an invalid template", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expect a panic")
+			}
+		}()
+		WithParams("/:foo(", nil, record)
+	})
+}
+
+func TestMatchContext(t *testing.T) {
+	t.Run("should match with a live context", func(t *testing.T) {
+		matcher := mustMatcher("/users/:id", nil)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, ctx := range []context.Context{context.Background(), ctx} {
+			result, err := matcher.MatchContext(ctx, "/users/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := &MatchResult{Path: "/users/1", Params: m{"id": "1"}, Groups: []string{"/users/1", "1"}}
+			if !reflect.DeepEqual(result, expect) {
+				t.Errorf(testErrorFormat, result, expect)
+			}
+			if result, err := matcher.MatchContext(ctx, "/posts/1"); result != nil || err != nil {
+				t.Errorf(testErrorFormat, err, nil)
+			}
+		}
+	})
+
+	t.Run("should not match with a cancelled context", func(t *testing.T) {
+		matcher := mustMatcher("/users/:id", nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := matcher.MatchContext(ctx, "/users/1")
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf(testErrorFormat, err, context.Canceled)
+		}
+		if result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should not return a match finished after the deadline", func(t *testing.T) {
+		// The timeout bounds the match, which outlives the context.
+		matcher := mustMatcher("/:foo((?:a|aa)+)", &Options{MatchTimeout: 200 * time.Millisecond})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		result, err := matcher.MatchContext(ctx, "/"+strings.Repeat("a", 40)+"!")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf(testErrorFormat, err, context.DeadlineExceeded)
+		}
+		if result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf(testErrorFormat, d, "less than 1s")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// DebugString describes the matcher on a single line, e.g. for a log: the
+// template, the tokens, the options which shape the regexp after defaulting,
+// and the regexp source. A path which isn't a string template is described
+// as `regexp`, `tokens`, `array` or `source`. The format is stable.
+func (m *Matcher) DebugString() string {
+	template, tokens, options, source := m.debugFields()
+	return "template=" + template + " tokens=[" + strings.Join(tokens, ", ") + "] options={" + options +
+		"} regexp=" + source
+}
+
+// DebugStringMultiline is like DebugString but writes each field, and each
+// token, on its own line.
+func (m *Matcher) DebugStringMultiline() string {
+	template, tokens, options, source := m.debugFields()
+	var b strings.Builder
+	b.WriteString("template: " + template + "\ntokens:\n")
+	for _, token := range tokens {
+		b.WriteString("  " + token + "\n")
+	}
+	b.WriteString("options: " + options + "\nregexp: " + source)
+	return b.String()
+}
+
+// Returns the fields of the debug strings.
+func (m *Matcher) debugFields() (string, []string, string, string) {
+	var template string
+	switch path := m.path.(type) {
+	case string:
+		template = strconv.Quote(path)
+	case *regexp2.Regexp, *regexp.Regexp:
+		template = "regexp"
+	case nil:
+		template = "source"
+	default:
+		if _, ok := pathTokens(path); ok {
+			template = "tokens"
+		} else {
+			template = "array"
+		}
+	}
+
+	tokens := make([]string, len(m.tokens))
+	for i, token := range m.tokens {
+		name := token.NameString()
+		if token.Matrix != nil {
+			name = "matrix"
+		} else if token.IsNamed() {
+			name = strconv.Quote(name)
+		}
+		tokens[i] = fmt.Sprintf("%s prefix=%q suffix=%q pattern=%q modifier=%q",
+			name, token.Prefix, token.Suffix, token.Pattern, token.Modifier)
+	}
+
+	o := m.options
+	if o == nil {
+		o = &Options{}
+	}
+	start, end := o.Start == nil || *o.Start, o.End == nil || *o.End
+	options := fmt.Sprintf("sensitive=%t strict=%t start=%t end=%t delimiter=%q endsWith=%q",
+		o.Sensitive, o.Strict, start, end, anyString(o.Delimiter, defaultDelimiter), o.EndsWith)
+	return template, tokens, options, strconv.Quote(m.source)
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestDebugString(t *testing.T) {
+	t.Run("should match the golden file", func(t *testing.T) {
+		matcher := mustMatcher("/users/:id/(\\d+)/:tab?/:rest*", nil)
+		dump := matcher.DebugString() + "\n\n" + matcher.DebugStringMultiline() + "\n"
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "debug.golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dump != string(golden) {
+			t.Errorf(testErrorFormat, dump, string(golden))
+		}
+	})
+
+	t.Run("should describe the paths and the options", func(t *testing.T) {
+		tests := []struct {
+			matcher *Matcher
+			expect  string
+		}{
+			{mustMatcher([]string{"/a"}, &Options{Strict: true, End: &falseValue}),
+				`template=array tokens=[] options={sensitive=false strict=true start=true end=false ` +
+					`delimiter="/#?" endsWith=""} regexp="(?:^\\/a(?=[\\/#\\?]|$))"`},
+			{mustMatcher(regexp.MustCompile(`^/(\d+)$`), &Options{Sensitive: true, Delimiter: "."}),
+				`template=regexp tokens=[0 prefix="" suffix="" pattern="" modifier=""] options={sensitive=true ` +
+					`strict=false start=true end=true delimiter="." endsWith=""} regexp="^/(\\d+)$"`},
+			{MustMatcherFromSource("^/a$", nil, &Options{Start: &falseValue, EndsWith: "?"}),
+				`template=source tokens=[] options={sensitive=false strict=false start=false end=true ` +
+					`delimiter="/#?" endsWith="?"} regexp="^/a$"`},
+		}
+		for _, test := range tests {
+			if dump := test.matcher.DebugString(); dump != test.expect {
+				t.Errorf(testErrorFormat, dump, test.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,290 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChangeKind is the kind of a Change between two versions of a template.
+type ChangeKind string
+
+const (
+	// ParamRenamed is a param whose name changed, e.g. `:id` to `:userId`.
+	ParamRenamed ChangeKind = "param-renamed"
+
+	// PatternTightened is a param whose default pattern was replaced by a
+	// custom one, e.g. `:id` to `:id(\d+)`.
+	PatternTightened ChangeKind = "pattern-tightened"
+
+	// PatternLoosened is a param whose custom pattern was replaced by the
+	// default one.
+	PatternLoosened ChangeKind = "pattern-loosened"
+
+	// PatternChanged is a param whose custom pattern was replaced by another
+	// custom one, which can't be compared.
+	PatternChanged ChangeKind = "pattern-changed"
+
+	// ModifierChanged is a param whose modifier changed, e.g. `:id` to `:id?`.
+	ModifierChanged ChangeKind = "modifier-changed"
+
+	// AffixChanged is a param whose prefix or suffix changed.
+	AffixChanged ChangeKind = "affix-changed"
+
+	// ParamAdded is a param of the new template only.
+	ParamAdded ChangeKind = "param-added"
+
+	// ParamRemoved is a param of the old template only.
+	ParamRemoved ChangeKind = "param-removed"
+
+	// SegmentAdded is a literal segment of the new template only.
+	SegmentAdded ChangeKind = "segment-added"
+
+	// SegmentRemoved is a literal segment of the old template only.
+	SegmentRemoved ChangeKind = "segment-removed"
+)
+
+// Change is a change between two versions of a template.
+type Change struct {
+	Kind ChangeKind
+
+	// The param of the old and of the new template, nil when there's none
+	Old, New *Token
+
+	// The literal segment of a SegmentAdded or SegmentRemoved change
+	Segment string
+}
+
+// Breaking reports whether the change can break the existing URLs, which is
+// the case of the changes making the new template reject a path matched by
+// the old one. A renamed param only breaks the code building the paths.
+func (c Change) Breaking() bool {
+	switch c.Kind {
+	case ParamRenamed, PatternLoosened:
+		return false
+	case ModifierChanged:
+		return isOptional(*c.Old) && !isOptional(*c.New) || isRepeat(*c.Old) && !isRepeat(*c.New)
+	case ParamAdded:
+		return !isOptional(*c.New)
+	case ParamRemoved:
+		return !isOptional(*c.Old)
+	}
+	return true
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Old == nil && c.New == nil:
+		return fmt.Sprintf("%s: %q", c.Kind, c.Segment)
+	case c.Old == nil:
+		return fmt.Sprintf("%s: \"%v\"", c.Kind, c.New.Name)
+	case c.New == nil || c.Old.Name == c.New.Name:
+		return fmt.Sprintf("%s: \"%v\"", c.Kind, c.Old.Name)
+	}
+	return fmt.Sprintf("%s: \"%v\" to \"%v\"", c.Kind, c.Old.Name, c.New.Name)
+}
+
+// TemplateDiff is the list of changes between two versions of a template,
+// see Diff.
+type TemplateDiff struct {
+	Changes []Change
+}
+
+// BreakingChanges returns the changes which can break the existing URLs.
+func (d *TemplateDiff) BreakingChanges() []Change {
+	var changes []Change
+	for _, c := range d.Changes {
+		if c.Breaking() {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Diff compares the parsed templates, aligning their literal segments, which
+// are the literal texts split before each delimiter. The params between two
+// aligned segments are paired by name first, and then in order, so that a
+// param paired with one of another name is renamed.
+func Diff(oldPath, newPath string, options *Options) (*TemplateDiff, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	oldTokens, err := Parse(oldPath, options)
+	if err != nil {
+		return nil, err
+	}
+	newTokens, err := Parse(newPath, options)
+	if err != nil {
+		return nil, err
+	}
+	x, y := diffElements(oldTokens, options), diffElements(newTokens, options)
+
+	// The longest common subsequence of the literal segments.
+	lengths := make([][]int, len(x)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if isSegment(x[i]) && x[i] == y[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	d := &TemplateDiff{}
+	var oldGap, newGap []interface{}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && isSegment(x[i]) && x[i] == y[j]:
+			d.diffGap(oldGap, newGap, options)
+			oldGap, newGap = nil, nil
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lengths[i+1][j] >= lengths[i][j+1]):
+			oldGap = append(oldGap, x[i])
+			i++
+		default:
+			newGap = append(newGap, y[j])
+			j++
+		}
+	}
+	d.diffGap(oldGap, newGap, options)
+	return d, nil
+}
+
+// Compares the elements between two aligned segments.
+func (d *TemplateDiff) diffGap(x, y []interface{}, options *Options) {
+	var oldParams, newParams []Token
+	for _, e := range x {
+		if token, ok := e.(Token); ok {
+			oldParams = append(oldParams, token)
+		} else {
+			d.Changes = append(d.Changes, Change{Kind: SegmentRemoved, Segment: e.(string)})
+		}
+	}
+	for _, e := range y {
+		if token, ok := e.(Token); ok {
+			newParams = append(newParams, token)
+		} else {
+			d.Changes = append(d.Changes, Change{Kind: SegmentAdded, Segment: e.(string)})
+		}
+	}
+
+	paired := make([]int, len(oldParams))
+	used := make([]bool, len(newParams))
+	for i, old := range oldParams {
+		paired[i] = -1
+		for j, token := range newParams {
+			if !used[j] && token.Name == old.Name {
+				paired[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	for i := range oldParams {
+		for j := range newParams {
+			if paired[i] < 0 && !used[j] {
+				paired[i], used[j] = j, true
+			}
+		}
+	}
+
+	for i := range oldParams {
+		old := &oldParams[i]
+		if paired[i] < 0 {
+			d.Changes = append(d.Changes, Change{Kind: ParamRemoved, Old: old})
+			continue
+		}
+		token := &newParams[paired[i]]
+		if old.Name != token.Name {
+			d.Changes = append(d.Changes, Change{Kind: ParamRenamed, Old: old, New: token})
+		}
+		if kind := patternChange(old.Pattern, token.Pattern, options); kind != "" {
+			d.Changes = append(d.Changes, Change{Kind: kind, Old: old, New: token})
+		}
+		if old.Modifier != token.Modifier {
+			d.Changes = append(d.Changes, Change{Kind: ModifierChanged, Old: old, New: token})
+		}
+		if old.Prefix != token.Prefix || old.Suffix != token.Suffix {
+			d.Changes = append(d.Changes, Change{Kind: AffixChanged, Old: old, New: token})
+		}
+	}
+	for j := range newParams {
+		if !used[j] {
+			d.Changes = append(d.Changes, Change{Kind: ParamAdded, New: &newParams[j]})
+		}
+	}
+}
+
+// Returns the kind of the change of the pattern, or an empty string when it
+// didn't change.
+func patternChange(old, pattern string, options *Options) ChangeKind {
+	if old == pattern {
+		return ""
+	}
+	switch defaultPattern := paramPattern(options); {
+	case old == defaultPattern:
+		return PatternTightened
+	case pattern == defaultPattern:
+		return PatternLoosened
+	}
+	return PatternChanged
+}
+
+// Returns the tokens with their literals split before each delimiter.
+func diffElements(tokens []interface{}, options *Options) []interface{} {
+	sequence := delimiterSequence(options)
+	delimiter := anyString(options.Delimiter, defaultDelimiter)
+	var elements []interface{}
+	for _, token := range tokens {
+		str, ok := token.(string)
+		if !ok {
+			elements = append(elements, token)
+			continue
+		}
+		for str != "" {
+			// The next delimiter after the first character or sequence.
+			start := len(sequence)
+			if sequence == "" || !strings.HasPrefix(str, sequence) {
+				_, start = utf8.DecodeRuneInString(str)
+			}
+			end := len(str)
+			if sequence != "" {
+				if i := strings.Index(str[start:], sequence); i >= 0 {
+					end = start + i
+				}
+			} else if i := strings.IndexAny(str[start:], delimiter); i >= 0 {
+				end = start + i
+			}
+			elements = append(elements, str[:end])
+			str = str[end:]
+		}
+	}
+	return elements
+}
+
+// Reports whether the element is a literal segment.
+func isSegment(e interface{}) bool {
+	_, ok := e.(string)
+	return ok
+}
+
+// Reports whether the token may be omitted.
+func isOptional(token Token) bool {
+	return token.Modifier == "?" || token.Modifier == "*"
+}
+
+// Reports whether the token may be repeated.
+func isRepeat(token Token) bool {
+	return token.Modifier == "+" || token.Modifier == "*"
+}
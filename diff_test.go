@@ -0,0 +1,94 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	t.Run("should report the changes", func(t *testing.T) {
+		tests := []struct {
+			oldPath, newPath string
+			options          *Options
+			expect           []string
+			breaking         []string
+		}{
+			{"/v1/users/:id", "/v1/users/:id", nil, nil, nil},
+			{"/v1/users/:id", "/v1/users/:userId", nil,
+				[]string{`param-renamed: "id" to "userId"`}, nil},
+			{"/v1/users/:id", "/v1/users/:userId(\\d+)", nil,
+				[]string{`param-renamed: "id" to "userId"`, `pattern-tightened: "id" to "userId"`},
+				[]string{`pattern-tightened: "id" to "userId"`}},
+			{"/users/:id(\\d+)", "/users/:id", nil, []string{`pattern-loosened: "id"`}, nil},
+			{"/users/:id(\\d+)", "/users/:id(\\w+)", nil,
+				[]string{`pattern-changed: "id"`}, []string{`pattern-changed: "id"`}},
+			{"/users/:id", "/users/:id?", nil, []string{`modifier-changed: "id"`}, nil},
+			{"/users/:id?", "/users/:id", nil,
+				[]string{`modifier-changed: "id"`}, []string{`modifier-changed: "id"`}},
+			{"/files/:path+", "/files/:path*", nil, []string{`modifier-changed: "path"`}, nil},
+			{"/files/:path*", "/files/:path?", nil,
+				[]string{`modifier-changed: "path"`}, []string{`modifier-changed: "path"`}},
+			{"/users/:id", "/users-:id", nil,
+				[]string{`segment-removed: "/users"`, `segment-added: "/users-"`, `affix-changed: "id"`},
+				[]string{`segment-removed: "/users"`, `segment-added: "/users-"`, `affix-changed: "id"`}},
+			{"/users/:id", "/users/:id/:tab?", nil, []string{`param-added: "tab"`}, nil},
+			{"/users/:id", "/users/:id/:tab", nil,
+				[]string{`param-added: "tab"`}, []string{`param-added: "tab"`}},
+			{"/:lang?/users/:id", "/users/:id", nil, []string{`param-removed: "lang"`}, nil},
+			{"/:lang/users/:id", "/users/:id", nil,
+				[]string{`param-removed: "lang"`}, []string{`param-removed: "lang"`}},
+			{"/users/:id", "/v2/users/:id", nil,
+				[]string{`segment-added: "/v2"`}, []string{`segment-added: "/v2"`}},
+			{"/api/users/:id/show", "/users/:id", nil,
+				[]string{`segment-removed: "/api"`, `segment-removed: "/show"`},
+				[]string{`segment-removed: "/api"`, `segment-removed: "/show"`}},
+			{"/users/:id/:tab", "/users/:tab/:id", nil, nil, nil},
+			{"/users/(\\d+)", "/users/:id(\\d+)", nil, []string{`param-renamed: "0" to "id"`}, nil},
+			{"acme.:env", "acme.:stage", &Options{Delimiter: "."}, []string{`param-renamed: "env" to "stage"`}, nil},
+		}
+		for _, test := range tests {
+			d, err := Diff(test.oldPath, test.newPath, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changes := changeStrings(d.Changes); !reflect.DeepEqual(changes, test.expect) {
+				t.Errorf(testErrorFormat, changes, test.expect)
+			}
+			if breaking := changeStrings(d.BreakingChanges()); !reflect.DeepEqual(breaking, test.breaking) {
+				t.Errorf(testErrorFormat, breaking, test.breaking)
+			}
+		}
+	})
+
+	t.Run("should keep the tokens of the changes", func(t *testing.T) {
+		d, err := Diff("/users/:id", "/users/:id(\\d+)", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := d.Changes[0]
+		if c.Kind != PatternTightened || c.Old.Pattern != "[^\\/#\\?]+?" || c.New.Pattern != "\\d+" {
+			t.Errorf(testErrorFormat, c, PatternTightened)
+		}
+	})
+
+	t.Run("should return the parse errors", func(t *testing.T) {
+		if _, err := Diff("/users/:id(", "/users/:id", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if _, err := Diff("/users/:id", "/users/:", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
+
+func changeStrings(changes []Change) []string {
+	var strs []string
+	for _, c := range changes {
+		strs = append(strs, c.String())
+	}
+	return strs
+}
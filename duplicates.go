@@ -0,0 +1,75 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import "strings"
+
+// DuplicateDelimiters selects how the match function treats a delimiter
+// repeated in a pathname, such as `//`, see `Options.DuplicateDelimiters`.
+type DuplicateDelimiters int
+
+const (
+	// DuplicateDelimitersAllow leaves the repeated delimiters to the regexp:
+	// `/test//` matches `/test/`, which isn't strict, while `/:test?` doesn't
+	// match `//`.
+	DuplicateDelimitersAllow DuplicateDelimiters = iota
+
+	// DuplicateDelimitersReject doesn't match a pathname with a repeated
+	// delimiter anywhere, even after the part matched with `End: false`.
+	DuplicateDelimitersReject
+
+	// DuplicateDelimitersCollapse matches the pathname with each repeated
+	// delimiter collapsed into one, the Path and the Index of the result
+	// referring to the collapsed pathname.
+	DuplicateDelimitersCollapse
+)
+
+// Applies `Options.DuplicateDelimiters` to the match function of the matcher.
+// The pathname is scanned before running the regexp, which is unchanged, so
+// that its source is the same whatever the option.
+func duplicateDelimiters(m *Matcher) *Matcher {
+	if m.options == nil || m.options.DuplicateDelimiters == DuplicateDelimitersAllow {
+		return m
+	}
+	delimiter := anyString(m.options.Delimiter, defaultDelimiter)
+	collapse := m.options.DuplicateDelimiters == DuplicateDelimitersCollapse
+	match := m.match
+	m.match = func(pathname string) (*MatchResult, error) {
+		i := duplicateDelimiter(pathname, delimiter)
+		if i < 0 {
+			return match(pathname)
+		}
+		if !collapse {
+			return nil, nil
+		}
+		return match(collapseDelimiters(pathname, delimiter, i))
+	}
+	return m
+}
+
+// Returns the byte offset of the first delimiter followed by the same
+// delimiter, or -1 if there is none.
+func duplicateDelimiter(pathname, delimiter string) int {
+	for i := 1; i < len(pathname); i++ {
+		if pathname[i] == pathname[i-1] && strings.IndexByte(delimiter, pathname[i]) >= 0 {
+			return i - 1
+		}
+	}
+	return -1
+}
+
+// Returns the pathname with the runs of a delimiter collapsed into one, from
+// the first one at the byte offset i.
+func collapseDelimiters(pathname, delimiter string, i int) string {
+	var b strings.Builder
+	b.Grow(len(pathname))
+	b.WriteString(pathname[:i+1])
+	for j := i + 1; j < len(pathname); j++ {
+		if c := pathname[j]; c != pathname[j-1] || strings.IndexByte(delimiter, c) < 0 {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateDelimiters(t *testing.T) {
+	// The fixtures matching a pathname with a repeated delimiter, with the
+	// default delimiter.
+	type fixture struct {
+		path      string
+		options   Options
+		pathname  string
+		expect    interface{}
+		collapsed string
+	}
+	var fixtures []fixture
+	for _, test := range tests {
+		path, ok := test[0].(string)
+		if !ok {
+			continue
+		}
+		var options Options
+		if o, ok := test[1].(*Options); ok && o != nil {
+			options = *o
+		}
+		if options.Delimiter != "" || options.Compat != CompatNone {
+			continue
+		}
+		for _, matchCase := range test[3].(a) {
+			matchCase := matchCase.(a)
+			pathname := matchCase[0].(string)
+			if !strings.Contains(pathname, "//") {
+				continue
+			}
+			collapsed := pathname
+			for strings.Contains(collapsed, "//") {
+				collapsed = strings.Replace(collapsed, "//", "/", -1)
+			}
+			fixtures = append(fixtures, fixture{path, options, pathname, matchCase[1], collapsed})
+		}
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures")
+	}
+
+	// Returns the full match of the pathname, or nil.
+	match := func(f fixture, mode DuplicateDelimiters, pathname string) interface{} {
+		options := f.options
+		options.DuplicateDelimiters = mode
+		result, err := MustMatch(f.path, &options)(pathname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil {
+			return nil
+		}
+		return result.Path
+	}
+
+	t.Run("should pin the behavior of the fixtures as Allow", func(t *testing.T) {
+		for _, f := range fixtures {
+			var expect interface{}
+			if groups, ok := f.expect.(a); ok {
+				expect = groups[0]
+			}
+			if result := match(f, DuplicateDelimitersAllow, f.pathname); !reflect.DeepEqual(result, expect) {
+				t.Errorf("%s, %s: "+testErrorFormat, f.path, f.pathname, result, expect)
+			}
+		}
+	})
+
+	t.Run("should reject the pathnames with Reject", func(t *testing.T) {
+		for _, f := range fixtures {
+			if result := match(f, DuplicateDelimitersReject, f.pathname); result != nil {
+				t.Errorf("%s, %s: "+testErrorFormat, f.path, f.pathname, result, nil)
+			}
+			expect := match(f, DuplicateDelimitersAllow, f.collapsed)
+			if result := match(f, DuplicateDelimitersReject, f.collapsed); !reflect.DeepEqual(result, expect) {
+				t.Errorf("%s, %s: "+testErrorFormat, f.path, f.collapsed, result, expect)
+			}
+		}
+	})
+
+	t.Run("should match the collapsed pathnames with Collapse", func(t *testing.T) {
+		for _, f := range fixtures {
+			expect := match(f, DuplicateDelimitersAllow, f.collapsed)
+			if result := match(f, DuplicateDelimitersCollapse, f.pathname); !reflect.DeepEqual(result, expect) {
+				t.Errorf("%s, %s: "+testErrorFormat, f.path, f.pathname, result, expect)
+			}
+		}
+	})
+
+	t.Run("should collapse each delimiter", func(t *testing.T) {
+		options := &Options{DuplicateDelimiters: DuplicateDelimitersCollapse, End: &falseValue}
+		result, err := MustMatch("/a/:b", options)("//a///b##c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/a/b", Params: map[interface{}]interface{}{"b": "b"}, Groups: []string{"/a/b", "b"}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should reject an unknown mode", func(t *testing.T) {
+		_, err := NewMatcher("/a", &Options{DuplicateDelimiters: 3})
+		if expect := "unknown DuplicateDelimiters 3"; err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+}
@@ -0,0 +1,298 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Engine compiles the regexps used to match paths and validate values, it's
+// set with `Options.Engine`. The patterns given to it are regexp2 syntax.
+type Engine interface {
+	// Compile compiles the pattern, ignoring case when `ignoreCase` is true.
+	Compile(pattern string, ignoreCase bool) (Pattern, error)
+}
+
+// Pattern is a regexp compiled by an Engine, it must be safe for concurrent
+// use.
+type Pattern interface {
+	// Find returns the first match in the input, or nil if there is none. An
+	// error is only returned when the match can't complete, such as on a
+	// timeout.
+	Find(input string) (*EngineMatch, error)
+}
+
+// EngineMatch is a match found by a Pattern.
+type EngineMatch struct {
+	// start index of the match in characters
+	Index int
+
+	// the matched text followed by the text of each group
+	Groups []string
+
+	// start index of the matched text followed by the index of each group in
+	// characters, -1 for the groups which didn't take part in the match
+	Indexes []int
+}
+
+// Regexp2Engine is the Engine backed by regexp2, which is used when no engine
+// is set.
+type Regexp2Engine struct {
+	// The maximum duration of a single match, zero means no timeout.
+	MatchTimeout time.Duration
+}
+
+// Compile compiles the pattern with regexp2.
+func (e Regexp2Engine) Compile(pattern string, ignoreCase bool) (Pattern, error) {
+	opt := regexp2.None
+	if ignoreCase {
+		opt = regexp2.IgnoreCase
+	}
+	re, err := regexp2.Compile(pattern, opt)
+	if err != nil {
+		return nil, err
+	}
+	if e.MatchTimeout > 0 {
+		re.MatchTimeout = e.MatchTimeout
+	}
+	return regexp2Pattern{re: re}, nil
+}
+
+// StdEngine is the Engine backed by the standard library regexp. It fails to
+// compile lookarounds and backreferences, and `\d`, `\w` and `\s` only match
+// ASCII characters.
+type StdEngine struct{}
+
+// Compile compiles the pattern with the standard library regexp.
+func (StdEngine) Compile(pattern string, ignoreCase bool) (Pattern, error) {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return stdPattern{re: re}, nil
+}
+
+// regexp2Pattern runs the regexp2 engine, which supports all the syntax.
+type regexp2Pattern struct {
+	re *regexp2.Regexp
+}
+
+func (p regexp2Pattern) Find(input string) (*EngineMatch, error) {
+	m, err := p.re.FindStringMatch(input)
+	if err != nil {
+		return nil, matchTimeoutError(err)
+	}
+	if m == nil {
+		return nil, nil
+	}
+
+	// Character indexes are byte offsets for ASCII input, so the groups can
+	// be substrings of the input rather than strings of their runes.
+	ascii := isASCII(input)
+	groups := m.Groups()
+	result := &EngineMatch{Index: m.Index, Groups: make([]string, len(groups)), Indexes: make([]int, len(groups))}
+	for i, group := range groups {
+		result.Indexes[i] = -1
+		if len(group.Captures) > 0 {
+			result.Indexes[i] = group.Index
+		}
+		if ascii {
+			result.Groups[i] = input[group.Index : group.Index+group.Length]
+		} else {
+			result.Groups[i] = group.String()
+		}
+	}
+	return result, nil
+}
+
+// stdPattern runs the standard library engine.
+type stdPattern struct {
+	re *regexp.Regexp
+}
+
+func (p stdPattern) Find(input string) (*EngineMatch, error) {
+	indexes := p.re.FindStringSubmatchIndex(input)
+	if indexes == nil {
+		return nil, nil
+	}
+
+	// Byte offsets are character indexes for ASCII input.
+	ascii := isASCII(input)
+	n := len(indexes) / 2
+	result := &EngineMatch{Groups: make([]string, n), Indexes: make([]int, n)}
+	for i := 0; i < n; i++ {
+		start, end := indexes[2*i], indexes[2*i+1]
+		result.Indexes[i] = start
+		if start >= 0 {
+			result.Groups[i] = input[start:end]
+			if !ascii {
+				result.Indexes[i] = utf8.RuneCountInString(input[:start])
+			}
+		}
+	}
+	result.Index = result.Indexes[0]
+	return result, nil
+}
+
+// asciiPattern runs the standard library engine, which is faster, on the
+// inputs it's known to match like regexp2. The others are given to the
+// fallback.
+type asciiPattern struct {
+	std      stdPattern
+	fallback Pattern
+}
+
+func (p asciiPattern) Find(input string) (*EngineMatch, error) {
+	// `$` also matches before a trailing newline in regexp2, and the case
+	// folding rules differ beyond ASCII.
+	if strings.HasSuffix(input, "\n") || !isASCII(input) {
+		return p.fallback.Find(input)
+	}
+	return p.std.Find(input)
+}
+
+// The Unicode equivalents of the class escapes, out of and within a character
+// class, see `Options.UnicodeClasses`.
+var unicodeClassEscapes = map[rune][2]string{
+	'w': {`[\p{L}\p{N}_]`, `\p{L}\p{N}_`},
+	'W': {`[^\p{L}\p{N}_]`, ""},
+	'd': {`\p{Nd}`, `\p{Nd}`},
+	'D': {`\P{Nd}`, `\P{Nd}`},
+	's': {`[\s\p{Z}\x85]`, `\s\p{Z}\x85`},
+	'S': {`[^\s\p{Z}\x85]`, ""},
+}
+
+// Returns the pattern with the class escapes rewritten to their Unicode
+// equivalents, which both regexp2 and the standard library regexp support.
+func unicodeClasses(pattern string) string {
+	var b strings.Builder
+	arr := []rune(pattern)
+	inClass, classStart := false, 0
+	for i := 0; i < len(arr); i++ {
+		c := arr[i]
+		switch {
+		case c == '\\' && i+1 < len(arr):
+			i++
+			escapes := unicodeClassEscapes[arr[i]]
+			if escape := escapes[0]; !inClass && escape != "" {
+				b.WriteString(escape)
+				continue
+			} else if escape := escapes[1]; inClass && escape != "" {
+				b.WriteString(escape)
+				continue
+			}
+			b.WriteRune(c)
+			c = arr[i]
+		case c == '[' && !inClass:
+			inClass, classStart = true, i+1
+			if classStart < len(arr) && arr[classStart] == '^' {
+				classStart++
+			}
+		case c == ']' && inClass && i > classStart:
+			inClass = false
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Returns the pattern of the source, compiled with `Options.Engine` when it's
+// set.
+func compilePattern(source string, options *Options) (Pattern, error) {
+	if options != nil && options.Engine != nil {
+		return options.Engine.Compile(source, ignoreCase(options))
+	}
+	re, err := compile(source, options)
+	if err != nil {
+		return nil, err
+	}
+	return newPattern(re, options), nil
+}
+
+// Returns the pattern to match with the regexp built from the options, the
+// standard library engine is used when the regexp allows it.
+func newPattern(re *regexp2.Regexp, options *Options) Pattern {
+	fallback := regexp2Pattern{re: re}
+	if re.MatchTimeout != regexp2.DefaultMatchTimeout {
+		return fallback
+	}
+	// The flags such as Singleline change the semantics of the regexp.
+	if options != nil && options.RegexFlags&^regexp2.IgnoreCase != 0 {
+		return fallback
+	}
+
+	source := re.String()
+	fold := ignoreCase(options)
+	// Non ASCII characters fold differently.
+	if fold && !isASCII(source) {
+		return fallback
+	}
+	if !stdCompatible(source) {
+		return fallback
+	}
+
+	std, err := StdEngine{}.Compile(source, fold)
+	if err != nil {
+		return fallback
+	}
+	return asciiPattern{std: std.(stdPattern), fallback: fallback}
+}
+
+// Reports whether the source means the same in both engines when it compiles
+// with the standard library. Lookarounds and backreferences fail to compile,
+// while the constructs checked here compile with a different meaning.
+func stdCompatible(source string) bool {
+	for i := 0; i < len(source); i++ {
+		switch source[i] {
+		case '\\':
+			if i+1 < len(source) {
+				i++
+				switch c := source[i]; {
+				case c == 's' || c == 'S':
+					// `\s` matches `\v` in regexp2 only.
+					return false
+				case c >= '0' && c <= '9':
+					// Backreference or octal.
+					return false
+				}
+			}
+		case '[':
+			// POSIX classes such as `[[:alpha:]]`.
+			if strings.HasPrefix(source[i+1:], ":") {
+				return false
+			}
+		case '-':
+			// Class subtraction such as `[a-z-[aeiou]]`.
+			if strings.HasPrefix(source[i+1:], "[") {
+				return false
+			}
+		case '(':
+			// Named groups are numbered after the unnamed ones in regexp2.
+			if strings.HasPrefix(source[i+1:], "?<") || strings.HasPrefix(source[i+1:], "?P<") ||
+				strings.HasPrefix(source[i+1:], "?'") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Reports whether the string only holds ASCII characters.
+func isASCII(str string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,334 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+func TestPattern(t *testing.T) {
+	t.Run("should match like regexp2 for the fixtures", func(t *testing.T) {
+		count := 0
+		for _, test := range tests {
+			if _, ok := test[0].(*regexp2.Regexp); ok {
+				continue
+			}
+			var o *Options
+			if test[1] != nil {
+				o = test[1].(*Options)
+			}
+			re, err := PathToRegexp(test[0], nil, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := newPattern(re, o)
+			if _, ok := p.(asciiPattern); !ok {
+				continue
+			}
+			count++
+
+			for _, v := range test[3].(a) {
+				pathname := v.(a)[0].(string)
+				expect, err := regexp2Pattern{re: re}.Find(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := p.Find(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(result, expect) {
+					t.Errorf("%s %s: "+testErrorFormat, inspect(test[0]), inspect(pathname), result, expect)
+				}
+			}
+		}
+		if count == 0 {
+			t.Error("expect fixtures using the standard library")
+		}
+	})
+
+	t.Run("should fall back to regexp2", func(t *testing.T) {
+		tests := []struct {
+			path    interface{}
+			options *Options
+		}{
+			{"/:foo(\\s+)", nil},
+			{[]interface{}{regexp2.MustCompile("^/(a)\\1$", regexp2.None)}, nil},
+			{[]interface{}{regexp2.MustCompile("^/(\\d)\\s$", regexp2.None)}, nil},
+			{"/:foo((?!login)[^/]+)", nil},
+			{"/:foo([[:alpha:]]+)", nil},
+			{"/:foo([a-z-[aeiou]]+)", nil},
+			{"/café", nil},
+			{"/test", &Options{End: &falseValue}},
+			{"/test", &Options{MatchTimeout: 1}},
+		}
+		for _, test := range tests {
+			re, err := PathToRegexp(test.path, nil, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := newPattern(re, test.options).(regexp2Pattern); !ok {
+				t.Errorf(testErrorFormat, test.path, "regexp2")
+			}
+		}
+
+		re := Must(PathToRegexp("/café", nil, &Options{Sensitive: true}))
+		if _, ok := newPattern(re, &Options{Sensitive: true}).(asciiPattern); !ok {
+			t.Errorf(testErrorFormat, re, "standard library")
+		}
+	})
+
+	t.Run("should give regexp2 the strings it handles differently", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			pathname string
+		}{
+			{"/test", "/test\n"},
+			{"/:foo", "/café"},
+			{"/:foo(s)", "/ſ"},
+			{"/:foo(k)", "/K"},
+		}
+		for _, test := range tests {
+			re := Must(PathToRegexp(test.path, nil, nil))
+			p := newPattern(re, nil)
+			if _, ok := p.(asciiPattern); !ok {
+				t.Fatalf(testErrorFormat, test.path, "standard library")
+			}
+			expect, _ := regexp2Pattern{re: re}.Find(test.pathname)
+			result, err := p.Find(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, expect) {
+				t.Errorf(testErrorFormat, result, expect)
+			}
+		}
+	})
+
+	t.Run("should always use regexp2 for given regexps", func(t *testing.T) {
+		matcher, err := NewMatcher(regexp2.MustCompile("^/(\\d+)$", regexp2.IgnoreCase), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := matcher.Match("/123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/123", Index: 0, Params: m{0: "123"}}
+		if !expect.equals(result) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+}
+
+func TestEngine(t *testing.T) {
+	for _, engine := range []Engine{Regexp2Engine{}, StdEngine{}} {
+		t.Run(inspect(engine), func(t *testing.T) {
+			count := 0
+			for _, test := range tests {
+				path := test[0]
+				o := &Options{}
+				if test[1] != nil {
+					*o = *test[1].(*Options)
+				}
+				o.Engine = engine
+
+				matcher, err := NewMatcher(path, o)
+				if _, ok := engine.(StdEngine); ok && err != nil {
+					// Lookarounds are not supported.
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				count++
+
+				for _, v := range test[3].(a) {
+					io := v.(a)
+					pathname := io[0].(string)
+					result, err := matcher.Match(pathname)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var expect a
+					if io[1] != nil {
+						expect = io[1].(a)
+					}
+					if (result == nil) != (expect == nil) || (result != nil && result.Path != expect[0]) {
+						t.Errorf("%s %s: "+testErrorFormat, inspect(path), inspect(pathname), result, expect)
+					}
+
+					if len(io) >= 3 && io[2] != nil {
+						params := io[2].(*MatchResult)
+						options := &Options{}
+						if len(io) >= 4 && io[3] != nil {
+							*options = *io[3].(*Options)
+						}
+						options.Engine = engine
+						result, err := MustMatch(path, options)(pathname)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if !params.equals(result) {
+							t.Errorf("%s %s: "+testErrorFormat, inspect(path), inspect(pathname), result, params)
+						}
+					}
+				}
+			}
+			if count == 0 {
+				t.Error("expect fixtures")
+			}
+		})
+	}
+}
+
+// recordingEngine records the patterns it compiles.
+type recordingEngine struct {
+	mu       sync.Mutex
+	patterns []string
+}
+
+func (e *recordingEngine) Compile(pattern string, ignoreCase bool) (Pattern, error) {
+	e.mu.Lock()
+	e.patterns = append(e.patterns, pattern)
+	e.mu.Unlock()
+	return StdEngine{}.Compile(pattern, ignoreCase)
+}
+
+func TestEngineRouting(t *testing.T) {
+	t.Run("should compile matchers with the engine", func(t *testing.T) {
+		engine := &recordingEngine{}
+		matcher, err := NewMatcher([]interface{}{"/user/:id", "/about"}, &Options{Engine: engine})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{matcher.RouteString()}
+		if !reflect.DeepEqual(engine.patterns, expect) {
+			t.Errorf(testErrorFormat, engine.patterns, expect)
+		}
+		if matcher.Regexp() != nil {
+			t.Errorf(testErrorFormat, matcher.Regexp(), nil)
+		}
+		re := Must(PathToRegexp([]string{"/user/:id", "/about"}, nil, nil))
+		if matcher.RouteString() != re.String() {
+			t.Errorf(testErrorFormat, matcher.RouteString(), re.String())
+		}
+
+		result, err := matcher.Match("/USER/123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := &MatchResult{Path: "/USER/123", Index: 0, Params: m{"id": "123"}}
+		if !params.equals(result) {
+			t.Errorf(testErrorFormat, result, params)
+		}
+	})
+
+	t.Run("should compile validators with the engine", func(t *testing.T) {
+		engine := &recordingEngine{}
+		toPath, err := Compile("/:id(\\d+)", &Options{Engine: engine})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := toPath(m{"id": "abc"}); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+		path, err := toPath(m{"id": 123})
+		if err != nil || path != "/123" {
+			t.Errorf(testErrorFormat, path, "/123")
+		}
+		expect := []string{"^(?:\\d+)$"}
+		if !reflect.DeepEqual(engine.patterns, expect) {
+			t.Errorf(testErrorFormat, engine.patterns, expect)
+		}
+	})
+
+	t.Run("should return engine errors", func(t *testing.T) {
+		_, err := NewMatcher("/:foo((?!login)[^/]+)", &Options{Engine: StdEngine{}})
+		if err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+	})
+
+	t.Run("should time out with the regexp2 engine", func(t *testing.T) {
+		options := &Options{Engine: Regexp2Engine{MatchTimeout: 50 * time.Millisecond}}
+		match, err := Match("/:foo((?:a|aa)+)", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = match("/" + strings.Repeat("a", 40) + "!")
+		if !errors.Is(err, ErrMatchTimeout) {
+			t.Errorf(testErrorFormat, err, ErrMatchTimeout)
+		}
+	})
+}
+
+func BenchmarkPattern(b *testing.B) {
+	re := Must(PathToRegexp("/user/:id", nil, nil))
+	b.Run("regexp2", func(b *testing.B) {
+		match := regexpToFunction(regexp2Pattern{re: re}, []GroupBinding{{Token: Token{Name: "id", Prefix: "/"}, Group: 1}}, nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			match("/user/123")
+		}
+	})
+	b.Run("standard library", func(b *testing.B) {
+		match := regexpToFunction(newPattern(re, nil), []GroupBinding{{Token: Token{Name: "id", Prefix: "/"}, Group: 1}}, nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			match("/user/123")
+		}
+	})
+}
+
+func TestUnicodeClasses(t *testing.T) {
+	t.Run("should rewrite the class escapes", func(t *testing.T) {
+		tests := []struct {
+			pattern string
+			expect  string
+		}{
+			{`\w+`, `[\p{L}\p{N}_]+`},
+			{`\d+-\D`, `\p{Nd}+-\P{Nd}`},
+			{`\s\S\W`, `[\s\p{Z}\x85][^\s\p{Z}\x85][^\p{L}\p{N}_]`},
+			{`[\w.-]+`, `[\p{L}\p{N}_.-]+`},
+			{`[^\d\W]`, `[^\p{Nd}\W]`},
+			{`[]\w]`, `[]\p{L}\p{N}_]`},
+			{`\\w\.\b`, `\\w\.\b`},
+		}
+		for _, test := range tests {
+			if pattern := unicodeClasses(test.pattern); pattern != test.expect {
+				t.Errorf(testErrorFormat, pattern, test.expect)
+			}
+		}
+	})
+
+	t.Run("should match the unicode letters with any engine", func(t *testing.T) {
+		for _, engine := range []Engine{nil, StdEngine{}} {
+			for _, unicode := range []bool{false, true} {
+				options := &Options{Engine: engine, UnicodeClasses: unicode}
+				result, err := MustMatch("/:name(\\w+)/:n(\\d+)", options)("/café/٤٢")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expect := unicode || engine == nil; (result != nil) != expect {
+					t.Errorf(testErrorFormat, result, expect)
+				}
+
+				_, err = MustCompile("/:name(\\w+)", options)(m{"name": "café"})
+				if expect := unicode || engine == nil; (err == nil) != expect {
+					t.Errorf(testErrorFormat, err, expect)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// TemplatesEquivalent reports whether two templates match the same paths with
+// the same params, however they're written, e.g. `/\-:id` and
+// `/-:id([^\/#\?]+?)`. The names of the params are compared unless
+// `Options.IgnoreParamNames` is set.
+func TemplatesEquivalent(a, b string, options *Options) (bool, error) {
+	reason, err := ExplainDifference(a, b, options)
+	return err == nil && reason == "", err
+}
+
+// ExplainDifference returns the first difference between the tokens of two
+// templates, or an empty string when they're equivalent. The tokens are
+// compared in their normalized form, as Normalize writes them, and the
+// regexp sources when the tokens differ or can't be normalized.
+func ExplainDifference(a, b string, options *Options) (string, error) {
+	ta, err := equivalenceTokens(a, options)
+	if err != nil {
+		return "", err
+	}
+	tb, err := equivalenceTokens(b, options)
+	if err != nil {
+		return "", err
+	}
+
+	reason := ""
+	if ta != nil && tb != nil {
+		if reason = tokensDifference(ta, tb, options); reason == "" {
+			return "", nil
+		}
+	}
+	var tokensA, tokensB []Token
+	sa, err := pathToSource(a, &tokensA, options)
+	if err != nil {
+		return "", err
+	}
+	sb, err := pathToSource(b, &tokensB, options)
+	if err != nil {
+		return "", err
+	}
+	if sa == sb {
+		if options != nil && options.IgnoreParamNames {
+			return "", nil
+		}
+		for i := 0; i < len(tokensA) && i < len(tokensB); i++ {
+			if tokensA[i].Name != tokensB[i].Name {
+				return fmt.Sprintf("param %d: \"%v\" differs from \"%v\"", i, tokensA[i].Name, tokensB[i].Name), nil
+			}
+		}
+		return "", nil
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("regexp %s differs from %s", strconv.Quote(sa), strconv.Quote(sb))
+	}
+	return reason, nil
+}
+
+// Returns the flattened tokens of the template, or nil when the options
+// can't be normalized. The names of the params are dropped with
+// `Options.IgnoreParamNames`.
+func equivalenceTokens(path string, options *Options) ([]interface{}, error) {
+	if _, err := Normalize(path, options); err != nil {
+		if _, err := Parse(path, options); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if options != nil && options.QueryParams {
+		if p, _, ok := splitQuery(path); ok {
+			path = p
+		}
+	}
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return nil, err
+	}
+	tokens = flattenTokens(tokens)
+	if options != nil && options.IgnoreParamNames {
+		for i, token := range tokens {
+			if token, ok := token.(Token); ok && token.Pattern != "" {
+				token.Name = nil
+				tokens[i] = token
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// Returns the first difference between the flattened tokens.
+func tokensDifference(a, b []interface{}, options *Options) string {
+	n := &normalizer{options: options}
+	if tokens, err := Parse(":x", options); err == nil && len(tokens) == 1 {
+		n.defaultPattern = tokens[0].(Token).Pattern
+	}
+	format := func(token interface{}) string {
+		switch token := token.(type) {
+		case string:
+			return `"` + n.escape(token) + `"`
+		case Token:
+			if token.Name == nil {
+				token.Name = 0
+			}
+			return `"` + n.token(token, nil, nil, true) + `"`
+		}
+		return "the end"
+	}
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y interface{}
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if !reflect.DeepEqual(x, y) {
+			return fmt.Sprintf("token %d: %s differs from %s", i, format(x), format(y))
+		}
+	}
+	return ""
+}
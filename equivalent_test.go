@@ -0,0 +1,72 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplatesEquivalent(t *testing.T) {
+	t.Run("should compare the templates however they're written", func(t *testing.T) {
+		tests := []struct {
+			a, b    string
+			options *Options
+			expect  bool
+		}{
+			{`/files/report\.pdf`, "/files/report.pdf", nil, true},
+			{"/users/:id", `/users/:id([^\/#\?]+?)`, nil, true},
+			{"{/:id}?", "/:id?", nil, true},
+			{"{/x}/:id", "/x/:id", nil, true},
+			{"/users/:id", `/users/:id(\d+)`, nil, false},
+			{"/users/:id", "/users/:id?", nil, false},
+			{"/users/:id", "/users/:uid", nil, false},
+			{"/users/:id", "/users/:uid", &Options{IgnoreParamNames: true}, true},
+			{"/users/:id", "/users/([^\\/#\\?]+?)", &Options{IgnoreParamNames: true}, true},
+			{"/users/:id", "/users/:uid", &Options{MatrixParams: true}, false},
+			{"/users/:id", "/users/:uid", &Options{MatrixParams: true, IgnoreParamNames: true}, true},
+		}
+		for _, test := range tests {
+			ok, err := TemplatesEquivalent(test.a, test.b, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != test.expect {
+				t.Errorf("%s, %s: "+testErrorFormat, test.a, test.b, ok, test.expect)
+			}
+		}
+	})
+
+	t.Run("should explain the first difference", func(t *testing.T) {
+		tests := []struct {
+			a, b    string
+			options *Options
+			expect  string
+		}{
+			{"/users/:id", "/users/:id([^\\/#\\?]+?)", nil, ""},
+			{"/users/:id", `/users/\:id`, nil, `token 0: "/users/" differs from "/users/\:id"`},
+			{"/users/:id", `/users/:id(\d+)`, nil, `token 1: ":id" differs from ":id(\d+)"`},
+			{"/users/:id", "/users/:id?", nil, `token 0: "/users/" differs from "/users"`},
+			{"/users/", "/users/:id", nil, `token 1: the end differs from ":id"`},
+			{"/users/:id", "/users/:uid", &Options{MatrixParams: true}, `param 0: "id" differs from "uid"`},
+		}
+		for _, test := range tests {
+			reason, err := ExplainDifference(test.a, test.b, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != test.expect {
+				t.Errorf(testErrorFormat, reason, test.expect)
+			}
+		}
+	})
+
+	t.Run("should return the parse errors", func(t *testing.T) {
+		_, err := TemplatesEquivalent("/users/:id", "/users/:id(", nil)
+		if expect := "unbalanced pattern"; err == nil || !strings.HasPrefix(err.Error(), expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+}
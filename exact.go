@@ -0,0 +1,45 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import "fmt"
+
+// ExactMatch matches the whole pathname against the template, case
+// sensitively and without an optional trailing delimiter, e.g. `/test/`
+// doesn't match `/test`: the Start, End, Strict, Sensitive and EndsWith
+// options are overridden. A fully static template is compared with the
+// pathname without building a regexp. The template is parsed on each call,
+// use NewMatcher with StrictOptions to match many pathnames.
+func ExactMatch(template, pathname string, options *Options) (*MatchResult, error) {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	start, end := true, true
+	o.Start, o.End, o.Strict, o.Sensitive, o.EndsWith = &start, &end, true, true, ""
+
+	if !o.QueryParams && !o.MatrixParams && o.Hooks == nil && o.DuplicateDelimiters == DuplicateDelimitersAllow {
+		fallback := func(pathname string) (*MatchResult, error) {
+			m, err := NewMatcher(template, &o)
+			if err != nil {
+				return nil, err
+			}
+			return m.find(pathname)
+		}
+		if match := staticMatch(template, &o, fallback); match != nil {
+			result, err := match(pathname)
+			if err == nil && result == nil && o.ErrorOnNoMatch {
+				return nil, fmt.Errorf("%w: %q", ErrNoMatch, pathname)
+			}
+			return result, err
+		}
+	}
+
+	m, err := NewMatcher(template, &o)
+	if err != nil {
+		return nil, err
+	}
+	return m.Match(pathname)
+}
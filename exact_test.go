@@ -0,0 +1,101 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExactMatch(t *testing.T) {
+	t.Run("should force the exact options", func(t *testing.T) {
+		prefix := &Options{Start: &falseValue, End: &falseValue, EndsWith: "?"}
+		tests := []struct {
+			template string
+			pathname string
+			options  *Options
+			expect   *MatchResult
+		}{
+			{"/test", "/test", nil, &MatchResult{Path: "/test", Params: map[interface{}]interface{}{},
+				Groups: []string{"/test"}}},
+			{"/test/", "/test", nil, nil},
+			{"/test", "/test/", nil, nil},
+			{"/test", "/TEST", nil, nil},
+			{"/test", "/test/route", prefix, nil},
+			{"/test", "/route/test", prefix, nil},
+			{"/test", "/test?q=1", prefix, nil},
+			{"/users/:id", "/users/42", prefix, &MatchResult{Path: "/users/42",
+				Params: map[interface{}]interface{}{"id": "42"}, Groups: []string{"/users/42", "42"}}},
+			{"/users/:id", "/users/42/", nil, nil},
+			{"/users/:id", "/Users/42", nil, nil},
+		}
+		for _, test := range tests {
+			result, err := ExactMatch(test.template, test.pathname, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Errorf("%s, %s: "+testErrorFormat, test.template, test.pathname, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should leave the options unchanged", func(t *testing.T) {
+		options := &Options{Start: &falseValue}
+		if _, err := ExactMatch("/test", "/test", options); err != nil {
+			t.Fatal(err)
+		}
+		if *options.Start || options.End != nil || options.Strict {
+			t.Errorf(testErrorFormat, options, &Options{Start: &falseValue})
+		}
+	})
+
+	t.Run("should match the static templates like the regexp", func(t *testing.T) {
+		for _, pathname := range []string{"/test", "/test\n", "/test/", "/tést", "/te\xffst", ""} {
+			for _, template := range []string{"/test", "/tést", "/te\\:st", ""} {
+				result, err := ExactMatch(template, pathname, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expect, err := mustMatcher(template, StrictOptions()).Match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(result, expect) {
+					t.Errorf("%q, %q: "+testErrorFormat, template, pathname, result, expect)
+				}
+			}
+		}
+	})
+
+	t.Run("should return the errors of the options", func(t *testing.T) {
+		_, err := ExactMatch("/test", "/route", &Options{ErrorOnNoMatch: true})
+		if !errors.Is(err, ErrNoMatch) {
+			t.Errorf(testErrorFormat, err, ErrNoMatch)
+		}
+		if _, err := ExactMatch("/:id(", "/route", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "unbalanced pattern")
+		}
+	})
+}
+
+func BenchmarkExactMatch(b *testing.B) {
+	b.Run("static", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if result, _ := ExactMatch("/api/v1/health", "/api/v1/health", nil); result == nil {
+				b.Fatal("no match")
+			}
+		}
+	})
+
+	b.Run("regexp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if result, _ := ExactMatch("/api/v1/:name", "/api/v1/health", nil); result == nil {
+				b.Fatal("no match")
+			}
+		}
+	})
+}
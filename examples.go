@@ -0,0 +1,281 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"reflect"
+	"regexp/syntax"
+	"strings"
+)
+
+// Examples are the example paths of a template, see TestTemplate.
+type Examples struct {
+	// The paths the template must match
+	Match []string
+
+	// The paths the template must not match
+	NoMatch []string
+
+	// The params expected for some of the Match paths, by path
+	Params map[string]map[interface{}]interface{}
+}
+
+// ExampleResult is the outcome of an example path.
+type ExampleResult struct {
+	Path string
+
+	// Whether the path is expected to match, and whether it matched
+	WantMatch, Matched bool
+
+	// The expected params, nil when they aren't asserted, and the matched ones
+	WantParams, Params map[interface{}]interface{}
+
+	// The error of the match, e.g. a timeout
+	Err error
+}
+
+// Failed reports whether the example didn't behave as expected.
+func (r ExampleResult) Failed() bool {
+	return r.Err != nil || r.Matched != r.WantMatch ||
+		(r.Matched && r.WantParams != nil && !reflect.DeepEqual(r.Params, r.WantParams))
+}
+
+func (r ExampleResult) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%q: %v", r.Path, r.Err)
+	case r.WantMatch && !r.Matched:
+		return fmt.Sprintf("%q: expected a match", r.Path)
+	case !r.WantMatch && r.Matched:
+		return fmt.Sprintf("%q: expected no match, but got %v", r.Path, r.Params)
+	case r.Failed():
+		return fmt.Sprintf("%q: expected %v, but got %v", r.Path, r.WantParams, r.Params)
+	}
+	return fmt.Sprintf("%q: ok %v", r.Path, r.Params)
+}
+
+// TemplateReport is the outcome of the examples of a template.
+type TemplateReport struct {
+	Template string
+	Results  []ExampleResult
+}
+
+// OK reports whether every example behaved as expected.
+func (r *TemplateReport) OK() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the results of the examples which didn't behave as
+// expected.
+func (r *TemplateReport) Failures() []ExampleResult {
+	var failures []ExampleResult
+	for _, result := range r.Results {
+		if result.Failed() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// String describes the failures, one per line after a summary.
+func (r *TemplateReport) String() string {
+	failures := r.Failures()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%q: %d of %d examples failed", r.Template, len(failures), len(r.Results))
+	for _, failure := range failures {
+		b.WriteString("\n  ")
+		b.WriteString(failure.String())
+	}
+	return b.String()
+}
+
+// TestTemplate matches the example paths against the template, e.g. to
+// validate the templates loaded from a config. The returned error is the
+// one compiling the template, the failures of the examples being reported
+// by the TemplateReport.
+func TestTemplate(template string, options *Options, examples Examples) (*TemplateReport, error) {
+	m, err := NewMatcher(template, options)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &TemplateReport{Template: template}
+	test := func(path string, wantMatch bool) {
+		result := ExampleResult{Path: path, WantMatch: wantMatch}
+		if wantMatch {
+			result.WantParams = examples.Params[path]
+		}
+		match, err := m.find(path)
+		if match != nil {
+			result.Matched, result.Params = true, match.Params
+		}
+		result.Err = err
+		r.Results = append(r.Results, result)
+	}
+	for _, path := range examples.Match {
+		test(path, true)
+	}
+	for _, path := range examples.NoMatch {
+		test(path, false)
+	}
+	return r, nil
+}
+
+// The value of a param whose pattern can't be generated from.
+const exampleValue = "example"
+
+// GenerateExamples returns up to n distinct paths of the template, e.g. for
+// smoke tests. The params are filled with values generated from their
+// patterns, the value of a param of the default pattern being its name, and
+// the examples cover the variants of the optional params being present or
+// absent, from all present to all absent. Every example is checked against
+// the matcher of the template, the examples which fail to match are dropped.
+func GenerateExamples(template string, options *Options, n int) ([]string, error) {
+	tokens, err := Parse(template, options)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewMatcher(template, options)
+	if err != nil {
+		return nil, err
+	}
+	toPath, err := Compile(template, options)
+	if err != nil {
+		return nil, err
+	}
+	if options == nil {
+		options = &Options{}
+	}
+
+	var params []Token
+	optionals := 0
+	for _, token := range tokens {
+		if token, ok := token.(Token); ok {
+			if token.Matrix != nil {
+				continue
+			}
+			params = append(params, token)
+			if isOptional(token) {
+				optionals++
+			}
+		}
+	}
+	variants := 1
+	for i := 0; i < optionals && variants < n; i++ {
+		variants *= 2
+	}
+
+	// The literals of the patterns are generated as they're written.
+	sensitive := *options
+	sensitive.Sensitive = true
+	c := newOverlapChecker(&sensitive)
+	defaultPattern := paramPattern(options)
+	var examples []string
+	seen := make(map[string]bool)
+	// Stops when the seeds don't give new examples.
+	for i, misses := 0, 0; len(examples) < n && misses < variants; i++ {
+		variant, seed := i%variants, i/variants
+		data := make(map[interface{}]interface{})
+		optional := 0
+		for _, token := range params {
+			if isOptional(token) {
+				// The first variant has all the optional params, the second none,
+				// the next ones are the other combinations.
+				absent := variant == 1 || (variant > 1 && (variant-1)&(1<<uint(optional)) != 0)
+				optional++
+				if absent {
+					continue
+				}
+			}
+			value := exampleValue
+			if token.Pattern == defaultPattern {
+				if token.IsNamed() {
+					value = token.NameString()
+				}
+			} else if re, ok := c.parsePattern(token.Pattern); ok {
+				if str, ok := sampleString(re, seed); ok {
+					value = str
+				}
+			}
+			if isRepeat(token) {
+				data[token.Name] = []string{value}
+			} else {
+				data[token.Name] = value
+			}
+		}
+
+		path, err := toPath(data)
+		if err == nil && !seen[path] {
+			if result, err := m.find(path); err == nil && result != nil {
+				seen[path] = true
+				examples = append(examples, path)
+				misses = 0
+				continue
+			}
+		}
+		misses++
+	}
+	return examples, nil
+}
+
+// The characters picked from a class, in order of preference.
+const sampleRunes = "abcdefghijklmnopqrstuvwxyz1234567890ABCDEFGHIJKLMNOPQRSTUVWXYZ-_"
+
+// Returns a string matched by the regexp, the seed picking among the
+// alternatives and the characters of the classes.
+func sampleString(re *syntax.Regexp, seed int) (string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return "", true
+	case syntax.OpLiteral:
+		return string(re.Rune), true
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return string(sampleRunes[seed%26]), true
+	case syntax.OpCharClass:
+		var runes []rune
+		for _, r := range sampleRunes {
+			for i := 0; i+1 < len(re.Rune); i += 2 {
+				if re.Rune[i] <= r && r <= re.Rune[i+1] {
+					runes = append(runes, r)
+					break
+				}
+			}
+		}
+		if len(runes) == 0 {
+			if len(re.Rune) == 0 {
+				return "", false
+			}
+			return string(re.Rune[0]), true
+		}
+		return string(runes[seed%len(runes)]), true
+	case syntax.OpCapture, syntax.OpPlus, syntax.OpQuest, syntax.OpStar:
+		return sampleString(re.Sub[0], seed)
+	case syntax.OpRepeat:
+		var b strings.Builder
+		for i := 0; i < re.Min || (i == 0 && re.Max != 0); i++ {
+			str, ok := sampleString(re.Sub[0], seed)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(str)
+		}
+		return b.String(), true
+	case syntax.OpConcat:
+		var b strings.Builder
+		for _, sub := range re.Sub {
+			str, ok := sampleString(sub, seed)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(str)
+		}
+		return b.String(), true
+	case syntax.OpAlternate:
+		return sampleString(re.Sub[seed%len(re.Sub)], seed)
+	}
+	return "", false
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTestTemplate(t *testing.T) {
+	t.Run("should pass the expected examples", func(t *testing.T) {
+		r, err := TestTemplate("/user/:id(\\d+)", nil, Examples{
+			Match:   []string{"/user/1", "/user/2/"},
+			NoMatch: []string{"/user/x", "/user/1/extra"},
+			Params:  map[string]map[interface{}]interface{}{"/user/1": {"id": "1"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.OK() || len(r.Results) != 4 {
+			t.Errorf(testErrorFormat, r, "ok")
+		}
+		expect := map[interface{}]interface{}{"id": "2"}
+		if !reflect.DeepEqual(r.Results[1].Params, expect) {
+			t.Errorf(testErrorFormat, r.Results[1].Params, expect)
+		}
+	})
+
+	t.Run("should report a failing positive", func(t *testing.T) {
+		r, err := TestTemplate("/user/:id(\\d+)", nil, Examples{Match: []string{"/user/1", "/user/x"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		failures := r.Failures()
+		if r.OK() || len(failures) != 1 || failures[0].Path != "/user/x" || failures[0].Matched {
+			t.Errorf(testErrorFormat, failures, "/user/x")
+		}
+		expect := "\"/user/:id(\\\\d+)\": 1 of 2 examples failed\n  \"/user/x\": expected a match"
+		if r.String() != expect {
+			t.Errorf(testErrorFormat, r.String(), expect)
+		}
+	})
+
+	t.Run("should report a failing negative", func(t *testing.T) {
+		r, err := TestTemplate("/user/:id", nil, Examples{NoMatch: []string{"/user/x", "/user/1/extra"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		failures := r.Failures()
+		if len(failures) != 1 || failures[0].Path != "/user/x" || !failures[0].Matched {
+			t.Errorf(testErrorFormat, failures, "/user/x")
+		}
+		expect := "\"/user/:id\": 1 of 2 examples failed\n  \"/user/x\": expected no match, but got map[id:x]"
+		if r.String() != expect {
+			t.Errorf(testErrorFormat, r.String(), expect)
+		}
+	})
+
+	t.Run("should assert the params", func(t *testing.T) {
+		r, err := TestTemplate("/:lang?/docs/:page", &Options{Sensitive: true}, Examples{
+			Match: []string{"/en/docs/intro", "/docs/intro"},
+			Params: map[string]map[interface{}]interface{}{
+				"/en/docs/intro": {"lang": "en", "page": "intro"},
+				"/docs/intro":    {"lang": "en", "page": "intro"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		failures := r.Failures()
+		if len(failures) != 1 || failures[0].Path != "/docs/intro" {
+			t.Errorf(testErrorFormat, failures, "/docs/intro")
+		}
+		expect := "\"/docs/intro\": expected map[lang:en page:intro], but got map[page:intro]"
+		if failures[0].String() != expect {
+			t.Errorf(testErrorFormat, failures[0].String(), expect)
+		}
+	})
+
+	t.Run("should return the compile error", func(t *testing.T) {
+		if _, err := TestTemplate("/user/:id(", nil, Examples{}); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
+
+func TestGenerateExamples(t *testing.T) {
+	t.Run("should generate the examples", func(t *testing.T) {
+		tests := []struct {
+			template string
+			options  *Options
+			n        int
+			expect   []string
+		}{
+			{"/user/:id(\\d+)/posts/:slug?", nil, 2, []string{"/user/1/posts/slug", "/user/1/posts"}},
+			{"/user/:id(\\d+)/posts/:slug?", nil, 4, []string{"/user/1/posts/slug", "/user/1/posts",
+				"/user/2/posts/slug", "/user/2/posts"}},
+			{"/user/:id(\\d+)", nil, 1, []string{"/user/1"}},
+			{"/:lang(en|fr)/:page([a-z]+)", nil, 3, []string{"/en/a", "/fr/b", "/en/c"}},
+			{"/:year(\\d{4})-:month(\\d{2})", nil, 1, []string{"/1111-11"}},
+			{"/files/:path*", nil, 5, []string{"/files/path", "/files"}},
+			{"/x/:a?/:b?", nil, 4, []string{"/x/a/b", "/x", "/x/b", "/x/a"}},
+			{"/healthz", nil, 3, []string{"/healthz"}},
+			{"/(\\d+)", nil, 1, []string{"/1"}},
+			{"/:id((?!new)[^/]+)", nil, 1, []string{"/example"}},
+		}
+		for _, test := range tests {
+			examples, err := GenerateExamples(test.template, test.options, test.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(examples, test.expect) {
+				t.Errorf(testErrorFormat, examples, test.expect)
+			}
+		}
+	})
+
+	t.Run("should check the examples against the matcher", func(t *testing.T) {
+		templates := []string{"/user/:id(\\d+)/posts/:slug?", "/:id([A-Z]{2}\\d)", "/:v(\\w+)\\.:ext(json|xml)"}
+		for _, template := range templates {
+			examples, err := GenerateExamples(template, &Options{Sensitive: true}, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(examples) == 0 {
+				t.Errorf(testErrorFormat, examples, "examples")
+			}
+			matcher := mustMatcher(template, &Options{Sensitive: true})
+			for _, example := range examples {
+				if result, err := matcher.Match(example); err != nil || result == nil {
+					t.Errorf(testErrorFormat, result, example)
+				}
+			}
+		}
+	})
+
+	t.Run("should drop the examples which don't match", func(t *testing.T) {
+		examples, err := GenerateExamples("/:id(\\d+(?=x))", nil, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(examples) != 0 {
+			t.Errorf(testErrorFormat, examples, "no examples")
+		}
+	})
+
+	t.Run("should return the parse errors", func(t *testing.T) {
+		if _, err := GenerateExamples("/user/:id(", nil, 1); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
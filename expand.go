@@ -0,0 +1,96 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import "fmt"
+
+// ExpandStatic returns every path of a template whose params only match a
+// finite set of strings, such as `/:env(staging|prod)/status`, e.g. for cache
+// warming. An optional param adds the paths without it. An error is returned
+// when a param is open-ended, such as `:id(\d+)` or a repeated param, or when
+// there are more than limit paths.
+func ExpandStatic(path string, options *Options, limit int) ([]string, error) {
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return nil, err
+	}
+	toPath, err := Compile(path, options)
+	if err != nil {
+		return nil, err
+	}
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	// The literals of the patterns are expanded as they're written.
+	o.Sensitive = true
+	c := newOverlapChecker(&o)
+
+	var params []Token
+	var values [][]interface{}
+	count := 1
+	for _, token := range tokens {
+		token, ok := token.(Token)
+		if !ok {
+			continue
+		}
+		if isRepeat(token) {
+			return nil, fmt.Errorf("param \"%v\" is repeated", token.Name)
+		}
+		re, ok := c.parsePattern(token.Pattern)
+		if !ok {
+			return nil, fmt.Errorf("param \"%v\" is open-ended", token.Name)
+		}
+		strs, ok := finiteStrings(re.Simplify())
+		if !ok {
+			return nil, fmt.Errorf("param \"%v\" is open-ended", token.Name)
+		}
+		var vs []interface{}
+		for _, str := range strs {
+			vs = append(vs, str)
+		}
+		if isOptional(token) {
+			vs = append(vs, nil)
+		}
+		params = append(params, token)
+		values = append(values, vs)
+		if count *= len(vs); count > limit {
+			return nil, fmt.Errorf("%q has more than %d paths", path, limit)
+		}
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	data := make(map[interface{}]interface{})
+	var expand func(i int) error
+	expand = func(i int) error {
+		if i == len(params) {
+			p, err := toPath(data)
+			if err != nil {
+				return err
+			}
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+			return nil
+		}
+		for _, value := range values[i] {
+			if value == nil {
+				delete(data, params[i].Name)
+			} else {
+				data[params[i].Name] = value
+			}
+			if err := expand(i + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := expand(0); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandStatic(t *testing.T) {
+	t.Run("should expand the templates", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			expect  []string
+		}{
+			{"/healthz", nil, []string{"/healthz"}},
+			{"/reports.:ext(csv|json|xml)", nil, []string{"/reports.csv", "/reports.json", "/reports.xml"}},
+			{"/:env(staging|prod)/status", nil, []string{"/staging/status", "/prod/status"}},
+			{"/:env(staging|prod)/:region(eu|us)", nil, []string{"/staging/eu", "/staging/us", "/prod/eu", "/prod/us"}},
+			{"/v:major([12])", nil, []string{"/v1", "/v2"}},
+			{"/(en|fr)/docs", nil, []string{"/en/docs", "/fr/docs"}},
+			{"/:lang(EN|FR)", nil, []string{"/EN", "/FR"}},
+		}
+		for _, test := range tests {
+			paths, err := ExpandStatic(test.path, test.options, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(paths, test.expect) {
+				t.Errorf(testErrorFormat, paths, test.expect)
+			}
+		}
+	})
+
+	t.Run("should double the paths with the optional params", func(t *testing.T) {
+		tests := []struct {
+			path   string
+			expect []string
+		}{
+			{"/:lang(en|fr)?/docs", []string{"/en/docs", "/fr/docs", "/docs"}},
+			{"/reports.:ext(csv|json)?", []string{"/reports.csv", "/reports.json", "/reports"}},
+			{"/a/:x(1)?/:y(2)?", []string{"/a/1/2", "/a/1", "/a/2", "/a"}},
+		}
+		for _, test := range tests {
+			paths, err := ExpandStatic(test.path, nil, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(paths, test.expect) {
+				t.Errorf(testErrorFormat, paths, test.expect)
+			}
+		}
+	})
+
+	t.Run("should reject the open-ended templates", func(t *testing.T) {
+		tests := []struct {
+			path   string
+			limit  int
+			expect string
+		}{
+			{"/users/:id", 10, `param "id" is open-ended`},
+			{"/users/:id(\\d+)", 10, `param "id" is open-ended`},
+			{"/users/:id((?!new)[a-z])", 10, `param "id" is open-ended`},
+			{"/files/:path(a|b)*", 10, `param "path" is repeated`},
+			{"/:a(1|2|3)/:b(1|2|3)", 8, `"/:a(1|2|3)/:b(1|2|3)" has more than 8 paths`},
+			{"/users/:id(", 10, "unbalanced pattern"},
+		}
+		for _, test := range tests {
+			_, err := ExpandStatic(test.path, nil, test.limit)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expect) {
+				t.Errorf(testErrorFormat, err, test.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// The version of the encoding written by ExportRoutes.
+const routesVersion = 1
+
+type exportedRoutes struct {
+	Version int             `json:"version"`
+	Routes  []exportedRoute `json:"routes"`
+}
+
+type exportedRoute struct {
+	Templates []string        `json:"templates"`
+	Array     bool            `json:"array,omitempty"`
+	Source    string          `json:"source"`
+	Tokens    []exportedToken `json:"tokens"`
+	Options   exportedOptions `json:"options"`
+}
+
+type exportedToken struct {
+	Name     interface{} `json:"name"`
+	Prefix   string      `json:"prefix,omitempty"`
+	Suffix   string      `json:"suffix,omitempty"`
+	Pattern  string      `json:"pattern,omitempty"`
+	Modifier string      `json:"modifier,omitempty"`
+}
+
+// The options used when matching.
+type exportedOptions struct {
+	Sensitive        bool          `json:"sensitive,omitempty"`
+	MatchTimeout     time.Duration `json:"matchTimeout,omitempty"`
+	RequireValidUTF8 bool          `json:"requireValidUTF8,omitempty"`
+	DecodeValues     bool          `json:"decodeValues,omitempty"`
+	TypedParams      bool          `json:"typedParams,omitempty"`
+
+	RegexFlags regexp2.RegexOptions `json:"regexFlags,omitempty"`
+}
+
+// Templates returns the templates the matcher was built from, a regexp is
+// described by its source.
+func (m *Matcher) Templates() []string {
+	return pathTemplates(m.path)
+}
+
+// ExportRoutes encodes the matchers, so that ImportRoutes can restore them
+// without parsing the templates again. Matchers built from a regexp, or with
+// options holding functions, an encoder or a decoder, an engine, query or matrix
+// params, can't be exported.
+func ExportRoutes(matchers []*Matcher) ([]byte, error) {
+	routes := exportedRoutes{Version: routesVersion, Routes: make([]exportedRoute, len(matchers))}
+	for i, m := range matchers {
+		switch m.path.(type) {
+		case *regexp2.Regexp, *regexp.Regexp, nil:
+			return nil, fmt.Errorf("route %d: only matchers built from templates can be exported", i)
+		}
+		o := m.options
+		if o == nil {
+			o = &Options{}
+		}
+		if o.Decode != nil || o.Encode != nil || o.Encoder != nil || o.Decoder != nil || o.Engine != nil ||
+			len(o.Constraints) > 0 {
+			return nil, fmt.Errorf("route %d: options with functions or an engine can't be exported", i)
+		}
+		if o.QueryParams || o.MatrixParams {
+			return nil, fmt.Errorf("route %d: options with query or matrix params can't be exported", i)
+		}
+		if o.Compat != CompatNone {
+			return nil, fmt.Errorf("route %d: options with a compat mode can't be exported", i)
+		}
+
+		_, isString := m.path.(string)
+		route := exportedRoute{
+			Templates: m.Templates(),
+			Array:     !isString,
+			Source:    m.source,
+			Tokens:    make([]exportedToken, len(m.tokens)),
+			Options: exportedOptions{
+				Sensitive:        o.Sensitive,
+				MatchTimeout:     o.MatchTimeout,
+				RequireValidUTF8: o.RequireValidUTF8,
+				DecodeValues:     o.DecodeValues,
+				TypedParams:      o.TypedParams,
+				RegexFlags:       o.RegexFlags,
+			},
+		}
+		for j, t := range m.tokens {
+			route.Tokens[j] = exportedToken{Name: t.Name, Prefix: t.Prefix, Suffix: t.Suffix,
+				Pattern: t.Pattern, Modifier: t.Modifier}
+		}
+		routes.Routes[i] = route
+	}
+
+	return json.Marshal(routes)
+}
+
+// ImportRoutes decodes the matchers encoded by ExportRoutes, the regexps are
+// compiled again from their source.
+func ImportRoutes(data []byte) ([]*Matcher, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var routes exportedRoutes
+	if err := decoder.Decode(&routes); err != nil {
+		return nil, err
+	}
+	if routes.Version != routesVersion {
+		return nil, fmt.Errorf("unsupported routes version %d", routes.Version)
+	}
+
+	matchers := make([]*Matcher, len(routes.Routes))
+	for i, route := range routes.Routes {
+		if len(route.Templates) == 0 || (!route.Array && len(route.Templates) != 1) {
+			return nil, fmt.Errorf("route %d: invalid templates", i)
+		}
+
+		tokens := make([]Token, len(route.Tokens))
+		for j, t := range route.Tokens {
+			name, err := importTokenName(t.Name)
+			if err != nil {
+				return nil, fmt.Errorf("route %d: %v", i, err)
+			}
+			tokens[j] = Token{Name: name, Prefix: t.Prefix, Suffix: t.Suffix,
+				Pattern: t.Pattern, Modifier: t.Modifier}
+		}
+
+		options := &Options{
+			Sensitive:        route.Options.Sensitive,
+			MatchTimeout:     route.Options.MatchTimeout,
+			RequireValidUTF8: route.Options.RequireValidUTF8,
+			DecodeValues:     route.Options.DecodeValues,
+			TypedParams:      route.Options.TypedParams,
+			RegexFlags:       route.Options.RegexFlags,
+		}
+		m, err := NewMatcherFromSource(route.Source, tokens, options)
+		if err != nil {
+			return nil, fmt.Errorf("route %d: %v", i, err)
+		}
+		if m.re != nil && len(m.re.GetGroupNumbers()) != len(tokens)+1 {
+			return nil, fmt.Errorf("route %d: expected %d groups in the source, but got %d",
+				i, len(tokens), len(m.re.GetGroupNumbers())-1)
+		}
+
+		m.path = route.Templates[0]
+		if route.Array {
+			m.path = route.Templates
+		}
+		matchers[i] = m
+	}
+	return matchers, nil
+}
+
+// Token names are strings, or ints for unnamed params.
+func importTokenName(name interface{}) (interface{}, error) {
+	switch name := name.(type) {
+	case string:
+		return name, nil
+	case json.Number:
+		if i, err := name.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	return nil, errors.New("token names should be strings or integers")
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dlclark/regexp2"
+)
+
+func TestExportRoutes(t *testing.T) {
+	t.Run("should restore the fixtures", func(t *testing.T) {
+		var matchers []*Matcher
+		var cases [][]string
+		for _, test := range tests {
+			switch test[0].(type) {
+			case *regexp2.Regexp, *regexp.Regexp:
+				continue
+			}
+			var o *Options
+			if test[1] != nil {
+				o = test[1].(*Options)
+				if o.Encode != nil || o.Decode != nil {
+					continue
+				}
+			}
+			m, err := NewMatcher(test[0], o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matchers = append(matchers, m)
+
+			var pathnames []string
+			for _, v := range test[3].(a) {
+				pathnames = append(pathnames, v.(a)[0].(string))
+			}
+			cases = append(cases, pathnames)
+		}
+
+		data, err := ExportRoutes(matchers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := ImportRoutes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(restored) != len(matchers) {
+			t.Fatalf(testErrorFormat, len(restored), len(matchers))
+		}
+
+		for i, m := range matchers {
+			r := restored[i]
+			if r.RouteString() != m.RouteString() {
+				t.Errorf(testErrorFormat, r.RouteString(), m.RouteString())
+			}
+			if !reflect.DeepEqual(r.Tokens(), m.Tokens()) {
+				t.Errorf(testErrorFormat, r.Tokens(), m.Tokens())
+			}
+			if !reflect.DeepEqual(r.Templates(), m.Templates()) {
+				t.Errorf(testErrorFormat, r.Templates(), m.Templates())
+			}
+			for _, pathname := range cases[i] {
+				expect, err := m.Match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := r.Match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(result, expect) {
+					t.Errorf("%s %s: "+testErrorFormat, m.Templates(), pathname, result, expect)
+				}
+			}
+		}
+	})
+
+	t.Run("should keep the options used when matching", func(t *testing.T) {
+		m, err := NewMatcher("/:foo", &Options{Sensitive: true, RequireValidUTF8: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ExportRoutes([]*Matcher{m})
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := ImportRoutes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, _ := restored[0].Match("/FOO"); result == nil {
+			t.Errorf(testErrorFormat, result, "match")
+		}
+		if result, _ := restored[0].Match("/\xff"); result == nil {
+			t.Errorf(testErrorFormat, result, "match")
+		}
+		if _, err := restored[0].Match("/%FF"); err != nil {
+			t.Error(err)
+		}
+		if !restored[0].options.Sensitive || !restored[0].options.RequireValidUTF8 {
+			t.Errorf(testErrorFormat, restored[0].options, "sensitive and valid UTF-8")
+		}
+	})
+
+	t.Run("should fail to export", func(t *testing.T) {
+		tests := []struct {
+			matcher *Matcher
+			message string
+		}{
+			{MustMatcherFromSource("^/$", nil, nil), "route 0: only matchers built from templates can be exported"},
+			{mustMatcher(regexp2.MustCompile("^/$", regexp2.None), nil),
+				"route 0: only matchers built from templates can be exported"},
+			{mustMatcher("/:foo", &Options{Decode: decodeURIComponent}),
+				"route 0: options with functions or an engine can't be exported"},
+			{mustMatcher("/:foo", &Options{Constraints: map[string]func(string) bool{"foo": isASCII}}),
+				"route 0: options with functions or an engine can't be exported"},
+		}
+		for _, test := range tests {
+			_, err := ExportRoutes([]*Matcher{test.matcher})
+			if err == nil || err.Error() != test.message {
+				t.Errorf(testErrorFormat, err, test.message)
+			}
+		}
+	})
+
+	t.Run("should fail to import", func(t *testing.T) {
+		tests := []struct {
+			data    string
+			message string
+		}{
+			{`{"version":2,"routes":[]}`, "unsupported routes version 2"},
+			{`{"version":1,"routes":[{"templates":[],"source":"^/$"}]}`, "route 0: invalid templates"},
+			{`{"version":1,"routes":[{"templates":["/"],"source":"^/(a"}]}`,
+				"route 0: error parsing regexp: missing closing ) in `^/(a`"},
+			{`{"version":1,"routes":[{"templates":["/"],"source":"^/(a)(b)$","tokens":[{"name":0}]}]}`,
+				"route 0: expected 1 groups in the source, but got 2"},
+			{`{"version":1,"routes":[{"templates":["/"],"source":"^/(a)$","tokens":[{"name":1.5}]}]}`,
+				"route 0: token names should be strings or integers"},
+		}
+		for _, test := range tests {
+			_, err := ImportRoutes([]byte(test.data))
+			if err == nil || err.Error() != test.message {
+				t.Errorf(testErrorFormat, err, test.message)
+			}
+		}
+
+		if _, err := ImportRoutes([]byte("{")); err == nil || !strings.Contains(err.Error(), "EOF") {
+			t.Errorf(testErrorFormat, err, "EOF")
+		}
+	})
+}
+
+func mustMatcher(path interface{}, options *Options) *Matcher {
+	m, err := NewMatcher(path, options)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// GenerateSource returns a Go source file for the package `pkgName` which
+// declares `Routes`, a map from the route names to matchers of the route
+// templates. The regexps are built when generating, and only compiled when
+// the package is initialized.
+//
+// Options holding functions, an encoder or a decoder, an engine, query or
+// matrix params can't be generated, and only the options used when matching
+// are kept.
+func GenerateSource(pkgName string, routes map[string]string, options *Options) ([]byte, error) {
+	if !token.IsIdentifier(pkgName) {
+		return nil, fmt.Errorf("invalid package name %q", pkgName)
+	}
+	if options != nil && (options.Encode != nil || options.Decode != nil || options.Encoder != nil ||
+		options.Decoder != nil || options.Engine != nil || len(options.Constraints) > 0) {
+		return nil, errors.New("options with functions or an engine can't be generated")
+	}
+	if options != nil && (options.QueryParams || options.MatrixParams) {
+		return nil, errors.New("options with query or matrix params can't be generated")
+	}
+	if options != nil && options.Compat != CompatNone {
+		return nil, errors.New("options with a compat mode can't be generated")
+	}
+
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by pathtoregexp.GenerateSource. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	b.WriteString("import pathtoregexp \"github.com/soongo/path-to-regexp\"\n\n")
+	fmt.Fprintf(&b, "var routeOptions = %s\n\n", optionsLiteral(options))
+	b.WriteString("// Routes holds the matchers by route name.\n")
+	b.WriteString("var Routes = map[string]*pathtoregexp.Matcher{\n")
+	for _, name := range names {
+		m, err := NewMatcher(routes[name], options)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %v", name, err)
+		}
+
+		fmt.Fprintf(&b, "// %s\n", strconv.Quote(routes[name]))
+		fmt.Fprintf(&b, "%s: pathtoregexp.MustMatcherFromSource(\n", strconv.Quote(name))
+		fmt.Fprintf(&b, "%s,\n", quote(m.RouteString()))
+		b.WriteString("[]pathtoregexp.Token{\n")
+		for _, t := range m.tokens {
+			fmt.Fprintf(&b, "{Name: %s", nameLiteral(t.Name))
+			for _, field := range [][2]string{
+				{"Prefix", t.Prefix}, {"Suffix", t.Suffix}, {"Modifier", t.Modifier}, {"Pattern", t.Pattern},
+			} {
+				if field[1] != "" {
+					fmt.Fprintf(&b, ", %s: %s", field[0], quote(field[1]))
+				}
+			}
+			b.WriteString("},\n")
+		}
+		b.WriteString("},\nrouteOptions,\n),\n")
+	}
+	b.WriteString("}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// Returns the literal of the options used when matching.
+func optionsLiteral(options *Options) string {
+	if options == nil {
+		return "(*pathtoregexp.Options)(nil)"
+	}
+
+	var fields []string
+	if options.Sensitive {
+		fields = append(fields, "Sensitive: true")
+	}
+	if options.MatchTimeout > 0 {
+		fields = append(fields, "MatchTimeout: "+strconv.FormatInt(int64(options.MatchTimeout), 10))
+	}
+	if options.RequireValidUTF8 {
+		fields = append(fields, "RequireValidUTF8: true")
+	}
+	if options.DecodeValues {
+		fields = append(fields, "DecodeValues: true")
+	}
+	if options.TypedParams {
+		fields = append(fields, "TypedParams: true")
+	}
+	if options.RegexFlags != 0 {
+		fields = append(fields, fmt.Sprintf("RegexFlags: %#x", int(options.RegexFlags)))
+	}
+
+	var b bytes.Buffer
+	b.WriteString("&pathtoregexp.Options{")
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(field)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Returns the literal of a token name, which is a string or an int.
+func nameLiteral(name interface{}) string {
+	if name, ok := name.(int); ok {
+		return strconv.Itoa(name)
+	}
+	return strconv.Quote(fmt.Sprintf("%v", name))
+}
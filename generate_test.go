@@ -0,0 +1,114 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"bytes"
+	"go/format"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var generateRoutes = map[string]string{
+	"home":  "/",
+	"user":  "/user/:id(\\d+)",
+	"files": "/files/:path*",
+	"post":  "/:year/:month?/`x`/(.*)",
+}
+
+func TestGenerateSource(t *testing.T) {
+	source, err := GenerateSource("routes", generateRoutes, &Options{Sensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should match the golden file", func(t *testing.T) {
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "generate.golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, golden) {
+			t.Errorf(testErrorFormat, string(source), string(golden))
+		}
+	})
+
+	t.Run("should be formatted", func(t *testing.T) {
+		formatted, err := format.Source(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, formatted) {
+			t.Errorf(testErrorFormat, string(source), string(formatted))
+		}
+	})
+
+	t.Run("should build", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping build in short mode")
+		}
+		goTool, err := osexec.LookPath("go")
+		if err != nil {
+			t.Skip("go tool not found")
+		}
+
+		dir, err := ioutil.TempDir(".", "generated")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if err := ioutil.WriteFile(filepath.Join(dir, "routes.go"), source, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := osexec.Command(goTool, "vet", "./"+filepath.Base(dir)).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %s", err, out)
+		}
+	})
+
+	t.Run("should match like NewMatcher", func(t *testing.T) {
+		options := &Options{Sensitive: true}
+		for _, path := range generateRoutes {
+			expect := MustMatch(path, options)
+			m, err := NewMatcher(path, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			generated := MustMatcherFromSource(m.RouteString(), m.Tokens(), options)
+			for _, pathname := range []string{"/", "/user/123", "/files/a/b", "/2020/`x`/c", "/USER/1"} {
+				result, _ := generated.Match(pathname)
+				want, _ := expect(pathname)
+				if (result == nil) != (want == nil) || (result != nil && !want.equals(result)) {
+					t.Errorf(testErrorFormat, result, want)
+				}
+			}
+		}
+	})
+}
+
+func TestGenerateSourceErrors(t *testing.T) {
+	tests := []struct {
+		pkgName string
+		routes  map[string]string
+		options *Options
+		message string
+	}{
+		{"1routes", generateRoutes, nil, "invalid package name \"1routes\""},
+		{"routes", generateRoutes, &Options{Decode: decodeURIComponent},
+			"options with functions or an engine can't be generated"},
+		{"routes", generateRoutes, &Options{Constraints: map[string]func(string) bool{"id": isASCII}},
+			"options with functions or an engine can't be generated"},
+		{"routes", map[string]string{"bad": "/:foo("}, nil, "route \"bad\": unbalanced pattern at 5"},
+	}
+	for _, test := range tests {
+		_, err := GenerateSource(test.pkgName, test.routes, test.options)
+		if err == nil || err.Error() != test.message {
+			t.Errorf(testErrorFormat, err, test.message)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"regexp"
+
+	"github.com/dlclark/regexp2"
+)
+
+// GroupBinding binds a token to the capture group of the regexp matching it.
+type GroupBinding struct {
+	Token Token
+
+	// The index of the group, as in `EngineMatch.Groups`
+	Group int
+
+	// The index of the path holding the token in an array path, 0 for other
+	// paths. A named group of several regexps is held by the first one.
+	Alternative int
+}
+
+// SubexpNamer is implemented by the Patterns whose groups aren't numbered
+// like regexp2's, which numbers the named groups after the unnamed ones.
+type SubexpNamer interface {
+	// SubexpNames returns the names of the groups in the order of their
+	// numbers, as regexp.Regexp's, the unnamed groups having an empty name.
+	SubexpNames() []string
+}
+
+// SubexpNames returns the names of the groups of the regexp.
+func (p stdPattern) SubexpNames() []string {
+	return p.re.SubexpNames()
+}
+
+// Returns the bindings of the tokens to the groups of the pattern. The tokens
+// of the named groups of regexps come last, as regexp2 numbers them, which
+// is the order of the groups unless the pattern is a SubexpNamer.
+func groupBindings(tokens []Token, p Pattern) []GroupBinding {
+	groups := make([]GroupBinding, len(tokens))
+	for i, token := range tokens {
+		groups[i] = GroupBinding{Token: token, Group: i + 1}
+	}
+	namer, ok := p.(SubexpNamer)
+	if !ok {
+		return groups
+	}
+
+	names := namer.SubexpNames()
+	named := make(map[string]int)
+	for _, name := range names[1:] {
+		if name != "" {
+			named[name] = -1
+		}
+	}
+	positional := len(tokens) - len(named)
+	if positional < 0 || len(names)-1 != len(tokens) {
+		return groups
+	}
+	for i := positional; i < len(tokens); i++ {
+		name, ok := tokens[i].Name.(string)
+		if _, exists := named[name]; !ok || !exists {
+			return groups
+		}
+		named[name] = i
+	}
+
+	next := 0
+	for group, name := range names[1:] {
+		i := named[name]
+		if name == "" {
+			i, next = next, next+1
+		}
+		groups[i].Group = group + 1
+	}
+	return groups
+}
+
+// Returns the index of the alternative of the array holding each token, in
+// the order of the tokens of the array path, as they're collected by
+// arrayToSource.
+func tokenAlternatives(path []interface{}, options *Options) []int {
+	var positional, named []int
+	seenSource, seenName := make(map[string]bool), make(map[interface{}]bool)
+	for k, p := range path {
+		var tokens, namedTokens []Token
+		source, err := arrayToNamedSource([]interface{}{p}, &tokens, &namedTokens, options)
+		if err != nil || seenSource[source] {
+			continue
+		}
+		seenSource[source] = true
+		for range tokens {
+			positional = append(positional, k)
+		}
+		for _, token := range namedTokens {
+			if !seenName[token.Name] {
+				seenName[token.Name] = true
+				named = append(named, k)
+			}
+		}
+	}
+	return append(positional, named...)
+}
+
+// GroupMap returns the bindings of the tokens to the capture groups of the
+// regexp, in the order of the tokens, e.g. to run the regexp with another
+// engine.
+func (m *Matcher) GroupMap() []GroupBinding {
+	m.groupMapOnce.Do(func() {
+		m.groupMap = append([]GroupBinding(nil), m.groups...)
+		if _, ok := pathTokens(m.path); ok || !isArrayPath(m.path) {
+			return
+		}
+		alternatives := tokenAlternatives(toSlice(m.path), m.options)
+		if len(alternatives) != len(m.groupMap) {
+			return
+		}
+		for i := range m.groupMap {
+			m.groupMap[i].Alternative = alternatives[i]
+		}
+	})
+	return append([]GroupBinding(nil), m.groupMap...)
+}
+
+// Reports whether the path is an array of paths rather than a single one.
+func isArrayPath(path interface{}) bool {
+	switch path.(type) {
+	case string, *regexp2.Regexp, *regexp.Regexp, nil:
+		return false
+	}
+	return isArray(path)
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/dlclark/regexp2"
+)
+
+func TestGroupMap(t *testing.T) {
+	t.Run("should bind the tokens of a template", func(t *testing.T) {
+		matcher := mustMatcher("/users/:id/(\\d+)", nil)
+		groups := matcher.GroupMap()
+		tokens := matcher.Tokens()
+		expect := []GroupBinding{{Token: tokens[0], Group: 1}, {Token: tokens[1], Group: 2}}
+		if !reflect.DeepEqual(groups, expect) {
+			t.Errorf(testErrorFormat, groups, expect)
+		}
+	})
+
+	t.Run("should bind the tokens of the alternatives", func(t *testing.T) {
+		matcher := mustMatcher([]interface{}{
+			"/users/:id",
+			"/users/:id",
+			regexp2.MustCompile(`^/(?<year>\d+)/(\d+)`, regexp2.None),
+			[]string{"/a/:x", "/b/:y"},
+			regexp2.MustCompile(`^/posts/(?<year>\d+)`, regexp2.None),
+		}, nil)
+		var names []interface{}
+		var groups, alternatives []int
+		for _, binding := range matcher.GroupMap() {
+			names = append(names, binding.Token.Name)
+			groups = append(groups, binding.Group)
+			alternatives = append(alternatives, binding.Alternative)
+		}
+		// The repeated template is left out, and the named group shared by the
+		// regexps is numbered last.
+		if expect := []interface{}{"id", 0, "x", "y", "year"}; !reflect.DeepEqual(names, expect) {
+			t.Errorf(testErrorFormat, names, expect)
+		}
+		if expect := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(groups, expect) {
+			t.Errorf(testErrorFormat, groups, expect)
+		}
+		if expect := []int{0, 2, 3, 3, 2}; !reflect.DeepEqual(alternatives, expect) {
+			t.Errorf(testErrorFormat, alternatives, expect)
+		}
+
+		expect := &MatchResult{Path: "/posts/2020", Params: m{"year": "2020"},
+			Groups: []string{"/posts/2020", "", "", "", "", "2020"}}
+		if result, _ := matcher.Match("/posts/2020"); !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should bind the groups of a raw regexp", func(t *testing.T) {
+		groups := mustMatcher(regexp2.MustCompile(`^/(?<year>\d+)/(\w+)$`, regexp2.None), nil).GroupMap()
+		expect := []GroupBinding{{Token: Token{Name: 0}, Group: 1}, {Token: Token{Name: "year"}, Group: 2}}
+		if !reflect.DeepEqual(groups, expect) {
+			t.Errorf(testErrorFormat, groups, expect)
+		}
+	})
+
+	t.Run("should bind the groups numbered in order by the engine", func(t *testing.T) {
+		options := &Options{Engine: StdEngine{}}
+		matcher := mustMatcher(regexp.MustCompile(`^/(?P<year>\d+)/(\w+)$`), options)
+		expect := []GroupBinding{{Token: Token{Name: 0}, Group: 2}, {Token: Token{Name: "year"}, Group: 1}}
+		if groups := matcher.GroupMap(); !reflect.DeepEqual(groups, expect) {
+			t.Errorf(testErrorFormat, groups, expect)
+		}
+		result, err := matcher.Match("/2020/abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params := (m{0: "abc", "year": "2020"}); !reflect.DeepEqual(result.Params, map[interface{}]interface{}(params)) {
+			t.Errorf(testErrorFormat, result.Params, params)
+		}
+
+		matcher = mustMatcher([]interface{}{regexp.MustCompile(`^/x/(?P<year>\d+)/(\w+)$`), "/y/:id"}, options)
+		for pathname, params := range map[string]m{
+			"/x/2020/abc": {0: "abc", "year": "2020"},
+			"/y/5":        {"id": "5"},
+		} {
+			result, err := matcher.Match(pathname)
+			if err != nil || result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(params)) {
+				t.Errorf(testErrorFormat, result, params)
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/dlclark/regexp2"
+)
+
+// The version of the encoding hashed by RouteHash, bumped when it changes.
+const routeHashVersion = 1
+
+// RouteHash returns a stable hex digest of the route, computed from the
+// generated regexp source, its case sensitivity and the tokens (names,
+// patterns, prefixes, suffixes and modifiers). `Strict`, `Start`, `End`,
+// `Delimiter`, `EndsWith` and `Prefixes` are part of the source, so two
+// templates which generate the same regexp and tokens hash equal, e.g.
+// `/foo\\.bar` and `/foo.bar`.
+//
+// The functions of the options aren't hashed, only the effect of `Encode` on
+// the regexp is. The options which don't change the regexp, such as
+// `MatchTimeout`, are left out. A regexp path is hashed by its source, its
+// flags can't be read. The query of a template with `QueryParams` is hashed
+// as written, and the matrix params with `MatrixParams` by their keys and
+// values.
+func RouteHash(path interface{}, options *Options) (string, error) {
+	var tokens []Token
+	source, err := pathToSource(path, &tokens, options)
+	if err != nil {
+		return "", err
+	}
+
+	var b []byte
+	b = strconv.AppendInt(b, routeHashVersion, 10)
+	b = append(b, '\n')
+	if _, ok := path.(*regexp2.Regexp); !ok {
+		b = strconv.AppendBool(b, !ignoreCase(options))
+		if options != nil && options.RegexFlags != 0 {
+			b = append(b, ' ')
+			b = strconv.AppendInt(b, int64(flags(options)), 10)
+		}
+	}
+	b = append(b, '\n')
+	b = strconv.AppendQuote(b, source)
+	for _, token := range tokens {
+		b = append(b, '\n')
+		if index, ok := token.Index(); ok {
+			b = strconv.AppendInt(b, int64(index), 10)
+		} else if token.Matrix != nil {
+			b = append(b, ';')
+			for _, param := range token.Matrix.Params {
+				b = append(b, ' ')
+				b = strconv.AppendQuote(b, param.Key)
+				if param.Token == nil {
+					b = append(b, '=')
+					b = strconv.AppendQuote(b, param.Literal)
+					continue
+				}
+				b = append(b, ':')
+				b = strconv.AppendQuote(b, fmt.Sprintf("%v", param.Token.Name))
+				b = strconv.AppendQuote(b, param.Token.Pattern)
+				b = strconv.AppendQuote(b, param.Token.Modifier)
+			}
+		} else {
+			b = strconv.AppendQuote(b, token.Name.(string))
+		}
+		for _, field := range []string{token.Prefix, token.Suffix, token.Pattern, token.Modifier} {
+			b = append(b, ' ')
+			b = strconv.AppendQuote(b, field)
+		}
+	}
+	if template, ok := path.(string); ok && options != nil && options.QueryParams {
+		_, query, _ := splitQuery(template)
+		b = append(b, "\n?"...)
+		b = strconv.AppendQuote(b, query)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
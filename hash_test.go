@@ -0,0 +1,94 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+func TestRouteHash(t *testing.T) {
+	hash := func(path interface{}, options *Options) string {
+		h, err := RouteHash(path, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	t.Run("should be a stable hex digest", func(t *testing.T) {
+		h := hash("/user/:id", nil)
+		if len(h) != 64 {
+			t.Errorf(testErrorFormat, len(h), 64)
+		}
+		if expect := "8dd09c7c4cd69c1b8fd6a39bc8eb8aebc85447b115021c5f72332f7924a376b9"; h != expect {
+			t.Errorf(testErrorFormat, h, expect)
+		}
+	})
+
+	t.Run("should hash equivalent routes equal", func(t *testing.T) {
+		tests := [][2]interface{}{
+			{"/foo\\.bar", "/foo.bar"},
+			{"/:id", "/:id([^\\/#\\?]+?)"},
+			{[]string{"/a", "/a"}, []string{"/a"}},
+		}
+		for _, test := range tests {
+			if a, b := hash(test[0], nil), hash(test[1], nil); a != b {
+				t.Errorf("%s %s: "+testErrorFormat, inspect(test[0]), inspect(test[1]), a, b)
+			}
+		}
+
+		a := hash("/foo", &Options{Strict: true, MatchTimeout: time.Second, Decode: decodeURIComponent})
+		b := hash("/foo", &Options{Strict: true})
+		if a != b {
+			t.Errorf(testErrorFormat, a, b)
+		}
+		end := true
+		if a, b := hash("/foo", nil), hash("/foo", &Options{End: &end, Delimiter: "/#?"}); a != b {
+			t.Errorf(testErrorFormat, a, b)
+		}
+	})
+
+	t.Run("should hash different routes differently", func(t *testing.T) {
+		routes := []struct {
+			path    interface{}
+			options *Options
+		}{
+			{"/:id", nil},
+			{"/:name", nil},
+			{"/:id?", nil},
+			{"/:id(\\d+)", nil},
+			{"/(\\d+)", nil},
+			{"/:id", &Options{Sensitive: true}},
+			{"/:id", &Options{Strict: true}},
+			{"/:id", &Options{Start: &falseValue}},
+			{"/:id", &Options{End: &falseValue}},
+			{"/:id", &Options{Delimiter: "/"}},
+			{"/:id", &Options{EndsWith: "?"}},
+			{"/:id", &Options{Encode: func(str string, token interface{}) string { return str + "x" }}},
+			{[]string{"/:id"}, nil},
+			{regexp2.MustCompile("^\\/([^\\/#\\?]+?)[\\/#\\?]?$", regexp2.None), nil},
+		}
+		seen := map[string]int{}
+		for i, route := range routes {
+			h := hash(route.path, route.options)
+			if j, ok := seen[h]; ok {
+				t.Errorf("%d and %d: "+testErrorFormat, i, j, h, "different hashes")
+			}
+			seen[h] = i
+		}
+	})
+
+	t.Run("should return the error of the path", func(t *testing.T) {
+		if _, err := RouteHash("/:foo(", nil); err == nil {
+			t.Error("expect an error")
+		}
+		if _, err := RouteHash(1, nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
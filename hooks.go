@@ -0,0 +1,47 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"strings"
+	"time"
+)
+
+// Hooks are the callbacks of `Options.Hooks`, e.g. to record per-route
+// metrics. A nil callback is skipped. The callbacks are called synchronously,
+// possibly from several goroutines at once.
+type Hooks struct {
+	// OnMatch is called after each match of a pathname by the match function,
+	// or by a Matcher, including the matches of the routers, with the
+	// template, whether it matched and the duration of the match.
+	OnMatch func(template string, pathname string, matched bool, d time.Duration)
+
+	// OnBuild is called after each path built by the path function, with the
+	// template, the error of the path function and the duration of the build.
+	OnBuild func(template string, err error, d time.Duration)
+}
+
+// Returns the template of the matcher given to the hooks: the string template,
+// the templates of an array separated by `, `, or the source of the regexp.
+func (m *Matcher) hookTemplate() string {
+	if path, ok := m.path.(string); ok {
+		return path
+	}
+	if templates := pathTemplates(m.path); len(templates) > 0 {
+		return strings.Join(templates, ", ")
+	}
+	return m.source
+}
+
+// Returns the path function calling the OnBuild hook after each build.
+func buildHook(template string, toPath func(interface{}) (string, error),
+	onBuild func(string, error, time.Duration)) func(interface{}) (string, error) {
+	return func(data interface{}) (string, error) {
+		start := time.Now()
+		path, err := toPath(data)
+		onBuild(template, err, time.Since(start))
+		return path, err
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the calls of the hooks.
+type hookRecorder struct {
+	mu      sync.Mutex
+	matches []string
+	builds  []string
+}
+
+func (r *hookRecorder) hooks() *Hooks {
+	return &Hooks{
+		OnMatch: func(template, pathname string, matched bool, d time.Duration) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.matches = append(r.matches, template+" "+pathname+" "+inspect(matched))
+		},
+		OnBuild: func(template string, err error, d time.Duration) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.builds = append(r.builds, template+" "+inspect(err))
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("should call OnMatch after each match", func(t *testing.T) {
+		r := &hookRecorder{}
+		options := &Options{Hooks: r.hooks(), ErrorOnNoMatch: true}
+		match := MustMatch("/users/:id", options)
+		match("/users/1")
+		match("/posts/1")
+		match("/users/2")
+		MustMatch([]string{"/a", "/b/:id"}, options)("/b/2")
+		expect := []string{"/users/:id /users/1 true", "/users/:id /posts/1 false", "/users/:id /users/2 true",
+			"/a, /b/:id /b/2 true"}
+		if !reflect.DeepEqual(r.matches, expect) {
+			t.Errorf(testErrorFormat, r.matches, expect)
+		}
+	})
+
+	t.Run("should call OnMatch for the routes of a router", func(t *testing.T) {
+		r := &hookRecorder{}
+		s := NewRouteSet(InsertionOrder)
+		for _, template := range []string{"/users/:id", "/posts/:id"} {
+			if err := s.Add(template, template, &Options{Hooks: r.hooks()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Match("/posts/1")
+		expect := []string{"/users/:id /posts/1 false", "/posts/:id /posts/1 true"}
+		if !reflect.DeepEqual(r.matches, expect) {
+			t.Errorf(testErrorFormat, r.matches, expect)
+		}
+	})
+
+	t.Run("should call OnBuild after each build", func(t *testing.T) {
+		r := &hookRecorder{}
+		toPath := MustCompile("/users/:id(\\d+)", &Options{Hooks: r.hooks(), SelfCheck: true})
+		toPath(m{"id": 1})
+		toPath(m{"id": "a"})
+		expect := []string{"/users/:id(\\d+) <nil>", `/users/:id(\d+) expected "id" to match "\d+", but got "a"`}
+		if !reflect.DeepEqual(r.builds, expect) {
+			t.Errorf(testErrorFormat, r.builds, expect)
+		}
+		// The self check doesn't report its matches.
+		if len(r.matches) != 0 {
+			t.Errorf(testErrorFormat, r.matches, nil)
+		}
+	})
+
+	t.Run("should skip the nil callbacks", func(t *testing.T) {
+		options := &Options{Hooks: &Hooks{}}
+		if result, err := MustMatch("/users/:id", options)("/users/1"); err != nil || result == nil {
+			t.Errorf(testErrorFormat, result, "a match")
+		}
+		if path, err := MustCompile("/users/:id", options)(m{"id": 1}); err != nil || path != "/users/1" {
+			t.Errorf(testErrorFormat, path, "/users/1")
+		}
+	})
+}
+
+func BenchmarkHooks(b *testing.B) {
+	for _, test := range []struct {
+		name  string
+		hooks *Hooks
+	}{
+		{"unset", nil},
+		{"set", &Hooks{OnMatch: func(string, string, bool, time.Duration) {}}},
+	} {
+		matcher := mustMatcher("/api/:version/users/:id(\\d+)", &Options{Hooks: test.hooks})
+		b.Run(test.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matcher.Match("/api/v1/users/42")
+			}
+		})
+	}
+}
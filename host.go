@@ -0,0 +1,140 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewHostMatcher creates a Matcher of hostnames such as `:tenant.example.com`.
+// The template is matched with `.` as the only delimiter, case insensitively
+// and up to the end, and a trailing dot is allowed so that the fully
+// qualified `example.com.` matches as well. The other options are taken from
+// `extra`, which may be nil.
+//
+// The matched host is normalized first: its port is stripped, and it is
+// lower-cased, so the host of a request or its `Host` header can be given as
+// is.
+func NewHostMatcher(template string, extra *Options) (*Matcher, error) {
+	options := &Options{}
+	if extra != nil {
+		*options = *extra
+	}
+	options.Delimiter = "."
+	options.Sensitive = false
+	options.End = nil
+	options.Strict = false
+
+	m, err := NewMatcher(template, options)
+	if err != nil {
+		return nil, err
+	}
+	match := m.match
+	m.match = func(host string) (*MatchResult, error) {
+		return match(normalizeHost(host))
+	}
+	return m, nil
+}
+
+// Returns the lower-cased host without its port, nor the brackets of an IPv6
+// address.
+func normalizeHost(host string) string {
+	return strings.ToLower((&url.URL{Host: host}).Hostname())
+}
+
+// HostPathMatcher matches a host and a path with the halves of a template
+// such as `:tenant.example.com/api/:version`, see MatchHostPath.
+type HostPathMatcher struct {
+	host  *Matcher
+	path  *Matcher
+	hosts int
+}
+
+// MatchHostPath creates a HostPathMatcher from a template split on its first
+// `/`, the host being matched like NewHostMatcher and the path with the
+// options. A name used in both halves is an error, while the unnamed params
+// of the path are numbered after those of the host.
+func MatchHostPath(template string, options *Options) (*HostPathMatcher, error) {
+	i := strings.IndexByte(template, '/')
+	if i < 0 {
+		return nil, fmt.Errorf("missing path in %q", template)
+	}
+	if i == 0 {
+		return nil, fmt.Errorf("missing host in %q", template)
+	}
+
+	host, err := NewHostMatcher(template[:i], options)
+	if err != nil {
+		return nil, err
+	}
+	path, err := NewMatcher(template[i:], options)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := 0
+	names := map[string]bool{}
+	for _, token := range host.tokens {
+		if name, ok := token.Name.(string); ok {
+			names[name] = true
+		} else {
+			hosts++
+		}
+	}
+	for _, token := range path.tokens {
+		if name, ok := token.Name.(string); ok && names[name] {
+			return nil, fmt.Errorf("param %q is in both the host and the path of %q", name, template)
+		}
+	}
+
+	return &HostPathMatcher{host: host, path: path, hosts: hosts}, nil
+}
+
+// Match matches the host and the path, returning nil if either doesn't
+// match. The path of the result is the normalized host followed by the path,
+// and the params are those of both.
+func (m *HostPathMatcher) Match(host, path string) (*MatchResult, error) {
+	hostResult, err := m.host.Match(host)
+	if err != nil || hostResult == nil {
+		return nil, err
+	}
+	pathResult, err := m.path.Match(path)
+	if err != nil || pathResult == nil {
+		return nil, err
+	}
+
+	params := hostResult.Params
+	for key, value := range pathResult.Params {
+		if index, ok := key.(int); ok {
+			key = index + m.hosts
+		}
+		params[key] = value
+	}
+	// The groups of the path are numbered after those of the host, like the
+	// unnamed params.
+	groups := make([]string, 0, len(hostResult.Groups)+len(pathResult.Groups)-1)
+	groups = append(groups, hostResult.Path+pathResult.Path)
+	groups = append(groups, hostResult.Groups[1:]...)
+	groups = append(groups, pathResult.Groups[1:]...)
+	result := &MatchResult{
+		Path:   hostResult.Path + pathResult.Path,
+		Index:  hostResult.Index,
+		Params: params,
+		Groups: groups,
+	}
+	if hostResult.DecodedPath != "" && pathResult.DecodedPath != "" {
+		result.DecodedPath = hostResult.DecodedPath + pathResult.DecodedPath
+	}
+	return result, nil
+}
+
+// MatchRequest matches the host of the request, `r.Host`, and its escaped
+// path.
+func (m *HostPathMatcher) MatchRequest(r *http.Request) (*MatchResult, error) {
+	return m.Match(r.Host, r.URL.EscapedPath())
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNewHostMatcher(t *testing.T) {
+	tests := []struct {
+		template string
+		options  *Options
+		// host and expected result, nil meaning no match
+		matches [][2]interface{}
+	}{
+		{":tenant.example.com", nil, [][2]interface{}{
+			{"acme.example.com", &MatchResult{Path: "acme.example.com", Params: m{"tenant": "acme"},
+				Groups: []string{"acme.example.com", "acme"}}},
+			{"Acme.Example.COM", &MatchResult{Path: "acme.example.com", Params: m{"tenant": "acme"},
+				Groups: []string{"acme.example.com", "acme"}}},
+			{"acme.example.com:8080", &MatchResult{Path: "acme.example.com", Params: m{"tenant": "acme"},
+				Groups: []string{"acme.example.com", "acme"}}},
+			{"acme.example.com.", &MatchResult{Path: "acme.example.com.", Params: m{"tenant": "acme"},
+				Groups: []string{"acme.example.com.", "acme"}}},
+			{"acme.example.com.:443", &MatchResult{Path: "acme.example.com.", Params: m{"tenant": "acme"},
+				Groups: []string{"acme.example.com.", "acme"}}},
+			{"example.com", nil},
+			{"a.b.example.com", nil},
+			{"acme.example.com.evil.org", nil},
+			{"acme.example.community", nil},
+		}},
+		{"api.example.com", nil, [][2]interface{}{
+			{"API.example.com:80", &MatchResult{Path: "api.example.com", Params: m{},
+				Groups: []string{"api.example.com"}}},
+			{"api.example.com.", &MatchResult{Path: "api.example.com.", Params: m{},
+				Groups: []string{"api.example.com."}}},
+			{"api.example.com..", nil},
+		}},
+		{"\\:\\:1", nil, [][2]interface{}{
+			{"[::1]:8080", &MatchResult{Path: "::1", Params: m{}, Groups: []string{"::1"}}},
+		}},
+		// The preset wins over the extra options.
+		{":tenant.example.com", &Options{Sensitive: true, Strict: true, End: &falseValue, Delimiter: "/",
+			Decode: func(str string, token interface{}) (string, error) { return "<" + str + ">", nil }},
+			[][2]interface{}{
+				{"ACME.example.com.", &MatchResult{Path: "acme.example.com.", Params: m{"tenant": "<acme>"},
+					DecodedPath: "<acme.example.com.>", Groups: []string{"acme.example.com.", "acme"}}},
+				{"acme.example.com.org", nil},
+			}},
+	}
+
+	for _, test := range tests {
+		matcher, err := NewHostMatcher(test.template, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range test.matches {
+			result, err := matcher.Match(v[0].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect, _ := v[1].(*MatchResult)
+			if (result == nil) != (expect == nil) || (result != nil && !reflect.DeepEqual(result, expect)) {
+				t.Errorf("%s %s: "+testErrorFormat, test.template, v[0], result, expect)
+			}
+		}
+	}
+
+	t.Run("should return the parse error", func(t *testing.T) {
+		if _, err := NewHostMatcher(":foo(", nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
+
+func TestMatchHostPath(t *testing.T) {
+	matcher, err := MatchHostPath(":tenant.example.com/api/:version/users/:id", &Options{Decode: decodeURIComponent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should merge the params of both halves", func(t *testing.T) {
+		tests := []struct {
+			host, path string
+			expect     *MatchResult
+		}{
+			{"acme.example.com", "/api/v1/users/42", &MatchResult{Path: "acme.example.com/api/v1/users/42",
+				Params: m{"tenant": "acme", "version": "v1", "id": "42"}, DecodedPath: "acme.example.com/api/v1/users/42",
+				Groups: []string{"acme.example.com/api/v1/users/42", "acme", "v1", "42"}}},
+			{"ACME.example.com:8443", "/api/v1/users/a%20b/", &MatchResult{Path: "acme.example.com/api/v1/users/a%20b/",
+				Params: m{"tenant": "acme", "version": "v1", "id": "a b"}, DecodedPath: "acme.example.com/api/v1/users/a b/",
+				Groups: []string{"acme.example.com/api/v1/users/a%20b/", "acme", "v1", "a%20b"}}},
+			{"example.com", "/api/v1/users/42", nil},
+			{"acme.example.com", "/api/v1/posts/42", nil},
+		}
+		for _, test := range tests {
+			result, err := matcher.Match(test.host, test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Errorf("%s %s: "+testErrorFormat, test.host, test.path, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should match a request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://acme.example.com:8080/api/v2/users/a%2Fb", nil)
+		result, err := matcher.MatchRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "acme.example.com/api/v2/users/a%2Fb",
+			Params: m{"tenant": "acme", "version": "v2", "id": "a/b"}, DecodedPath: "acme.example.com/api/v2/users/a/b",
+			Groups: []string{"acme.example.com/api/v2/users/a%2Fb", "acme", "v2", "a%2Fb"}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should number the unnamed params of the path after the host", func(t *testing.T) {
+		matcher, err := MatchHostPath("(\\w+).example.com/files/(.*)", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := matcher.Match("cdn.example.com", "/files/a/b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := (m{0: "cdn", 1: "a/b"}); result == nil || !reflect.DeepEqual(m(result.Params), expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should return an error", func(t *testing.T) {
+		tests := [][2]string{
+			{":id.example.com/users/:id", `param "id" is in both the host and the path of ":id.example.com/users/:id"`},
+			{"example.com", `missing path in "example.com"`},
+			{"/users", `missing host in "/users"`},
+			{":foo(.example.com/", "unbalanced pattern at 4"},
+		}
+		for _, test := range tests {
+			_, err := MatchHostPath(test[0], nil)
+			if err == nil || err.Error() != test[1] {
+				t.Errorf(testErrorFormat, err, test[1])
+			}
+		}
+	})
+}
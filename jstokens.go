@@ -0,0 +1,190 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// JSVersion is a major version of the JavaScript path-to-regexp, whose
+// `parse()` output is emitted by ToJSTokensVersion.
+type JSVersion int
+
+// The supported versions of the JavaScript path-to-regexp.
+const (
+	// Strings for the literals and objects with the name, prefix, suffix,
+	// pattern and modifier of the parameters, the shape of this package.
+	JSv6 JSVersion = 6
+
+	// A `TokenData` object whose tokens are `text`, `param`, `wildcard` and
+	// `group` objects.
+	JSv8 JSVersion = 8
+)
+
+type jsToken struct {
+	Name     interface{} `json:"name"`
+	Prefix   string      `json:"prefix"`
+	Suffix   string      `json:"suffix"`
+	Pattern  string      `json:"pattern"`
+	Modifier string      `json:"modifier"`
+}
+
+type jsTokenData struct {
+	Tokens []interface{} `json:"tokens"`
+}
+
+type jsText struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type jsParam struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type jsGroup struct {
+	Type   string        `json:"type"`
+	Tokens []interface{} `json:"tokens"`
+}
+
+// ToJSTokens returns the JSON of the tokens of the path in the shape returned
+// by `parse()` of the JavaScript path-to-regexp v6, see ToJSTokensVersion.
+func ToJSTokens(path string, options *Options) ([]byte, error) {
+	return ToJSTokensVersion(path, options, JSv6)
+}
+
+// ToJSTokensVersion returns the JSON of the tokens of the path in the shape
+// returned by `parse()` of the version of the JavaScript path-to-regexp, as
+// `JSON.stringify` writes it.
+//
+// The syntax of v8 is less expressive, so the parameters are translated to
+// their closest v8 tokens: a parameter is a `param`, a repeated one a
+// `wildcard`, and an optional one is wrapped in a `group` with its prefix and
+// suffix. Unnamed parameters and parameters with a custom pattern can't be
+// represented and return an error, as well as the matrix params of both
+// versions.
+func ToJSTokensVersion(path string, options *Options, version JSVersion) ([]byte, error) {
+	if options != nil && options.MatrixParams {
+		return nil, errors.New("matrix params can't be represented")
+	}
+	if options != nil && options.Compat != CompatNone {
+		return nil, errors.New("compat templates can't be represented")
+	}
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch version {
+	case JSv6:
+		value, err = jsV6Tokens(tokens), nil
+	case JSv8:
+		value, err = jsV8Tokens(tokens, options)
+	default:
+		return nil, fmt.Errorf("unsupported path-to-regexp version %d", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// `JSON.stringify` doesn't escape the HTML characters.
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+func jsV6Tokens(tokens []interface{}) []interface{} {
+	result := make([]interface{}, len(tokens))
+	for i, token := range tokens {
+		if token, ok := token.(Token); ok {
+			result[i] = jsToken{Name: token.Name, Prefix: token.Prefix, Suffix: token.Suffix,
+				Pattern: token.Pattern, Modifier: token.Modifier}
+			continue
+		}
+		result[i] = token
+	}
+	return result
+}
+
+func jsV8Tokens(tokens []interface{}, options *Options) (*jsTokenData, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	defaultPattern := paramPattern(options)
+
+	var result []interface{}
+	for _, token := range tokens {
+		if str, ok := token.(string); ok {
+			result = appendJSText(result, str)
+			continue
+		}
+
+		token := token.(Token)
+		if token.Pattern == "" {
+			// A group without parameter, e.g. `{-}?`.
+			switch token.Modifier {
+			case "":
+				result = appendJSText(result, token.Prefix+token.Suffix)
+			case "?":
+				result = append(result, jsGroup{Type: "group", Tokens: appendJSText(nil, token.Prefix+token.Suffix)})
+			default:
+				return nil, fmt.Errorf("repeated group %q can't be represented in v8", token.Prefix+token.Suffix)
+			}
+			continue
+		}
+
+		name, ok := token.Name.(string)
+		if !ok {
+			return nil, fmt.Errorf("unnamed parameter %v can't be represented in v8", token.Name)
+		}
+		if token.Pattern != defaultPattern {
+			return nil, fmt.Errorf("parameter %q with a custom pattern can't be represented in v8", name)
+		}
+
+		param := jsParam{Type: "param", Name: name}
+		if token.Modifier == "+" || token.Modifier == "*" {
+			param.Type = "wildcard"
+		}
+		parts := appendJSText(nil, token.Prefix)
+		parts = append(parts, param)
+		parts = appendJSText(parts, token.Suffix)
+
+		if token.Modifier == "?" || token.Modifier == "*" {
+			result = append(result, jsGroup{Type: "group", Tokens: parts})
+		} else {
+			result = appendJSText(result, token.Prefix)
+			result = append(result, param)
+			result = appendJSText(result, token.Suffix)
+		}
+	}
+
+	if result == nil {
+		result = []interface{}{}
+	}
+	return &jsTokenData{Tokens: result}, nil
+}
+
+// Appends a text token, merged with the previous one as the v8 parser does.
+func appendJSText(tokens []interface{}, str string) []interface{} {
+	if str == "" {
+		return tokens
+	}
+	if last := len(tokens) - 1; last >= 0 {
+		if text, ok := tokens[last].(jsText); ok {
+			tokens[last] = jsText{Type: "text", Value: text.Value + str}
+			return tokens
+		}
+	}
+	return append(tokens, jsText{Type: "text", Value: str})
+}
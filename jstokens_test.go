@@ -0,0 +1,91 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestToJSTokens(t *testing.T) {
+	// The outputs of `JSON.stringify(parse(path))` of the JavaScript library,
+	// the v8 ones being missing for the paths it can't represent.
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "jstokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var golden []struct {
+		Path string          `json:"path"`
+		V6   json.RawMessage `json:"v6"`
+		V8   json.RawMessage `json:"v8"`
+	}
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key order of the objects differs between the tokens in JavaScript.
+	equalJSON := func(a, b []byte) bool {
+		var x, y interface{}
+		return json.Unmarshal(a, &x) == nil && json.Unmarshal(b, &y) == nil && reflect.DeepEqual(x, y)
+	}
+
+	t.Run("should match the v6 outputs", func(t *testing.T) {
+		for _, test := range golden {
+			result, err := ToJSTokens(test.Path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(result, test.V6) {
+				t.Errorf("%s: "+testErrorFormat, test.Path, string(result), string(test.V6))
+			}
+		}
+	})
+
+	t.Run("should match the v8 outputs", func(t *testing.T) {
+		for _, test := range golden {
+			result, err := ToJSTokensVersion(test.Path, nil, JSv8)
+			if test.V8 == nil {
+				if err == nil {
+					t.Errorf("%s: "+testErrorFormat, test.Path, string(result), "an error")
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(result, test.V8) {
+				t.Errorf("%s: "+testErrorFormat, test.Path, string(result), string(test.V8))
+			}
+		}
+	})
+
+	t.Run("should write like JSON.stringify", func(t *testing.T) {
+		result, err := ToJSTokens("/a&b<c>/:id", &Options{Delimiter: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := `["/a&b<c>",{"name":"id","prefix":"/","suffix":"","pattern":"[^\\/]+?","modifier":""}]`
+		if string(result) != expect {
+			t.Errorf(testErrorFormat, string(result), expect)
+		}
+
+		result, err = ToJSTokensVersion("", nil, JSv8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := `{"tokens":[]}`; string(result) != expect {
+			t.Errorf(testErrorFormat, string(result), expect)
+		}
+	})
+
+	t.Run("should reject an unsupported version", func(t *testing.T) {
+		if _, err := ToJSTokensVersion("/", nil, JSVersion(7)); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// LazyRoutes is a collection of routes which are compiled on first use, for
+// the route tables of which only a few routes ever match. It is safe for
+// concurrent use.
+type LazyRoutes struct {
+	options  *Options
+	routes   []*lazyRoute
+	compiled int64
+}
+
+type lazyRoute struct {
+	template string
+	// the literal text the pathname must start with, see lazyPrefix
+	prefix  string
+	once    sync.Once
+	matcher *Matcher
+	err     error
+}
+
+// NewLazyRoutes creates a LazyRoutes of the templates, which aren't parsed
+// until Match or Validate.
+func NewLazyRoutes(templates []string, options *Options) *LazyRoutes {
+	r := &LazyRoutes{options: options, routes: make([]*lazyRoute, len(templates))}
+	for i, template := range templates {
+		r.routes[i] = &lazyRoute{template: template, prefix: lazyPrefix(template, options)}
+	}
+	return r
+}
+
+// Match returns the index and the result of the first route matching the
+// pathname, or -1 if none matches. The routes are compiled when they're
+// first tried, the routes whose static beginning the pathname doesn't start
+// with being skipped without compiling them. A *CompileError is returned
+// for a route which fails to compile, each time it's tried.
+func (r *LazyRoutes) Match(pathname string) (int, *MatchResult, error) {
+	sensitive := r.options != nil && r.options.Sensitive
+	for i, route := range r.routes {
+		if len(pathname) < len(route.prefix) {
+			continue
+		}
+		if start := pathname[:len(route.prefix)]; start != route.prefix &&
+			(sensitive || !strings.EqualFold(start, route.prefix)) {
+			continue
+		}
+
+		route.once.Do(func() {
+			route.matcher, route.err = NewMatcher(route.template, r.options)
+			if route.err == nil {
+				atomic.AddInt64(&r.compiled, 1)
+			}
+		})
+		if route.err != nil {
+			return -1, nil, &CompileError{Index: i, Path: route.template, Err: route.err}
+		}
+		result, err := route.matcher.find(pathname)
+		if err != nil || result != nil {
+			return i, result, err
+		}
+	}
+	return -1, nil, nil
+}
+
+// Validate parses all the templates without compiling them, returning a
+// CompileErrors of the templates which fail to parse.
+func (r *LazyRoutes) Validate() error {
+	var errs CompileErrors
+	for i, route := range r.routes {
+		if _, err := Parse(route.template, r.options); err != nil {
+			errs = append(errs, &CompileError{Index: i, Path: route.template, Err: err})
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// CompiledCount returns the number of routes which have been compiled.
+func (r *LazyRoutes) CompiledCount() int {
+	return int(atomic.LoadInt64(&r.compiled))
+}
+
+// Len returns the number of routes.
+func (r *LazyRoutes) Len() int {
+	return len(r.routes)
+}
+
+// Returns the literal text every pathname matching the template starts with,
+// which is the text before the first template syntax character, without its
+// last character as it may be the prefix of an optional param. It's empty
+// when the options change how the literals are matched.
+func lazyPrefix(template string, options *Options) string {
+	if options != nil && (options.Encode != nil || options.Encoding != EncodingNone ||
+		options.Compat != CompatNone || (options.Start != nil && !*options.Start)) {
+		return ""
+	}
+	end := strings.IndexAny(template, `:(){}*+?\`)
+	if end < 0 {
+		end = len(template)
+	}
+	prefix := template[:end]
+	if end < len(template) {
+		_, size := utf8.DecodeLastRuneInString(prefix)
+		prefix = prefix[:len(prefix)-size]
+	}
+	if options == nil || !options.Sensitive {
+		// Case folding may change the length of the other characters.
+		for i := 0; i < len(prefix); i++ {
+			if prefix[i] >= utf8.RuneSelf {
+				return prefix[:i]
+			}
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestLazyRoutes(t *testing.T) {
+	t.Run("should compile the routes on first use", func(t *testing.T) {
+		r := NewLazyRoutes([]string{"/users/:id", "/posts/:id", "/posts/:id/comments", "/healthz"}, nil)
+		if r.CompiledCount() != 0 || r.Len() != 4 {
+			t.Errorf(testErrorFormat, r.CompiledCount(), 0)
+		}
+
+		index, result, err := r.Match("/Posts/1/comments")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/Posts/1/comments", Params: m{"id": "1"}, Groups: []string{"/Posts/1/comments", "1"}}
+		if index != 2 || !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+		// The users route is skipped by its static beginning.
+		if r.CompiledCount() != 2 {
+			t.Errorf(testErrorFormat, r.CompiledCount(), 2)
+		}
+
+		if index, result, err := r.Match("/posts/2"); err != nil || index != 1 || result.Params["id"] != "2" {
+			t.Errorf(testErrorFormat, result, "/posts/2")
+		}
+		if index, result, err := r.Match("/missing"); err != nil || index != -1 || result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+		if r.CompiledCount() != 2 {
+			t.Errorf(testErrorFormat, r.CompiledCount(), 2)
+		}
+	})
+
+	t.Run("should respect the case sensitivity", func(t *testing.T) {
+		r := NewLazyRoutes([]string{"/Users/:id?"}, &Options{Sensitive: true})
+		if _, result, _ := r.Match("/users/1"); result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+		if _, result, _ := r.Match("/Users"); result == nil {
+			t.Errorf(testErrorFormat, result, "/Users")
+		}
+	})
+
+	t.Run("should surface the errors on first use", func(t *testing.T) {
+		r := NewLazyRoutes([]string{"/a", "/b/:foo(", "/b/:id"}, nil)
+		if index, _, err := r.Match("/a"); err != nil || index != 0 {
+			t.Errorf(testErrorFormat, err, nil)
+		}
+		for i := 0; i < 2; i++ {
+			_, _, err := r.Match("/b/1")
+			compileErr, ok := err.(*CompileError)
+			if !ok || compileErr.Index != 1 || compileErr.Path != "/b/:foo(" {
+				t.Errorf(testErrorFormat, err, "a *CompileError")
+			}
+		}
+	})
+
+	t.Run("should validate the templates", func(t *testing.T) {
+		r := NewLazyRoutes([]string{"/a", "/:foo(", "/b", "/:"}, nil)
+		errs, ok := r.Validate().(CompileErrors)
+		if !ok || len(errs) != 2 || errs[0].Index != 1 || errs[1].Index != 3 {
+			t.Errorf(testErrorFormat, errs, "2 errors")
+		}
+		if r.CompiledCount() != 0 {
+			t.Errorf(testErrorFormat, r.CompiledCount(), 0)
+		}
+		if err := NewLazyRoutes([]string{"/a"}, nil).Validate(); err != nil {
+			t.Errorf(testErrorFormat, err, nil)
+		}
+	})
+
+	t.Run("should compile a route once when used concurrently", func(t *testing.T) {
+		templates := make([]string, 100)
+		for i := range templates {
+			templates[i] = "/tenant" + strconv.Itoa(i) + "/users/:id"
+		}
+		r := NewLazyRoutes(templates, nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					index, result, err := r.Match("/tenant42/users/" + strconv.Itoa(j))
+					if err != nil || index != 42 || result == nil {
+						t.Errorf(testErrorFormat, result, 42)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if r.CompiledCount() != 1 {
+			t.Errorf(testErrorFormat, r.CompiledCount(), 1)
+		}
+	})
+}
+
+func TestLazyPrefix(t *testing.T) {
+	tests := []struct {
+		template string
+		options  *Options
+		expect   string
+	}{
+		{"/users/:id", nil, "/users"},
+		{"/users/:id?", nil, "/users"},
+		{"/users{/:id}?", nil, "/user"},
+		{"/healthz", nil, "/healthz"},
+		{":tenant.example.com", nil, ""},
+		{"/café/:id", nil, "/caf"},
+		{"/café/:id", &Options{Sensitive: true}, "/café"},
+		{"/users/:id", &Options{Encoding: EncodingURIComponent}, ""},
+		{"/users/:id", &Options{Start: &falseValue}, ""},
+	}
+	for _, test := range tests {
+		if prefix := lazyPrefix(test.template, test.options); prefix != test.expect {
+			t.Errorf(testErrorFormat, prefix, test.expect)
+		}
+	}
+}
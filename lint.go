@@ -0,0 +1,629 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Warning describes a potential problem found by CheckPattern or Lint.
+type Warning struct {
+	// The rule which produced the warning (e.g. `nested-quantifier`)
+	Rule string
+
+	// The name of the token the warning applies to, nil when produced by CheckPattern
+	Token interface{}
+
+	// The index in the pattern at which the problem starts, or the index of the
+	// token in the parsed template for `ambiguous-repeat`
+	Index int
+
+	// A human readable description of the problem
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Token != nil {
+		return fmt.Sprintf("%s: %s at %d of \"%v\"", w.Rule, w.Message, w.Index, w.Token)
+	}
+	return fmt.Sprintf("%s: %s at %d", w.Rule, w.Message, w.Index)
+}
+
+// Lint parses the path and checks the pattern of every token with
+// CheckPattern, the returned warnings carry the name of their token. The
+// repeated tokens whose values can't be split apart when matching are
+// reported as `ambiguous-repeat`: the ones without a separator, e.g. `{:a}+`,
+// and the ones whose pattern matches the separator, e.g. `/:a(.*)+`.
+func Lint(path string, options *Options) ([]Warning, error) {
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []Warning
+	for _, token := range tokens {
+		if token, ok := token.(Token); ok {
+			for _, w := range CheckPattern(token.Pattern) {
+				w.Token = token.Name
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	return append(warnings, ambiguousRepeats(tokens, options)...), nil
+}
+
+// Returns the `ambiguous-repeat` warnings of the repeated tokens, whose
+// separator, see repeatSeparator, is empty or matched by their pattern.
+func ambiguousRepeats(tokens []interface{}, options *Options) []Warning {
+	var warnings []Warning
+	for i, token := range tokens {
+		token, ok := token.(Token)
+		if !ok || token.Pattern == "" || !isRepeat(token) {
+			continue
+		}
+		if token.Matrix != nil {
+			continue
+		}
+		sep := repeatSeparator(token, options)
+		if sep == "" {
+			warnings = append(warnings, Warning{Rule: "ambiguous-repeat", Token: token.Name, Index: i,
+				Message: "repeated values without a separator can't be split"})
+			continue
+		}
+		v := &validator{source: "^(?:" + tokenPattern(token, options) + ")$", options: options}
+		if ok, err := v.MatchString(sep); ok || err != nil {
+			warnings = append(warnings, Warning{Rule: "ambiguous-repeat", Token: token.Name, Index: i,
+				Message: fmt.Sprintf("repeated values of a pattern matching the separator %q can't be split", sep)})
+		}
+	}
+	return warnings
+}
+
+// CheckPattern statically checks a token pattern for shapes which are known
+// to cause catastrophic backtracking:
+//
+//   - `nested-quantifier`: an unbounded quantifier applied to an expression
+//     which itself contains an unbounded quantifier that isn't separated from
+//     the next iteration by other characters, e.g. `(?:a+)+` but not `(?:a+-)+`
+//   - `overlapping-alternation`: an unbounded quantifier applied to an
+//     alternation whose branches can start with the same character, e.g. `(?:a|aa)+`
+//   - `adjacent-unbounded`: two adjacent unbounded expressions which can match
+//     the same characters, e.g. `.*\d+`
+//
+// The check is a heuristic, not a prover: it is advisory, can report patterns
+// which are harmless in practice and can miss dangerous ones. Use
+// `Options.MatchTimeout` to bound the execution of untrusted patterns.
+func CheckPattern(pattern string) []Warning {
+	p := &patternParser{arr: []rune(pattern)}
+
+	var warnings []Warning
+	for {
+		checkSequence(p.parseSequence(), &warnings)
+		if p.i >= len(p.arr) {
+			break
+		}
+		p.i++ // `|` or an unbalanced `)`
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Index < warnings[j].Index
+	})
+	return warnings
+}
+
+func checkSequence(nodes []*patternNode, warnings *[]Warning) {
+	for i, node := range nodes {
+		unbounded := node.max < 0
+
+		if node.alts != nil {
+			if unbounded {
+				if inner := findUnbounded(node.alts); inner != nil {
+					*warnings = append(*warnings, Warning{
+						Rule:    "nested-quantifier",
+						Index:   node.index,
+						Message: fmt.Sprintf("unbounded quantifier applied to an expression with the unbounded quantifier at %d", inner.index),
+					})
+				}
+				if overlappingAlternatives(node.alts) {
+					*warnings = append(*warnings, Warning{
+						Rule:    "overlapping-alternation",
+						Index:   node.index,
+						Message: "unbounded quantifier applied to alternatives which can match the same text",
+					})
+				}
+			}
+			for _, alt := range node.alts {
+				checkSequence(alt, warnings)
+			}
+		}
+
+		if unbounded && i+1 < len(nodes) {
+			next := nodes[i+1]
+			if next.max < 0 && (node.chars.isAny() || next.chars.isAny()) &&
+				node.chars.intersects(next.chars) {
+				*warnings = append(*warnings, Warning{
+					Rule:    "adjacent-unbounded",
+					Index:   node.index,
+					Message: fmt.Sprintf("unbounded expression followed by the overlapping unbounded expression at %d", next.index),
+				})
+			}
+		}
+	}
+}
+
+// Returns the first unbounded node of the alternatives of an unbounded group
+// which can be followed by the same characters it consumes, either by the rest
+// of its alternative or by the next iteration of the group. Bounded groups
+// containing unbounded nodes are treated as unbounded themselves.
+func findUnbounded(alts [][]*patternNode) *patternNode {
+	var start charSet
+	for _, alt := range alts {
+		first, _ := firstChars(alt)
+		start = start.union(first)
+	}
+
+	for _, alt := range alts {
+		for i, node := range alt {
+			inner := node
+			if node.max >= 0 {
+				if node.alts == nil {
+					continue
+				}
+				if inner = findAnyUnbounded(node.alts); inner == nil {
+					continue
+				}
+			}
+
+			follow, nullable := firstChars(alt[i+1:])
+			if nullable {
+				follow = follow.union(start)
+			}
+			if node.chars.intersects(follow) {
+				return inner
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the first node with an unbounded quantifier found in the alternatives.
+func findAnyUnbounded(alts [][]*patternNode) *patternNode {
+	for _, alt := range alts {
+		for _, node := range alt {
+			if node.max < 0 {
+				return node
+			}
+			if node.alts != nil {
+				if inner := findAnyUnbounded(node.alts); inner != nil {
+					return inner
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Reports whether two alternatives can start with the same character, an
+// alternative which can match the empty string overlaps with every other.
+func overlappingAlternatives(alts [][]*patternNode) bool {
+	if len(alts) < 2 {
+		return false
+	}
+
+	firsts := make([]charSet, len(alts))
+	for i, alt := range alts {
+		first, nullable := firstChars(alt)
+		if nullable {
+			return true
+		}
+		firsts[i] = first
+	}
+
+	for i := 0; i < len(firsts); i++ {
+		for j := i + 1; j < len(firsts); j++ {
+			if firsts[i].intersects(firsts[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the characters a sequence can start with and whether it can match
+// the empty string.
+func firstChars(nodes []*patternNode) (charSet, bool) {
+	var result charSet
+	for _, node := range nodes {
+		if node.zeroWidth {
+			continue
+		}
+
+		first := node.chars
+		nullable := node.min == 0
+		if node.alts != nil {
+			first = charSet{}
+			altNullable := false
+			for _, alt := range node.alts {
+				f, n := firstChars(alt)
+				first = first.union(f)
+				altNullable = altNullable || n
+			}
+			nullable = nullable || altNullable
+		}
+
+		result = result.union(first)
+		if !nullable {
+			return result, false
+		}
+	}
+	return result, true
+}
+
+// A node of a parsed pattern, either a single character matcher or a group.
+type patternNode struct {
+	// The index of the node in the pattern
+	index int
+
+	// All characters the node can consume
+	chars charSet
+
+	// The alternatives of a group, nil for other nodes
+	alts [][]*patternNode
+
+	// Lookarounds and anchors don't consume characters
+	zeroWidth bool
+
+	// The quantifier bounds, max is -1 when unbounded
+	min, max int
+}
+
+type patternParser struct {
+	arr []rune
+	i   int
+}
+
+func (p *patternParser) parseSequence() []*patternNode {
+	var nodes []*patternNode
+	for p.i < len(p.arr) {
+		char := p.arr[p.i]
+		if char == '|' || char == ')' {
+			break
+		}
+
+		node := &patternNode{index: p.i, min: 1, max: 1}
+		switch char {
+		case '(':
+			p.parseGroup(node)
+		case '[':
+			node.chars = p.parseClass()
+		case '\\':
+			node.chars, node.zeroWidth = p.parseEscape(false)
+		case '.':
+			node.chars = anyChars
+			p.i++
+		case '^', '$':
+			node.zeroWidth = true
+			p.i++
+		default:
+			node.chars = charSet{ranges: [][2]rune{{char, char}}}
+			p.i++
+		}
+
+		p.parseQuantifier(node)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (p *patternParser) parseGroup(node *patternNode) {
+	p.i++
+	if p.i < len(p.arr) && p.arr[p.i] == '?' {
+		p.i++
+		if p.i < len(p.arr) {
+			switch p.arr[p.i] {
+			case '=', '!':
+				node.zeroWidth = true
+				p.i++
+			case '<':
+				if p.i+1 < len(p.arr) && (p.arr[p.i+1] == '=' || p.arr[p.i+1] == '!') {
+					node.zeroWidth = true
+					p.i += 2
+				} else {
+					for p.i < len(p.arr) && p.arr[p.i] != '>' {
+						p.i++
+					}
+					p.i++
+				}
+			default:
+				// non-capturing groups, named groups and inline flags
+				for p.i < len(p.arr) && p.arr[p.i] != ':' && p.arr[p.i] != ')' {
+					p.i++
+				}
+				if p.i < len(p.arr) && p.arr[p.i] == ':' {
+					p.i++
+				}
+			}
+		}
+	}
+
+	alts := [][]*patternNode{p.parseSequence()}
+	for p.i < len(p.arr) && p.arr[p.i] == '|' {
+		p.i++
+		alts = append(alts, p.parseSequence())
+	}
+	if p.i < len(p.arr) {
+		p.i++ // `)`
+	}
+
+	if node.zeroWidth {
+		return
+	}
+	node.alts = alts
+	for _, alt := range alts {
+		for _, n := range alt {
+			node.chars = node.chars.union(n.chars)
+		}
+	}
+}
+
+func (p *patternParser) parseClass() charSet {
+	p.i++
+	result, negated := charSet{}, false
+	if p.i < len(p.arr) && p.arr[p.i] == '^' {
+		negated = true
+		p.i++
+	}
+
+	first := true
+	for p.i < len(p.arr) && (p.arr[p.i] != ']' || first) {
+		first = false
+		lo := p.arr[p.i]
+		if lo == '\\' && p.i+1 < len(p.arr) {
+			chars, _ := p.parseEscape(true)
+			if len(chars.ranges) != 1 || chars.ranges[0][0] != chars.ranges[0][1] || chars.negated {
+				result = result.union(chars)
+				continue
+			}
+			lo = chars.ranges[0][0]
+		} else {
+			p.i++
+		}
+
+		hi := lo
+		if p.i+1 < len(p.arr) && p.arr[p.i] == '-' && p.arr[p.i+1] != ']' {
+			hi = p.arr[p.i+1]
+			p.i += 2
+			if hi == '\\' && p.i < len(p.arr) {
+				hi = p.arr[p.i]
+				p.i++
+			}
+		}
+		result = result.union(charSet{ranges: [][2]rune{{lo, hi}}})
+	}
+	p.i++ // `]`
+
+	if negated {
+		if result.negated {
+			return anyChars
+		}
+		return charSet{negated: true, ranges: result.ranges}
+	}
+	return result
+}
+
+func (p *patternParser) parseQuantifier(node *patternNode) {
+	if p.i >= len(p.arr) {
+		return
+	}
+
+	switch p.arr[p.i] {
+	case '*':
+		node.min, node.max = 0, -1
+		p.i++
+	case '+':
+		node.min, node.max = 1, -1
+		p.i++
+	case '?':
+		node.min, node.max = 0, 1
+		p.i++
+	case '{':
+		j, min, max, hasComma, digits := p.i+1, 0, 0, false, 0
+		for ; j < len(p.arr) && p.arr[j] != '}'; j++ {
+			c := p.arr[j]
+			if c == ',' && !hasComma {
+				hasComma, digits = true, 0
+				continue
+			}
+			if c < '0' || c > '9' {
+				return // a literal `{`
+			}
+			if hasComma {
+				max = max*10 + int(c-'0')
+			} else {
+				min = min*10 + int(c-'0')
+			}
+			digits++
+		}
+		if j >= len(p.arr) {
+			return
+		}
+		node.min, node.max = min, min
+		if hasComma {
+			node.max = max
+			if digits == 0 {
+				node.max = -1
+			}
+		}
+		p.i = j + 1
+	default:
+		return
+	}
+
+	// lazy quantifiers backtrack the same way
+	if p.i < len(p.arr) && p.arr[p.i] == '?' {
+		p.i++
+	}
+}
+
+// Consumes an escape sequence, returning the characters it matches and
+// whether it is zero width (e.g. `\b`).
+func (p *patternParser) parseEscape(inClass bool) (charSet, bool) {
+	p.i++
+	if p.i >= len(p.arr) {
+		return charSet{}, false
+	}
+	char := p.arr[p.i]
+	p.i++
+
+	switch char {
+	case 'd':
+		return digitChars, false
+	case 'w':
+		return wordChars, false
+	case 's':
+		return spaceChars, false
+	case 'D', 'W', 'S':
+		return anyChars, false
+	case 'p', 'P':
+		if p.i < len(p.arr) && p.arr[p.i] == '{' {
+			for p.i < len(p.arr) && p.arr[p.i] != '}' {
+				p.i++
+			}
+			p.i++
+		}
+		if char == 'P' {
+			return anyChars, false
+		}
+		return unicodeChars, false
+	case 'x', 'u':
+		code, n, size := rune(0), 0, 2
+		if char == 'u' {
+			size = 4
+		}
+		for ; n < size && p.i < len(p.arr) && isHexDigit(p.arr[p.i]); n++ {
+			code = code*16 + hexValue(p.arr[p.i])
+			p.i++
+		}
+		if n > 0 {
+			char = code
+		}
+	case 'b', 'B', 'A', 'z', 'Z', 'G':
+		if !inClass {
+			return charSet{}, true
+		}
+	case 'n':
+		char = '\n'
+	case 't':
+		char = '\t'
+	case 'r':
+		char = '\r'
+	}
+	return charSet{ranges: [][2]rune{{char, char}}}, false
+}
+
+func isHexDigit(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c rune) rune {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// A set of characters described by ranges, or by the ranges it excludes when
+// negated.
+type charSet struct {
+	negated bool
+	ranges  [][2]rune
+}
+
+var (
+	anyChars   = charSet{negated: true}
+	digitChars = charSet{ranges: [][2]rune{{'0', '9'}}}
+	wordChars  = charSet{ranges: [][2]rune{{'0', '9'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}}}
+	spaceChars = charSet{ranges: [][2]rune{{'\t', '\r'}, {' ', ' '}}}
+
+	// unicode properties are approximated by word characters and all non-ASCII characters
+	unicodeChars = charSet{ranges: [][2]rune{{'0', '9'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}, {0x80, 0x10FFFF}}}
+)
+
+func (c charSet) isAny() bool {
+	return c.negated && len(c.ranges) == 0
+}
+
+// Union of two sets, conservatively widened to any character when a negated
+// set is involved.
+func (c charSet) union(o charSet) charSet {
+	if c.negated || o.negated {
+		if c.negated && !o.negated && !o.intersects(charSet{ranges: c.ranges}) {
+			return c
+		}
+		if o.negated && !c.negated && !c.intersects(charSet{ranges: o.ranges}) {
+			return o
+		}
+		return anyChars
+	}
+	ranges := make([][2]rune, 0, len(c.ranges)+len(o.ranges))
+	ranges = append(ranges, c.ranges...)
+	ranges = append(ranges, o.ranges...)
+	return charSet{ranges: ranges}
+}
+
+func (c charSet) intersects(o charSet) bool {
+	switch {
+	case c.negated && o.negated:
+		return true
+	case c.negated:
+		return !coveredBy(o.ranges, c.ranges)
+	case o.negated:
+		return !coveredBy(c.ranges, o.ranges)
+	}
+
+	for _, r1 := range c.ranges {
+		for _, r2 := range o.ranges {
+			if r1[0] <= r2[1] && r2[0] <= r1[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether every range of a is contained in the ranges of b.
+func coveredBy(a, b [][2]rune) bool {
+	merged := append([][2]rune(nil), b...)
+	sort.Slice(merged, func(i, j int) bool { return merged[i][0] < merged[j][0] })
+	n := 0
+	for _, r := range merged {
+		if n > 0 && r[0] <= merged[n-1][1]+1 {
+			if r[1] > merged[n-1][1] {
+				merged[n-1][1] = r[1]
+			}
+			continue
+		}
+		merged[n] = r
+		n++
+	}
+	merged = merged[:n]
+
+	for _, r := range a {
+		covered := false
+		for _, m := range merged {
+			if m[0] <= r[0] && r[1] <= m[1] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckPattern(t *testing.T) {
+	bad := map[string]string{
+		"(?:a+)+":         "nested-quantifier",
+		"(?:a*)*b":        "nested-quantifier",
+		"(?:\\w+\\d*)+":   "nested-quantifier",
+		"(?:(?:ab)+a?)*":  "nested-quantifier",
+		"(?:[a-z]{2,})+":  "nested-quantifier",
+		"x(?:a|aa)+":      "overlapping-alternation",
+		"(?:a|a)*":        "overlapping-alternation",
+		"(?:\\w|\\d){1,}": "overlapping-alternation",
+		"(?:a?b|b)+":      "overlapping-alternation",
+		"(?:a|)+":         "overlapping-alternation",
+		".*.*":            "adjacent-unbounded",
+		".*\\d+":          "adjacent-unbounded",
+		"\\w+.+?":         "adjacent-unbounded",
+		"foo|.+\\S*":      "adjacent-unbounded",
+	}
+	for pattern, rule := range bad {
+		t.Run("should warn about "+pattern, func(t *testing.T) {
+			warnings := CheckPattern(pattern)
+			if len(warnings) == 0 {
+				t.Fatalf(testErrorFormat, warnings, rule)
+			}
+			found := false
+			for _, w := range warnings {
+				found = found || w.Rule == rule
+			}
+			if !found {
+				t.Errorf(testErrorFormat, warnings, rule)
+			}
+		})
+	}
+
+	good := []string{
+		"\\d+(?:\\.\\d+)?",
+		"[^\\/#\\?]+?",
+		"\\d+",
+		"user|u",
+		"(?:a|b)+",
+		"(?:ab)+",
+		"(?:[a-z]+-)+[0-9]+",
+		"(?:(?:ab)+c)*",
+		"(?:x[^x]*)*",
+		"(?:\\w+\\.)?\\w+",
+		".*",
+		"(?!login).*",
+		"[a-z]+\\d+",
+		"(?:a{2,3})+",
+		"\\p{L}+-\\d+",
+		"(?i:abc)+",
+		"[\\]a]+\\]+",
+		"",
+	}
+	for _, pattern := range good {
+		t.Run("should accept "+pattern, func(t *testing.T) {
+			if warnings := CheckPattern(pattern); len(warnings) != 0 {
+				t.Errorf(testErrorFormat, warnings, "no warnings")
+			}
+		})
+	}
+
+	t.Run("should report the index", func(t *testing.T) {
+		warnings := CheckPattern("\\d-(?:a+)+")
+		if len(warnings) != 1 || warnings[0].Index != 3 {
+			t.Errorf(testErrorFormat, warnings, "nested-quantifier at 3")
+		}
+	})
+}
+
+func TestLint(t *testing.T) {
+	t.Run("should attach token names", func(t *testing.T) {
+		warnings, err := Lint("/:foo((?:a+)+)/(.*.*)/:bar(\\d+)", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []interface{}
+		for _, w := range warnings {
+			names = append(names, w.Token)
+		}
+		expect := []interface{}{"foo", 0}
+		if !reflect.DeepEqual(names, expect) {
+			t.Errorf(testErrorFormat, names, expect)
+		}
+		if s := warnings[0].String(); !strings.HasPrefix(s, "nested-quantifier: ") ||
+			!strings.HasSuffix(s, `at 0 of "foo"`) {
+			t.Errorf(testErrorFormat, s, "nested-quantifier warning")
+		}
+	})
+
+	t.Run("should not warn about default patterns", func(t *testing.T) {
+		warnings, err := Lint("/:foo/:bar*/(\\d+)", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != 0 {
+			t.Errorf(testErrorFormat, warnings, "no warnings")
+		}
+	})
+
+	t.Run("should return parse errors", func(t *testing.T) {
+		if _, err := Lint("/:foo(abc", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+	})
+
+	t.Run("should reject dangerous patterns", func(t *testing.T) {
+		options := &Options{RejectDangerousPatterns: true}
+		_, err := PathToRegexp("/:foo((?:a|aa)+)", nil, options)
+		if err == nil || !strings.HasPrefix(err.Error(), `dangerous pattern "(?:a|aa)+" for "foo"`) {
+			t.Errorf(testErrorFormat, err, "dangerous pattern error")
+		}
+
+		if _, err := PathToRegexp("/:foo(\\d+(?:\\.\\d+)?)", nil, options); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("should warn about ambiguous repeated params", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			expect  []Warning
+		}{
+			{"{:a}+", nil, []Warning{{Rule: "ambiguous-repeat", Token: "a", Index: 0,
+				Message: "repeated values without a separator can't be split"}}},
+			{"/:a(.*)+", nil, []Warning{{Rule: "ambiguous-repeat", Token: "a", Index: 0,
+				Message: `repeated values of a pattern matching the separator "/" can't be split`}}},
+			{"/users/{:ids(\\d+)}*", nil, []Warning{{Rule: "ambiguous-repeat", Token: "ids", Index: 1,
+				Message: "repeated values without a separator can't be split"}}},
+			{"/:a+", nil, nil},
+			{"{:a}+", &Options{RepeatSeparator: ","}, []Warning{{Rule: "ambiguous-repeat", Token: "a", Index: 0,
+				Message: `repeated values of a pattern matching the separator "," can't be split`}}},
+			{"{:a(\\d+)}+", &Options{RepeatSeparator: ","}, nil},
+		}
+		for _, test := range tests {
+			warnings, err := Lint(test.path, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(warnings, test.expect) {
+				t.Errorf(testErrorFormat, warnings, test.expect)
+			}
+		}
+	})
+
+	t.Run("should reject ambiguous repeated params", func(t *testing.T) {
+		options := &Options{StrictAmbiguity: true}
+		_, err := Parse("/:a(.*)+", options)
+		expect := `ambiguous repeated param "a": ambiguous-repeat: repeated values of a pattern matching the separator "/" ` +
+			`can't be split at 0 of "a"`
+		if err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+
+		if _, err := PathToRegexp("/:a+", nil, options); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+// Applies `Options.LeftmostLongest` to the match function of the matcher of a
+// string template ending with optional tokens. The regexp finds the leftmost
+// match, but a param may end before an optional token which could match
+// after a longer value, e.g. `/:id([\w.]+?){.:format(json)}?` matching `/a` of
+// `/a.v1.json` when `.` is a delimiter. The templates with the trailing
+// optional tokens required, from all of them down to the first one, are tried
+// in turn and the first longer match at the same index wins.
+func leftmostLongest(m *Matcher) (*Matcher, error) {
+	path, ok := m.path.(string)
+	if !ok || m.options == nil || !m.options.LeftmostLongest || m.options.Compat != CompatNone {
+		return m, nil
+	}
+	tokens, err := Parse(path, m.options)
+	if err != nil {
+		return nil, err
+	}
+	optional := len(tokens)
+	for optional > 0 {
+		token, ok := tokens[optional-1].(Token)
+		if !ok || !isOptional(token) {
+			break
+		}
+		optional--
+	}
+
+	var variants []func(string) (*MatchResult, error)
+	for n := len(tokens); n > optional; n-- {
+		variant := append([]interface{}(nil), tokens...)
+		for i := optional; i < n; i++ {
+			token := variant[i].(Token)
+			if token.Modifier == "*" {
+				token.Modifier = "+"
+			} else {
+				token.Modifier = ""
+			}
+			variant[i] = token
+		}
+		var variantTokens []Token
+		source, err := tokensToSource(variant, &variantTokens, m.options)
+		if err != nil {
+			return nil, err
+		}
+		p, err := compilePattern(source, m.options)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, regexpToFunction(p, groupBindings(variantTokens, p), m.options))
+	}
+	if len(variants) == 0 {
+		return m, nil
+	}
+
+	match := m.match
+	m.match = func(pathname string) (*MatchResult, error) {
+		result, err := match(pathname)
+		if err != nil || result == nil {
+			return result, err
+		}
+		for _, variant := range variants {
+			longer, err := variant(pathname)
+			if err != nil {
+				return nil, err
+			}
+			if longer != nil && longer.Index == result.Index && len(longer.Path) > len(result.Path) {
+				return longer, nil
+			}
+		}
+		return result, nil
+	}
+	return m, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeftmostLongest(t *testing.T) {
+	t.Run("should return the leftmost of the overlapping candidates", func(t *testing.T) {
+		options := &Options{Start: &falseValue, End: &falseValue, LeftmostLongest: true}
+		tests := []struct {
+			path     string
+			pathname string
+			expect   *MatchResult
+		}{
+			{"/test", "/route/test/test2", &MatchResult{Path: "/test", Index: 6, Params: map[interface{}]interface{}{},
+				Groups: []string{"/test"}}},
+			{"/:a/:b", "/x/y/z", &MatchResult{Path: "/x/y", Params: map[interface{}]interface{}{"a": "x", "b": "y"},
+				Groups: []string{"/x/y", "x", "y"}}},
+		}
+		for _, test := range tests {
+			result, err := MustMatch(test.path, options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Errorf(testErrorFormat, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should extend the match with the optional trailing tokens", func(t *testing.T) {
+		path := "/:id([\\w.]+?){.:format(json)}?"
+		options := &Options{Start: &falseValue, End: &falseValue, Delimiter: "/."}
+		result, err := MustMatch(path, options)("/a.v1.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/a", Params: map[interface{}]interface{}{"id": "a"},
+			Groups: []string{"/a", "a", ""}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+
+		options.LeftmostLongest = true
+		result, err = MustMatch(path, options)("/a.v1.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect = &MatchResult{Path: "/a.v1.json",
+			Params: map[interface{}]interface{}{"id": "a.v1", "format": "json"}, Groups: []string{"/a.v1.json", "a.v1", "json"}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+
+		result, err = MustMatch(path, options)("/a.v1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect = &MatchResult{Path: "/a", Params: map[interface{}]interface{}{"id": "a"},
+			Groups: []string{"/a", "a", ""}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+}
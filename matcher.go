@@ -0,0 +1,489 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Matcher is a compiled path matcher which keeps the regexp and the tokens
+// it was built from for introspection.
+type Matcher struct {
+	re      *regexp2.Regexp
+	source  string
+	tokens  []Token
+	match   func(string) (*MatchResult, error)
+	static  bool
+	path    interface{}
+	options *Options
+
+	// The bindings of the tokens to the groups, see GroupMap.
+	groups       []GroupBinding
+	groupMapOnce sync.Once
+	groupMap     []GroupBinding
+
+	// The results of the recent pathnames, see `Options.ResultCache`.
+	results *Cache
+
+	// The path function used by Rebuild, compiled on first use.
+	toPathOnce sync.Once
+	toPath     func(interface{}) (string, error)
+	toPathErr  error
+}
+
+// NewMatcher creates a Matcher from `path-to-regexp` spec.
+func NewMatcher(path interface{}, options *Options) (*Matcher, error) {
+	if str, ok := stringerPath(path); ok {
+		path = str
+	}
+	var tokens []Token
+	if options != nil && options.Engine != nil {
+		if err := options.Check(); err != nil {
+			return nil, err
+		}
+		source, err := pathToSource(path, &tokens, options)
+		if err != nil {
+			return nil, err
+		}
+		p, err := compilePattern(source, options)
+		if err != nil {
+			return nil, err
+		}
+		groups := groupBindings(tokens, p)
+		m, err := leftmostLongest(&Matcher{source: source, tokens: tokens, groups: groups,
+			match: regexpToFunction(p, groups, options), path: path, options: options})
+		if err != nil {
+			return nil, err
+		}
+		return resultCache(checkConstraints(queryMatcher(duplicateDelimiters(m), path, options)))
+	}
+
+	re, err := PathToRegexp(path, &tokens, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The flags of a given regexp are unknown, so it always runs with regexp2.
+	var p Pattern = regexp2Pattern{re: re}
+	if _, ok := path.(*regexp2.Regexp); !ok {
+		p = newPattern(re, options)
+	}
+
+	groups := groupBindings(tokens, p)
+	m := &Matcher{re: re, source: re.String(), tokens: tokens, groups: groups,
+		match: regexpToFunction(p, groups, options), path: path, options: options}
+	if path, ok := path.(string); ok && len(tokens) == 0 {
+		if match := staticMatch(path, options, m.match); match != nil {
+			m.match, m.static = match, true
+		}
+	}
+	if m, err = leftmostLongest(m); err != nil {
+		return nil, err
+	}
+
+	return resultCache(checkConstraints(queryMatcher(duplicateDelimiters(m), path, options)))
+}
+
+// Sets the result cache of the matcher when `Options.ResultCache` is set.
+func resultCache(m *Matcher, err error) (*Matcher, error) {
+	if err != nil {
+		return nil, err
+	}
+	if m.options != nil && m.options.ResultCache > 0 {
+		m.results = NewCache(m.options.ResultCache)
+	}
+	return m, nil
+}
+
+// Returns an error when a constraint of `Options.Constraints` names a param
+// missing from the tokens of the matcher.
+func checkConstraints(m *Matcher, err error) (*Matcher, error) {
+	if err != nil || m.options == nil || len(m.options.Constraints) == 0 {
+		return m, err
+	}
+	names := make(map[string]bool, len(m.tokens))
+	for _, token := range m.tokens {
+		if name, ok := token.Name.(string); ok {
+			names[name] = true
+		}
+	}
+	var unknown []string
+	for name := range m.options.Constraints {
+		if !names[name] {
+			unknown = append(unknown, strconv.Quote(name))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("constraints of unknown params: %s", strings.Join(unknown, ", "))
+	}
+	return m, nil
+}
+
+// NewMatcherFromSource creates a Matcher from a regexp source and the tokens
+// of its groups, such as the RouteString and Tokens of another Matcher. Only
+// the options used when matching apply.
+func NewMatcherFromSource(source string, tokens []Token, options *Options) (*Matcher, error) {
+	tokens = append([]Token(nil), tokens...)
+	if options != nil && options.Engine != nil {
+		p, err := compilePattern(source, options)
+		if err != nil {
+			return nil, err
+		}
+		groups := groupBindings(tokens, p)
+		return resultCache(checkConstraints(duplicateDelimiters(&Matcher{source: source, tokens: tokens, groups: groups,
+			match: regexpToFunction(p, groups, options), options: options}), nil))
+	}
+
+	re, err := compile(source, options)
+	if err != nil {
+		return nil, err
+	}
+	p := newPattern(re, options)
+	groups := groupBindings(tokens, p)
+	return resultCache(checkConstraints(duplicateDelimiters(&Matcher{re: re, source: source, tokens: tokens,
+		groups: groups, match: regexpToFunction(p, groups, options), options: options}), nil))
+}
+
+// MustMatcherFromSource is like NewMatcherFromSource but panics if the source
+// can't be compiled.
+func MustMatcherFromSource(source string, tokens []Token, options *Options) *Matcher {
+	m, err := NewMatcherFromSource(source, tokens, options)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Returns a match function comparing strings directly when the path is fully
+// static and the options allow it, or nil otherwise. The fallback handles the
+// inputs where the regexp would behave differently from a plain comparison.
+func staticMatch(path string, options *Options,
+	fallback func(string) (*MatchResult, error)) func(string) (*MatchResult, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	if options.EndsWith != "" || options.Compat != CompatNone || (options.Start != nil && !*options.Start) ||
+		(options.End != nil && !*options.End) || options.Decode != nil || options.DecodeValues {
+		return nil
+	}
+	delimiter := anyString(options.Delimiter, defaultDelimiter)
+	if delimiterSequence(options) != "" {
+		return nil
+	}
+
+	rawTokens, err := Parse(path, options)
+	if err != nil {
+		return nil
+	}
+	encode := encoder(options)
+	var literal strings.Builder
+	for _, token := range rawTokens {
+		str, ok := token.(string)
+		if !ok {
+			return nil
+		}
+		literal.WriteString(encode(str, nil))
+	}
+
+	equal, contains := func(a, b string) bool { return a == b }, strings.ContainsRune
+	if ignoreCase(options) {
+		equal, contains = equalFold, containsFold
+	}
+	str, strict := literal.String(), options.Strict
+	if !utf8.ValidString(str) {
+		return nil
+	}
+
+	return func(pathname string) (*MatchResult, error) {
+		// `$` also matches before a trailing newline, and the regexp works on
+		// runes, leave these cases to the regexp.
+		if strings.HasSuffix(pathname, "\n") || !utf8.ValidString(pathname) {
+			return fallback(pathname)
+		}
+
+		if equal(pathname, str) {
+			return &MatchResult{Path: pathname, Params: map[interface{}]interface{}{}, Groups: []string{pathname}}, nil
+		}
+
+		// Allow an optional trailing delimiter when not strict.
+		if !strict && pathname != "" {
+			r, size := utf8.DecodeLastRuneInString(pathname)
+			if contains(delimiter, r) && equal(pathname[:len(pathname)-size], str) {
+				return &MatchResult{Path: pathname, Params: map[interface{}]interface{}{}, Groups: []string{pathname}}, nil
+			}
+		}
+
+		return nil, nil
+	}
+}
+
+// Reports whether the strings are equal when lowering each rune, the way the
+// case insensitive regexps compare characters.
+func equalFold(a, b string) bool {
+	if a == b {
+		return true
+	}
+	for a != "" && b != "" {
+		ra, sizeA := utf8.DecodeRuneInString(a)
+		rb, sizeB := utf8.DecodeRuneInString(b)
+		if ra != rb && unicode.ToLower(ra) != unicode.ToLower(rb) {
+			return false
+		}
+		a, b = a[sizeA:], b[sizeB:]
+	}
+	return a == "" && b == ""
+}
+
+// Reports whether the set contains the rune when lowering each rune.
+func containsFold(set string, r rune) bool {
+	r = unicode.ToLower(r)
+	for _, c := range set {
+		if unicode.ToLower(c) == r {
+			return true
+		}
+	}
+	return false
+}
+
+// CompileError is the error of a single template given to CompileAll or
+// ParseAll.
+type CompileError struct {
+	// index of the template in the paths
+	Index int
+
+	// the template
+	Path string
+
+	// the error returned by NewMatcher, or by Parse for ParseAll
+	Err error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("path %d %q: %v", e.Index, e.Path, e.Err)
+}
+
+// Unwrap returns the error returned by NewMatcher.
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// CompileErrors holds the errors of all the templates given to CompileAll
+// which failed, ordered by index.
+type CompileErrors []*CompileError
+
+func (e CompileErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of the paths failed to compile: %s", len(e), strings.Join(messages, "; "))
+}
+
+// CompileAll creates a Matcher for each of the paths using `parallelism`
+// workers, zero or less means `runtime.GOMAXPROCS(0)`. The matchers are in
+// the order of the paths, a CompileErrors is returned if any of them fails.
+func CompileAll(paths []string, options *Options, parallelism int) ([]*Matcher, error) {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > len(paths) {
+		parallelism = len(paths)
+	}
+
+	matchers := make([]*Matcher, len(paths))
+	errs := make([]error, len(paths))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				matchers[i], errs[i] = NewMatcher(paths[i], options)
+			}
+		}()
+	}
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var compileErrors CompileErrors
+	for i, err := range errs {
+		if err != nil {
+			compileErrors = append(compileErrors, &CompileError{Index: i, Path: paths[i], Err: err})
+		}
+	}
+	if compileErrors != nil {
+		return nil, compileErrors
+	}
+	return matchers, nil
+}
+
+// LongestMatch returns the index and the result of the matcher matching the
+// longest path of the pathname, the first one on a tie, or -1 if none of
+// them matches. It's meant for prefix matchers, whose End option is false,
+// such as the mounts `/api` and `/api/v2`. The matchers after a result
+// covering the whole pathname don't run, and the first error is returned.
+func LongestMatch(matchers []*Matcher, pathname string) (int, *MatchResult, error) {
+	index := -1
+	var best *MatchResult
+	for i, m := range matchers {
+		result, err := m.find(pathname)
+		if err != nil {
+			return -1, nil, err
+		}
+		if result != nil && (best == nil || len(result.Path) > len(best.Path)) {
+			index, best = i, result
+			if len(best.Path) == len(pathname) {
+				break
+			}
+		}
+	}
+	return index, best, nil
+}
+
+// Match matches the pathname, returning nil if it doesn't match, with an
+// error wrapping ErrNoMatch when `Options.ErrorOnNoMatch` is set. With
+// `Options.ResultCache` the result is a copy of the cached one.
+func (m *Matcher) Match(pathname string) (*MatchResult, error) {
+	result, err := m.find(pathname)
+	if err == nil && result == nil && m.options != nil && m.options.ErrorOnNoMatch {
+		return nil, fmt.Errorf("%w: %q", ErrNoMatch, pathname)
+	}
+	return result, err
+}
+
+// Matches the pathname as Match, but returns a nil error when it doesn't
+// match whatever `Options.ErrorOnNoMatch`, for the functions combining the
+// results of matchers.
+func (m *Matcher) find(pathname string) (*MatchResult, error) {
+	if m.options != nil && m.options.Hooks != nil && m.options.Hooks.OnMatch != nil {
+		start := time.Now()
+		result, err := m.cachedMatch(pathname)
+		m.options.Hooks.OnMatch(m.hookTemplate(), pathname, result != nil, time.Since(start))
+		return result, err
+	}
+	return m.cachedMatch(pathname)
+}
+
+// Matches the pathname through the result cache, if any.
+func (m *Matcher) cachedMatch(pathname string) (*MatchResult, error) {
+	if m.results == nil {
+		return m.match(pathname)
+	}
+	if v, ok := m.results.get(pathname); ok {
+		return copyResult(v.(*MatchResult)), nil
+	}
+	result, err := m.match(pathname)
+	if err != nil {
+		return nil, err
+	}
+	m.results.add(pathname, result)
+	return copyResult(result), nil
+}
+
+// Returns a copy of the result, the arrays of the repeated params included.
+func copyResult(result *MatchResult) *MatchResult {
+	if result == nil {
+		return nil
+	}
+	params := make(map[interface{}]interface{}, len(result.Params))
+	for k, v := range result.Params {
+		switch v := v.(type) {
+		case []string:
+			params[k] = append([]string(nil), v...)
+		case []int64:
+			params[k] = append([]int64(nil), v...)
+		case []float64:
+			params[k] = append([]float64(nil), v...)
+		default:
+			params[k] = v
+		}
+	}
+	return &MatchResult{Path: result.Path, Index: result.Index, Params: params, DecodedPath: result.DecodedPath,
+		Groups: append([]string(nil), result.Groups...)}
+}
+
+// Rebuild returns the path of the template with the params of the result,
+// such as a result of Match, replaced by the overrides. A nil override
+// removes the param, and an unnamed param can be overridden by its index or
+// by the decimal form of the index. The params are encoded again, so that
+// with matching Decode and Encode options the path of an unchanged result is
+// the matched path. Only matchers built from a string template can rebuild
+// paths.
+func (m *Matcher) Rebuild(result *MatchResult, overrides map[interface{}]interface{}) (string, error) {
+	template, ok := m.path.(string)
+	if !ok {
+		return "", errors.New("only matchers built from a string template can rebuild paths")
+	}
+	m.toPathOnce.Do(func() {
+		m.toPath, m.toPathErr = Compile(template, m.options)
+	})
+	if m.toPathErr != nil {
+		return "", m.toPathErr
+	}
+
+	data := make(map[interface{}]interface{}, len(overrides))
+	if result != nil {
+		for k, v := range result.Params {
+			data[k] = v
+		}
+	}
+	for k, v := range overrides {
+		if s, ok := k.(string); ok {
+			if index, err := strconv.Atoi(s); err == nil && strconv.Itoa(index) == s && !m.hasName(s) {
+				k = index
+			}
+		}
+		data[k] = v
+	}
+	return m.toPath(data)
+}
+
+// Reports whether a token of the matcher is named by the name.
+func (m *Matcher) hasName(name string) bool {
+	for _, token := range m.tokens {
+		if token.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Regexp returns the compiled regexp of the matcher, which is nil when
+// `Options.Engine` is set.
+func (m *Matcher) Regexp() *regexp2.Regexp {
+	return m.re
+}
+
+// Tokens returns a copy of the tokens found in the path.
+func (m *Matcher) Tokens() []Token {
+	return append([]Token(nil), m.tokens...)
+}
+
+// RouteString returns the source of the generated regexp.
+func (m *Matcher) RouteString() string {
+	return m.source
+}
+
+// RouteLen returns the length of the generated regexp source, which is the
+// value limited by `Options.MaxRegexpLen`.
+func (m *Matcher) RouteLen() int {
+	return len(m.source)
+}
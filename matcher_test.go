@@ -0,0 +1,467 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatcher(t *testing.T) {
+	matcher, err := NewMatcher("/user/:id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should match", func(t *testing.T) {
+		result, err := matcher.Match("/user/123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/user/123", Index: 0, Params: m{"id": "123"}}
+		if !expect.equals(result) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+
+		result, err = matcher.Match("/route")
+		if result != nil || err != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should expose tokens", func(t *testing.T) {
+		expect := []Token{{Name: "id", Prefix: "/", Pattern: "[^\\/#\\?]+?"}}
+		if tokens := matcher.Tokens(); !reflect.DeepEqual(tokens, expect) {
+			t.Errorf(testErrorFormat, tokens, expect)
+		}
+	})
+
+	t.Run("should expose the generated regexp", func(t *testing.T) {
+		re := Must(PathToRegexp("/user/:id", nil, nil))
+		if matcher.RouteString() != re.String() {
+			t.Errorf(testErrorFormat, matcher.RouteString(), re.String())
+		}
+		if matcher.Regexp().String() != re.String() {
+			t.Errorf(testErrorFormat, matcher.Regexp().String(), re.String())
+		}
+		if matcher.RouteLen() != len(re.String()) {
+			t.Errorf(testErrorFormat, matcher.RouteLen(), len(re.String()))
+		}
+	})
+
+	t.Run("should fail with invalid path", func(t *testing.T) {
+		_, err := NewMatcher("/:foo(abc", nil)
+		if err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+	})
+}
+
+func TestStaticMatcher(t *testing.T) {
+	t.Run("should use the fast path for static fixtures", func(t *testing.T) {
+		count := 0
+		for _, test := range tests {
+			path, ok := test[0].(string)
+			if !ok {
+				continue
+			}
+			var o *Options
+			if test[1] != nil {
+				o = test[1].(*Options)
+			}
+			if o != nil && (o.EndsWith != "" || (o.Start != nil && !*o.Start) ||
+				(o.End != nil && !*o.End) || strings.ContainsRune(o.Delimiter, '-')) {
+				continue
+			}
+			static := true
+			for _, token := range test[2].(a) {
+				if _, ok := token.(string); !ok {
+					static = false
+				}
+			}
+			if !static {
+				continue
+			}
+
+			m, err := NewMatcher(path, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !m.static {
+				t.Errorf(testErrorFormat, inspect(path), "static")
+			}
+			count++
+		}
+		if count == 0 {
+			t.Error("expect static fixtures")
+		}
+	})
+
+	t.Run("should fall back to the regexp", func(t *testing.T) {
+		for _, o := range []*Options{
+			{EndsWith: "?"},
+			{Start: &falseValue},
+			{End: &falseValue},
+			{Delimiter: "::"},
+		} {
+			m, err := NewMatcher("/test", o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.static {
+				t.Errorf(testErrorFormat, inspect(o), "not static")
+			}
+		}
+
+		m, err := NewMatcher("/{test}?", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.static {
+			t.Errorf(testErrorFormat, m.static, false)
+		}
+	})
+
+	t.Run("should match like the regexp", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			options  *Options
+			pathname string
+		}{
+			{"/test", nil, "/test"},
+			{"/test", nil, "/TEST/"},
+			{"/test", nil, "/test#"},
+			{"/test", nil, "/test//"},
+			{"/test", nil, "/test\n"},
+			{"/test", nil, "/tes"},
+			{"/test", &Options{Strict: true}, "/test/"},
+			{"/test", &Options{Sensitive: true}, "/TEST"},
+			{"/café", nil, "/CAFÉ"},
+			{"/k", nil, "/K"},
+			{"/a\xffb", nil, "/a\xffb"},
+			{"/test", &Options{Delimiter: "X"}, "/testx"},
+			{"/café", &Options{Encode: encodeURIComponent}, "/caf%C3%A9"},
+		}
+		for _, test := range tests {
+			m, err := NewMatcher(test.path, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			re := Must(PathToRegexp(test.path, nil, test.options))
+			expect := exec(re, test.pathname)
+			result, err := m.Match(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (result == nil) != (expect == nil) || (result != nil && result.Path != expect[0]) {
+				t.Errorf(testErrorFormat, result, expect)
+			}
+		}
+	})
+}
+
+func TestCompileAll(t *testing.T) {
+	t.Run("should compile in order", func(t *testing.T) {
+		paths := make([]string, 100)
+		for i := range paths {
+			paths[i] = "/" + strconv.Itoa(i) + "/:id"
+		}
+		for _, parallelism := range []int{0, 1, 4, 200} {
+			matchers, err := CompileAll(paths, nil, parallelism)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matchers) != len(paths) {
+				t.Fatalf(testErrorFormat, len(matchers), len(paths))
+			}
+			for i, m := range matchers {
+				result, err := m.Match("/" + strconv.Itoa(i) + "/x")
+				if err != nil || result == nil {
+					t.Errorf(testErrorFormat, result, paths[i])
+				}
+			}
+		}
+	})
+
+	t.Run("should compile nothing", func(t *testing.T) {
+		matchers, err := CompileAll(nil, nil, 4)
+		if err != nil || len(matchers) != 0 {
+			t.Errorf(testErrorFormat, matchers, "[]")
+		}
+	})
+
+	t.Run("should aggregate errors", func(t *testing.T) {
+		paths := []string{"/a", "/:foo(", "/b", "/:", "/c(?)"}
+		matchers, err := CompileAll(paths, nil, 3)
+		if matchers != nil {
+			t.Errorf(testErrorFormat, matchers, nil)
+		}
+		errs, ok := err.(CompileErrors)
+		if !ok || len(errs) != 3 {
+			t.Fatalf(testErrorFormat, err, "3 errors")
+		}
+		for i, index := range []int{1, 3, 4} {
+			if errs[i].Index != index || errs[i].Path != paths[index] {
+				t.Errorf(testErrorFormat, errs[i], paths[index])
+			}
+			_, expect := NewMatcher(paths[index], nil)
+			if !reflect.DeepEqual(errs[i].Err, expect) {
+				t.Errorf(testErrorFormat, errs[i].Err, expect)
+			}
+		}
+		expect := "3 of the paths failed to compile: " +
+			"path 1 \"/:foo(\": unbalanced pattern at 5; " +
+			"path 3 \"/:\": missing parameter name at 1; " +
+			"path 4 \"/c(?)\": pattern cannot start with \"?\" at 3"
+		if err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+}
+
+func TestLongestMatch(t *testing.T) {
+	options := PrefixOptions()
+	matchers := []*Matcher{
+		mustMatcher("/api", options),
+		mustMatcher("/api/v2/users", options),
+		mustMatcher("/api/v2", options),
+		mustMatcher("/api/:version", options),
+	}
+
+	t.Run("should pick the longest path", func(t *testing.T) {
+		tests := []struct {
+			pathname string
+			index    int
+			path     string
+		}{
+			{"/api/v2/users/1", 1, "/api/v2/users"},
+			{"/api/v2/users", 1, "/api/v2/users"},
+			{"/api/v2/posts", 2, "/api/v2"},
+			{"/api/v1/users", 3, "/api/v1"},
+			{"/api", 0, "/api"},
+			{"/apis", -1, ""},
+		}
+		for _, test := range tests {
+			index, result, err := LongestMatch(matchers, test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if index != test.index || (result == nil) != (test.index < 0) ||
+				(result != nil && result.Path != test.path) {
+				t.Errorf("%v: "+testErrorFormat, test.pathname, result, test.path)
+			}
+		}
+	})
+
+	t.Run("should match nothing", func(t *testing.T) {
+		if index, result, err := LongestMatch(nil, "/api"); index != -1 || result != nil || err != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should return the errors", func(t *testing.T) {
+		m := mustMatcher("/:foo((?:a|aa)+)", &Options{MatchTimeout: 50 * time.Millisecond})
+		pathname := "/" + strings.Repeat("a", 40) + "!"
+		if _, _, err := LongestMatch([]*Matcher{m}, pathname); !errors.Is(err, ErrMatchTimeout) {
+			t.Errorf(testErrorFormat, err, ErrMatchTimeout)
+		}
+	})
+}
+
+func TestMatcherRebuild(t *testing.T) {
+	// The literals of the template are encoded too when matching.
+	codec := &Options{Decode: decodeURIComponent, Encode: func(str string, token interface{}) string {
+		if token == nil {
+			return str
+		}
+		return EncodeURIComponent(str)
+	}}
+
+	t.Run("should round-trip the matched paths", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			options  *Options
+			pathname string
+		}{
+			{"/users/:id", nil, "/users/1"},
+			{"/users/:id", codec, "/users/caf%C3%A9"},
+			{"/files/:path+", codec, "/files/a%20b/c%2Fd"},
+			{"/:lang?/posts/(\\d+)", codec, "/posts/42"},
+			{"/:lang?/posts/(\\d+)", codec, "/en/posts/42"},
+			{"/items/:id(\\d+)", &Options{TypedParams: true}, "/items/7"},
+			{"/search?q=:q", &Options{QueryParams: true}, "/search?q=a+b"},
+		}
+		for _, test := range tests {
+			matcher := mustMatcher(test.path, test.options)
+			result, err := matcher.Match(test.pathname)
+			if err != nil || result == nil {
+				t.Fatalf(testErrorFormat, result, test.pathname)
+			}
+			path, err := matcher.Rebuild(result, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.pathname {
+				t.Errorf(testErrorFormat, path, test.pathname)
+			}
+		}
+	})
+
+	t.Run("should override the params", func(t *testing.T) {
+		tests := []struct {
+			path      string
+			pathname  string
+			overrides map[interface{}]interface{}
+			expect    string
+		}{
+			{"/:lang/posts/:page(\\d+)", "/en/posts/1", m{"page": 2}, "/en/posts/2"},
+			{"/:lang/posts/:page(\\d+)", "/en/posts/1", m{"lang": "fr", "page": "3"}, "/fr/posts/3"},
+			{"/:lang?/posts", "/en/posts", m{"lang": nil}, "/posts"},
+			{"/posts/(\\d+)/(\\w+)", "/posts/1/a", m{0: 2, "1": "b"}, "/posts/2/b"},
+			{"/tags/:tag*", "/tags/a/b", m{"tag": []string{"c"}}, "/tags/c"},
+		}
+		for _, test := range tests {
+			matcher := mustMatcher(test.path, codec)
+			result, err := matcher.Match(test.pathname)
+			if err != nil || result == nil {
+				t.Fatalf(testErrorFormat, result, test.pathname)
+			}
+			path, err := matcher.Rebuild(result, test.overrides)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+			if result.Path != test.pathname {
+				t.Errorf(testErrorFormat, result.Path, test.pathname)
+			}
+		}
+	})
+
+	t.Run("should validate the overrides", func(t *testing.T) {
+		matcher := mustMatcher("/posts/:page(\\d+)", nil)
+		if _, err := matcher.Rebuild(nil, m{"page": "x"}); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+
+	t.Run("should fail without a string template", func(t *testing.T) {
+		matcher := mustMatcher([]string{"/a", "/b"}, nil)
+		if _, err := matcher.Rebuild(&MatchResult{Params: m{}}, nil); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if _, err := mustMatcher("/:id", &Options{Compat: CompatExpress4}).Rebuild(nil, nil); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
+
+func TestResultCache(t *testing.T) {
+	// Counts the regexp executions through the decoded params, the path being
+	// decoded with a nil token.
+	runs := 0
+	decode := func(str string, token interface{}) (string, error) {
+		if token != nil {
+			runs++
+		}
+		return str, nil
+	}
+
+	t.Run("should evict the least recently used results", func(t *testing.T) {
+		runs = 0
+		matcher := mustMatcher("/users/:id", &Options{ResultCache: 2, Decode: decode})
+		for _, pathname := range []string{"/users/1", "/users/2", "/users/1", "/users/3", "/users/1", "/users/2"} {
+			if result, err := matcher.Match(pathname); err != nil || result == nil {
+				t.Fatalf(testErrorFormat, result, pathname)
+			}
+		}
+		// `/users/2` was evicted by `/users/3`.
+		if runs != 4 {
+			t.Errorf(testErrorFormat, runs, 4)
+		}
+		if matcher.results.Len() != 2 {
+			t.Errorf(testErrorFormat, matcher.results.Len(), 2)
+		}
+	})
+
+	t.Run("should cache the paths which don't match", func(t *testing.T) {
+		matcher := mustMatcher("/users/:id", &Options{ResultCache: 2})
+		for i := 0; i < 2; i++ {
+			if result, err := matcher.Match("/posts/1"); err != nil || result != nil {
+				t.Errorf(testErrorFormat, result, nil)
+			}
+		}
+		if matcher.results.Len() != 1 {
+			t.Errorf(testErrorFormat, matcher.results.Len(), 1)
+		}
+	})
+
+	t.Run("should return copies of the results", func(t *testing.T) {
+		fn := MustMatch("/files/:path*", &Options{ResultCache: 8})
+		result, _ := fn("/files/a/b")
+		result.Params["path"].([]string)[0] = "x"
+		result.Params["extra"] = "y"
+		result.Path = "/changed"
+
+		expect := &MatchResult{Path: "/files/a/b", Params: m{"path": []string{"a", "b"}}, Groups: []string{"/files/a/b", "a/b"}}
+		if result, _ := fn("/files/a/b"); !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		matcher := mustMatcher("/users/:id", &Options{ResultCache: 4})
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					id := strconv.Itoa((i + j) % 6)
+					if result, err := matcher.Match("/users/" + id); err != nil || result.Params["id"] != id {
+						t.Errorf(testErrorFormat, result, id)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func BenchmarkCompileAll(b *testing.B) {
+	paths := make([]string, 2000)
+	for i := range paths {
+		paths[i] = "/api/v" + strconv.Itoa(i%3) + "/resource" + strconv.Itoa(i) + "/:id(\\d+)/:action?"
+	}
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run("parallelism "+strconv.Itoa(parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				CompileAll(paths, nil, parallelism)
+			}
+		})
+	}
+}
+
+func BenchmarkResultCache(b *testing.B) {
+	pathnames := make([]string, 100)
+	for i := range pathnames {
+		pathnames[i] = "/api/v1/users/" + strconv.Itoa(i) + "/posts/" + strconv.Itoa(i*7)
+	}
+	for _, size := range []int{0, 256} {
+		matcher := mustMatcher("/api/:version/users/:id(\\d+)/posts/:post(\\d+)", &Options{ResultCache: size})
+		b.Run("cache "+strconv.Itoa(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matcher.Match(pathnames[i%len(pathnames)])
+			}
+		})
+	}
+}
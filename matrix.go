@@ -0,0 +1,282 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Matrix holds the matrix params of a segment, e.g. `;id=:id;view=full` in
+// `/items;id=:id;view=full/details`, with `Options.MatrixParams`, as the
+// Matrix of the token matching them. The params are matched in any order, the other keys
+// of the segment being ignored.
+type Matrix struct {
+	// The declared params, in the order of the template
+	Params []MatrixParam
+
+	sensitive bool
+	// the validators of the params, nil for the literals
+	validators []*validator
+}
+
+// MatrixParam is a `key=value` pair declared by a template, the value being a
+// literal or a param, which is optional with the `?` modifier.
+type MatrixParam struct {
+	// The key of the pair
+	Key string
+
+	// The literal value, when Token is nil
+	Literal string
+
+	// The param of the value
+	Token *Token
+}
+
+// String returns the matrix params as written in a template.
+func (m *Matrix) String() string {
+	var b strings.Builder
+	for _, param := range m.Params {
+		writeStrings(&b, ";", param.Key, "=")
+		if param.Token == nil {
+			b.WriteString(escapeMatrix(param.Literal))
+			continue
+		}
+		if name, ok := param.Token.Name.(string); ok {
+			writeStrings(&b, ":", name)
+		} else {
+			writeStrings(&b, "(", param.Token.Pattern, ")")
+		}
+		b.WriteString(param.Token.Modifier)
+	}
+	return b.String()
+}
+
+// Escapes the characters of a literal which are special in templates.
+func escapeMatrix(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		if strings.ContainsRune(`:()*+?\{}`, r) {
+			b.WriteString(`\`)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Replaces the `;key=value` sequences of the parsed tokens by a token holding
+// a *Matrix per segment, the sequence of a segment ending at a delimiter.
+func matrixTokens(tokens []interface{}, options *Options) ([]interface{}, error) {
+	delimiter := anyString(options.Delimiter, defaultDelimiter)
+	result := make([]interface{}, 0, len(tokens))
+	var text strings.Builder
+	var matrix *Matrix
+	var key, literal strings.Builder
+	inKey, inValue := false, false
+
+	flushText := func() {
+		if text.Len() > 0 {
+			result = append(result, text.String())
+			text.Reset()
+		}
+	}
+	endPair := func() error {
+		if inKey && key.Len() > 0 {
+			return fmt.Errorf("expected key=value in matrix params, but got %q", key.String())
+		}
+		if inValue {
+			matrix.Params = append(matrix.Params, MatrixParam{Key: key.String(), Literal: literal.String()})
+			matrix.validators = append(matrix.validators, nil)
+		}
+		key.Reset()
+		literal.Reset()
+		inKey, inValue = true, false
+		return nil
+	}
+	endMatrix := func() error {
+		if matrix == nil {
+			return nil
+		}
+		if err := endPair(); err != nil {
+			return err
+		}
+		inKey = false
+		result = append(result, Token{
+			Name:    matrix.String(),
+			Pattern: "(?:;[^" + escapeCached(delimiter) + "]*)*",
+			Matrix:  matrix,
+		})
+		matrix = nil
+		return nil
+	}
+
+	for _, token := range tokens {
+		if str, ok := token.(string); ok {
+			for _, r := range str {
+				switch {
+				case matrix == nil && r != ';':
+					text.WriteRune(r)
+				case matrix == nil:
+					flushText()
+					matrix = &Matrix{sensitive: !ignoreCase(options)}
+					inKey = true
+				case strings.ContainsRune(delimiter, r):
+					if err := endMatrix(); err != nil {
+						return nil, err
+					}
+					text.WriteRune(r)
+				case r == ';':
+					if err := endPair(); err != nil {
+						return nil, err
+					}
+				case inKey && r == '=':
+					if key.Len() == 0 {
+						return nil, errors.New("missing key in matrix params")
+					}
+					inKey, inValue = false, true
+				case inKey:
+					key.WriteRune(r)
+				case inValue:
+					literal.WriteRune(r)
+				default:
+					return nil, fmt.Errorf("expected a literal or a single param for matrix key %q",
+						matrix.Params[len(matrix.Params)-1].Key)
+				}
+			}
+			continue
+		}
+
+		t := token.(Token)
+		if matrix != nil && t.Prefix != "" && strings.ContainsAny(t.Prefix[:1], delimiter) {
+			if err := endMatrix(); err != nil {
+				return nil, err
+			}
+		}
+		if matrix == nil {
+			flushText()
+			result = append(result, t)
+			continue
+		}
+
+		if !inValue || literal.Len() > 0 || t.Prefix != "" || t.Suffix != "" || t.Pattern == "" {
+			return nil, fmt.Errorf("expected a literal or a single param for matrix key %q", key.String())
+		}
+		if t.Modifier == "*" || t.Modifier == "+" {
+			return nil, fmt.Errorf("matrix param \"%v\" can't repeat", t.Name)
+		}
+		matrix.Params = append(matrix.Params, MatrixParam{Key: key.String(), Token: &t})
+		matrix.validators = append(matrix.validators, &validator{source: "^(?:" + t.Pattern + ")$",
+			options: options})
+		key.Reset()
+		inKey, inValue = false, false
+	}
+	if err := endMatrix(); err != nil {
+		return nil, err
+	}
+	flushText()
+
+	return result, nil
+}
+
+// Matches the matrix params of a segment, such as `;id=1;view=full`, putting
+// the values of the declared params in `params`. It returns false if a
+// declared param is missing or doesn't match.
+func (m *Matrix) match(str string, params map[interface{}]interface{},
+	decode func(string, Token, int) (string, error)) (bool, error) {
+	values := map[string]string{}
+	for _, pair := range strings.Split(str, ";") {
+		if pair == "" {
+			continue
+		}
+		key, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		if _, ok := values[key]; !ok {
+			values[key] = value
+		}
+	}
+
+	equal := equalFold
+	if m.sensitive {
+		equal = func(a, b string) bool { return a == b }
+	}
+	for i, param := range m.Params {
+		value, ok := values[param.Key]
+		if param.Token == nil {
+			if !ok || !equal(value, param.Literal) {
+				return false, nil
+			}
+			continue
+		}
+
+		if !ok {
+			if param.Token.Modifier == "?" {
+				continue
+			}
+			return false, nil
+		}
+		if ok, err := m.validators[i].MatchString(value); err != nil || !ok {
+			return false, err
+		}
+		decoded, err := decode(value, *param.Token, 0)
+		if err != nil {
+			return false, err
+		}
+		params[param.Token.Name] = decoded
+	}
+	return true, nil
+}
+
+// Returns the matrix params with the values of the data, sorted by key.
+// `segment` encodes and checks a value when `validate` is true.
+func (m *Matrix) path(data interface{}, validate bool,
+	segment func(token Token, value string) (string, error)) (string, error) {
+	indexes := make([]int, len(m.Params))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return m.Params[indexes[i]].Key < m.Params[indexes[j]].Key
+	})
+	var b strings.Builder
+	for _, i := range indexes {
+		param := m.Params[i]
+		if param.Token == nil {
+			writeStrings(&b, ";", param.Key, "=", param.Literal)
+			continue
+		}
+
+		token := *param.Token
+		values, err := queryValues(data, token.Name, false)
+		if err != nil {
+			return "", err
+		}
+		if len(values) == 0 {
+			if token.Modifier == "?" {
+				continue
+			}
+			return "", fmt.Errorf("expected \"%v\" to be a string", token.Name)
+		}
+		value, err := segment(token, values[0])
+		if err != nil {
+			return "", err
+		}
+		if validate {
+			ok, err := m.validators[i].MatchString(value)
+			if err != nil {
+				return "", validatorError(token, err)
+			}
+			if !ok {
+				return "", fmt.Errorf("expected \"%v\" to match \"%v\", but got \"%v\"",
+					token.Name, token.Pattern, value)
+			}
+		}
+		writeStrings(&b, ";", param.Key, "=", value)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatrixParams(t *testing.T) {
+	options := &Options{MatrixParams: true}
+
+	t.Run("should parse the matrix params into a token", func(t *testing.T) {
+		tokens, err := Parse("/items;id=:id;view=full/:name;color=:color(\\w+)?", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 4 {
+			t.Fatalf(testErrorFormat, tokens, "4 tokens")
+		}
+		matrix := tokens[1].(Token).Matrix
+		expect := []MatrixParam{
+			{Key: "id", Token: &Token{Name: "id", Pattern: "[^\\/#\\?;]+?"}},
+			{Key: "view", Literal: "full"},
+		}
+		if !reflect.DeepEqual(matrix.Params, expect) {
+			t.Errorf(testErrorFormat, matrix.Params, expect)
+		}
+		if str := matrix.String(); str != ";id=:id;view=full" {
+			t.Errorf(testErrorFormat, str, ";id=:id;view=full")
+		}
+		if token := tokens[1].(Token); !token.IsNamed() || token.Name != ";id=:id;view=full" {
+			t.Errorf(testErrorFormat, token.Name, ";id=:id;view=full")
+		}
+		if token := tokens[2].(Token); token.Name != "name" || token.Pattern != "[^\\/#\\?;]+?" {
+			t.Errorf(testErrorFormat, token, "the name token")
+		}
+		if name := tokens[3].(Token).Name; name != ";color=:color?" {
+			t.Errorf(testErrorFormat, name, ";color=:color?")
+		}
+	})
+
+	t.Run("should match the matrix params in any order", func(t *testing.T) {
+		tests := []struct {
+			template string
+			// pathname and params, nil params meaning no match
+			matches [][2]interface{}
+		}{
+			{"/items;id=:id;view=:view/details", [][2]interface{}{
+				{"/items;id=1;view=full/details", m{"id": "1", "view": "full"}},
+				{"/items;view=full;id=1/details", m{"id": "1", "view": "full"}},
+				{"/items;view=full;x=y;id=1/details/", m{"id": "1", "view": "full"}},
+				{"/items;id=1/details", nil},
+				{"/items/details", nil},
+				{"/items;id=1;view=full", nil},
+			}},
+			{"/shop/:name;color=:color;size=(\\d+)?", [][2]interface{}{
+				{"/shop/shirt;color=red;size=42", m{"name": "shirt", "color": "red", 0: "42"}},
+				{"/shop/shirt;size=42;color=red", m{"name": "shirt", "color": "red", 0: "42"}},
+				{"/shop/shirt;color=red", m{"name": "shirt", "color": "red"}},
+				{"/shop/shirt;color=red;size=xl", nil},
+				{"/shop/shirt", nil},
+				{"/shop/shirt;red", nil},
+			}},
+			{"/docs;lang=en/:page", [][2]interface{}{
+				{"/docs;lang=EN/intro", m{"page": "intro"}},
+				{"/docs;lang=fr/intro", nil},
+				{"/docs/intro;x=1", nil},
+			}},
+		}
+
+		for _, test := range tests {
+			match, err := Match(test.template, &Options{MatrixParams: true, Decode: decodeURIComponent})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range test.matches {
+				result, err := match(v[0].(string))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var params interface{}
+				if result != nil {
+					params = m(result.Params)
+				}
+				if (params == nil) != (v[1] == nil) || (params != nil && !reflect.DeepEqual(params, v[1])) {
+					t.Errorf("%s %s: "+testErrorFormat, test.template, v[0], params, v[1])
+				}
+			}
+		}
+	})
+
+	t.Run("should compile the matrix params sorted by key", func(t *testing.T) {
+		tests := []struct {
+			template string
+			data     interface{}
+			expect   string
+		}{
+			{"/items;view=:view;id=:id/details", m{"id": 1, "view": "full"}, "/items;id=1;view=full/details"},
+			{"/shop/:name;size=(\\d+)?;color=:color", m{"name": "shirt", "color": "red"}, "/shop/shirt;color=red"},
+			{"/shop/:name;size=(\\d+)?;color=:color", m{"name": "shirt", "color": "red", 0: 42},
+				"/shop/shirt;color=red;size=42"},
+			{"/docs;lang=en/:page", m{"page": "intro"}, "/docs;lang=en/intro"},
+		}
+		for _, test := range tests {
+			toPath, err := Compile(test.template, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, err := toPath(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf("%s: "+testErrorFormat, test.template, path, test.expect)
+			}
+		}
+
+		toPath := MustCompile("/shop;size=(\\d+)", &Options{MatrixParams: true, Encode: encodeURIComponent})
+		if _, err := toPath(m{0: "xl"}); err == nil || err.Error() != `expected "0" to match "\d+", but got "xl"` {
+			t.Errorf(testErrorFormat, err, `expected "0" to match "\d+", but got "xl"`)
+		}
+		if _, err := toPath(m{}); err == nil || err.Error() != `expected "0" to be a string` {
+			t.Errorf(testErrorFormat, err, `expected "0" to be a string`)
+		}
+	})
+
+	t.Run("should reject invalid matrix params", func(t *testing.T) {
+		tests := [][2]string{
+			{"/items;id", `expected key=value in matrix params, but got "id"`},
+			{"/items;=1", "missing key in matrix params"},
+			{"/items;id=a:id", `expected a literal or a single param for matrix key "id"`},
+			{"/items;id=:id.x", `expected a literal or a single param for matrix key "id"`},
+			{"/items;id=:id+", `matrix param "id" can't repeat`},
+		}
+		for _, test := range tests {
+			if _, err := Parse(test[0], options); err == nil || err.Error() != test[1] {
+				t.Errorf("%s: "+testErrorFormat, test[0], err, test[1])
+			}
+		}
+	})
+
+	t.Run("should hash the matrix params", func(t *testing.T) {
+		a, err := RouteHash("/items;id=:id", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := RouteHash("/items;key=:id", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a == b {
+			t.Errorf(testErrorFormat, a, "a different hash")
+		}
+	})
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"strings"
+)
+
+// Route is a route built from tokens rather than from a template, such as the
+// routes joined by Mount, which can be matched and built.
+type Route struct {
+	tokens    []interface{}
+	options   *Options
+	namespace func(name string) string
+	matcher   *Matcher
+	toPath    func(interface{}) (string, error)
+}
+
+// Mount joins the prefix and the child templates with a delimiter, the child
+// params colliding with the prefix params being renamed `child.<name>`, and
+// the unnamed child params being indexed after the prefix ones.
+func Mount(prefix, child string, options *Options) (*Route, error) {
+	return MountNamespace(prefix, child, options, nil)
+}
+
+// MountNamespace is like Mount but renames the colliding child params with
+// the namespace function, which is called again while the name collides.
+func MountNamespace(prefix, child string, options *Options, namespace func(name string) string) (*Route, error) {
+	if options != nil && (options.Compat != CompatNone || options.QueryParams) {
+		return nil, errors.New("templates with a compat mode or query params can't be mounted")
+	}
+	if namespace == nil {
+		namespace = func(name string) string {
+			return "child." + name
+		}
+	}
+
+	delimiter := mountDelimiter(options)
+	tokens, err := Parse(strings.TrimSuffix(prefix, delimiter), options)
+	if err != nil {
+		return nil, err
+	}
+	r := &Route{tokens: tokens, options: options, namespace: namespace}
+	return r.Mount(child)
+}
+
+// Mount returns the route of the child template mounted on the route, with
+// the namespace function of the route.
+func (r *Route) Mount(child string) (*Route, error) {
+	if child != "" {
+		delimiter := mountDelimiter(r.options)
+		child = delimiter + strings.TrimPrefix(child, delimiter)
+	}
+	childTokens, err := Parse(child, r.options)
+	if err != nil {
+		return nil, err
+	}
+
+	names, unnamed := make(map[string]bool), 0
+	for _, token := range r.tokens {
+		if token, ok := token.(Token); ok {
+			if _, ok := token.Index(); ok {
+				unnamed++
+			} else if token.IsNamed() {
+				names[token.NameString()] = true
+			}
+		}
+	}
+	tokens := append(append([]interface{}(nil), r.tokens...), childTokens...)
+	for i := len(r.tokens); i < len(tokens); i++ {
+		token, ok := tokens[i].(Token)
+		if !ok {
+			continue
+		}
+		if index, ok := token.Index(); ok {
+			token.Name = index + unnamed
+		} else if name, ok := token.Name.(string); ok && name != "" {
+			for names[name] {
+				name = r.namespace(name)
+			}
+			names[name] = true
+			token.Name = name
+		}
+		tokens[i] = token
+	}
+
+	return newRoute(tokens, r.options, r.namespace)
+}
+
+// Match matches the pathname, returning nil if it doesn't match.
+func (r *Route) Match(pathname string) (*MatchResult, error) {
+	return r.matcher.Match(pathname)
+}
+
+// Build returns the path of the route with the params, the renamed params
+// being given by their new names.
+func (r *Route) Build(params interface{}) (string, error) {
+	return r.toPath(params)
+}
+
+// Tokens returns a copy of the tokens of the route.
+func (r *Route) Tokens() []interface{} {
+	return append([]interface{}(nil), r.tokens...)
+}
+
+// Creates the route of the tokens.
+func newRoute(tokens []interface{}, options *Options, namespace func(string) string) (*Route, error) {
+	var matcherTokens []Token
+	source, err := tokensToSource(tokens, &matcherTokens, options)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := NewMatcherFromSource(source, matcherTokens, options)
+	if err != nil {
+		return nil, err
+	}
+	toPath, err := tokensToFunction(tokens, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Route{tokens: tokens, options: options, namespace: namespace, matcher: matcher, toPath: toPath}, nil
+}
+
+// Returns the delimiter joining the mounted templates, the delimiter sequence
+// or the first delimiter character, escaped as it's written in a template.
+func mountDelimiter(options *Options) string {
+	if options == nil {
+		return "/"
+	}
+	delimiter := delimiterSequence(options)
+	if delimiter == "" {
+		for _, r := range anyString(options.Delimiter, defaultDelimiter) {
+			delimiter = string(r)
+			break
+		}
+	}
+	if options.LiteralBackslash {
+		return delimiter
+	}
+	var b strings.Builder
+	for _, r := range delimiter {
+		if strings.ContainsRune(`:(){}*+?\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
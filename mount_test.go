@@ -0,0 +1,115 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	t.Run("should join the templates", func(t *testing.T) {
+		tests := []struct {
+			prefix, child string
+			options       *Options
+			pathname      string
+			params        m
+			path          string
+		}{
+			{"/orgs/:org", "/users/:user", nil, "/orgs/1/users/2", m{"org": "1", "user": "2"}, "/orgs/1/users/2"},
+			{"/orgs/:org/", "users/:user", nil, "/orgs/1/users/2", m{"org": "1", "user": "2"}, "/orgs/1/users/2"},
+			{"/orgs/:id", "/users/:id", nil, "/orgs/1/users/2", m{"id": "1", "child.id": "2"}, "/orgs/1/users/2"},
+			{"/", "/users/:id", nil, "/users/2", m{"id": "2"}, "/users/2"},
+			{"/api", "", nil, "/api", m{}, "/api"},
+			{"/(\\d+)", "/(\\w+)", nil, "/1/a", m{0: "1", 1: "a"}, "/1/a"},
+			{":tenant", ":app", &Options{Delimiter: "."}, "acme.web", m{"tenant": "acme", "app": "web"}, "acme.web"},
+			{":team", ":id", &Options{Delimiter: "::"}, "a::b", m{"team": "a", "id": "b"}, "a::b"},
+		}
+		for _, test := range tests {
+			r, err := Mount(test.prefix, test.child, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := r.Match(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(test.params)) {
+				t.Errorf(testErrorFormat, result, test.params)
+			}
+			path, err := r.Build(test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.path {
+				t.Errorf(testErrorFormat, path, test.path)
+			}
+		}
+	})
+
+	t.Run("should rename with the namespace", func(t *testing.T) {
+		r, err := MountNamespace("/orgs/:id", "/users/:id/:name", nil, func(name string) string {
+			return "user_" + name
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := r.Match("/orgs/1/users/2/bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := m{"id": "1", "user_id": "2", "name": "bob"}
+		if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(expect)) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should nest the mounts", func(t *testing.T) {
+		r, err := Mount("/orgs/:id", "/teams/:id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, err = r.Mount("/users/:id"); err != nil {
+			t.Fatal(err)
+		}
+		result, err := r.Match("/orgs/1/teams/2/users/3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := m{"id": "1", "child.id": "2", "child.child.id": "3"}
+		if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(expect)) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+		path, err := r.Build(m{"id": "a", "child.id": "b", "child.child.id": "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/orgs/a/teams/b/users/c" {
+			t.Errorf(testErrorFormat, path, "/orgs/a/teams/b/users/c")
+		}
+		if tokens := r.Tokens(); len(tokens) != 6 {
+			t.Errorf(testErrorFormat, tokens, "6 tokens")
+		}
+	})
+
+	t.Run("should return the errors", func(t *testing.T) {
+		tests := []struct {
+			prefix, child string
+			options       *Options
+			expect        string
+		}{
+			{"/:foo(", "/a", nil, "unbalanced pattern"},
+			{"/a", "/:", nil, "missing parameter name"},
+			{"/a", "/b", &Options{QueryParams: true}, "can't be mounted"},
+		}
+		for _, test := range tests {
+			_, err := Mount(test.prefix, test.child, test.options)
+			if err == nil || !strings.Contains(err.Error(), test.expect) {
+				t.Errorf(testErrorFormat, err, test.expect)
+			}
+		}
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ToNginx returns the regexp of the path adapted for the PCRE of nginx, to use
+// in a `location ~` block: the parameters are captured by named groups, the
+// unnamed parameters, the names starting with a digit and all but the last
+// parameter of a repeated name keeping a plain group, and the case
+// insensitivity is expressed by a leading `(?i)`.
+// The anchors follow the Start and End options.
+//
+// The custom patterns of the parameters are copied as is, note that `\d`, `\w`
+// and `\s` only match ASCII characters in PCRE.
+func ToNginx(path string, options *Options) (string, error) {
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return "", err
+	}
+
+	// The last parameter of a name wins in the match result, so it's the one
+	// whose group is named.
+	counts := map[string]int{}
+	for _, token := range tokens {
+		if token, ok := token.(Token); ok {
+			if name, ok := token.Name.(string); ok {
+				counts[name]++
+			}
+		}
+	}
+	source, err := tokensToNamedSource(tokens, nil, options, func(token Token) string {
+		name, ok := token.Name.(string)
+		if !ok || name == "" || name[0] >= '0' && name[0] <= '9' {
+			return ""
+		}
+		if counts[name]--; counts[name] > 0 {
+			return ""
+		}
+		return name
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if options == nil || !options.Sensitive {
+		source = "(?i)" + source
+	}
+	return source, nil
+}
+
+// ToNginxLocations returns a `location ~` block per path, preceded by a
+// comment with the path, in the order of the paths. The body is written in
+// each block, indented, and may be empty. A path failing to convert is
+// reported by a *CompileError.
+//
+//	# /users/:id
+//	location ~ "(?i)^\\/users(?:\\/(?P<id>[^\\/#\\?]+?))[\\/#\\?]?$" {
+//	    proxy_pass http://users;
+//	}
+func ToNginxLocations(paths []string, options *Options, body string) (string, error) {
+	var b strings.Builder
+	for i, path := range paths {
+		pattern, err := ToNginx(path, options)
+		if err != nil {
+			return "", &CompileError{Index: i, Path: path, Err: err}
+		}
+
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		comment := path
+		if strings.ContainsAny(path, "\r\n") {
+			comment = strconv.Quote(path)
+		}
+		writeStrings(&b, "# ", comment, "\nlocation ~ ", nginxString(pattern), " {\n")
+		if body != "" {
+			for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+				if line != "" {
+					writeStrings(&b, "    ", line)
+				}
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return b.String(), nil
+}
+
+// Quotes the string for an nginx configuration, whose parser unescapes `\\`,
+// `\"`, `\'`, `\t`, `\r` and `\n` even in the quoted strings, so every
+// backslash is doubled and the tabs and line breaks are escaped.
+func nginxString(str string) string {
+	var b strings.Builder
+	b.WriteString(`"`)
+	for _, r := range str {
+		switch r {
+		case '\\', '"':
+			writeStrings(&b, `\`, string(r))
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString(`"`)
+	return b.String()
+}
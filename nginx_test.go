@@ -0,0 +1,139 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestToNginx(t *testing.T) {
+	t.Run("should match like the matcher", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			samples []string
+		}{
+			{"/users/:id", nil, []string{"/users/42", "/USERS/42/", "/users", "/users/42/posts"}},
+			{"/users/:id", &Options{Sensitive: true}, []string{"/users/42", "/USERS/42"}},
+			{"/users/:id", &Options{Strict: true}, []string{"/users/42", "/users/42/"}},
+			{"/users/:id", &Options{Start: &falseValue}, []string{"/api/users/42", "/users/42"}},
+			{"/files/:path*", nil, []string{"/files", "/files/a/b", "/files/a/b/"}},
+			{"/:foo/:bar?", nil, []string{"/a", "/a/b", "/a/b/c"}},
+			{"/:id(\\d+)", nil, []string{"/42", "/abc"}},
+			{"/icon-:size(\\d+).png", nil, []string{"/icon-16.png", "/icon-x.png"}},
+			{"/(.*)", nil, []string{"/", "/a/b"}},
+			{"/{:a-}?:b", nil, []string{"/x-y", "/y"}},
+			{"/:1/:x/:x", nil, []string{"/a/b/c", "/a/b"}},
+		}
+
+		for _, test := range tests {
+			pattern, err := ToNginx(test.path, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				t.Errorf("%s: %v", test.path, err)
+				continue
+			}
+			matcher := mustMatcher(test.path, test.options)
+			for _, sample := range test.samples {
+				result, err := matcher.Match(sample)
+				if err != nil {
+					t.Fatal(err)
+				}
+				match := re.FindStringSubmatch(sample)
+				if (match != nil) != (result != nil) {
+					t.Errorf("%s %s: "+testErrorFormat, test.path, sample, match != nil, result != nil)
+					continue
+				}
+				if match == nil {
+					continue
+				}
+				for i, name := range re.SubexpNames() {
+					if value, ok := result.Params[name]; name != "" && ok {
+						if s, ok := value.(string); ok && s != match[i] {
+							t.Errorf("%s %s: "+testErrorFormat, test.path, sample, match[i], s)
+						}
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("should name the groups of the parameters", func(t *testing.T) {
+		tests := [][2]string{
+			{"/users/:id", "(?i)^\\/users(?:\\/(?P<id>[^\\/#\\?]+?))[\\/#\\?]?$"},
+			{"/:1/:x/:x", "(?i)^(?:\\/([^\\/#\\?]+?))(?:\\/([^\\/#\\?]+?))(?:\\/(?P<x>[^\\/#\\?]+?))[\\/#\\?]?$"},
+			{"/(.*)", "(?i)^(?:\\/(.*))[\\/#\\?]?$"},
+		}
+		for _, test := range tests {
+			pattern, err := ToNginx(test[0], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pattern != test[1] {
+				t.Errorf("%s: "+testErrorFormat, test[0], pattern, test[1])
+			}
+		}
+
+		pattern, err := ToNginx("/users/:id", &Options{Sensitive: true, End: &falseValue})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "^\\/users(?:\\/(?P<id>[^\\/#\\?]+?))(?:[\\/#\\?](?=$))?(?=[\\/#\\?]|$)"; pattern != expect {
+			t.Errorf(testErrorFormat, pattern, expect)
+		}
+	})
+
+	t.Run("should return the parse error", func(t *testing.T) {
+		if _, err := ToNginx("/:foo(", nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
+
+func TestToNginxLocations(t *testing.T) {
+	t.Run("should write a location block per path", func(t *testing.T) {
+		conf, err := ToNginxLocations([]string{"/users/:id", "/a\"b\nc"}, &Options{Sensitive: true, Strict: true},
+			"auth_request /auth;\n\nproxy_pass http://app;\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := `# /users/:id
+location ~ "^\\/users(?:\\/(?P<id>[^\\/#\\?]+?))$" {
+    auth_request /auth;
+
+    proxy_pass http://app;
+}
+
+# "/a\"b\nc"
+location ~ "^\\/a\"b\nc$" {
+    auth_request /auth;
+
+    proxy_pass http://app;
+}
+`
+		if conf != expect {
+			t.Errorf(testErrorFormat, conf, expect)
+		}
+
+		conf, err = ToNginxLocations([]string{"/"}, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "# /\nlocation ~ \"(?i)^\\\\/[\\\\/#\\\\?]?$\" {\n}\n"; conf != expect {
+			t.Errorf(testErrorFormat, conf, expect)
+		}
+	})
+
+	t.Run("should report the failing path", func(t *testing.T) {
+		_, err := ToNginxLocations([]string{"/a", "/:foo("}, nil, "")
+		if e, ok := err.(*CompileError); !ok || e.Index != 1 || e.Path != "/:foo(" {
+			t.Errorf(testErrorFormat, err, "a *CompileError of the path 1")
+		}
+	})
+}
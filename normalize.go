@@ -0,0 +1,189 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Normalize returns the canonical form of a template, so that the templates
+// written differently but meaning the same, such as `/:a` and
+// `/:a([^\/#\?]+?)`, normalize alike:
+//   - the default patterns are left out
+//   - the literals are only escaped where the syntax requires it
+//   - the groups without a modifier are unwrapped, e.g. `{/x}` into `/x`
+//   - the groups of the params are left out where the prefix or the suffix
+//     would be the same without them, e.g. `{/:id}?` into `/:id?`, or where the
+//     param has no modifier, the prefix and the suffix being folded into the
+//     literals, e.g. `{-:id}` into `-:id`
+//
+// The normalized template generates the same regexp, up to the non-capturing
+// groups of the unwrapped groups and of the folded prefixes and suffixes,
+// which is checked by parsing it again.
+func Normalize(path string, options *Options) (string, error) {
+	if options != nil && (options.Compat != CompatNone || options.MatrixParams) {
+		return "", errors.New("templates with a compat mode or matrix params can't be normalized")
+	}
+	query := ""
+	if options != nil && options.QueryParams {
+		if p, q, ok := splitQuery(path); ok {
+			path, query = p, "?"+q
+		}
+	}
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return "", err
+	}
+	n := &normalizer{options: options}
+	if tokens, err := Parse(":x", options); err == nil && len(tokens) == 1 {
+		n.defaultPattern = tokens[0].(Token).Pattern
+	}
+
+	canonical := flattenTokens(tokens)
+	// Falls back to the groups when the shortest form parses differently,
+	// e.g. with a delimiter sequence.
+	for _, braced := range []bool{false, true} {
+		str := n.format(canonical, braced)
+		if parsed, err := Parse(str, options); err == nil && reflect.DeepEqual(flattenTokens(parsed), canonical) {
+			return str + query, nil
+		}
+	}
+	return "", fmt.Errorf("%q can't be normalized", path)
+}
+
+// Returns the tokens with the literals of the tokens without a modifier, and
+// of their groups, merged into the adjacent literals.
+func flattenTokens(tokens []interface{}) []interface{} {
+	var result []interface{}
+	literal := ""
+	for _, token := range tokens {
+		switch token := token.(type) {
+		case string:
+			literal += token
+		case Token:
+			if token.Modifier != "" {
+				if literal != "" {
+					result, literal = append(result, literal), ""
+				}
+				result = append(result, token)
+				continue
+			}
+			literal += token.Prefix
+			suffix := token.Suffix
+			if token.Pattern != "" {
+				if literal != "" {
+					result, literal = append(result, literal), ""
+				}
+				token.Prefix, token.Suffix = "", ""
+				result = append(result, token)
+			}
+			literal += suffix
+		}
+	}
+	if literal != "" {
+		result = append(result, literal)
+	}
+	return result
+}
+
+// normalizer writes the tokens of a template in their canonical form.
+type normalizer struct {
+	options        *Options
+	defaultPattern string
+}
+
+// Writes the flattened tokens, all the params with a modifier being in a group
+// when braced is true.
+func (n *normalizer) format(tokens []interface{}, braced bool) string {
+	var b strings.Builder
+	for i, token := range tokens {
+		switch token := token.(type) {
+		case string:
+			b.WriteString(n.escape(token))
+		case Token:
+			var prev, next interface{}
+			if i > 0 {
+				prev = tokens[i-1]
+			}
+			if i+1 < len(tokens) {
+				next = tokens[i+1]
+			}
+			b.WriteString(n.token(token, prev, next, braced))
+		}
+	}
+	return b.String()
+}
+
+// Writes a token, in a group unless the template means the same without it.
+func (n *normalizer) token(token, prev, next interface{}, braced bool) string {
+	t := token.(Token)
+	defaultPattern := t.IsNamed() && t.Pattern == n.defaultPattern
+	inGroup := t.Suffix != "" || t.Pattern == "" || (braced && t.Modifier != "")
+	if t.Modifier != "" && t.Prefix != "" && !n.foldable(t.Prefix) {
+		inGroup = true
+	}
+	// The last character of the literal would be the prefix of the param.
+	if prev, ok := prev.(string); ok && t.Prefix == "" && t.Modifier != "" && prev != "" {
+		if last := []rune(prev); n.foldable(string(last[len(last)-1])) {
+			inGroup = true
+		}
+	}
+	// The name or the pattern would run into the next token.
+	if defaultPattern && t.Modifier == "" {
+		switch next := next.(type) {
+		case string:
+			inGroup = inGroup || isNameChar(next[0])
+		case Token:
+			inGroup = inGroup || (!next.IsNamed() && next.Prefix == "")
+		}
+	}
+
+	param := ""
+	if t.Pattern != "" {
+		if t.IsNamed() {
+			param = ":" + t.NameString()
+		}
+		if !defaultPattern || (inGroup && t.Suffix != "" && isNameChar(t.Suffix[0])) {
+			param += "(" + t.Pattern + ")"
+		}
+	}
+	if !inGroup {
+		return t.Prefix + param + t.Modifier
+	}
+	return "{" + n.escape(t.Prefix) + param + n.escape(t.Suffix) + "}" + t.Modifier
+}
+
+// Reports whether the text written before a param becomes its prefix.
+func (n *normalizer) foldable(prefix string) bool {
+	if n.escape(prefix) != prefix {
+		return false
+	}
+	tokens, err := Parse(prefix+":x", n.options)
+	return err == nil && len(tokens) == 1 && tokens[0].(Token).Prefix == prefix
+}
+
+// Escapes the characters of the template syntax in a literal, which can't be
+// escaped with `Options.LiteralBackslash`.
+func (n *normalizer) escape(str string) string {
+	if n.options != nil && n.options.LiteralBackslash {
+		return str
+	}
+	var b strings.Builder
+	for _, r := range str {
+		if strings.ContainsRune(`:(){}*+?\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Reports whether the character continues the name of a param.
+func isNameChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_'
+}
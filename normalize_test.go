@@ -0,0 +1,127 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("should generate the same regexp", func(t *testing.T) {
+		tests := []struct {
+			rule   string
+			path   string
+			expect string
+		}{
+			{"default pattern", `/:id([^\/#\?]+?)`, "/:id"},
+			{"default pattern", `/:id([^\/#\?]+?)?`, "/:id?"},
+			{"escapes", `/foo\.bar\-baz`, "/foo.bar-baz"},
+			{"escapes", `/\:literal\(x\)`, `/\:literal\(x\)`},
+			{"group of a param", "{/:id}?", "/:id?"},
+			{"group of a param", "{.:ext}?", ".:ext?"},
+			{"group of a pattern", `{/(\d+)}*`, `/(\d+)*`},
+			{"group of a pattern", `/{(\d+)}*`, `/{(\d+)}*`},
+			{"canonical", `/:id(\d+)`, `/:id(\d+)`},
+			{"canonical", "/:id{-:rev}?", "/:id{-:rev}?"},
+			{"canonical", "/{:lang}?", "/{:lang}?"},
+			{"canonical", "{/x}?", "{/x}?"},
+		}
+		for _, test := range tests {
+			str, err := Normalize(test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if str != test.expect {
+				t.Errorf("%s: "+testErrorFormat, test.rule, str, test.expect)
+			}
+			source, err := RouteSource(test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			normalized, err := RouteSource(str, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalized != source {
+				t.Errorf("%s: "+testErrorFormat, test.rule, normalized, source)
+			}
+		}
+	})
+
+	t.Run("should generate the same regexp up to the groups", func(t *testing.T) {
+		tests := []struct {
+			rule      string
+			path      string
+			expect    string
+			pathnames []string
+		}{
+			{"unwrapped group", "{/x}/:id", "/x/:id", []string{"/x/1", "/x", "/y/1"}},
+			{"folded prefix", "/{-:id}", "/-:id", []string{"/-1", "/1", "/-"}},
+			{"folded prefix", "{/:id}", "/:id", []string{"/1", "/", "/1/2"}},
+			{"folded suffix", "/{:id-}x", "/:id-x", []string{"/1-x", "/1x", "/-x"}},
+			{"name run into the literal", "/{:id}abc", "/{:id}abc", []string{"/1abc", "/abc"}},
+			{"name run into the pattern", `/{:id}{(\d+)}`, `/{:id}(\d+)`, []string{"/a1", "/1"}},
+		}
+		for _, test := range tests {
+			str, err := Normalize(test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if str != test.expect {
+				t.Errorf("%s: "+testErrorFormat, test.rule, str, test.expect)
+			}
+			// Both are the regexp of the tokens without the groups.
+			sources := make([]string, 2)
+			for i, path := range []string{test.path, str} {
+				tokens, err := Parse(path, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if sources[i], err = tokensToSource(flattenTokens(tokens), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if sources[1] != sources[0] {
+				t.Errorf("%s: "+testErrorFormat, test.rule, sources[1], sources[0])
+			}
+			for _, pathname := range test.pathnames {
+				expect, _ := mustMatcher(test.path, nil).Match(pathname)
+				result, _ := mustMatcher(str, nil).Match(pathname)
+				if !reflect.DeepEqual(result, expect) {
+					t.Errorf("%s: "+testErrorFormat, test.rule, result, expect)
+				}
+			}
+		}
+	})
+
+	t.Run("should keep the query of the query params", func(t *testing.T) {
+		str, err := Normalize(`/:id([^\/#\?]+?)?page=:page`, &Options{QueryParams: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "/:id?page=:page"; str != expect {
+			t.Errorf(testErrorFormat, str, expect)
+		}
+	})
+
+	t.Run("should reject the templates it can't normalize", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			expect  string
+		}{
+			{"/:id(", nil, "unbalanced pattern"},
+			{"/:id", &Options{MatrixParams: true}, "templates with a compat mode or matrix params can't be normalized"},
+		}
+		for _, test := range tests {
+			_, err := Normalize(test.path, test.options)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expect) {
+				t.Errorf(testErrorFormat, err, test.expect)
+			}
+		}
+	})
+}
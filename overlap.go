@@ -0,0 +1,572 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Overlaps reports whether a pathname can be matched by both paths, so that
+// registering both routes may conflict.
+//
+// A static path is tested against the other path exactly. Otherwise the paths
+// are compared segment by segment: static text against a param is tested with
+// the pattern of the param, while two params only don't overlap when their
+// patterns are known to be disjoint, from their first characters or from the
+// strings matched by the patterns made of alternations. Segments mixing static
+// text and params, and params whose pattern can match a delimiter, overlap
+// with anything, so the result may be a false positive but never a false
+// negative.
+func Overlaps(a, b string, options *Options) (bool, error) {
+	c := newOverlapChecker(options)
+	x, err := c.template(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := c.template(b)
+	if err != nil {
+		return false, err
+	}
+	return c.overlaps(x, y), nil
+}
+
+// FindConflicts returns the pairs of indexes of the paths which overlap, the
+// first index of a pair being the lower one. A *CompileError is returned for
+// the first path which fails to compile.
+func FindConflicts(paths []string, options *Options) ([][2]int, error) {
+	c := newOverlapChecker(options)
+	templates := make([]*overlapTemplate, len(paths))
+	for i, path := range paths {
+		t, err := c.template(path)
+		if err != nil {
+			return nil, &CompileError{Index: i, Path: path, Err: err}
+		}
+		templates[i] = t
+	}
+
+	var conflicts [][2]int
+	for i := range templates {
+		for j := i + 1; j < len(templates); j++ {
+			if c.overlaps(templates[i], templates[j]) {
+				conflicts = append(conflicts, [2]int{i, j})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// overlapChecker compares templates parsed with the same options.
+type overlapChecker struct {
+	options   *Options
+	delimiter string
+	patterns  map[string]*regexp2.Regexp
+}
+
+// overlapTemplate is a path split in segments.
+type overlapTemplate struct {
+	matcher *Matcher
+
+	// the literal of a static path
+	literal string
+	static  bool
+
+	segments []overlapSegment
+}
+
+// overlapSegment is static text, a param, or a wildcard overlapping with any
+// segment, which is repeated between min and max times, -1 meaning no limit.
+type overlapSegment struct {
+	// the delimiter starting the segment, empty for the first segment
+	// when the path doesn't start with a delimiter
+	delimiter string
+
+	text     string
+	pattern  string
+	wildcard bool
+	min, max int
+}
+
+func newOverlapChecker(options *Options) *overlapChecker {
+	if options == nil {
+		options = &Options{}
+	}
+	return &overlapChecker{options: options, delimiter: anyString(options.Delimiter, defaultDelimiter),
+		patterns: make(map[string]*regexp2.Regexp)}
+}
+
+// Parses the path in segments.
+func (c *overlapChecker) template(path string) (*overlapTemplate, error) {
+	rawTokens, err := Parse(path, c.options)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewMatcher(path, c.options)
+	if err != nil {
+		return nil, err
+	}
+	encode := encoder(c.options)
+
+	t := &overlapTemplate{matcher: m, static: true}
+	var literal strings.Builder
+	var cur *overlapSegment
+	flush := func(s overlapSegment) {
+		t.segments = append(t.segments, s)
+		cur = &t.segments[len(t.segments)-1]
+	}
+	addText := func(str string) {
+		for _, r := range str {
+			switch {
+			case strings.ContainsRune(c.delimiter, r):
+				flush(overlapSegment{delimiter: string(r), min: 1, max: 1})
+			case cur == nil:
+				flush(overlapSegment{text: string(r), min: 1, max: 1})
+			case cur.pattern != "":
+				// Static text following a param in the same segment.
+				cur.wildcard, cur.min, cur.max = true, 0, -1
+			default:
+				cur.text += string(r)
+			}
+		}
+	}
+
+	for _, token := range rawTokens {
+		if str, ok := token.(string); ok {
+			literal.WriteString(encode(str, nil))
+			addText(encode(str, nil))
+			continue
+		}
+
+		token := token.(Token)
+		t.static = false
+		prefix, suffix := encode(token.Prefix, nil), encode(token.Suffix, nil)
+		if token.Pattern == "" && token.Modifier == "" {
+			addText(prefix + suffix)
+			continue
+		}
+
+		first, size := utf8.DecodeRuneInString(prefix)
+		startsSegment := prefix != "" && strings.ContainsRune(c.delimiter, first)
+		spanning := token.Pattern == "" || strings.ContainsAny(prefix[size:]+suffix, c.delimiter) ||
+			c.mayMatchDelimiter(token.Pattern)
+		if !spanning && suffix == "" && (startsSegment || (prefix == "" && cur == nil)) {
+			s := overlapSegment{pattern: token.Pattern, min: 1, max: 1}
+			if startsSegment {
+				s.delimiter = prefix
+			}
+			switch token.Modifier {
+			case "?":
+				s.min = 0
+			case "+":
+				s.max = -1
+			case "*":
+				s.min, s.max = 0, -1
+			}
+			flush(s)
+			continue
+		}
+
+		// A param mixed with static text in a segment, or spanning segments.
+		if startsSegment || cur == nil {
+			flush(overlapSegment{wildcard: true, min: 0, max: -1})
+		} else {
+			cur.wildcard, cur.min, cur.max = true, 0, -1
+		}
+	}
+
+	// The other template also matches a trailing delimiter when not strict.
+	if n := len(t.segments); !c.options.Strict && n > 0 {
+		if last := &t.segments[n-1]; !last.wildcard && last.pattern == "" && last.text == "" {
+			last.min = 0
+		}
+	}
+
+	t.literal = literal.String()
+	return t, nil
+}
+
+// Reports whether the templates overlap.
+func (c *overlapChecker) overlaps(x, y *overlapTemplate) bool {
+	o := c.options
+	if o.Start != nil && !*o.Start {
+		return true
+	}
+	end := o.End == nil || *o.End
+	if end && o.EndsWith == "" {
+		if x.static {
+			return c.matchesLiteral(y.matcher, x.literal)
+		}
+		if y.static {
+			return c.matchesLiteral(x.matcher, y.literal)
+		}
+	}
+
+	// Search the states reached by consuming the segments of both templates
+	// together, a state being the index of the current segment in each
+	// template and whether a repeated segment was consumed once.
+	type state struct {
+		i, j         int
+		usedX, usedY bool
+	}
+	a, b := x.segments, y.segments
+	visited := map[state]bool{}
+	queue := []state{{}}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if visited[s] {
+			continue
+		}
+		visited[s] = true
+
+		if s.i == len(a) && s.j == len(b) {
+			return true
+		}
+		// The rest of the pathname is free when not matching to the end.
+		if !end && (s.i == len(a) || s.j == len(b)) {
+			return true
+		}
+		if s.i < len(a) && (a[s.i].min == 0 || s.usedX) {
+			queue = append(queue, state{s.i + 1, s.j, false, s.usedY})
+		}
+		if s.j < len(b) && (b[s.j].min == 0 || s.usedY) {
+			queue = append(queue, state{s.i, s.j + 1, s.usedX, false})
+		}
+		if s.i < len(a) && s.j < len(b) && c.segmentsOverlap(a[s.i], b[s.j]) {
+			next := state{s.i + 1, s.j + 1, false, false}
+			if a[s.i].max < 0 {
+				next.i, next.usedX = s.i, true
+			}
+			if b[s.j].max < 0 {
+				next.j, next.usedY = s.j, true
+			}
+			queue = append(queue, next)
+		}
+	}
+	return false
+}
+
+// Reports whether the matcher matches the literal of a static template, or
+// the literal followed by a delimiter when not strict.
+func (c *overlapChecker) matchesLiteral(m *Matcher, literal string) bool {
+	candidates := []string{literal}
+	if !c.options.Strict {
+		for _, r := range c.delimiter {
+			candidates = append(candidates, literal+string(r))
+		}
+	}
+	for _, candidate := range candidates {
+		// An error means the regexp matched but the params were invalid.
+		if result, err := m.find(candidate); result != nil || err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a string can be matched by both segments.
+func (c *overlapChecker) segmentsOverlap(x, y overlapSegment) bool {
+	if x.wildcard || y.wildcard {
+		return true
+	}
+	if x.delimiter != y.delimiter {
+		return false
+	}
+	switch {
+	case x.pattern == "" && y.pattern == "":
+		if c.options.Sensitive {
+			return x.text == y.text
+		}
+		return equalFold(x.text, y.text)
+	case x.pattern == "":
+		return c.fullMatch(y.pattern, x.text)
+	case y.pattern == "":
+		return c.fullMatch(x.pattern, y.text)
+	}
+	return c.patternsOverlap(x.pattern, y.pattern)
+}
+
+// Reports whether the pattern matches the whole string, a pattern which fails
+// to compile matches anything.
+func (c *overlapChecker) fullMatch(pattern, str string) bool {
+	re, ok := c.patterns[pattern]
+	if !ok {
+		re, _ = compile("^(?:"+pattern+")$", c.options)
+		c.patterns[pattern] = re
+	}
+	if re == nil {
+		return true
+	}
+	matched, err := re.MatchString(str)
+	return matched || err != nil
+}
+
+// Reports whether the patterns may match a same string.
+func (c *overlapChecker) patternsOverlap(x, y string) bool {
+	if x == y {
+		return true
+	}
+	re1, ok1 := c.parsePattern(x)
+	re2, ok2 := c.parsePattern(y)
+	if !ok1 || !ok2 {
+		return true
+	}
+
+	// Test the strings of a pattern made of alternations against the other.
+	if strs, ok := finiteStrings(re1); ok {
+		for _, str := range strs {
+			if c.fullMatch(y, str) {
+				return true
+			}
+		}
+		return false
+	}
+	if strs, ok := finiteStrings(re2); ok {
+		for _, str := range strs {
+			if c.fullMatch(x, str) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first1, nullable1 := firstRunes(re1)
+	first2, nullable2 := firstRunes(re2)
+	if nullable1 || nullable2 {
+		return true
+	}
+	return rangesIntersect(first1, first2)
+}
+
+// Reports whether the pattern may match a delimiter.
+func (c *overlapChecker) mayMatchDelimiter(pattern string) bool {
+	re, ok := c.parsePattern(pattern)
+	if !ok {
+		return true
+	}
+	var delimiters []rune
+	for _, r := range c.delimiter {
+		delimiters = append(delimiters, r, r)
+	}
+	return mayMatchRunes(re, delimiters)
+}
+
+// Parses a regexp2 pattern with the standard library parser, the classes
+// which are Unicode aware in regexp2 are widened. The syntax only known by
+// regexp2 fails to parse.
+func (c *overlapChecker) parsePattern(pattern string) (*syntax.Regexp, bool) {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			class := ""
+			switch pattern[i] {
+			case 'd':
+				class = `\p{Nd}`
+			case 'w':
+				class = `\p{L}\p{M}\p{N}\p{Pc}`
+			case 's':
+				class = `\s\p{Z}\x85\v`
+			default:
+				b.WriteByte(ch)
+				b.WriteByte(pattern[i])
+				continue
+			}
+			if !inClass {
+				class = "[" + class + "]"
+			}
+			b.WriteString(class)
+			continue
+		case ch == '[' && !inClass:
+			inClass = true
+			b.WriteByte(ch)
+			// A leading `]` or `^]` is a character of the class.
+			if strings.HasPrefix(pattern[i+1:], "^]") {
+				b.WriteString("^]")
+				i += 2
+			} else if strings.HasPrefix(pattern[i+1:], "]") {
+				b.WriteString("]")
+				i++
+			}
+			continue
+		case ch == ']' && inClass:
+			inClass = false
+		}
+		b.WriteByte(ch)
+	}
+
+	flags := syntax.Perl
+	if !c.options.Sensitive {
+		flags |= syntax.FoldCase
+	}
+	re, err := syntax.Parse(b.String(), flags)
+	if err != nil {
+		return nil, false
+	}
+	return re, true
+}
+
+// The maximum number of strings expanded from a pattern.
+const maxFiniteStrings = 64
+
+// Returns the strings matched by a regexp made of literals, small classes,
+// concatenations and alternations.
+func finiteStrings(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}, true
+	case syntax.OpCharClass:
+		var strs []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(strs) == maxFiniteStrings {
+					return nil, false
+				}
+				strs = append(strs, string(r))
+			}
+		}
+		return strs, true
+	case syntax.OpCapture:
+		return finiteStrings(re.Sub[0])
+	case syntax.OpQuest:
+		strs, ok := finiteStrings(re.Sub[0])
+		return append(strs, ""), ok && len(strs) < maxFiniteStrings
+	case syntax.OpAlternate:
+		var strs []string
+		for _, sub := range re.Sub {
+			s, ok := finiteStrings(sub)
+			if !ok || len(strs)+len(s) > maxFiniteStrings {
+				return nil, false
+			}
+			strs = append(strs, s...)
+		}
+		return strs, true
+	case syntax.OpConcat:
+		strs := []string{""}
+		for _, sub := range re.Sub {
+			s, ok := finiteStrings(sub)
+			if !ok || len(strs)*len(s) > maxFiniteStrings {
+				return nil, false
+			}
+			product := make([]string, 0, len(strs)*len(s))
+			for _, prefix := range strs {
+				for _, suffix := range s {
+					product = append(product, prefix+suffix)
+				}
+			}
+			strs = product
+		}
+		return strs, true
+	}
+	return nil, false
+}
+
+// Returns the ranges of the first characters matched by the regexp, as pairs
+// of the lowest and the highest character, and whether it matches the empty
+// string.
+func firstRunes(re *syntax.Regexp) ([]rune, bool) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil, false
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return nil, true
+		}
+		r := re.Rune[0]
+		ranges := []rune{r, r}
+		if re.Flags&syntax.FoldCase != 0 {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				ranges = append(ranges, f, f)
+			}
+		}
+		return ranges, false
+	case syntax.OpCharClass:
+		return re.Rune, false
+	case syntax.OpAnyCharNotNL:
+		return []rune{0, '\n' - 1, '\n' + 1, unicode.MaxRune}, false
+	case syntax.OpAnyChar:
+		return []rune{0, unicode.MaxRune}, false
+	case syntax.OpCapture, syntax.OpPlus:
+		return firstRunes(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		ranges, _ := firstRunes(re.Sub[0])
+		return ranges, true
+	case syntax.OpRepeat:
+		ranges, nullable := firstRunes(re.Sub[0])
+		return ranges, nullable || re.Min == 0
+	case syntax.OpConcat:
+		var ranges []rune
+		for _, sub := range re.Sub {
+			r, nullable := firstRunes(sub)
+			ranges = append(ranges, r...)
+			if !nullable {
+				return ranges, false
+			}
+		}
+		return ranges, true
+	case syntax.OpAlternate:
+		var ranges []rune
+		nullable := false
+		for _, sub := range re.Sub {
+			r, n := firstRunes(sub)
+			ranges = append(ranges, r...)
+			nullable = nullable || n
+		}
+		return ranges, nullable
+	}
+	// Anchors and word boundaries match the empty string.
+	return nil, true
+}
+
+// Reports whether the regexp has a character matching one of the ranges.
+func mayMatchRunes(re *syntax.Regexp, ranges []rune) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if rangesIntersect([]rune{r, r}, ranges) {
+				return true
+			}
+			if re.Flags&syntax.FoldCase != 0 {
+				for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+					if rangesIntersect([]rune{f, f}, ranges) {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	case syntax.OpCharClass:
+		return rangesIntersect(re.Rune, ranges)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if mayMatchRunes(sub, ranges) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether two lists of character ranges intersect.
+func rangesIntersect(x, y []rune) bool {
+	for i := 0; i+1 < len(x); i += 2 {
+		for j := 0; j+1 < len(y); j += 2 {
+			if x[i] <= y[j+1] && y[j] <= x[i+1] {
+				return true
+			}
+		}
+	}
+	return false
+}
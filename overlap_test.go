@@ -0,0 +1,122 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		options *Options
+		expect  bool
+	}{
+		// static paths
+		{"/users/new", "/users/new", nil, true},
+		{"/users/new", "/USERS/new/", nil, true},
+		{"/users/new", "/USERS/new", &Options{Sensitive: true}, false},
+		{"/users", "/users/", &Options{Strict: true}, false},
+		{"/users", "/users/new", nil, false},
+
+		// static text against params
+		{"/users/:id", "/users/new", nil, true},
+		{"/users/new", "/users/:id", nil, true},
+		{"/users/:id(\\d+)", "/users/new", nil, false},
+		{"/users/:id(\\d+)", "/users/42", nil, true},
+		{"/users/:id(\\d+)/edit", "/users/new/edit", nil, false},
+		{"/users/:id/edit", "/users/new/:action", nil, true},
+		{"/users/:id/edit", "/users/new/:action(show|delete)", nil, false},
+		{"/:foo/bar", "/baz/:qux", nil, true},
+
+		// params against params
+		{"/users/:id", "/users/:name", nil, true},
+		{"/users/:id(\\d+)", "/users/:name([a-z]+)", nil, false},
+		{"/users/:id(\\d+)", "/users/:name(\\w+)", nil, true},
+		{"/users/:id(\\d+)", "/users/:name(x\\d+)", nil, false},
+		{"/users/:id(\\d+)", "/users/:name(X\\d+)", nil, false},
+		{"/users/:action(edit|show)", "/users/:name(new|delete)", nil, false},
+		{"/users/:action(edit|show)", "/users/:name(new|show)", nil, true},
+		{"/users/:action(edit|show)", "/users/:id(\\d+)", nil, false},
+		{"/users/:id(\\d*)", "/users/:name([a-z]+)", nil, true},
+		{"/users/:id(\\d+)", "/posts/:id(\\d+)", nil, false},
+
+		// optional segments
+		{"/users/:id?", "/users", nil, true},
+		{"/users/:id?", "/users/:id/posts", nil, false},
+		{"/users/:id?/posts", "/users/posts", nil, true},
+		{"/users/:id", "/users/:name?/", nil, true},
+		{"/users/:id", "/users/:name?/", &Options{Strict: true}, false},
+		{"/users{/:id}?/posts", "/users/:x/:y/posts", nil, false},
+		{"/users/:id(\\d+)?/posts", "/users/:x([a-z]+)/posts", nil, false},
+
+		// repeated params
+		{"/files/:path+", "/files", nil, false},
+		{"/files/:path*", "/files", nil, true},
+		{"/files/:path+", "/files/a/b/:c", nil, true},
+		{"/files/:path(\\d+)+", "/files/1/:c([a-z]+)", nil, false},
+		{"/files/:path+/edit", "/files/:a/:b/edit", nil, true},
+		{"/files/:path+/edit", "/files/edit", nil, false},
+		{"/:all*", "/users/new", nil, true},
+
+		// conservative cases
+		{"/:path(.*)", "/users/:id/posts", nil, true},
+		{"/users{-x}?", "/:name([a-z]+-x)", nil, true},
+		{"/icon-:foo(\\d+).png", "/:file", nil, true},
+		{"/users/:id((?=a)\\w+)", "/users/:name(\\d+)", nil, true},
+		{"/a", "/b", &Options{Start: &falseValue}, true},
+
+		// matching prefixes
+		{"/users", "/users/:id", &Options{End: &falseValue}, true},
+		{"/users", "/posts/:id", &Options{End: &falseValue}, false},
+	}
+
+	for _, test := range tests {
+		result, err := Overlaps(test.a, test.b, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.expect {
+			t.Errorf("%s %s: "+testErrorFormat, test.a, test.b, result, test.expect)
+		}
+		result, err = Overlaps(test.b, test.a, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != test.expect {
+			t.Errorf("%s %s: "+testErrorFormat, test.b, test.a, result, test.expect)
+		}
+	}
+
+	t.Run("should return the parse error", func(t *testing.T) {
+		if _, err := Overlaps("/:foo(", "/", nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
+
+func TestFindConflicts(t *testing.T) {
+	t.Run("should return the overlapping pairs", func(t *testing.T) {
+		paths := []string{"/users/new", "/users/:id(\\d+)", "/users/:name", "/posts", "/:all*"}
+		conflicts, err := FindConflicts(paths, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := [][2]int{{0, 2}, {0, 4}, {1, 2}, {1, 4}, {2, 4}, {3, 4}}
+		if !reflect.DeepEqual(conflicts, expect) {
+			t.Errorf(testErrorFormat, conflicts, expect)
+		}
+	})
+
+	t.Run("should return the error of the failed path", func(t *testing.T) {
+		_, err := FindConflicts([]string{"/a", "/:b("}, nil)
+		var compileErr *CompileError
+		if !errors.As(err, &compileErr) || compileErr.Index != 1 {
+			t.Errorf(testErrorFormat, err, "a *CompileError of path 1")
+		}
+	})
+}
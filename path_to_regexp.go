@@ -9,10 +9,15 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/dlclark/regexp2"
+	"github.com/dlclark/regexp2/syntax"
 )
 
 // Token is parsed from path. For example, using `/user/:id`, `tokens` will
@@ -32,6 +37,35 @@ type Token struct {
 
 	// The modifier character used for the segment (e.g. `?`)
 	Modifier string
+
+	// The matrix params of the segment with `Options.MatrixParams`, the name
+	// being the params as written in the template (e.g. `;id=:id`)
+	Matrix *Matrix
+}
+
+// IsNamed reports whether the token is a named param, e.g. `:id`.
+func (t Token) IsNamed() bool {
+	_, ok := t.Name.(string)
+	return ok
+}
+
+// NameString returns the name of the token, or the decimal form of its
+// index for an unnamed param, as used for the keys of the Compile data.
+func (t Token) NameString() string {
+	switch name := t.Name.(type) {
+	case string:
+		return name
+	case int:
+		return strconv.Itoa(name)
+	}
+	return fmt.Sprintf("%v", t.Name)
+}
+
+// Index returns the index of an unnamed param, e.g. `(\d+)`, and whether the
+// token is one.
+func (t Token) Index() (int, bool) {
+	index, ok := t.Name.(int)
+	return index, ok
 }
 
 // Options contains some optional configs
@@ -51,9 +85,21 @@ type Options struct {
 	// When `false` the function can produce an invalid (unmatched) path. (default: `true`)
 	Validate *bool
 
-	// Sets the final character for non-ending optimistic matches. (default: `/`)
+	// Sets the final character, or sequence such as `::`, for non-ending optimistic matches. (default: `/`)
 	Delimiter string
 
+	// When true a backslash and a `:` without a name are literal characters. (default: `false`)
+	LiteralBackslash bool
+
+	// When true a name may hold dots, e.g. `:user.id`, looked up in nested data. (default: `false`)
+	DotNames bool
+
+	// The separator of the values of a repeated param without a prefix nor a suffix. (default: `""`)
+	RepeatSeparator string
+
+	// The characters excluded from the default pattern, the Delimiter when empty. (default: `""`)
+	ExcludeChars string
+
 	// Optional character to treat as "end" characters.
 	EndsWith string
 
@@ -63,8 +109,231 @@ type Options struct {
 	// how to encode uri
 	Encode func(uri string, token interface{}) string
 
+	// The built-in encoder used when Encode is nil. (default: `EncodingNone`)
+	Encoding Encoding
+
 	// how to decode uri
 	Decode func(str string, token interface{}) (string, error)
+
+	// The encoder of the values of the params, instead of Encode and Encoding. (default: `nil`)
+	Encoder Encoder
+
+	// The decoder of the values of the params, instead of Decode and DecodeValues. (default: `nil`)
+	Decoder Decoder
+
+	// When true and Decode is nil the params are decoded with DecodeURIComponent. (default: `false`)
+	DecodeValues bool
+
+	// Bounds the work done for untrusted templates. (default: `nil`, unlimited)
+	Limits *Limits
+
+	// The maximum length of the generated regexp source, zero means unlimited. (default: `0`)
+	MaxRegexpLen int
+
+	// The maximum length of the built paths, zero means unlimited. (default: `0`)
+	MaxPathLen int
+
+	// The maximum number of values of a repeated param, zero means unlimited. (default: `0`)
+	MaxRepeats int
+
+	// The maximum duration of a single regexp execution, zero means no timeout. (default: `0`)
+	MatchTimeout time.Duration
+
+	// The maximum number of results cached by a matcher, zero disables the cache. (default: `0`)
+	ResultCache int
+
+	// When true the match function returns ErrNoMatch when the pathname doesn't match. (default: `false`)
+	ErrorOnNoMatch bool
+
+	// When true patterns reported by CheckPattern are rejected when parsing. (default: `false`)
+	RejectDangerousPatterns bool
+
+	// When true ambiguous repeated params are rejected when parsing. (default: `false`)
+	StrictAmbiguity bool
+
+	// When true decoded params and compiled values must be valid UTF-8. (default: `false`)
+	RequireValidUTF8 bool
+
+	// When true the compiled function rejects values producing `.` or `..` segments. (default: `false`)
+	RejectTraversal bool
+
+	// The regexp engine of the match and path functions, regexp2 when nil. (default: `nil`)
+	Engine Engine
+
+	// When true `\w`, `\d` and `\s` in the token patterns match the Unicode classes. (default: `false`)
+	UnicodeClasses bool
+
+	// The regexp2 flags added to the flags of every regexp compiled with regexp2. (default: `0`)
+	RegexFlags regexp2.RegexOptions
+
+	// When true a string template may declare `?key=:name` query params. (default: `false`)
+	QueryParams bool
+
+	// When true the `;key=:name` sequences of a segment are parsed into a matrix token. (default: `false`)
+	MatrixParams bool
+
+	// The names of the params matched case sensitively when Sensitive is false. (default: `nil`)
+	SensitiveParams []string
+
+	// The names of the params matched ignoring the case when Sensitive is true. (default: `nil`)
+	InsensitiveParams []string
+
+	// When true the path function percent-encodes the non-ASCII bytes of the path. (default: `false`)
+	ASCIIOnly bool
+
+	// When true the path function checks that each path matches back to its values. (default: `false`)
+	RoundTrip bool
+
+	// When true the path function checks that each path matches the template. (default: `false`)
+	SelfCheck bool
+
+	// When true the path function returns the path in lower case. (default: `false`)
+	LowercasePath bool
+
+	// The template syntax and the matching of another router. (default: `CompatNone`)
+	Compat Compat
+
+	// How the match function treats a repeated delimiter. (default: `DuplicateDelimitersAllow`)
+	DuplicateDelimiters DuplicateDelimiters
+
+	// When true the match function prefers the longest match of the optional tokens. (default: `false`)
+	LeftmostLongest bool
+
+	// When true integer and decimal params are matched as int64 and float64 values. (default: `false`)
+	TypedParams bool
+
+	// When true the params used several times in a template only match the same text. (default: `false`)
+	BindDuplicates bool
+
+	// The constraints of the matched params by name. (default: `nil`)
+	Constraints map[string]func(value string) bool
+
+	// When true the decoded params are tested against the patterns of their tokens. (default: `false`)
+	ValidateMatch bool
+
+	// When true with ValidateMatch a failing value returns a *ValidationError. (default: `false`)
+	ValidateMatchError bool
+
+	// The callbacks called after each match and each path built. (default: `nil`)
+	Hooks *Hooks
+
+	// When true TemplatesEquivalent and ExplainDifference ignore the param names. (default: `false`)
+	IgnoreParamNames bool
+}
+
+// Limits contains the bounds applied when parsing untrusted templates,
+// zero values mean unlimited
+type Limits struct {
+	// The maximum length of the template in bytes
+	MaxTemplateLen int
+
+	// The maximum number of tokens (literal strings and parameters) produced by Parse
+	MaxTokens int
+
+	// The maximum length of a custom parameter pattern in characters
+	MaxPatternLen int
+}
+
+// LimitError is returned when a template exceeds one of the configured limits
+type LimitError struct {
+	// The name of the limit which was exceeded (e.g. `MaxTokens`)
+	Limit string
+
+	// The configured maximum
+	Max int
+
+	// The index in the template at which the limit was exceeded, or the length
+	// of the generated regexp for `MaxRegexpLen`
+	Index int
+
+	// The source templates of the generated regexp, only set for `MaxRegexpLen`
+	Templates []string
+}
+
+func (e *LimitError) Error() string {
+	msg := fmt.Sprintf("%s of %d exceeded at %d", e.Limit, e.Max, e.Index)
+	if len(e.Templates) > 0 {
+		quoted := make([]string, len(e.Templates))
+		for i, v := range e.Templates {
+			quoted[i] = quote(v)
+		}
+		msg += " by " + strings.Join(quoted, ", ")
+	}
+	return msg
+}
+
+// PathLenError is returned by the path function when the path exceeds
+// `Options.MaxPathLen`
+type PathLenError struct {
+	// The configured maximum
+	Max int
+
+	// The name of the token whose value exceeded the maximum, nil when it was
+	// exceeded by a literal, by the query or by the transformation of the path
+	Token interface{}
+
+	// The length of the path when the maximum was exceeded
+	Len int
+}
+
+func (e *PathLenError) Error() string {
+	if e.Token == nil {
+		return fmt.Sprintf("MaxPathLen of %d exceeded with %d", e.Max, e.Len)
+	}
+	return fmt.Sprintf("MaxPathLen of %d exceeded by \"%v\" with %d", e.Max, e.Token, e.Len)
+}
+
+// RepeatsError is returned by the path function when a repeated param has
+// more values than `Options.MaxRepeats`
+type RepeatsError struct {
+	// The configured maximum
+	Max int
+
+	// The name of the token
+	Token interface{}
+
+	// The number of values
+	Len int
+}
+
+func (e *RepeatsError) Error() string {
+	return fmt.Sprintf("MaxRepeats of %d exceeded by \"%v\" with %d", e.Max, e.Token, e.Len)
+}
+
+// UTF8Error is returned when a param is not valid UTF-8 and
+// `Options.RequireValidUTF8` is set
+type UTF8Error struct {
+	// The name of the token
+	Token interface{}
+
+	// The raw value, as captured when matching or as passed when compiling
+	Value string
+
+	// The byte offset of the first invalid sequence in the value after decoding
+	Offset int
+}
+
+func (e *UTF8Error) Error() string {
+	return fmt.Sprintf("expected \"%v\" to be valid UTF-8, but got invalid byte at %d of \"%v\"",
+		e.Token, e.Offset, e.Value)
+}
+
+// ValidationError is returned by the match function when a decoded value
+// doesn't match the pattern of its token, with `Options.ValidateMatch` and
+// `Options.ValidateMatchError` set
+type ValidationError struct {
+	// The name of the token
+	Token interface{}
+
+	// The pattern of the token
+	Pattern string
+
+	// The decoded value
+	Value string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("expected decoded \"%v\" to match \"%v\", but got \"%v\"", e.Token, e.Pattern, e.Value)
 }
 
 // MatchResult contains the result of match function
@@ -75,8 +344,40 @@ type MatchResult struct {
 	// matched start index
 	Index int
 
-	// matched params in url
+	// matched params in url, by the names of the named params and by the int
+	// indexes of the unnamed params, see Param
 	Params map[interface{}]interface{}
+
+	// matched url path decoded like the params, when `Options.Decode` or
+	// `Options.DecodeValues` is set, the decode function being given a nil
+	// token. It's empty when the params aren't decoded, or when the path fails
+	// to decode, which doesn't fail the match.
+	DecodedPath string
+
+	// the matched text followed by the text of each capture group of the
+	// regexp, as the regexp saw it before any decoding or splitting, a group
+	// which didn't take part in the match being empty
+	Groups []string
+}
+
+// Param returns the value of the param, or nil if it didn't match. As in the
+// Compile data, an unnamed param can be given by its index, e.g. `0`, or by
+// the decimal form of the index, e.g. `"0"`, unless a named param has this
+// name.
+func (r *MatchResult) Param(name interface{}) interface{} {
+	if r == nil {
+		return nil
+	}
+	if s, ok := name.(string); ok {
+		if value, ok := r.Params[s]; ok {
+			return value
+		}
+		if index, err := strconv.Atoi(s); err == nil && strconv.Itoa(index) == s {
+			return r.Params[index]
+		}
+		return nil
+	}
+	return paramValue(r.Params, name)
 }
 
 type lexTokenMode uint8
@@ -98,38 +399,115 @@ type lexToken struct {
 	value string
 }
 
-var escapeRegexp = regexp2.MustCompile("([.+*?=^!:${}()[\\]|/\\\\])", regexp2.None)
-var tokenRegexp = regexp2.MustCompile("\\((?!\\?)", regexp2.None)
+// ErrMatchTimeout is wrapped by the error returned when a regexp execution
+// exceeds `Options.MatchTimeout`, use `errors.Is` to detect it.
+var ErrMatchTimeout = errors.New("regexp match timed out")
+
+// ErrNoMatch is wrapped by the error returned by the match function when the
+// pathname doesn't match and `Options.ErrorOnNoMatch` is set, use `errors.Is`
+// to detect it.
+var ErrNoMatch = errors.New("path doesn't match")
 
 func identity(uri string, token interface{}) string {
 	return uri
 }
 
+// Encoding selects a built-in encoder of the values and the literals of the
+// templates, see `Options.Encoding`.
+type Encoding int
+
+const (
+	// EncodingNone keeps the strings as they are.
+	EncodingNone Encoding = iota
+
+	// EncodingURIComponent encodes the strings with EncodeURIComponent.
+	EncodingURIComponent
+
+	// EncodingURI encodes the strings like javascript's encodeURI, keeping
+	// the reserved characters `;/?:@&=+$,#`.
+	EncodingURI
+)
+
+// Returns the encode function of the options, Encode taking precedence over
+// Encoding.
+func encoder(options *Options) func(uri string, token interface{}) string {
+	if options == nil {
+		return identity
+	}
+	if options.Encode != nil {
+		return options.Encode
+	}
+	switch options.Encoding {
+	case EncodingURIComponent:
+		return encodeURIComponent
+	case EncodingURI:
+		return func(uri string, token interface{}) string {
+			return encodeURI(uri)
+		}
+	}
+	return identity
+}
+
 // EncodeURIComponent encodes a text string as a valid component of a Uniform
-// Resource Identifier (URI).
+// Resource Identifier (URI). Like javascript's encodeURIComponent, every byte
+// except `A-Z a-z 0-9 - _ . ! ~ * ' ( )` is percent-encoded.
 func EncodeURIComponent(str string) string {
-	return strings.Replace(url.QueryEscape(str), "+", "%20", -1)
+	return percentEncode(str, &uriComponentUnescaped)
 }
 
-// Gets the unencoded version of an encoded component of a Uniform Resource
-// Identifier (URI).
+// DecodeURIComponent gets the unencoded version of an encoded component of a
+// Uniform Resource Identifier (URI). Like javascript's decodeURIComponent, `+`
+// is a literal plus sign rather than a space.
 func DecodeURIComponent(str string) (string, error) {
-	return url.QueryUnescape(str)
+	return url.PathUnescape(str)
 }
 
-// Encodes a text string as a valid Uniform Resource Identifier (URI)
+// Encodes a text string as a valid Uniform Resource Identifier (URI). Like
+// javascript's encodeURI, the reserved characters `;/?:@&=+$,#` are kept in
+// addition to the ones kept by EncodeURIComponent.
 func encodeURI(str string) string {
-	excludes := ";/?:@&=+$,#"
-	arr := strings.Split(str, "")
-	result := ""
-	for _, v := range arr {
-		if strings.Contains(excludes, v) {
-			result += v
+	return percentEncode(str, &uriUnescaped)
+}
+
+const upperHex = "0123456789ABCDEF"
+
+// Bytes left unescaped by javascript's encodeURIComponent and encodeURI.
+var uriComponentUnescaped, uriUnescaped [256]bool
+
+func init() {
+	for c := 0; c < 256; c++ {
+		b := byte(c)
+		uriComponentUnescaped[c] = 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' ||
+			'0' <= b && b <= '9' || strings.IndexByte("-_.!~*'()", b) >= 0
+		uriUnescaped[c] = uriComponentUnescaped[c] || strings.IndexByte(";/?:@&=+$,#", b) >= 0
+	}
+}
+
+// Percent-encode every byte of the string which isn't kept unescaped.
+func percentEncode(str string, unescaped *[256]bool) string {
+	n := 0
+	for i := 0; i < len(str); i++ {
+		if !unescaped[str[i]] {
+			n++
+		}
+	}
+	if n == 0 {
+		return str
+	}
+
+	var b strings.Builder
+	b.Grow(len(str) + 2*n)
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if unescaped[c] {
+			b.WriteByte(c)
 		} else {
-			result += EncodeURIComponent(v)
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&15])
 		}
 	}
-	return result
+	return b.String()
 }
 
 // Gets the unencoded version of an encoded Uniform Resource Identifier (URI).
@@ -159,100 +537,162 @@ func decodeURI(str string) (string, error) {
 	return str, nil
 }
 
-// Tokenize input string.
-func lexer(str string) ([]lexToken, error) {
-	tokens, i := make([]lexToken, 0), 0
+// Tokenize input string. The indexes of the tokens (and in errors) count
+// characters rather than bytes, an invalid UTF-8 byte counts as one character.
+func lexer(str string, limits *Limits) ([]lexToken, error) {
+	return lex(make([]lexToken, 0, len(str)), str, limits, false, false)
+}
+
+// The token slices reused by Parse, slices grown beyond `maxPooledTokens` are
+// left to the garbage collector.
+var lexTokensPool = sync.Pool{New: func() interface{} { return new([]lexToken) }}
 
-	// use list to deal with unicode in str
-	arr := strings.Split(str, "")
+const maxPooledTokens = 1024
 
-	length := len(arr)
-	for i < length {
-		char := arr[i]
-		if char == "*" || char == "+" || char == "?" {
-			tokens = append(tokens, lexToken{mode: modeModifier, index: i, value: arr[i]})
-			i++
+// Like lexer, but appends the tokens to the given slice. With
+// `literalBackslash` a backslash outside of a pattern is a literal character
+// rather than an escape, and so is a `:` which isn't followed by a name. With
+// `dotNames` a dot between the characters of a name is part of it.
+func lex(tokens []lexToken, str string, limits *Limits, literalBackslash, dotNames bool) ([]lexToken, error) {
+	if limits == nil {
+		limits = &Limits{}
+	}
+	if limits.MaxTemplateLen > 0 && len(str) > limits.MaxTemplateLen {
+		return nil, &LimitError{Limit: "MaxTemplateLen", Max: limits.MaxTemplateLen,
+			Index: limits.MaxTemplateLen}
+	}
+
+	// `i` is the index of the current character and `pos` its byte offset,
+	// characters are decoded on the fly to avoid splitting the string
+	i, pos, length := 0, 0, len(str)
+	for pos < length {
+		char := str[pos]
+		if char == '*' || char == '+' || char == '?' {
+			tokens = append(tokens, lexToken{mode: modeModifier, index: i, value: str[pos : pos+1]})
+			i, pos = i+1, pos+1
 			continue
 		}
 
-		if char == "\\" {
-			tokens = append(tokens, lexToken{mode: modeEscapedChar, index: i, value: arr[i+1]})
-			i += 2
+		if char == '\\' && literalBackslash {
+			tokens = append(tokens, lexToken{mode: modeChar, index: i, value: str[pos : pos+1]})
+			i, pos = i+1, pos+1
 			continue
 		}
 
-		if char == "{" {
-			tokens = append(tokens, lexToken{mode: modeOpen, index: i, value: arr[i]})
-			i++
+		if char == '\\' {
+			if pos+1 >= length {
+				return nil, fmt.Errorf("missing escaped character at %d", i)
+			}
+			end := nextChar(str, pos+1)
+			tokens = append(tokens, lexToken{mode: modeEscapedChar, index: i, value: str[pos+1 : end]})
+			i, pos = i+2, end
 			continue
 		}
 
-		if char == "}" {
-			tokens = append(tokens, lexToken{mode: modeClose, index: i, value: arr[i]})
-			i++
+		if char == '{' {
+			tokens = append(tokens, lexToken{mode: modeOpen, index: i, value: str[pos : pos+1]})
+			i, pos = i+1, pos+1
 			continue
 		}
 
-		if char == ":" {
-			name, j := "", i+1
+		if char == '}' {
+			tokens = append(tokens, lexToken{mode: modeClose, index: i, value: str[pos : pos+1]})
+			i, pos = i+1, pos+1
+			continue
+		}
 
-			for j < length {
-				if len(arr[j]) == 1 {
-					code := arr[j][0]
-					isNumber := code >= 48 && code <= 57 // `0-9`
-					isUpper := code >= 65 && code <= 90  // `A-Z`
-					isLower := code >= 97 && code <= 122 // `a-z`
-					isUnderscore := code == 95           // `_`
-					if isNumber || isUpper || isLower || isUnderscore {
-						name += arr[j]
-						j++
-						continue
-					}
+		if char == ':' {
+			// name characters are ASCII, so bytes and characters are counted alike
+			end := pos + 1
+			for end < length {
+				code := str[end]
+				isNumber := code >= 48 && code <= 57 // `0-9`
+				isUpper := code >= 65 && code <= 90  // `A-Z`
+				isLower := code >= 97 && code <= 122 // `a-z`
+				isUnderscore := code == 95           // `_`
+				isDot := dotNames && code == '.' && end > pos+1 && end+1 < length && isNameChar(str[end+1])
+				if !isNumber && !isUpper && !isLower && !isUnderscore && !isDot {
+					break
 				}
-
-				break
+				end++
 			}
 
-			if name == "" {
+			if end == pos+1 && literalBackslash {
+				tokens = append(tokens, lexToken{mode: modeChar, index: i, value: str[pos : pos+1]})
+				i, pos = i+1, pos+1
+				continue
+			}
+			if end == pos+1 {
 				return nil, fmt.Errorf("missing parameter name at %d", i)
 			}
 
-			tokens = append(tokens, lexToken{mode: modeName, index: i, value: name})
-			i = j
+			tokens = append(tokens, lexToken{mode: modeName, index: i, value: str[pos+1 : end]})
+			i, pos = i+end-pos, end
 			continue
 		}
 
-		if char == "(" {
-			count, pattern, j := 1, "", i+1
+		if char == '(' {
+			count, j, p, inClass := 1, i+1, pos+1, false
 
-			if arr[j] == "?" {
+			if p < length && str[p] == '?' {
 				return nil, fmt.Errorf("pattern cannot start with \"?\" at %d", j)
 			}
 
-			for j < length {
-				if arr[j] == "\\" {
-					pattern += arr[j] + arr[j+1]
-					j += 2
+			for p < length {
+				if limits.MaxPatternLen > 0 && j-i-1 > limits.MaxPatternLen {
+					return nil, &LimitError{Limit: "MaxPatternLen", Max: limits.MaxPatternLen, Index: j}
+				}
+
+				if str[p] == '\\' && p+1 < length {
+					j, p = j+2, nextChar(str, p+1)
 					continue
 				}
 
-				if arr[j] == ")" {
+				// parentheses are literal characters in a character class
+				if inClass {
+					if str[p] == ']' {
+						inClass = false
+					}
+					j, p = j+1, nextChar(str, p)
+					continue
+				}
+
+				if str[p] == '[' {
+					inClass = true
+					j, p = j+1, p+1
+					// a leading `]` (or `^]`) is a literal in the class
+					if p < length && str[p] == '^' {
+						j, p = j+1, p+1
+					}
+					if p < length && str[p] == ']' {
+						j, p = j+1, p+1
+					}
+					continue
+				}
+
+				if str[p] == ')' {
 					count--
 					if count == 0 {
-						j++
 						break
 					}
-				} else if arr[j] == "(" {
+				} else if str[p] == '(' {
 					count++
-					if arr[j+1] != "?" {
+					if isCapturingGroup(str[p+1:]) {
 						return nil, fmt.Errorf("capturing groups are not allowed at %d", j)
 					}
 				}
 
-				pattern += arr[j]
-				j++
+				j, p = j+1, nextChar(str, p)
+			}
+
+			pattern, patternLen := str[pos+1:p], j-i-1
+			if count == 0 {
+				j, p = j+1, p+1
 			}
 
+			if limits.MaxPatternLen > 0 && patternLen > limits.MaxPatternLen {
+				return nil, &LimitError{Limit: "MaxPatternLen", Max: limits.MaxPatternLen, Index: j}
+			}
 			if count != 0 {
 				return nil, fmt.Errorf("unbalanced pattern at %d", i)
 			}
@@ -261,12 +701,13 @@ func lexer(str string) ([]lexToken, error) {
 			}
 
 			tokens = append(tokens, lexToken{mode: modePattern, index: i, value: pattern})
-			i = j
+			i, pos = j, p
 			continue
 		}
 
-		tokens = append(tokens, lexToken{mode: modeChar, index: i, value: arr[i]})
-		i++
+		end := nextChar(str, pos)
+		tokens = append(tokens, lexToken{mode: modeChar, index: i, value: str[pos:end]})
+		i, pos = i+1, end
 	}
 
 	tokens = append(tokens, lexToken{mode: modeEnd, index: i, value: ""})
@@ -274,24 +715,83 @@ func lexer(str string) ([]lexToken, error) {
 	return tokens, nil
 }
 
+// Returns the byte offset of the character following the one at pos.
+func nextChar(str string, pos int) int {
+	if str[pos] < utf8.RuneSelf {
+		return pos + 1
+	}
+	_, size := utf8.DecodeRuneInString(str[pos:])
+	return pos + size
+}
+
+// Reports whether a group, given the text following its opening parenthesis,
+// is capturing. Named groups (`(?<name>`, `(?'name'`, `(?P<name>`) capture
+// while lookarounds and other `(?` constructs don't.
+func isCapturingGroup(str string) bool {
+	if len(str) == 0 || str[0] != '?' {
+		return true
+	}
+	if len(str) > 2 && str[1] == '<' {
+		return str[2] != '=' && str[2] != '!'
+	}
+	if len(str) > 2 && str[1] == 'P' {
+		return str[2] == '<'
+	}
+	return len(str) > 1 && str[1] == '\''
+}
+
 // Parse a string for the raw tokens.
 func Parse(str string, options *Options) ([]interface{}, error) {
 	if options == nil {
 		options = &Options{}
 	}
-	tokens, err := lexer(str)
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	if options.Compat == CompatExpress4 {
+		return express4Parse(str)
+	}
+	if options.QueryParams {
+		str, _, _ = splitQuery(str)
+	}
+	buf := lexTokensPool.Get().(*[]lexToken)
+	tokens, err := lex((*buf)[:0], str, options.Limits, options.LiteralBackslash, options.DotNames)
 	if err != nil {
+		lexTokensPool.Put(buf)
 		return nil, err
 	}
+	defer func() {
+		if cap(tokens) <= maxPooledTokens {
+			// Release the strings of the template.
+			for i := range tokens {
+				tokens[i] = lexToken{}
+			}
+			*buf = tokens[:0]
+			lexTokensPool.Put(buf)
+		}
+	}()
+	maxTokens := 0
+	if options.Limits != nil {
+		maxTokens = options.Limits.MaxTokens
+	}
 	prefixes := "./"
+	if options.LiteralBackslash {
+		prefixes = "./\\"
+	}
 	if options.Prefixes != nil {
 		prefixes = *options.Prefixes
 	}
-	delimiter, err := escapeString(anyString(options.Delimiter, "/#?"))
-	if err != nil {
-		return nil, err
+	defaultPattern, sequence := paramPattern(options), ""
+	if options.ExcludeChars == "" {
+		sequence = delimiterSequence(options)
+	}
+	if options.MatrixParams {
+		if sequence != "" {
+			defaultPattern = "(?:(?!" + escapeCached(sequence) + ")[^;])+?"
+		} else {
+			defaultPattern = "[^" + escapeCached(excludeChars(options)) + ";]+?"
+		}
 	}
-	defaultPattern := "[^" + delimiter + "]+?"
 	result, key, i, path := make([]interface{}, 0), 0, 0, ""
 
 	tryConsume := func(mode lexTokenMode) *string {
@@ -312,6 +812,14 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 		return fmt.Errorf("unexpected %d at %d, expected %d", nextMode, index, mode)
 	}
 
+	push := func(token interface{}) error {
+		if maxTokens > 0 && len(result) >= maxTokens {
+			return &LimitError{Limit: "MaxTokens", Max: maxTokens, Index: tokens[i-1].index}
+		}
+		result = append(result, token)
+		return nil
+	}
+
 	consumeText := func() string {
 		result, value := "", tryConsume(modeChar)
 		if value == nil || *value == "" {
@@ -336,17 +844,21 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 				prefix = *char
 			}
 
-			if strings.Index(prefixes, prefix) == -1 {
+			if sequence != "" && strings.HasSuffix(path+prefix, sequence) {
+				path, prefix = strings.TrimSuffix(path+prefix, sequence), sequence
+			} else if strings.Index(prefixes, prefix) == -1 {
 				path += prefix
 				prefix = ""
 			}
 
 			if path != "" {
-				result = append(result, path)
+				if err := push(path); err != nil {
+					return nil, err
+				}
 				path = ""
 			}
 
-			result = append(result, Token{
+			err := push(Token{
 				Name: func() interface{} {
 					if name != nil && *name != "" {
 						return *name
@@ -371,6 +883,9 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 					return ""
 				}(),
 			})
+			if err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -386,7 +901,9 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 		}
 
 		if path != "" {
-			result = append(result, path)
+			if err := push(path); err != nil {
+				return nil, err
+			}
 			path = ""
 		}
 
@@ -399,7 +916,7 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 				return nil, err
 			}
 
-			result = append(result, Token{
+			err = push(Token{
 				Name: func() interface{} {
 					if name != nil && *name != "" {
 						return *name
@@ -430,6 +947,9 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 					return ""
 				}(),
 			})
+			if err != nil {
+				return nil, err
+			}
 
 			continue
 		}
@@ -440,16 +960,100 @@ func Parse(str string, options *Options) ([]interface{}, error) {
 		}
 	}
 
+	if options.RejectDangerousPatterns {
+		for _, token := range result {
+			if token, ok := token.(Token); ok {
+				if warnings := CheckPattern(token.Pattern); len(warnings) > 0 {
+					return nil, fmt.Errorf("dangerous pattern \"%v\" for \"%v\": %v",
+						token.Pattern, token.Name, warnings[0])
+				}
+			}
+		}
+	}
+
+	if options.RegexFlags&regexp2.RE2 != 0 {
+		for _, token := range result {
+			if token, ok := token.(Token); ok && token.Pattern != "" {
+				if _, err := regexp.Compile(token.Pattern); err != nil {
+					return nil, fmt.Errorf("pattern \"%v\" for \"%v\" isn't RE2: %v", token.Pattern, token.Name, err)
+				}
+			}
+		}
+	}
+
+	if options.StrictAmbiguity {
+		if warnings := ambiguousRepeats(result, options); len(warnings) > 0 {
+			return nil, fmt.Errorf("ambiguous repeated param \"%v\": %v", warnings[0].Token, warnings[0])
+		}
+	}
+
+	if options.MatrixParams {
+		return matrixTokens(result, options)
+	}
 	return result, nil
 }
 
+// ParseAll parses each of the paths for the raw tokens, keeping the tokens of
+// each template apart, unlike an array given to PathToRegexp. The error of the
+// first template failing to parse is returned as a *CompileError holding its
+// index. See TokenArray to build the regexp of the templates.
+func ParseAll(paths []string, options *Options) ([][]interface{}, error) {
+	groups := make([][]interface{}, len(paths))
+	for i, path := range paths {
+		tokens, err := Parse(path, options)
+		if err != nil {
+			return nil, &CompileError{Index: i, Path: path, Err: err}
+		}
+		groups[i] = tokens
+	}
+	return groups, nil
+}
+
+// TokenArray returns the array path of the tokens of each template, such as
+// the result of ParseAll, to be given to PathToRegexp or NewMatcher. The
+// alternative of a token in `Matcher.GroupMap` is the index of its template.
+func TokenArray(groups [][]interface{}) []interface{} {
+	path := make([]interface{}, len(groups))
+	for i, tokens := range groups {
+		path[i] = tokens
+	}
+	return path
+}
+
 // Compile a string to a template function for the path.
 func Compile(str string, options *Options) (func(interface{}) (string, error), error) {
-	tokens, err := Parse(str, options)
-	if err != nil {
-		return nil, err
+	if options != nil && options.Compat == CompatExpress4 {
+		return nil, errors.New("Express 4 templates can't be compiled")
+	}
+	var toPath func(interface{}) (string, error)
+	if options != nil && options.QueryParams {
+		f, err := queryFunction(str, options)
+		if err != nil {
+			return nil, err
+		}
+		toPath = f
+	} else {
+		tokens, err := Parse(str, options)
+		if err != nil {
+			return nil, err
+		}
+		if toPath, err = tokensToFunction(tokens, options); err != nil {
+			return nil, err
+		}
+	}
+	if options != nil && options.RoundTrip {
+		f, err := roundTripFunction(str, toPath, options)
+		if err != nil {
+			return nil, err
+		}
+		toPath = f
+	} else if options != nil && options.SelfCheck {
+		toPath = selfCheckFunction(str, toPath, options)
 	}
-	return tokensToFunction(tokens, options)
+	if options != nil && options.Hooks != nil && options.Hooks.OnBuild != nil {
+		toPath = buildHook(str, toPath, options.Hooks.OnBuild)
+	}
+	return toPath, nil
 }
 
 // MustCompile is like Compile but panics if the expression cannot be compiled.
@@ -464,16 +1068,18 @@ func MustCompile(str string, options *Options) func(interface{}) (string, error)
 
 // Match creates path match function from `path-to-regexp` spec.
 func Match(path interface{}, options *Options) (func(string) (*MatchResult, error), error) {
-	var tokens []Token
-	re, err := PathToRegexp(path, &tokens, options)
+	m, err := NewMatcher(path, options)
 	if err != nil {
 		return nil, err
 	}
 
-	return regexpToFunction(re, tokens, options), nil
+	return m.Match, nil
 }
 
-// MustMatch is like Match but panics if err occur in match function.
+// MustMatch is like Match but panics if err occur in creating the match
+// function. The match function doesn't panic, a pathname which doesn't match
+// gives a nil result, and an error wrapping ErrNoMatch with
+// `Options.ErrorOnNoMatch`.
 func MustMatch(path interface{}, options *Options) func(string) (*MatchResult, error) {
 	f, err := Match(path, options)
 	if err != nil {
@@ -482,108 +1088,359 @@ func MustMatch(path interface{}, options *Options) func(string) (*MatchResult, e
 	return f
 }
 
-// Create a path match function from `path-to-regexp` output.
-func regexpToFunction(re *regexp2.Regexp, tokens []Token, options *Options) func(string) (*MatchResult, error) {
-	decode := func(str string, token interface{}) (string, error) {
+// Create a path match function from `path-to-regexp` output, the params being
+// the groups bound to the tokens, see groupBindings.
+func regexpToFunction(re Pattern, groups []GroupBinding, options *Options) func(string) (*MatchResult, error) {
+	var decoder Decoder = DecodeFunc(func(str string, token interface{}) (string, error) {
 		return str, nil
-	}
+	})
+	// The decode function of the matched path, nil when the params aren't
+	// decoded.
+	var decodePath func(string, interface{}) (string, error)
 	if options != nil && options.Decode != nil {
-		decode = options.Decode
+		decoder, decodePath = DecodeFunc(options.Decode), options.Decode
+	} else if options != nil && (options.DecodeValues || options.Compat == CompatExpress4) {
+		decoder, decodePath = DecodeFunc(decodeURIComponent), decodeURIComponent
 	}
-
-	return func(pathname string) (*MatchResult, error) {
-		m, err := re.FindStringMatch(pathname)
-		if m == nil || m.GroupCount() == 0 || err != nil {
-			return nil, err
+	if options != nil && options.Decoder != nil {
+		decoder = options.Decoder
+	}
+	decode := decoder.DecodeParam
+	if options != nil && options.RequireValidUTF8 {
+		decode = func(str string, token Token, elem int) (string, error) {
+			value, err := decoder.DecodeParam(str, token, elem)
+			if err != nil {
+				return "", err
+			}
+			if offset := invalidUTF8Offset(value); offset >= 0 {
+				return "", &UTF8Error{Token: token.Name, Value: str, Offset: offset}
+			}
+			return value, nil
 		}
+	}
 
-		path := m.Groups()[0].String()
-		index := m.Index
-		params := make(map[interface{}]interface{})
+	// Separators of the repeated tokens.
+	separators := make([]string, len(groups))
+	for i, group := range groups {
+		separators[i] = repeatSeparator(group.Token, options)
+	}
 
-		for i := 1; i < m.GroupCount(); i++ {
-			group := m.Groups()[i]
-			if len(group.Captures) == 0 {
-				continue
+	// Validators of the decoded params, compiled on first use.
+	var validators []*validator
+	if options != nil && options.ValidateMatch {
+		validators = make([]*validator, len(groups))
+		for i, group := range groups {
+			if group.Token.Matrix == nil {
+				validators[i] = &validator{source: "^(?:" + tokenPattern(group.Token, options) + ")$", options: options}
 			}
+		}
+	}
+	// Returns whether the decoded value matches the pattern of the token at
+	// index i, or a *ValidationError with `Options.ValidateMatchError`.
+	validate := func(i int, value string) (bool, error) {
+		if validators == nil || validators[i] == nil {
+			return true, nil
+		}
+		ok, err := validators[i].MatchString(value)
+		if err == nil && !ok && options.ValidateMatchError {
+			token := groups[i].Token
+			err = &ValidationError{Token: token.Name, Pattern: token.Pattern, Value: value}
+		}
+		return ok, err
+	}
 
-			token := tokens[i-1]
-			matchedStr := group.String()
+	// Constraints of the params.
+	var constraints []func(string) bool
+	if options != nil && len(options.Constraints) > 0 {
+		constraints = make([]func(string) bool, len(groups))
+		for i, group := range groups {
+			if name, ok := group.Token.Name.(string); ok {
+				constraints[i] = options.Constraints[name]
+			}
+		}
+	}
 
-			if token.Modifier == "*" || token.Modifier == "+" {
-				arr := strings.Split(matchedStr, token.Prefix+token.Suffix)
-				length := len(arr)
-				if length > 0 {
-					for i, str := range arr {
-						arr[i], err = decode(str, token)
-						if err != nil {
-							return nil, err
-						}
+	// Converters of the typed params.
+	var converters []func(string) (interface{}, bool)
+	if options != nil && options.TypedParams {
+		converters = make([]func(string) (interface{}, bool), len(groups))
+		for i, group := range groups {
+			converters[i] = paramConverter(group.Token)
+		}
+	}
+
+	return func(pathname string) (*MatchResult, error) {
+		m, err := re.Find(pathname)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil {
+			return nil, nil
+		}
+
+		result := &matchAlloc{}
+		result.MatchResult = MatchResult{
+			Path:   m.Groups[0],
+			Index:  m.Index,
+			Params: make(map[interface{}]interface{}, len(m.Groups)-1),
+			Groups: m.Groups,
+		}
+		if decodePath != nil {
+			result.DecodedPath, _ = decodePath(m.Groups[0], nil)
+		}
+		params := result.Params
+
+		for i, group := range groups {
+			if group.Group >= len(m.Groups) || m.Indexes[group.Group] < 0 {
+				continue
+			}
+
+			token := group.Token
+			matchedStr := m.Groups[group.Group]
+
+			if token.Matrix != nil {
+				ok, err := token.Matrix.match(matchedStr, params, decode)
+				if err != nil || !ok {
+					return nil, err
+				}
+			} else if token.Modifier == "*" || token.Modifier == "+" {
+				// Avoid splitting when the value is made of a single segment.
+				var arr []string
+				if sep := separators[i]; sep != "" && !strings.Contains(matchedStr, sep) {
+					result.segment[0] = matchedStr
+					arr = result.segment[:]
+				} else {
+					arr = strings.Split(matchedStr, sep)
+				}
+				if len(arr) > 0 {
+					for j, str := range arr {
+						arr[j], err = decode(str, token, j)
+						if err != nil {
+							return nil, err
+						}
+						if ok, err := validate(i, arr[j]); err != nil || !ok {
+							return nil, err
+						}
+						if constraints != nil && constraints[i] != nil && !constraints[i](arr[j]) {
+							return nil, nil
+						}
+					}
+					if converters != nil && converters[i] != nil {
+						params[token.Name] = convertParams(converters[i], arr)
+					} else {
+						params[token.Name] = arr
 					}
-					params[token.Name] = arr
 				}
 			} else {
-				params[token.Name], err = decode(matchedStr, token)
+				value, err := decode(matchedStr, token, 0)
 				if err != nil {
 					return nil, err
 				}
+				if ok, err := validate(i, value); err != nil || !ok {
+					return nil, err
+				}
+				if constraints != nil && constraints[i] != nil && !constraints[i](value) {
+					return nil, nil
+				}
+				params[token.Name] = value
+				if converters != nil && converters[i] != nil {
+					if v, ok := converters[i](value); ok {
+						params[token.Name] = v
+					}
+				}
 			}
 		}
 
-		return &MatchResult{Path: path, Index: index, Params: params}, nil
+		return &result.MatchResult, nil
 	}
 }
 
+// matchAlloc holds a match result along with the backing array of a single
+// segment repeated param, so that the common case needs one allocation.
+type matchAlloc struct {
+	MatchResult
+	segment [1]string
+}
+
 // Expose a method for transforming tokens into the path function.
 func tokensToFunction(tokens []interface{}, options *Options) (
 	func(interface{}) (string, error), error) {
 	if options == nil {
 		options = &Options{}
 	}
-	reFlags := flags(options)
-	encode, validate := identity, true
-	if options.Encode != nil {
-		encode = options.Encode
+	encode, validate := EncodeFunc(encoder(options)).EncodeParam, true
+	if options.Encoder != nil {
+		encode = options.Encoder.EncodeParam
 	}
+	requireUTF8, rejectTraversal := options.RequireValidUTF8, options.RejectTraversal
 	if options.Validate != nil {
 		validate = *options.Validate
 	}
 
-	// Compile all the tokens into regexps.
-	matches := make([]*regexp2.Regexp, len(tokens))
-	for i, token := range tokens {
+	// Estimate the output size from the static parts of the template.
+	size := 0
+	for _, token := range tokens {
+		if token, ok := token.(string); ok {
+			size += len(token)
+		}
 		if token, ok := token.(Token); ok {
-			m, err := regexp2.Compile("^(?:"+token.Pattern+")$", reFlags)
+			size += len(token.Prefix) + len(token.Suffix) + 8
+		}
+	}
+
+	// Encode a single value of the token.
+	encodeValue := func(token Token, value string, elem int) (string, error) {
+		if requireUTF8 {
+			if offset := invalidUTF8Offset(value); offset >= 0 {
+				return "", &UTF8Error{Token: token.Name, Value: value, Offset: offset}
+			}
+		}
+		return encode(value, token, elem)
+	}
+
+	// Check the encoded segment, `all` is true for values of an array.
+	checkTraversal := func(token Token, segment string, all bool) error {
+		if !rejectTraversal || !isTraversal(segment) {
+			return nil
+		}
+		if all {
+			return fmt.Errorf("expected all \"%v\" to not traverse "+
+				"paths, but got \"%v\"", token.Name, segment)
+		}
+		return fmt.Errorf("expected \"%v\" to not traverse paths, "+
+			"but got \"%v\"", token.Name, segment)
+	}
+
+	// Transform the assembled path.
+	var finish func(string) string
+	if options.LowercasePath || options.ASCIIOnly {
+		lowercase, ascii := options.LowercasePath, options.ASCIIOnly
+		finish = func(path string) string {
+			if lowercase {
+				path = lowercasePath(path)
+			}
+			if ascii {
+				path = asciiPath(path)
+			}
+			return path
+		}
+	}
+
+	// Encode the values of the matrix params, which are validated by the matrix.
+	matrix := func(m *Matrix, data interface{}) (string, error) {
+		return m.path(data, validate, func(token Token, value string) (string, error) {
+			segment, err := encodeValue(token, value, 0)
 			if err != nil {
-				return nil, err
+				return "", err
+			}
+			return segment, checkTraversal(token, segment, false)
+		})
+	}
+
+	if !validate {
+		return pathFunction(tokens, size, options.MaxPathLen, options.MaxRepeats, options.RepeatSeparator, finish, func(i int, token Token, value string, elem int, all bool) (string, error) {
+			segment, err := encodeValue(token, value, elem)
+			if err != nil {
+				return "", err
+			}
+			return segment, checkTraversal(token, segment, all)
+		}, matrix), nil
+	}
+
+	// Token validators are compiled on first use, but their syntax is checked
+	// now so that an invalid pattern fails here.
+	validators := make([]*validator, len(tokens))
+	for i, token := range tokens {
+		if token, ok := token.(Token); ok {
+			validators[i] = &validator{source: "^(?:" + tokenPattern(token, options) + ")$", options: options}
+			if err := validators[i].check(); err != nil {
+				return nil, validatorError(token, err)
+			}
+			if token.Matrix != nil {
+				for j, v := range token.Matrix.validators {
+					if err := v.check(); err != nil {
+						return nil, validatorError(*token.Matrix.Params[j].Token, err)
+					}
+				}
 			}
-			matches[i] = m
 		}
 	}
 
+	return pathFunction(tokens, size, options.MaxPathLen, options.MaxRepeats, options.RepeatSeparator, finish, func(i int, token Token, value string, elem int, all bool) (string, error) {
+		segment, err := encodeValue(token, value, elem)
+		if err != nil {
+			return "", err
+		}
+
+		ok, err := validators[i].MatchString(segment)
+		if err != nil {
+			return "", validatorError(token, err)
+		}
+		if !ok {
+			if all {
+				return "", fmt.Errorf("expected all \"%v\" to match \"%v\"",
+					token.Name, token.Pattern)
+			}
+			return "", fmt.Errorf("expected \"%v\" to match \"%v\", "+
+				"but got \"%v\"", token.Name, token.Pattern, segment)
+		}
+
+		return segment, checkTraversal(token, segment, all)
+	}, matrix), nil
+}
+
+// Returns the path function for the tokens, `segment` encodes and checks each
+// value given for the token at index `i`, and `matrix` writes the matrix
+// params of a segment. The path is returned through `finish` when it's not
+// nil. The length of the path is checked against `maxLen` as it's written,
+// zero meaning unlimited.
+func pathFunction(tokens []interface{}, size int, maxLen, maxRepeats int, separator string, finish func(string) string,
+	segment func(i int, token Token, value string, elem int, all bool) (string, error),
+	matrix func(m *Matrix, data interface{}) (string, error)) func(interface{}) (string, error) {
 	return func(data interface{}) (string, error) {
-		path := ""
+		var path strings.Builder
+		path.Grow(size)
+
+		// Check the length of the path written for the token.
+		checkLen := func(name interface{}) error {
+			if maxLen > 0 && path.Len() > maxLen {
+				return &PathLenError{Max: maxLen, Token: name, Len: path.Len()}
+			}
+			return nil
+		}
 
 		for i, token := range tokens {
 			if token, ok := token.(string); ok {
-				path += token
+				path.WriteString(token)
+				if err := checkLen(nil); err != nil {
+					return "", err
+				}
 				continue
 			}
 
 			if token, ok := token.(Token); ok {
+				if token.Matrix != nil {
+					s, err := matrix(token.Matrix, data)
+					if err != nil {
+						return "", err
+					}
+					path.WriteString(s)
+					if err := checkLen(token.Name); err != nil {
+						return "", err
+					}
+					continue
+				}
+
 				optional := token.Modifier == "?" || token.Modifier == "*"
 				repeat := token.Modifier == "*" || token.Modifier == "+"
 				if data != nil && reflect.TypeOf(data).Kind() == reflect.Map {
-					data := toMap(data)
-					value := data[token.Name]
-					if value == nil {
-						if intValue, ok := token.Name.(int); ok {
-							value = data[strconv.Itoa(intValue)]
-						}
-					}
+					value := paramValue(toMap(data), token.Name)
 
 					if value != nil {
 						if k := reflect.TypeOf(value).Kind(); k == reflect.Slice || k == reflect.Array {
+							if n := reflect.ValueOf(value).Len(); repeat && maxRepeats > 0 && n > maxRepeats {
+								return "", &RepeatsError{Max: maxRepeats, Token: token.Name, Len: n}
+							}
 							value := toSlice(value)
 							if !repeat {
 								return "", fmt.Errorf("expected \"%v\" to not repeat, "+
@@ -597,17 +1454,21 @@ func tokensToFunction(tokens []interface{}, options *Options) (
 								return "", fmt.Errorf("expected \"%v\" to not be empty", token.Name)
 							}
 
-							for _, v := range value {
-								segment := encode(fmt.Sprintf("%v", v), token)
-
-								if validate {
-									if ok, err := matches[i].MatchString(segment); err != nil || !ok {
-										return "", fmt.Errorf("expected all \"%v\" to match \"%v\"",
-											token.Name, token.Pattern)
-									}
+							for j, v := range value {
+								s, err := segment(i, token, fmt.Sprintf("%v", v), j, true)
+								if err != nil {
+									return "", err
 								}
 
-								path += token.Prefix + segment + token.Suffix
+								if j > 0 && token.Prefix == "" && token.Suffix == "" {
+									path.WriteString(separator)
+								}
+								path.WriteString(token.Prefix)
+								path.WriteString(s)
+								path.WriteString(token.Suffix)
+								if err := checkLen(token.Name); err != nil {
+									return "", err
+								}
 							}
 
 							continue
@@ -616,26 +1477,30 @@ func tokensToFunction(tokens []interface{}, options *Options) (
 
 					vString, isString := value.(string)
 					vInt, isInt := value.(int)
+					vInt64, isInt64 := value.(int64)
 					vFloat, isFloat := value.(float64)
-					if isString || isInt || isFloat {
+					if isString || isInt || isInt64 || isFloat {
 						var v string
 						if isString {
 							v = vString
 						} else if isInt {
 							v = strconv.Itoa(vInt)
+						} else if isInt64 {
+							v = strconv.FormatInt(vInt64, 10)
 						} else if isFloat {
 							v = strconv.FormatFloat(vFloat, 'f', -1, 64)
 						}
-						segment := encode(v, token)
-
-						if validate {
-							if ok, err := matches[i].MatchString(segment); err != nil || !ok {
-								return "", fmt.Errorf("expected \"%v\" to match \"%v\", "+
-									"but got \"%v\"", token.Name, token.Pattern, segment)
-							}
+						s, err := segment(i, token, v, 0, false)
+						if err != nil {
+							return "", err
 						}
 
-						path += token.Prefix + segment + token.Suffix
+						path.WriteString(token.Prefix)
+						path.WriteString(s)
+						path.WriteString(token.Suffix)
+						if err := checkLen(token.Name); err != nil {
+							return "", err
+						}
 						continue
 					}
 				}
@@ -652,8 +1517,197 @@ func tokensToFunction(tokens []interface{}, options *Options) (
 			}
 		}
 
-		return path, nil
-	}, nil
+		if finish != nil {
+			str := finish(path.String())
+			if maxLen > 0 && len(str) > maxLen {
+				return "", &PathLenError{Max: maxLen, Len: len(str)}
+			}
+			return str, nil
+		}
+		return path.String(), nil
+	}
+}
+
+// Returns the path with the non-ASCII bytes percent-encoded, the other bytes,
+// such as the `%` of the existing escapes, being kept.
+func asciiPath(path string) string {
+	i := 0
+	for i < len(path) && path[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(path) {
+		return path
+	}
+
+	var b strings.Builder
+	b.Grow(len(path) + 2*(len(path)-i))
+	b.WriteString(path[:i])
+	for ; i < len(path); i++ {
+		if c := path[i]; c >= utf8.RuneSelf {
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&15])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Returns the pattern of the token, wrapped with an inline case flag when the
+// token is listed in `Options.SensitiveParams` or `Options.InsensitiveParams`
+// and its case sensitivity differs from the route's.
+func tokenPattern(token Token, options *Options) string {
+	if options == nil {
+		return token.Pattern
+	}
+	pattern := token.Pattern
+	if options.UnicodeClasses {
+		pattern = unicodeClasses(pattern)
+	}
+	list, flag := options.SensitiveParams, "(?-i:"
+	if options.Sensitive {
+		list, flag = options.InsensitiveParams, "(?i:"
+	}
+	if len(list) == 0 {
+		return pattern
+	}
+	name := token.NameString()
+	for _, v := range list {
+		if v == name {
+			return flag + pattern + ")"
+		}
+	}
+	return pattern
+}
+
+// Returns the path in lower case, except the hex digits of the percent-encoded
+// bytes, which are kept as they were encoded.
+func lowercasePath(path string) string {
+	b := []byte(path)
+	for i := 0; i < len(b); i++ {
+		if b[i] == '%' && i+2 < len(b) && isHexDigit(rune(b[i+1])) && isHexDigit(rune(b[i+2])) {
+			i += 2
+			continue
+		}
+		if 'A' <= b[i] && b[i] <= 'Z' {
+			b[i] += 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// validator lazily compiles the regexp used to validate a token's values, it
+// is safe for concurrent use.
+type validator struct {
+	once    sync.Once
+	source  string
+	options *Options
+	pattern Pattern
+	err     error
+}
+
+// Checks the syntax of the validator without compiling it, unless it's
+// compiled by an Engine.
+func (v *validator) check() error {
+	if v == nil {
+		return nil
+	}
+	if v.options != nil && v.options.Engine != nil {
+		_, err := v.MatchString("")
+		return err
+	}
+	_, err := syntax.Parse(v.source, syntax.RegexOptions(flags(v.options)))
+	return err
+}
+
+// Returns the error of the validator of the token, which failed to compile or
+// to run.
+func validatorError(token Token, err error) error {
+	return fmt.Errorf("expected \"%v\" to match \"%v\": %w", token.Name, token.Pattern, err)
+}
+
+// MatchString compiles the validator on first use and reports whether the
+// segment matches it.
+func (v *validator) MatchString(segment string) (bool, error) {
+	v.once.Do(func() {
+		v.pattern, v.err = compilePattern(v.source, v.options)
+	})
+	if v.err != nil {
+		return false, v.err
+	}
+	m, err := v.pattern.Find(segment)
+	return m != nil, err
+}
+
+// Returns the byte offset of the first invalid UTF-8 sequence, or -1 if the
+// string is valid.
+func invalidUTF8Offset(str string) int {
+	for i := 0; i < len(str); {
+		r, size := utf8.DecodeRuneInString(str[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// Reports whether the encoded segment contains a `.` or `..` path segment once
+// percent-decoded, backslashes are treated as separators too.
+func isTraversal(segment string) bool {
+	if decoded, err := url.PathUnescape(segment); err == nil {
+		segment = decoded
+	}
+	for _, part := range strings.FieldsFunc(segment, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		if part == "." || part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the characters excluded from the default pattern of the params.
+func excludeChars(options *Options) string {
+	return anyString(options.ExcludeChars, options.Delimiter, defaultDelimiter)
+}
+
+// Returns the default pattern of the params, which doesn't match the
+// excluded characters or the delimiter sequence.
+func paramPattern(options *Options) string {
+	if options.ExcludeChars == "" {
+		if sequence := delimiterSequence(options); sequence != "" {
+			return "(?:(?!" + escapeCached(sequence) + ").)+?"
+		}
+	}
+	return "[^" + escapeCached(excludeChars(options)) + "]+?"
+}
+
+// Returns the Delimiter when it's a sequence of characters rather than a set
+// of characters, which is the case of a character repeated such as `::`, or
+// an empty string otherwise.
+func delimiterSequence(options *Options) string {
+	delimiter := options.Delimiter
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size == 0 || size == len(delimiter) {
+		return ""
+	}
+	for _, c := range delimiter[size:] {
+		if c != r {
+			return ""
+		}
+	}
+	return delimiter
+}
+
+// Returns the regexp matching a delimiter.
+func delimiterSource(options *Options) string {
+	if sequence := delimiterSequence(options); sequence != "" {
+		return "(?:" + escapeCached(sequence) + ")"
+	}
+	return "[" + escapeCached(anyString(options.Delimiter, defaultDelimiter)) + "]"
 }
 
 // Returns the first non empty string
@@ -677,16 +1731,82 @@ func toSlice(data interface{}) []interface{} {
 	return arr
 }
 
-// Transform data which is reflect.Map to map
+// Transform data which is reflect.Map to map, with the integer keys as int
 func toMap(data interface{}) map[interface{}]interface{} {
 	v, m := reflect.ValueOf(data), make(map[interface{}]interface{})
 	for _, k := range v.MapKeys() {
 		value := v.MapIndex(k)
-		m[k.Interface()] = value.Interface()
+		if k.Kind() == reflect.Interface {
+			k = k.Elem()
+		}
+		switch k.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			m[int(k.Int())] = value.Interface()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			m[int(k.Uint())] = value.Interface()
+		default:
+			m[k.Interface()] = value.Interface()
+		}
 	}
 	return m
 }
 
+// Returns the separator of the values of a repeated token, its prefix and
+// suffix, or `Options.RepeatSeparator` when they're empty.
+func repeatSeparator(token Token, options *Options) string {
+	if sep := token.Prefix + token.Suffix; sep != "" || options == nil {
+		return sep
+	}
+	return options.RepeatSeparator
+}
+
+// Returns the value of the param in the data. The value of an unnamed param
+// is looked up by its index, e.g. `0`, then by the decimal form of the index,
+// e.g. `"0"`, so the int key wins when both are given. The value of a dotted
+// name, e.g. `user.id`, is looked up in the nested maps and structs when
+// there's no such key.
+func paramValue(data map[interface{}]interface{}, name interface{}) interface{} {
+	value := data[name]
+	if index, ok := name.(int); ok && value == nil {
+		value = data[strconv.Itoa(index)]
+	}
+	if name, ok := name.(string); ok && value == nil && strings.Contains(name, ".") {
+		value = nestedValue(data, strings.Split(name, "."))
+	}
+	return value
+}
+
+// Returns the value at the path of keys in the nested maps, and of fields in
+// the nested structs, the names of which are matched regardless of case, e.g.
+// `ID` for `id`, or nil when a branch is missing.
+func nestedValue(data interface{}, keys []string) interface{} {
+	for _, key := range keys {
+		v := reflect.ValueOf(data)
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			data = toMap(v.Interface())[key]
+		case reflect.Struct:
+			field := v.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+			if !field.IsValid() || !field.CanInterface() {
+				return nil
+			}
+			data = field.Interface()
+		default:
+			return nil
+		}
+		if data == nil {
+			return nil
+		}
+	}
+	return data
+}
+
 func encodeURIComponent(str string, token interface{}) string {
 	return EncodeURIComponent(str)
 }
@@ -696,8 +1816,71 @@ func decodeURIComponent(str string, token interface{}) (string, error) {
 }
 
 // Escape a regular expression string.
-func escapeString(str string) (string, error) {
-	return escapeRegexp.Replace(str, "\\$1", -1, -1)
+func escapeString(str string) string {
+	n := 0
+	for i := 0; i < len(str); i++ {
+		if regexpSpecial[str[i]] {
+			n++
+		}
+	}
+	if n == 0 {
+		return str
+	}
+
+	var b strings.Builder
+	b.Grow(len(str) + n)
+	for i := 0; i < len(str); i++ {
+		if regexpSpecial[str[i]] {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(str[i])
+	}
+	return b.String()
+}
+
+const defaultDelimiter = "/#?"
+
+// DelimiterBackslash is the Delimiter of Windows paths such as
+// `C:\Users\:name`, usually along with `Options.LiteralBackslash`.
+const DelimiterBackslash = "\\"
+
+// The escaped delimiters and EndsWith strings, which are usually shared by
+// many templates.
+var (
+	escapedDefaultDelimiter = escapeString(defaultDelimiter)
+	escapedSlash            = escapeString("/")
+	escapedStrings          sync.Map
+)
+
+// Like escapeString, but memoizes the result.
+func escapeCached(str string) string {
+	switch str {
+	case defaultDelimiter:
+		return escapedDefaultDelimiter
+	case "/":
+		return escapedSlash
+	}
+	if v, ok := escapedStrings.Load(str); ok {
+		return v.(string)
+	}
+	escaped := escapeString(str)
+	escapedStrings.Store(str, escaped)
+	return escaped
+}
+
+// The characters escaped by escapeString.
+var regexpSpecial = func() (table [256]bool) {
+	for _, c := range ".+*?=^!:${}()[]|/\\" {
+		table[c] = true
+	}
+	return
+}()
+
+// Appends all the given strings to the builder.
+func writeStrings(b *strings.Builder, strs ...string) {
+	for _, s := range strs {
+		b.WriteString(s)
+	}
 }
 
 func quote(s string) string {
@@ -707,14 +1890,41 @@ func quote(s string) string {
 	return strconv.Quote(s)
 }
 
-// Get the flags for a regexp from the options.
+// Compile a regexp with the flags and the match timeout from the options.
+func compile(pattern string, options *Options) (*regexp2.Regexp, error) {
+	re, err := regexp2.Compile(pattern, flags(options))
+	if err != nil {
+		return nil, err
+	}
+	if options != nil && options.MatchTimeout > 0 {
+		re.MatchTimeout = options.MatchTimeout
+	}
+	return re, nil
+}
+
+// Wrap an error returned by a regexp execution, which can only be caused by
+// exceeding the match timeout.
+func matchTimeoutError(err error) error {
+	return fmt.Errorf("%w: %v", ErrMatchTimeout, err)
+}
+
+// Get the flags for a regexp from the options, `Options.RegexFlags` being
+// added to the IgnoreCase flag of Sensitive.
 func flags(options *Options) regexp2.RegexOptions {
 	if options != nil && options.Sensitive {
-		return regexp2.None
+		return options.RegexFlags
+	}
+	if options != nil {
+		return regexp2.IgnoreCase | options.RegexFlags
 	}
 	return regexp2.IgnoreCase
 }
 
+// Reports whether the flags of the options ignore the case.
+func ignoreCase(options *Options) bool {
+	return flags(options)&regexp2.IgnoreCase != 0
+}
+
 // Must is a helper that wraps a call to a function returning (*regexp2.Regexp, error)
 // and panics if the error is non-nil. It is intended for use in variable initializations
 // such as
@@ -726,147 +1936,456 @@ func Must(r *regexp2.Regexp, err error) *regexp2.Regexp {
 	return r
 }
 
-// Pull out tokens from a regexp.
+// errNilRegexp is returned for a nil regexp path, or a nil element of an array
+// of regexps.
+var errNilRegexp = errors.New("path is a nil regexp")
+
+// Pull out tokens from a regexp, a named group giving its name to its token.
 func regexpToRegexp(path *regexp2.Regexp, tokens *[]Token) *regexp2.Regexp {
 	if tokens != nil {
-		totalGroupCount := 0
-		for m, _ := tokenRegexp.FindStringMatch(path.String()); m != nil; m,
-			_ = tokenRegexp.FindNextMatch(m) {
-			totalGroupCount += m.GroupCount()
-		}
+		var named []Token
+		regexpTokens(path, tokens, &named)
+		*tokens = append(*tokens, named...)
+	}
 
-		if totalGroupCount > 0 {
-			for i := 0; i < totalGroupCount; i++ {
-				*tokens = append(*tokens, Token{
-					Name:     i,
-					Prefix:   "",
-					Suffix:   "",
-					Modifier: "",
-					Pattern:  "",
-				})
-			}
+	return path
+}
+
+// Appends the tokens of the unnamed groups of a regexp to `tokens`, numbered
+// from 0, and those of its named groups to `named`, as regexp2 numbers the
+// named groups after all the others.
+func regexpTokens(path *regexp2.Regexp, tokens, named *[]Token) {
+	index := 0
+	for _, name := range path.GetGroupNames()[1:] {
+		if _, err := strconv.Atoi(name); err == nil {
+			*tokens = append(*tokens, Token{Name: index})
+			index++
+		} else {
+			*named = append(*named, Token{Name: name})
 		}
 	}
+}
 
-	return path
+// Recompile a regexp of the standard library with regexp2 and the flags of the
+// options, and pull out its tokens.
+func stdRegexpToRegexp(path *regexp.Regexp, tokens *[]Token, options *Options) (*regexp2.Regexp, error) {
+	// regexp2 names groups with `(?<name>` only.
+	source := path.String()
+	for i := strings.Index(source, "(?P<"); i >= 0; {
+		if !isEscaped(source, i) {
+			source = source[:i+2] + source[i+3:]
+		}
+		j := strings.Index(source[i+1:], "(?P<")
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	re, err := compile(source, options)
+	if err != nil {
+		return nil, fmt.Errorf("regexp %q isn't supported by regexp2: %v", path.String(), err)
+	}
+	return regexpToRegexp(re, tokens), nil
 }
 
 // Transform an array into a regexp.
 func arrayToRegexp(path []interface{}, tokens *[]Token, options *Options) (*regexp2.Regexp, error) {
-	var parts []string
+	source, err := arrayToSource(path, tokens, options)
+	if err != nil {
+		return nil, err
+	}
+	return compile(source, options)
+}
 
-	for i := 0; i < len(path); i++ {
-		r, err := PathToRegexp(path[i], tokens, options)
+// Create the regexp source of an array of paths, which may hold regexps,
+// strings and arrays. Repeated alternatives can never match, so they are left
+// out along with their tokens.
+func arrayToSource(path []interface{}, tokens *[]Token, options *Options) (string, error) {
+	var named []Token
+	source, err := arrayToNamedSource(path, tokens, &named, options)
+	if err != nil {
+		return "", err
+	}
+	if tokens != nil {
+		// A name repeated by several regexps is a single group.
+		seen := make(map[interface{}]bool, len(named))
+		for _, token := range named {
+			if !seen[token.Name] {
+				seen[token.Name] = true
+				*tokens = append(*tokens, token)
+			}
+		}
+	}
+	return source, nil
+}
+
+// Create the regexp source of an array of paths, appending the tokens of the
+// named groups of its regexps to `named`, as they are numbered after the
+// groups of the whole array.
+func arrayToNamedSource(path []interface{}, tokens, named *[]Token, options *Options) (string, error) {
+	parts, seen, size := make([]string, 0, len(path)), make(map[string]bool, len(path)), 0
+	// The unnamed groups of the previous parts, which shift the groups of the
+	// backreferences of `Options.BindDuplicates`.
+	groups := 0
+	for _, p := range path {
+		var partTokens, partNamed []Token
+		var source string
+		var err error
+		switch re := p.(type) {
+		case *regexp2.Regexp:
+			if re == nil {
+				return "", errNilRegexp
+			}
+			source = re.String()
+			regexpTokens(re, &partTokens, &partNamed)
+		case *regexp.Regexp:
+			if re == nil {
+				return "", errNilRegexp
+			}
+			var compiled *regexp2.Regexp
+			if compiled, err = stdRegexpToRegexp(re, nil, options); err == nil {
+				source = compiled.String()
+				regexpTokens(compiled, &partTokens, &partNamed)
+			}
+		default:
+			if _, ok := pathTokens(p); !ok && isArray(p) {
+				source, err = arrayToNamedSource(toSlice(p), &partTokens, &partNamed, options)
+			} else {
+				source, err = pathToSource(p, &partTokens, options)
+			}
+		}
 		if err != nil {
-			return nil, err
+			return "", err
+		}
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		if options != nil && options.BindDuplicates && groups > 0 {
+			source = shiftBackreferences(source, groups)
+		}
+		groups += len(partTokens)
+		parts, size = append(parts, source), size+len(source)+1
+		if tokens != nil {
+			*tokens = append(*tokens, partTokens...)
+			*named = append(*named, partNamed...)
+		}
+	}
+
+	var route strings.Builder
+	route.Grow(size + len("(?:)"))
+	route.WriteString("(?:")
+	for i, part := range parts {
+		if i > 0 {
+			route.WriteString("|")
+		}
+		route.WriteString(part)
+	}
+	route.WriteString(")")
+
+	if options != nil && options.MaxRegexpLen > 0 && route.Len() > options.MaxRegexpLen {
+		return "", &LimitError{Limit: "MaxRegexpLen", Max: options.MaxRegexpLen,
+			Index: route.Len(), Templates: pathTemplates(path)}
+	}
+	return route.String(), nil
+}
+
+// Create the regexp source of a path, like PathToRegexp without compiling it.
+func pathToSource(path interface{}, tokens *[]Token, options *Options) (string, error) {
+	switch path := path.(type) {
+	case *regexp2.Regexp:
+		if path == nil {
+			return "", errNilRegexp
+		}
+		return regexpToRegexp(path, tokens).String(), nil
+	case *regexp.Regexp:
+		if path == nil {
+			return "", errNilRegexp
+		}
+		re, err := stdRegexpToRegexp(path, tokens, options)
+		if err != nil {
+			return "", err
+		}
+		return re.String(), nil
+	case string:
+		return stringToSource(path, tokens, options)
+	}
+	if rawTokens, ok := pathTokens(path); ok {
+		return tokensToSource(rawTokens, tokens, options)
+	}
+	if str, ok := stringerPath(path); ok {
+		return stringToSource(str, tokens, options)
+	}
+
+	if path != nil {
+		switch reflect.TypeOf(path).Kind() {
+		case reflect.Slice, reflect.Array:
+			return arrayToSource(toSlice(path), tokens, options)
+		}
+	}
+
+	return "", errors.New(`path should be string, tokens, array or slice of strings, 
+or a regular expression with type *github.com/dlclark/regexp2.Regexp or *regexp.Regexp`)
+}
+
+// Reports whether the character at `i` is escaped by a backslash.
+func isEscaped(str string, i int) bool {
+	n := 0
+	for i--; i >= 0 && str[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// Reports whether the value is a slice or an array.
+func isArray(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	k := reflect.TypeOf(v).Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+// Returns the raw tokens of a path given as tokens, either a []Token or a
+// []interface{} of strings and tokens holding at least one Token, such as the
+// result of Parse. A []interface{} of strings only is an array of templates.
+func pathTokens(path interface{}) ([]interface{}, bool) {
+	switch path := path.(type) {
+	case []Token:
+		rawTokens := make([]interface{}, len(path))
+		for i, token := range path {
+			rawTokens[i] = token
+		}
+		return rawTokens, true
+	case []interface{}:
+		hasToken := false
+		for _, token := range path {
+			switch token.(type) {
+			case Token:
+				hasToken = true
+			case string:
+			default:
+				return nil, false
+			}
 		}
-		parts = append(parts, r.String())
+		return path, hasToken
+	}
+	return nil, false
+}
+
+// Returns the template of a path implementing fmt.Stringer, unless it's a regexp
+// or an array, which have precedence.
+func stringerPath(path interface{}) (string, bool) {
+	switch path.(type) {
+	case *regexp2.Regexp, *regexp.Regexp:
+		return "", false
+	}
+	stringer, ok := path.(fmt.Stringer)
+	if !ok {
+		return "", false
+	}
+	if k := reflect.TypeOf(path).Kind(); k == reflect.Slice || k == reflect.Array {
+		return "", false
+	}
+	return stringer.String(), true
+}
+
+// Returns the templates of a path for error reporting, regexps are described
+// by their source.
+func pathTemplates(path interface{}) []string {
+	switch path := path.(type) {
+	case string:
+		return []string{path}
+	case *regexp2.Regexp:
+		return []string{path.String()}
+	case *regexp.Regexp:
+		return []string{path.String()}
+	}
+	if str, ok := stringerPath(path); ok {
+		return []string{str}
 	}
 
-	return regexp2.Compile("(?:"+strings.Join(parts, "|")+")", flags(options))
+	var templates []string
+	if path != nil {
+		if k := reflect.TypeOf(path).Kind(); k == reflect.Slice || k == reflect.Array {
+			for _, v := range toSlice(path) {
+				templates = append(templates, pathTemplates(v)...)
+			}
+		}
+	}
+	return templates
 }
 
 // Create a path regexp from string input.
 func stringToRegexp(path string, tokens *[]Token, options *Options) (*regexp2.Regexp, error) {
-	parsedTokens, err := Parse(path, options)
+	source, err := stringToSource(path, tokens, options)
 	if err != nil {
 		return nil, err
 	}
-	return tokensToRegExp(parsedTokens, tokens, options)
+	return compile(source, options)
+}
+
+// Create the regexp source of a path string.
+func stringToSource(path string, tokens *[]Token, options *Options) (string, error) {
+	parsedTokens, err := Parse(path, options)
+	if err != nil {
+		return "", err
+	}
+	if options != nil && options.Compat == CompatExpress4 {
+		return express4Source(path, parsedTokens, tokens, options), nil
+	}
+	source, err := tokensToSource(parsedTokens, tokens, options)
+	if e, ok := err.(*LimitError); ok && e.Limit == "MaxRegexpLen" {
+		e.Templates = []string{path}
+	}
+	return source, err
 }
 
 // Expose a function for taking tokens and returning a RegExp.
 func tokensToRegExp(rawTokens []interface{}, tokens *[]Token, options *Options) (*regexp2.Regexp, error) {
+	source, err := tokensToSource(rawTokens, tokens, options)
+	if err != nil {
+		return nil, err
+	}
+	return compile(source, options)
+}
+
+// Create the regexp source of the tokens.
+func tokensToSource(rawTokens []interface{}, tokens *[]Token, options *Options) (string, error) {
+	return tokensToNamedSource(rawTokens, tokens, options, nil)
+}
+
+// Adds the offset to the groups of the numbered backreferences of the source,
+// `\k<1>`, skipping the escaped backslashes.
+func shiftBackreferences(source string, offset int) string {
+	var b strings.Builder
+	for i := 0; i < len(source); i++ {
+		if source[i] != '\\' || i+1 == len(source) {
+			b.WriteByte(source[i])
+			continue
+		}
+		if strings.HasPrefix(source[i+1:], "k<") {
+			if end := strings.IndexByte(source[i+3:], '>'); end > 0 {
+				if group, err := strconv.Atoi(source[i+3 : i+3+end]); err == nil {
+					writeStrings(&b, `\k<`, strconv.Itoa(group+offset), ">")
+					i += 3 + end
+					continue
+				}
+			}
+		}
+		b.WriteString(source[i : i+2])
+		i++
+	}
+	return b.String()
+}
+
+// Create the regexp source of the tokens, the group of a token is named by
+// `groupName` unless it returns an empty string.
+func tokensToNamedSource(rawTokens []interface{}, tokens *[]Token, options *Options,
+	groupName func(Token) string) (string, error) {
 	if options == nil {
 		options = &Options{}
 	}
 
-	strict, start, end, route, encode := options.Strict, true, true, "", identity
+	strict, start, end, encode := options.Strict, true, true, encoder(options)
 	if options.Start != nil {
 		start = *options.Start
 	}
 	if options.End != nil {
 		end = *options.End
 	}
-	if options.Encode != nil {
-		encode = options.Encode
-	}
 
 	endsWith := "$"
 	// avoid syntax.ErrUnterminatedBracket `unterminated [] set`
 	// empty [] is not allowed in regexp2
 	if options.EndsWith != "" {
-		t, err := escapeString(options.EndsWith)
-		if err != nil {
-			return nil, err
-		}
-		endsWith = "[" + t + "]|$"
-	}
-	t, err := escapeString(anyString(options.Delimiter, "/#?"))
-	if err != nil {
-		return nil, err
+		endsWith = "[" + escapeCached(options.EndsWith) + "]|$"
 	}
-	delimiter := "[" + t + "]"
+	delimiter, sequence := delimiterSource(options), delimiterSequence(options)
+	var route strings.Builder
 	if start {
-		route = "^"
+		route.WriteString("^")
+	}
+
+	// The groups of the first occurrences of the params, by name, with
+	// `Options.BindDuplicates`.
+	var bound map[interface{}]int
+	if options.BindDuplicates {
+		bound = make(map[interface{}]int)
 	}
+	groups := 0
 
 	// Iterate over the tokens and create our regexp string.
 	for _, token := range rawTokens {
 		if str, ok := token.(string); ok {
-			t, err := escapeString(encode(str, nil))
-			if err != nil {
-				return nil, err
-			}
-			route += t
+			route.WriteString(escapeString(encode(str, nil)))
 		} else if token, ok := token.(Token); ok {
-			t, err := escapeString(encode(token.Prefix, nil))
-			if err != nil {
-				return nil, err
-			}
-			prefix := t
-			t, err = escapeString(encode(token.Suffix, nil))
-			if err != nil {
-				return nil, err
-			}
-			suffix := t
+			prefix := escapeString(encode(token.Prefix, nil))
+			suffix := escapeString(encode(token.Suffix, nil))
 
 			if token.Pattern != "" {
 				if tokens != nil {
 					*tokens = append(*tokens, token)
 				}
-				if prefix != "" || suffix != "" {
+				groups++
+				if group, ok := bound[token.Name]; ok && token.IsNamed() {
+					// The duplicate matches the text of the first occurrence, the
+					// separators of a repeated param included.
+					mod := ""
+					if isOptional(token) {
+						mod = "?"
+					}
+					writeStrings(&route, "(?:", prefix, `(\k<`, strconv.Itoa(group), ">)", suffix, ")", mod)
+					continue
+				} else if bound != nil && token.IsNamed() {
+					bound[token.Name] = groups
+				}
+				pattern := tokenPattern(token, options)
+				group := "("
+				if groupName != nil {
+					if name := groupName(token); name != "" {
+						group = "(?P<" + name + ">"
+					}
+				}
+				if prefix == "" && suffix == "" && options.RepeatSeparator != "" && isRepeat(token) {
+					sep := escapeString(encode(options.RepeatSeparator, nil))
+					mod := ""
+					if token.Modifier == "*" {
+						mod = "?"
+					}
+					writeStrings(&route, group, "(?:", pattern, ")", "(?:", sep, "(?:", pattern, "))*)", mod)
+				} else if prefix != "" || suffix != "" {
 					if token.Modifier == "+" || token.Modifier == "*" {
 						mod := ""
 						if token.Modifier == "*" {
 							mod = "?"
 						}
-						route += "(?:" + prefix + "((?:" + token.Pattern + ")" +
-							"(?:" + suffix + prefix + "(?:" + token.Pattern + "))" +
-							"*)" + suffix + ")" + mod
+						writeStrings(&route, "(?:", prefix, group, "(?:", pattern, ")",
+							"(?:", suffix, prefix, "(?:", pattern, "))",
+							"*)", suffix, ")", mod)
 					} else {
-						route += "(?:" + prefix + "(" + token.Pattern + ")" +
-							"" + suffix + ")" + token.Modifier
+						writeStrings(&route, "(?:", prefix, group, pattern, ")",
+							suffix, ")", token.Modifier)
 					}
 				} else {
-					route += "(" + token.Pattern + ")" + token.Modifier
+					writeStrings(&route, group, pattern, ")", token.Modifier)
 				}
 			} else {
-				route += "(?:" + prefix + suffix + ")" + token.Modifier
+				writeStrings(&route, "(?:", prefix, suffix, ")", token.Modifier)
 			}
 		}
 	}
 
 	if end {
 		if !strict {
-			route += delimiter + "?"
+			writeStrings(&route, delimiter, "?")
 		}
 
 		s := "(?=" + endsWith + ")"
 		if options.EndsWith == "" {
 			s = "$"
 		}
-		route += s
+		route.WriteString(s)
 	} else {
 		isEndDelimited := false
 		if len(rawTokens) == 0 {
@@ -875,39 +2394,104 @@ func tokensToRegExp(rawTokens []interface{}, tokens *[]Token, options *Options)
 			endToken := rawTokens[len(rawTokens)-1]
 			if endToken == nil {
 				isEndDelimited = true
+			} else if str, ok := endToken.(string); ok && sequence != "" {
+				isEndDelimited = strings.HasSuffix(str, sequence)
 			} else if str, ok := endToken.(string); ok {
 				isEndDelimited = strings.Index(delimiter, str[len(str)-1:]) > -1
 			}
 		}
 
 		if !strict {
-			route += "(?:" + delimiter + "(?=" + endsWith + "))?"
+			writeStrings(&route, "(?:", delimiter, "(?=", endsWith, "))?")
 		}
 		if !isEndDelimited {
-			route += "(?=" + delimiter + "|" + endsWith + ")"
+			writeStrings(&route, "(?=", delimiter, "|", endsWith, ")")
 		}
 	}
 
-	return regexp2.Compile(route, flags(options))
+	if options.MaxRegexpLen > 0 && route.Len() > options.MaxRegexpLen {
+		return "", &LimitError{Limit: "MaxRegexpLen", Max: options.MaxRegexpLen, Index: route.Len()}
+	}
+
+	return route.String(), nil
+}
+
+// RouteSource returns the regexp source PathToRegexp generates for the path,
+// without compiling it. It's equal to the String of the returned regexp.
+func RouteSource(path interface{}, options *Options) (string, error) {
+	if err := options.Check(); err != nil {
+		return "", err
+	}
+	return pathToSource(path, nil, options)
 }
 
 // PathToRegexp normalizes the given path string, returning a regular expression.
 // An empty array can be passed in for the tokens, which will hold the
 // placeholder token descriptions. For example, using `/user/:id`, `tokens` will
 // contain `[{Name: 'id', Delimiter: '/', Optional: false, Repeat: false}]`.
+// The path may also be tokens, such as the result of Parse, given as a
+// []interface{} or a []Token, alone or as elements of an array, and a regexp
+// of the standard library, which is recompiled with the flags of the options.
+// Any other value implementing fmt.Stringer, such as a named string type with
+// a String method, is the template returned by its String method, the types
+// above taking precedence.
+//
+// The tokens are appended to the slice pointed to by tokens, which therefore
+// can't be shared by concurrent calls, and they're discarded when tokens is
+// nil. PathToRegexpTokens returns them instead.
 func PathToRegexp(path interface{}, tokens *[]Token, options *Options) (*regexp2.Regexp, error) {
+	re, parsed, err := PathToRegexpTokens(path, options)
+	if err != nil {
+		return nil, err
+	}
+	if tokens != nil {
+		*tokens = append(*tokens, parsed...)
+	}
+	return re, nil
+}
+
+// PathToRegexpTokens is like PathToRegexp but returns the tokens in a new
+// slice, which is safe for concurrent use.
+func PathToRegexpTokens(path interface{}, options *Options) (*regexp2.Regexp, []Token, error) {
+	var tokens []Token
+	re, err := pathToRegexp(path, &tokens, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return re, tokens, nil
+}
+
+// Returns the regexp of the path, appending its tokens, see PathToRegexp.
+func pathToRegexp(path interface{}, tokens *[]Token, options *Options) (*regexp2.Regexp, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
 	switch path := path.(type) {
 	case *regexp2.Regexp:
+		if path == nil {
+			return nil, errNilRegexp
+		}
 		return regexpToRegexp(path, tokens), nil
+	case *regexp.Regexp:
+		if path == nil {
+			return nil, errNilRegexp
+		}
+		return stdRegexpToRegexp(path, tokens, options)
 	case string:
 		return stringToRegexp(path, tokens, options)
 	}
+	if rawTokens, ok := pathTokens(path); ok {
+		return tokensToRegExp(rawTokens, tokens, options)
+	}
+	if str, ok := stringerPath(path); ok {
+		return stringToRegexp(str, tokens, options)
+	}
 
 	switch reflect.TypeOf(path).Kind() {
 	case reflect.Slice, reflect.Array:
 		return arrayToRegexp(toSlice(path), tokens, options)
 	}
 
-	return nil, errors.New(`path should be string, array or slice of strings, 
-or a regular expression with type *github.com/dlclark/regexp2.Regexp`)
+	return nil, errors.New(`path should be string, tokens, array or slice of strings, 
+or a regular expression with type *github.com/dlclark/regexp2.Regexp or *regexp.Regexp`)
 }
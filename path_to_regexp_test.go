@@ -7,8 +7,14 @@ package pathtoregexp
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dlclark/regexp2"
 )
@@ -1876,9 +1882,66 @@ var tests = []a{
 		a{},
 	},
 
+	/**
+	 * Standard library regexps.
+	 */
+	{
+		regexp.MustCompile(`(.*)`),
+		nil,
+		a{
+			Token{
+				Name:     0,
+				Prefix:   "",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "",
+			},
+		},
+		a{
+			a{"/match/anything", a{"/match/anything", "/match/anything"}},
+		},
+		a{},
+	},
+	{
+		regexp.MustCompile(`^\/(\d+)$`),
+		nil,
+		a{
+			Token{
+				Name:     0,
+				Prefix:   "",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "",
+			},
+		},
+		a{
+			a{"/abc", nil},
+			a{"/123", a{"/123", "123"}},
+		},
+		a{},
+	},
+
 	/**
 	 * Mixed arrays.
 	 */
+	{
+		a{"/test", regexp.MustCompile(`\/(\d+)`)},
+		nil,
+		a{
+			Token{
+				Name:     0,
+				Prefix:   "",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "",
+			},
+		},
+		a{
+			a{"/test", a{"/test", ""}},
+			a{"/123", a{"/123", "123"}},
+		},
+		a{},
+	},
 	{
 		a{"/test", regexp2.MustCompile("\\/(\\d+)", regexp2.None)},
 		nil,
@@ -2515,6 +2578,247 @@ var tests = []a{
 		},
 	},
 
+	/**
+	 * Exclude chars.
+	 */
+	{
+		"/:tenant/:file",
+		&Options{
+			Delimiter:    "/",
+			ExcludeChars: "./",
+		},
+		a{
+			Token{
+				Name:     "tenant",
+				Prefix:   "/",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[^\\.\\/]+?",
+			},
+			Token{
+				Name:     "file",
+				Prefix:   "/",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[^\\.\\/]+?",
+			},
+		},
+		a{
+			a{"/acme/readme", a{"/acme/readme", "acme", "readme"}},
+			a{"/acme/readme/", a{"/acme/readme/", "acme", "readme"}},
+			a{"/acme/readme.md", nil},
+			a{"/acme.com/readme", nil},
+			a{"/acme/readme#top", a{"/acme/readme#top", "acme", "readme#top"}},
+		},
+		a{
+			a{m{"tenant": "acme", "file": "readme"}, "/acme/readme"},
+			a{m{"tenant": "acme", "file": "readme.md"}, nil},
+		},
+	},
+	{
+		"/:tenant",
+		&Options{
+			ExcludeChars: ".",
+			End:          &falseValue,
+		},
+		a{
+			Token{
+				Name:     "tenant",
+				Prefix:   "/",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[^\\.]+?",
+			},
+		},
+		a{
+			a{"/acme", a{"/acme", "acme"}},
+			a{"/acme/users", a{"/acme", "acme"}},
+			a{"/acme#top", a{"/acme", "acme"}},
+			a{"/acme.com", nil},
+		},
+		a{
+			a{m{"tenant": "acme"}, "/acme"},
+			a{m{"tenant": "acme.com"}, nil},
+		},
+	},
+
+	/**
+	 * Delimiter sequences.
+	 */
+	{
+		":team\\:\\::project\\:\\::env",
+		&Options{
+			Delimiter: "::",
+		},
+		a{
+			Token{
+				Name:     "team",
+				Prefix:   "",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "(?:(?!\\:\\:).)+?",
+			},
+			Token{
+				Name:     "project",
+				Prefix:   "::",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "(?:(?!\\:\\:).)+?",
+			},
+			Token{
+				Name:     "env",
+				Prefix:   "::",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "(?:(?!\\:\\:).)+?",
+			},
+		},
+		a{
+			a{"acme::web::prod", a{"acme::web::prod", "acme", "web", "prod"}},
+			a{"acme::web::prod::", a{"acme::web::prod::", "acme", "web", "prod"}},
+			a{"a:b::web::prod", a{"a:b::web::prod", "a:b", "web", "prod"}},
+			a{"acme::web", nil},
+			a{"acme::web::prod::x", nil},
+		},
+		a{
+			a{m{"team": "acme", "project": "web", "env": "prod"}, "acme::web::prod"},
+			a{m{"team": "acme", "project": "web::api", "env": "prod"}, nil},
+		},
+	},
+	{
+		":team\\:\\::path*",
+		&Options{
+			Delimiter: "::",
+		},
+		a{
+			Token{
+				Name:     "team",
+				Prefix:   "",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "(?:(?!\\:\\:).)+?",
+			},
+			Token{
+				Name:     "path",
+				Prefix:   "::",
+				Suffix:   "",
+				Modifier: "*",
+				Pattern:  "(?:(?!\\:\\:).)+?",
+			},
+		},
+		a{
+			a{"acme", a{"acme", "acme", ""}},
+			a{"acme::", a{"acme::", "acme", ""}},
+			a{"acme::web::prod", a{"acme::web::prod", "acme", "web::prod"}},
+			a{"acme::a:b::c", a{"acme::a:b::c", "acme", "a:b::c"}},
+		},
+		a{
+			a{m{"team": "acme"}, "acme"},
+			a{m{"team": "acme", "path": a{"web", "prod"}}, "acme::web::prod"},
+			a{m{"team": "acme", "path": a{"web::prod"}}, nil},
+		},
+	},
+	{
+		"scope\\:\\:",
+		&Options{
+			Delimiter: "::",
+			End:       &falseValue,
+		},
+		a{
+			"scope::",
+		},
+		a{
+			a{"scope::", a{"scope::"}},
+			a{"scope::a", a{"scope::"}},
+			a{"scope:a", nil},
+		},
+		a{
+			a{nil, "scope::"},
+		},
+	},
+
+	/**
+	 * Literal backslashes.
+	 */
+	{
+		"C:\\Users\\:name\\:path*",
+		&Options{
+			Delimiter:        DelimiterBackslash,
+			LiteralBackslash: true,
+		},
+		a{
+			"C:\\Users",
+			Token{
+				Name:     "name",
+				Prefix:   "\\",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[^\\\\]+?",
+			},
+			Token{
+				Name:     "path",
+				Prefix:   "\\",
+				Suffix:   "",
+				Modifier: "*",
+				Pattern:  "[^\\\\]+?",
+			},
+		},
+		a{
+			a{"C:\\Users\\bob", a{"C:\\Users\\bob", "bob", ""}},
+			a{"C:\\Users\\bob\\", a{"C:\\Users\\bob\\", "bob", ""}},
+			a{"C:\\Users\\bob\\docs\\a.txt", a{"C:\\Users\\bob\\docs\\a.txt", "bob", "docs\\a.txt"}},
+			a{"C:\\Users\\bob/docs", a{"C:\\Users\\bob/docs", "bob/docs", ""}},
+			a{"C:/Users/bob", nil},
+		},
+		a{
+			a{m{"name": "bob"}, "C:\\Users\\bob"},
+			a{m{"name": "bob", "path": a{"docs", "a.txt"}}, "C:\\Users\\bob\\docs\\a.txt"},
+			a{m{"name": "bob\\docs"}, nil},
+		},
+	},
+	{
+		":drive([A-Z]):\\:dir\\:file",
+		&Options{
+			Delimiter:        DelimiterBackslash + "/",
+			LiteralBackslash: true,
+		},
+		a{
+			Token{
+				Name:     "drive",
+				Prefix:   "",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[A-Z]",
+			},
+			":",
+			Token{
+				Name:     "dir",
+				Prefix:   "\\",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[^\\\\\\/]+?",
+			},
+			Token{
+				Name:     "file",
+				Prefix:   "\\",
+				Suffix:   "",
+				Modifier: "",
+				Pattern:  "[^\\\\\\/]+?",
+			},
+		},
+		a{
+			a{"C:\\Users\\bob", a{"C:\\Users\\bob", "C", "Users", "bob"}},
+			a{"C:\\Users\\bob/", a{"C:\\Users\\bob/", "C", "Users", "bob"}},
+			a{"C:\\Users\\bob\\", a{"C:\\Users\\bob\\", "C", "Users", "bob"}},
+			a{"C:\\Users/bob", nil},
+			a{"C:\\Users\\a/b", nil},
+		},
+		a{
+			a{m{"drive": "D", "dir": "data", "file": "x"}, "D:\\data\\x"},
+			a{m{"drive": "D", "dir": "a/b", "file": "x"}, nil},
+		},
+	},
+
 	/**
 	 * Ends with.
 	 */
@@ -2888,6 +3192,83 @@ func TestPathToRegexp(t *testing.T) {
 			}
 		})
 
+		t.Run("should throw on capturing group in the middle of a pattern", func(t *testing.T) {
+			_, err := PathToRegexp("/:foo(\\d+|(x)|y)/:bar", nil, nil)
+			expect := errors.New("capturing groups are not allowed at 10")
+			if !reflect.DeepEqual(err, expect) {
+				t.Errorf(testErrorFormat, err, expect)
+			}
+		})
+
+		t.Run("should throw on capturing group inside non-capturing group", func(t *testing.T) {
+			_, err := PathToRegexp("/:id((?:a)|(?:b(c)))", nil, nil)
+			expect := errors.New("capturing groups are not allowed at 15")
+			if !reflect.DeepEqual(err, expect) {
+				t.Errorf(testErrorFormat, err, expect)
+			}
+		})
+
+		t.Run("should throw on named capturing groups", func(t *testing.T) {
+			for _, path := range []string{"/:id(a(?<x>b))", "/:id(a(?'x'b))", "/:id(a(?P<x>b))"} {
+				_, err := PathToRegexp(path, nil, nil)
+				expect := errors.New("capturing groups are not allowed at 6")
+				if !reflect.DeepEqual(err, expect) {
+					t.Errorf(testErrorFormat, err, expect)
+				}
+			}
+		})
+
+		t.Run("should allow parentheses in character classes and lookarounds", func(t *testing.T) {
+			tests := map[string]string{
+				"/:id([()]+)":        "[()]+",
+				"/:id([^)]+)":        "[^)]+",
+				"/:id([]()]+)":       "[]()]+",
+				"/:id(a(?<=a)b)":     "a(?<=a)b",
+				"/:id(a(?<!c)(?!d))": "a(?<!c)(?!d)",
+			}
+			for path, pattern := range tests {
+				tokens, err := Parse(path, nil)
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if result := tokens[0].(Token).Pattern; result != pattern {
+					t.Errorf(testErrorFormat, result, pattern)
+				}
+			}
+		})
+
+		t.Run("should report character indexes", func(t *testing.T) {
+			tests := map[string]string{
+				"/café/:":          "missing parameter name at 6",
+				"/шеллы/:foo(?)":   "pattern cannot start with \"?\" at 12",
+				"/😀/:foo(a(b))":    "capturing groups are not allowed at 9",
+				"/\\é/:foo(abc":    "unbalanced pattern at 8",
+				"/\xff\xfe/:foo()": "missing pattern at 8",
+			}
+			for path, message := range tests {
+				_, err := Parse(path, nil)
+				if err == nil || err.Error() != message {
+					t.Errorf(testErrorFormat, err, message)
+				}
+			}
+
+			tokens, err := lexer("/é\\ü:x", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := []lexToken{
+				{mode: modeChar, index: 0, value: "/"},
+				{mode: modeChar, index: 1, value: "é"},
+				{mode: modeEscapedChar, index: 2, value: "ü"},
+				{mode: modeName, index: 4, value: "x"},
+				{mode: modeEnd, index: 6, value: ""},
+			}
+			if !reflect.DeepEqual(tokens, expect) {
+				t.Errorf(testErrorFormat, tokens, expect)
+			}
+		})
+
 		t.Run("should throw on unbalanced pattern", func(t *testing.T) {
 			_, err := PathToRegexp("/:foo(abc", nil, nil)
 			expect := errors.New("unbalanced pattern at 5")
@@ -3009,6 +3390,24 @@ func TestPathToRegexp(t *testing.T) {
 										t.Errorf(testErrorFormat, result, path)
 									}
 								})
+								t.Run("should compile without validation using "+inspect(params), func(t *testing.T) {
+									options := &Options{}
+									if merged := mergeOptions(o, o1); merged != nil {
+										*options = *merged
+									}
+									options.Validate = &falseValue
+									toPath, err := Compile(path, options)
+									if err != nil {
+										t.Fatal(err)
+									}
+									r, err := toPath(params)
+									if err != nil {
+										t.Fatal(err)
+									}
+									if !reflect.DeepEqual(r, result) {
+										t.Errorf(testErrorFormat, r, result)
+									}
+								})
 							} else {
 								t.Run("should not compile using "+inspect(params), func(t *testing.T) {
 									_, err := toPath(params)
@@ -3038,6 +3437,7 @@ func TestPathToRegexp(t *testing.T) {
 					name = " using " + inspect(opts)
 				}
 				t.Run("match"+name, func(t *testing.T) {
+					pathOptions := o
 					for _, v := range matchCases {
 						io := v.(a)
 						pathname, matches := io[0], io[1]
@@ -3065,6 +3465,27 @@ func TestPathToRegexp(t *testing.T) {
 							options = io[3].(*Options)
 						}
 
+						if path, ok := path.(string); ok {
+							t.Run(message+" using matcher", func(t *testing.T) {
+								m, err := NewMatcher(path, pathOptions)
+								if err != nil {
+									t.Fatal(err)
+								}
+								result, err := m.Match(pathname.(string))
+								if err != nil {
+									t.Fatal(err)
+								}
+								if (result == nil) != (o == nil) || (result != nil && result.Path != o[0]) {
+									t.Errorf(testErrorFormat, result, matches)
+								}
+								// The groups are those the regexp saw.
+								groups := exec(r, pathname.(string))
+								if result != nil && !reflect.DeepEqual(result.Groups, groups) {
+									t.Errorf(testErrorFormat, result.Groups, groups)
+								}
+							})
+						}
+
 						if path, ok := path.(string); ok && params != nil {
 							match := MustMatch(path, options)
 							t.Run(message+" params", func(t *testing.T) {
@@ -3153,18 +3574,2041 @@ func TestPathToRegexp(t *testing.T) {
 	})
 }
 
-func TestMustCompile(t *testing.T) {
-	r := MustCompile("/user/:id(\\d+)", nil)
-	if r == nil {
-		t.Errorf(testErrorFormat, r, "func")
-	}
-}
+func TestPathToRegexpTokens(t *testing.T) {
+	t.Run("should return the tokens of PathToRegexp", func(t *testing.T) {
+		paths := []interface{}{
+			"/users/:id(\\d+)/:tab?",
+			[]string{"/users/:id", "/posts/:post/(.*)"},
+			regexp2.MustCompile("^/(?<year>\\d+)/(\\w+)$", regexp2.None),
+			regexp.MustCompile("^/(?P<year>\\d+)/(\\w+)$"),
+		}
+		for _, path := range paths {
+			var tokens []Token
+			re, err := PathToRegexp(path, &tokens, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			re2, tokens2, err := PathToRegexpTokens(path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if re2.String() != re.String() {
+				t.Errorf(testErrorFormat, re2, re)
+			}
+			if len(tokens2) == 0 || !reflect.DeepEqual(tokens2, tokens) {
+				t.Errorf(testErrorFormat, tokens2, tokens)
+			}
+		}
+	})
+
+	t.Run("should return fresh tokens", func(t *testing.T) {
+		_, tokens, _ := PathToRegexpTokens("/:a/:b", nil)
+		_, tokens2, _ := PathToRegexpTokens("/:a/:b", nil)
+		tokens[0].Name = "c"
+		if tokens2[0].Name != "a" {
+			t.Errorf(testErrorFormat, tokens2[0].Name, "a")
+		}
+	})
+
+	t.Run("should return the error of PathToRegexp", func(t *testing.T) {
+		re, tokens, err := PathToRegexpTokens("/:foo(", nil)
+		if err == nil || re != nil || tokens != nil {
+			t.Errorf(testErrorFormat, err, "none nil error")
+		}
+	})
+}
+
+func TestMustCompile(t *testing.T) {
+	r := MustCompile("/user/:id(\\d+)", nil)
+	if r == nil {
+		t.Errorf(testErrorFormat, r, "func")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	t.Run("should reject template longer than MaxTemplateLen", func(t *testing.T) {
+		_, err := Parse("/foo/:bar", &Options{Limits: &Limits{MaxTemplateLen: 8}})
+		expect := &LimitError{Limit: "MaxTemplateLen", Max: 8, Index: 8}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should reject more tokens than MaxTokens", func(t *testing.T) {
+		_, err := Parse("/:a/:b/:c", &Options{Limits: &Limits{MaxTokens: 2}})
+		expect := &LimitError{Limit: "MaxTokens", Max: 2, Index: 7}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+
+		_, err = Parse("/a/:b/c", &Options{Limits: &Limits{MaxTokens: 2}})
+		expect = &LimitError{Limit: "MaxTokens", Max: 2, Index: 6}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should reject pattern longer than MaxPatternLen", func(t *testing.T) {
+		_, err := Parse("/:foo(\\d+\\.\\d+)", &Options{Limits: &Limits{MaxPatternLen: 7}})
+		expect := &LimitError{Limit: "MaxPatternLen", Max: 7, Index: 14}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+
+		_, err = PathToRegexp("/:foo(abcd)", nil, &Options{Limits: &Limits{MaxPatternLen: 3}})
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != "MaxPatternLen" {
+			t.Errorf(testErrorFormat, err, "MaxPatternLen error")
+		}
+	})
+
+	t.Run("should parse templates within limits", func(t *testing.T) {
+		limits := &Limits{MaxTemplateLen: 10, MaxTokens: 3, MaxPatternLen: 3}
+		for _, path := range []string{"/foo/:bar", "/:a/:b/:c", "/:foo(\\d+)"} {
+			tokens, err := Parse(path, &Options{Limits: limits})
+			if err != nil {
+				t.Error(err)
+			}
+			expect, _ := Parse(path, nil)
+			if !reflect.DeepEqual(tokens, expect) {
+				t.Errorf(testErrorFormat, tokens, expect)
+			}
+		}
+	})
+
+	t.Run("should be unlimited with zero values", func(t *testing.T) {
+		_, err := Parse("/:a/:b/:c/:d(\\d+\\.\\d+)", &Options{Limits: &Limits{}})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestMaxRegexpLen(t *testing.T) {
+	paths := []string{"/:foo+", "/:foo/:bar*", "/baz/:qux?"}
+
+	t.Run("should reject array exceeding MaxRegexpLen", func(t *testing.T) {
+		_, err := PathToRegexp(paths, nil, &Options{MaxRegexpLen: 100})
+		limitErr, ok := err.(*LimitError)
+		if !ok {
+			t.Fatalf(testErrorFormat, err, "*LimitError")
+		}
+		if limitErr.Limit != "MaxRegexpLen" || limitErr.Index <= 100 {
+			t.Errorf(testErrorFormat, limitErr, "MaxRegexpLen error")
+		}
+		if !reflect.DeepEqual(limitErr.Templates, paths) {
+			t.Errorf(testErrorFormat, limitErr.Templates, paths)
+		}
+	})
+
+	t.Run("should reject template exceeding MaxRegexpLen", func(t *testing.T) {
+		_, err := PathToRegexp("/:foo+", nil, &Options{MaxRegexpLen: 10})
+		expect := "MaxRegexpLen of 10 exceeded at 53 by `/:foo+`"
+		if err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should compile under a big limit", func(t *testing.T) {
+		r, err := PathToRegexp(paths, nil, &Options{MaxRegexpLen: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{"/a/b", "a/b", "", "", ""}
+		if result := exec(r, "/a/b"); !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+}
+
+func TestMaxPathLen(t *testing.T) {
+	segments := make([]string, 10)
+	for i := range segments {
+		segments[i] = "ab"
+	}
+
+	t.Run("should build a path just under the limit", func(t *testing.T) {
+		path, err := MustCompile("/files/:path*", &Options{MaxPathLen: 36})(m{"path": segments})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(path) != 36 {
+			t.Errorf(testErrorFormat, len(path), 36)
+		}
+	})
+
+	t.Run("should reject a path just over the limit", func(t *testing.T) {
+		_, err := MustCompile("/files/:path*", &Options{MaxPathLen: 35})(m{"path": segments})
+		expect := &PathLenError{Max: 35, Token: "path", Len: 36}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		if msg := `MaxPathLen of 35 exceeded by "path" with 36`; err.Error() != msg {
+			t.Errorf(testErrorFormat, err.Error(), msg)
+		}
+	})
+
+	t.Run("should measure the encoded path", func(t *testing.T) {
+		options := &Options{MaxPathLen: 11, Encoding: EncodingURIComponent}
+		if path, err := MustCompile("/files/:path", options)(m{"path": "a_b"}); err != nil {
+			t.Errorf(testErrorFormat, err, path)
+		}
+		_, err := MustCompile("/files/:path", options)(m{"path": "a b"})
+		expect := &PathLenError{Max: 11, Token: "path", Len: 12}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		_, err = MustCompile("/files/:path", &Options{MaxPathLen: 11, ASCIIOnly: true})(m{"path": "é"})
+		expect = &PathLenError{Max: 11, Len: 13}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should check the literals and the query", func(t *testing.T) {
+		_, err := MustCompile("/a-very-long-literal", &Options{MaxPathLen: 8})(nil)
+		expect := &PathLenError{Max: 8, Len: 20}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		toPath := MustCompile("/search?q=:q", &Options{MaxPathLen: 14, QueryParams: true})
+		if path, err := toPath(m{"q": "abcd"}); err != nil {
+			t.Errorf(testErrorFormat, err, path)
+		}
+		_, err = toPath(m{"q": "abcde"})
+		expect = &PathLenError{Max: 14, Len: 15}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		if msg := "MaxPathLen of 14 exceeded with 15"; err.Error() != msg {
+			t.Errorf(testErrorFormat, err.Error(), msg)
+		}
+	})
+}
+
+func TestMaxRepeats(t *testing.T) {
+	segments := []string{"a", "b", "c"}
+
+	t.Run("should build a path just under the limit", func(t *testing.T) {
+		path, err := MustCompile("/files/:path+", &Options{MaxRepeats: 3})(m{"path": segments})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "/files/a/b/c"; path != expect {
+			t.Errorf(testErrorFormat, path, expect)
+		}
+	})
+
+	t.Run("should reject the values just over the limit before encoding them", func(t *testing.T) {
+		encoded := 0
+		options := &Options{MaxRepeats: 2, Encode: func(value string, token interface{}) string {
+			encoded++
+			return value
+		}}
+		_, err := MustCompile("/files/:path*", options)(m{"path": segments})
+		expect := &RepeatsError{Max: 2, Token: "path", Len: 3}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		if msg := `MaxRepeats of 2 exceeded by "path" with 3`; err.Error() != msg {
+			t.Errorf(testErrorFormat, err.Error(), msg)
+		}
+		if encoded != 0 {
+			t.Errorf(testErrorFormat, encoded, 0)
+		}
+	})
+
+	t.Run("should accept any number of values by default", func(t *testing.T) {
+		values := make([]int, 1000)
+		path, err := MustCompile("/:n(\\d+)*", nil)(m{"n": values})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := strings.Repeat("/0", 1000); path != expect {
+			t.Errorf(testErrorFormat, path, expect)
+		}
+	})
+}
+
+func TestDotNames(t *testing.T) {
+	options := &Options{DotNames: true}
+
+	t.Run("should parse the dots between the characters of a name", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			expect  []interface{}
+		}{
+			{"/:user.id", options, []interface{}{
+				Token{Name: "user.id", Prefix: "/", Pattern: "[^\\/#\\?]+?"},
+			}},
+			{"/:file.:ext", options, []interface{}{
+				Token{Name: "file", Prefix: "/", Pattern: "[^\\/#\\?]+?"},
+				Token{Name: "ext", Prefix: ".", Pattern: "[^\\/#\\?]+?"},
+			}},
+			{"/:user.", options, []interface{}{
+				Token{Name: "user", Prefix: "/", Pattern: "[^\\/#\\?]+?"},
+				".",
+			}},
+			{"/:user.id", nil, []interface{}{
+				Token{Name: "user", Prefix: "/", Pattern: "[^\\/#\\?]+?"},
+				".id",
+			}},
+		}
+		for _, test := range tests {
+			tokens, err := Parse(test.path, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tokens, test.expect) {
+				t.Errorf(testErrorFormat, tokens, test.expect)
+			}
+		}
+	})
+
+	t.Run("should look up the values in the nested data", func(t *testing.T) {
+		type author struct {
+			ID   int
+			Name string
+		}
+		toPath := MustCompile("/users/:user.id/posts/:post.slug", options)
+		tests := []struct {
+			data   interface{}
+			expect string
+		}{
+			{m{"user": m{"id": 42}, "post": m{"slug": "x"}}, "/users/42/posts/x"},
+			{m{"user": author{ID: 7}, "post": &struct{ Slug string }{"y"}}, "/users/7/posts/y"},
+			{m{"user.id": "1", "post": map[string]string{"slug": "z"}}, "/users/1/posts/z"},
+		}
+		for _, test := range tests {
+			path, err := toPath(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+
+		result, err := MustMatch("/users/:user.id", options)("/users/42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "42"; result.Param("user.id") != expect {
+			t.Errorf(testErrorFormat, result.Param("user.id"), expect)
+		}
+	})
+
+	t.Run("should name the full dotted path of a missing branch", func(t *testing.T) {
+		toPath := MustCompile("/users/:user.profile.id", options)
+		for _, data := range []interface{}{m{}, m{"user": m{}}, m{"user": m{"profile": nil}}, m{"user": "x"}} {
+			_, err := toPath(data)
+			if expect := `expected "user.profile.id" to be a string`; err == nil || err.Error() != expect {
+				t.Errorf(testErrorFormat, err, expect)
+			}
+		}
+	})
+}
+
+func TestRepeatSeparator(t *testing.T) {
+	t.Run("should round-trip the values of a repeated param", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			params  m
+			expect  string
+		}{
+			{"/{:ids}+", &Options{RepeatSeparator: ","}, m{"ids": []interface{}{"1", "2", "3"}}, "/1,2,3"},
+			{"/tags/{:tags(\\w+)}*", &Options{RepeatSeparator: "+"}, m{"tags": []interface{}{"a", "b", "c"}}, "/tags/a+b+c"},
+			{"/{:ids}+", &Options{RepeatSeparator: ","}, m{"ids": []interface{}{"1"}}, "/1"},
+			{"/:ids+", &Options{RepeatSeparator: ","}, m{"ids": []interface{}{"1", "2", "3"}}, "/1/2/3"},
+		}
+		for _, test := range tests {
+			path, err := MustCompile(test.path, test.options)(test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+			result, err := MustMatch(test.path, test.options)(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var params map[interface{}]interface{}
+			if result != nil {
+				params = result.Params
+			}
+			expect := map[interface{}]interface{}{}
+			for k, v := range test.params {
+				values := make([]string, len(v.([]interface{})))
+				for i, value := range v.([]interface{}) {
+					values[i] = value.(string)
+				}
+				expect[k] = values
+			}
+			if !reflect.DeepEqual(params, expect) {
+				t.Errorf(testErrorFormat, params, expect)
+			}
+		}
+	})
+
+	t.Run("should match an empty optional param", func(t *testing.T) {
+		result, err := MustMatch("/tags/{:tags(\\w+)}*", &Options{RepeatSeparator: "+"})("/tags/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil || len(result.Params) != 0 {
+			t.Errorf(testErrorFormat, result, "no params")
+		}
+	})
+
+	t.Run("should concatenate the values by default", func(t *testing.T) {
+		path, err := MustCompile("/{:ids}+", nil)(m{"ids": []interface{}{"1", "2", "3"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "/123"; path != expect {
+			t.Errorf(testErrorFormat, path, expect)
+		}
+	})
+}
+
+func TestRegexFlags(t *testing.T) {
+	t.Run("should reject the patterns which aren't RE2", func(t *testing.T) {
+		options := &Options{RegexFlags: regexp2.RE2}
+		_, err := PathToRegexp("/users/:id((?!new)[a-z]+)", nil, options)
+		expect := `pattern "(?!new)[a-z]+" for "id" isn't RE2: `
+		if err == nil || !strings.HasPrefix(err.Error(), expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		if _, err := Compile("/users/:id([a-z]+)", options); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("should match the plain templates alike", func(t *testing.T) {
+		path := "/users/:id(\\d+)/:tab?"
+		with, without := mustMatcher(path, &Options{RegexFlags: regexp2.RE2}), mustMatcher(path, nil)
+		for _, pathname := range []string{"/users/42", "/USERS/42/posts", "/users/42/", "/users/x", "/users/42/a/b"} {
+			result, err := with.Match(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect, err := without.Match(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, expect) {
+				t.Errorf(testErrorFormat, result, expect)
+			}
+		}
+	})
+
+	t.Run("should add the flags to the case of Sensitive", func(t *testing.T) {
+		tests := []struct {
+			options  *Options
+			pathname string
+			expect   bool
+		}{
+			{&Options{Sensitive: true, RegexFlags: regexp2.IgnoreCase}, "/USERS/a", true},
+			{&Options{Sensitive: true}, "/USERS/a", false},
+			{&Options{RegexFlags: regexp2.Singleline}, "/users/a\nb", true},
+			{&Options{RegexFlags: regexp2.Singleline}, "/USERS/a\nb", true},
+			{nil, "/users/a\nb", false},
+		}
+		for _, test := range tests {
+			result, err := mustMatcher("/users/:body(.+)", test.options).Match(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (result != nil) != test.expect {
+				t.Errorf(testErrorFormat, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should ignore the case of the literals with IgnoreCase", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			options  Options
+			pathname string
+		}{
+			{"/Test", Options{}, "/test"},
+			{"/search?tab=posts", Options{QueryParams: true}, "/search?tab=POSTS"},
+			{"/items;view=full", Options{MatrixParams: true}, "/items;view=FULL"},
+		}
+		for _, test := range tests {
+			for _, ignore := range []bool{true, false} {
+				options := test.options
+				options.Sensitive = true
+				if ignore {
+					options.RegexFlags = regexp2.IgnoreCase
+				}
+				result, err := mustMatcher(test.path, &options).Match(test.pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (result != nil) != ignore {
+					t.Errorf(testErrorFormat, result, ignore)
+				}
+			}
+		}
+	})
+
+	t.Run("should reject the flags changing the groups", func(t *testing.T) {
+		_, err := Match("/users/:id", &Options{RegexFlags: regexp2.ExplicitCapture})
+		expect := "invalid options: RegexFlags has flags changing the groups or the syntax of the generated regexps: 0x4"
+		if err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should hash the flags", func(t *testing.T) {
+		hash, err := RouteHash("/users/:id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flagged, err := RouteHash("/users/:id", &Options{RegexFlags: regexp2.Singleline})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flagged == hash {
+			t.Errorf(testErrorFormat, flagged, "another hash")
+		}
+	})
+}
+
+func TestMatchTimeout(t *testing.T) {
+	options := &Options{MatchTimeout: 50 * time.Millisecond}
+	pathname := "/" + strings.Repeat("a", 40) + "!"
+
+	t.Run("should time out on catastrophic pattern", func(t *testing.T) {
+		match, err := Match("/:foo((?:a|aa)+)", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		result, err := match(pathname)
+		if !errors.Is(err, ErrMatchTimeout) {
+			t.Errorf(testErrorFormat, err, ErrMatchTimeout)
+		}
+		if result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf(testErrorFormat, d, "less than 5s")
+		}
+	})
+
+	t.Run("should apply timeout to arrays", func(t *testing.T) {
+		r, err := PathToRegexp([]string{"/:foo((?:a|aa)+)", "/test"}, nil, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.MatchTimeout != options.MatchTimeout {
+			t.Errorf(testErrorFormat, r.MatchTimeout, options.MatchTimeout)
+		}
+	})
+
+	t.Run("should time out on compile validation", func(t *testing.T) {
+		toPath, err := Compile("/:foo((?:a|aa)+)", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = toPath(m{"foo": pathname[1:]})
+		if !errors.Is(err, ErrMatchTimeout) {
+			t.Errorf(testErrorFormat, err, ErrMatchTimeout)
+		}
+	})
+
+	t.Run("should match within the timeout", func(t *testing.T) {
+		match := MustMatch("/:foo((?:a|aa)+)", options)
+		result, err := match("/aaa")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/aaa", Index: 0, Params: m{"foo": "aaa"}}
+		if !expect.equals(result) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+}
+
+func TestErrorOnNoMatch(t *testing.T) {
+	t.Run("should return nil without error by default", func(t *testing.T) {
+		match := MustMatch("/users/:id", nil)
+		result, err := match("/posts/1")
+		if err != nil || result != nil {
+			t.Errorf(testErrorFormat, err, nil)
+		}
+	})
+
+	t.Run("should return ErrNoMatch when the pathname doesn't match", func(t *testing.T) {
+		match := MustMatch("/users/:id", &Options{ErrorOnNoMatch: true})
+		result, err := match("/posts/1")
+		if !errors.Is(err, ErrNoMatch) {
+			t.Errorf(testErrorFormat, err, ErrNoMatch)
+		}
+		if result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+		if expected := `path doesn't match: "/posts/1"`; err == nil || err.Error() != expected {
+			t.Errorf(testErrorFormat, err, expected)
+		}
+
+		result, err = match("/users/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[interface{}]interface{}{"id": "1"}; !reflect.DeepEqual(result.Params, expected) {
+			t.Errorf(testErrorFormat, result.Params, expected)
+		}
+	})
+
+	t.Run("should return ErrNoMatch from the cached results", func(t *testing.T) {
+		match := MustMatch("/users/:id", &Options{ErrorOnNoMatch: true, ResultCache: 2})
+		for i := 0; i < 2; i++ {
+			if _, err := match("/posts/1"); !errors.Is(err, ErrNoMatch) {
+				t.Errorf(testErrorFormat, err, ErrNoMatch)
+			}
+		}
+	})
+
+	t.Run("should not return ErrNoMatch from the combined matchers", func(t *testing.T) {
+		options := &Options{ErrorOnNoMatch: true}
+		matchers := []*Matcher{mustMatcher("/users", options), mustMatcher("/posts/:id", options)}
+		index, result, err := LongestMatch(matchers, "/posts/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index != 1 || result == nil {
+			t.Errorf(testErrorFormat, index, 1)
+		}
+	})
+}
+
+func TestDecodeValues(t *testing.T) {
+	options := &Options{DecodeValues: true}
+
+	t.Run("should decode the params", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			pathname string
+			params   m
+		}{
+			{"/:name", "/caf%C3%A9", m{"name": "café"}},
+			{"/:path+", "/a%2Fb/caf%C3%A9", m{"path": []string{"a/b", "café"}}},
+		}
+		for _, test := range tests {
+			result, err := MustMatch(test.path, options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(test.params)) {
+				t.Errorf(testErrorFormat, result, test.params)
+			}
+		}
+	})
+
+	t.Run("should prefer an explicit decode function", func(t *testing.T) {
+		decode := func(str string, token interface{}) (string, error) {
+			return strings.ToUpper(str), nil
+		}
+		result, err := MustMatch("/:name", &Options{DecodeValues: true, Decode: decode})("/caf%C3%A9")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := "CAF%C3%A9"; result == nil || result.Params["name"] != expect {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should fail with malformed values", func(t *testing.T) {
+		if _, err := MustMatch("/:name", options)("/%E0%A4%A"); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+
+	t.Run("should be kept by ExportRoutes", func(t *testing.T) {
+		data, err := ExportRoutes([]*Matcher{mustMatcher("/:name", options)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := ImportRoutes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := restored[0].Match("/caf%C3%A9")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil || result.Params["name"] != "café" {
+			t.Errorf(testErrorFormat, result, "café")
+		}
+	})
+}
+
+func TestDecodedPath(t *testing.T) {
+	t.Run("should decode the matched path like the params", func(t *testing.T) {
+		for _, options := range []*Options{{DecodeValues: true}, {Decode: decodeURIComponent}} {
+			result, err := mustMatcher("/:name", options).Match("/caf%C3%A9")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Path != "/caf%C3%A9" {
+				t.Errorf(testErrorFormat, result.Path, "/caf%C3%A9")
+			}
+			if result.DecodedPath != "/café" {
+				t.Errorf(testErrorFormat, result.DecodedPath, "/café")
+			}
+			if result.Params["name"] != "café" {
+				t.Errorf(testErrorFormat, result.Params["name"], "café")
+			}
+		}
+	})
+
+	t.Run("should decode the path of a static template", func(t *testing.T) {
+		result, err := mustMatcher("/caf%C3%A9", &Options{DecodeValues: true}).Match("/caf%C3%A9/")
+		if err != nil || result == nil {
+			t.Fatalf(testErrorFormat, result, "/caf%C3%A9/")
+		}
+		if result.DecodedPath != "/café/" {
+			t.Errorf(testErrorFormat, result.DecodedPath, "/café/")
+		}
+	})
+
+	t.Run("should leave the path empty when it's not decoded", func(t *testing.T) {
+		result, err := mustMatcher("/:name", nil).Match("/caf%C3%A9")
+		if err != nil || result == nil {
+			t.Fatalf(testErrorFormat, result, "/caf%C3%A9")
+		}
+		if result.DecodedPath != "" {
+			t.Errorf(testErrorFormat, result.DecodedPath, "")
+		}
+	})
+
+	t.Run("should leave the path empty when it fails to decode", func(t *testing.T) {
+		decode := func(str string, token interface{}) (string, error) {
+			if token == nil {
+				return "", errors.New("no token")
+			}
+			return str, nil
+		}
+		result, err := mustMatcher("/:name", &Options{Decode: decode}).Match("/caf%C3%A9")
+		if err != nil || result == nil {
+			t.Fatalf(testErrorFormat, result, "/caf%C3%A9")
+		}
+		if result.DecodedPath != "" {
+			t.Errorf(testErrorFormat, result.DecodedPath, "")
+		}
+	})
+}
+
+func TestBindDuplicates(t *testing.T) {
+	options := &Options{BindDuplicates: true}
+
+	t.Run("should match the same text in every occurrence", func(t *testing.T) {
+		tests := []struct {
+			path     interface{}
+			options  *Options
+			pathname string
+			expect   map[interface{}]interface{}
+		}{
+			{"/compare/:lang/:lang", options, "/compare/en/en", m{"lang": "en"}},
+			{"/compare/:lang/:lang", options, "/compare/en/de", nil},
+			{"/compare/:lang/:lang", options, "/compare/en/EN", m{"lang": "EN"}},
+			{"/compare/:lang/:lang", &Options{BindDuplicates: true, Sensitive: true}, "/compare/en/EN", nil},
+			{"/:lang/:id/:lang", options, "/en/1/en", m{"lang": "en", "id": "1"}},
+			{"/:lang/:lang?", options, "/en", m{"lang": "en"}},
+			{"/:lang/:lang?", options, "/en/en", m{"lang": "en"}},
+			{"/:lang/:lang?", options, "/en/de", nil},
+			{"/:path+/-/:path+", options, "/a/b/-/a/b", m{"path": []string{"a", "b"}}},
+			{"/:path+/-/:path+", options, "/a/b/-/a", nil},
+			{"/:a-:a", options, "/a-b-a-b", m{"a": "a-b"}},
+			{[]string{"/:x/:y", "/:a/:a"}, options, "/1/1", m{"x": "1", "y": "1"}},
+			{[]string{"/:x/:y/z", "/:a/:a"}, options, "/1/1", m{"a": "1"}},
+			{[]string{"/:x/:y/z", "/:a/:a"}, options, "/1/2", nil},
+		}
+		for _, test := range tests {
+			result, err := MustMatch(test.path, test.options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expect == nil {
+				if result != nil {
+					t.Errorf("%v %s: "+testErrorFormat, test.path, test.pathname, result, nil)
+				}
+			} else if result == nil || !reflect.DeepEqual(result.Params, test.expect) {
+				t.Errorf("%v %s: "+testErrorFormat, test.path, test.pathname, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should match the occurrences independently by default", func(t *testing.T) {
+		result, err := MustMatch("/compare/:lang/:lang", nil)("/compare/en/de")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := map[interface{}]interface{}{"lang": "de"}; result == nil || !reflect.DeepEqual(result.Params, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should not change compiling", func(t *testing.T) {
+		path, err := MustCompile("/compare/:lang/:lang", options)(m{"lang": "en"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/compare/en/en" {
+			t.Errorf(testErrorFormat, path, "/compare/en/en")
+		}
+	})
+
+	t.Run("should shift the backreferences of the arrays", func(t *testing.T) {
+		tests := []struct {
+			source string
+			offset int
+			expect string
+		}{
+			{`^(?:/([^\/]+?))(?:/(\k<1>))$`, 2, `^(?:/([^\/]+?))(?:/(\k<3>))$`},
+			{`^/a\\k<1>(\k<12>)`, 3, `^/a\\k<1>(\k<15>)`},
+			{`^/\k<x>/\\`, 1, `^/\k<x>/\\`},
+		}
+		for _, test := range tests {
+			if source := shiftBackreferences(test.source, test.offset); source != test.expect {
+				t.Errorf(testErrorFormat, source, test.expect)
+			}
+		}
+	})
+}
+
+func TestConstraints(t *testing.T) {
+	// Reports whether the digits pass the Luhn checksum.
+	luhn := func(value string) bool {
+		sum := 0
+		for i := len(value) - 1; i >= 0; i-- {
+			d := int(value[i] - '0')
+			if (len(value)-i)%2 == 0 {
+				if d *= 2; d > 9 {
+					d -= 9
+				}
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	slugs := map[string]bool{"hello": true, "world": true}
+	options := &Options{Constraints: map[string]func(string) bool{
+		"id":   luhn,
+		"slug": func(value string) bool { return slugs[value] },
+	}}
+
+	t.Run("should match when the constraints pass", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			pathname string
+			expect   map[interface{}]interface{}
+		}{
+			{"/cards/:id(\\d+)/posts/:slug", "/cards/79927398713/posts/hello", m{"id": "79927398713", "slug": "hello"}},
+			{"/cards/:id(\\d+)/posts/:slug", "/cards/79927398710/posts/hello", nil},
+			{"/cards/:id(\\d+)/posts/:slug", "/cards/79927398713/posts/other", nil},
+			{"/posts/:slug+/:id(\\d+)?", "/posts/hello/world", m{"slug": []string{"hello", "world"}}},
+			{"/posts/:slug+/:id(\\d+)?", "/posts/hello/other", nil},
+		}
+		for _, test := range tests {
+			result, err := MustMatch(test.path, options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expect == nil {
+				if result != nil {
+					t.Errorf("%s: "+testErrorFormat, test.pathname, result, nil)
+				}
+			} else if result == nil || !reflect.DeepEqual(result.Params, test.expect) {
+				t.Errorf("%s: "+testErrorFormat, test.pathname, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should check the decoded values", func(t *testing.T) {
+		options := &Options{DecodeValues: true, Constraints: map[string]func(string) bool{
+			"slug": func(value string) bool { return value == "a b" },
+		}}
+		if result, err := MustMatch("/:slug", options)("/a%20b"); err != nil || result == nil {
+			t.Errorf(testErrorFormat, result, "/a%20b")
+		}
+	})
+
+	t.Run("should fall through to the next route", func(t *testing.T) {
+		s := NewRouteSet(InsertionOrder)
+		options := &Options{Constraints: map[string]func(string) bool{"id": luhn}}
+		if err := s.Add("card", "/cards/:id", options); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add("any", "/cards/:any", nil); err != nil {
+			t.Fatal(err)
+		}
+		for pathname, expect := range map[string]string{"/cards/79927398713": "card", "/cards/79927398710": "any"} {
+			if id, _, ok := s.Match(pathname); !ok || id != expect {
+				t.Errorf("%s: "+testErrorFormat, pathname, id, expect)
+			}
+		}
+	})
+
+	t.Run("should reject the constraints of unknown params", func(t *testing.T) {
+		_, err := NewMatcher("/cards/:id", options)
+		expect := `constraints of unknown params: "slug"`
+		if err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		if _, err := Match("/cards/:id", options); err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+}
+
+func TestValidateMatch(t *testing.T) {
+	t.Run("should not match a decoded value the pattern rejects", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			pathname string
+			expect   map[interface{}]interface{}
+		}{
+			{"/files/:name", "/files/a%20b", m{"name": "a b"}},
+			{"/files/:name", "/files/a%2Fb", nil},
+			{"/tags/:tag+", "/tags/a/b%20c", m{"tag": []string{"a", "b c"}}},
+			{"/tags/:tag+", "/tags/a/b%2Fc", nil},
+		}
+		options := &Options{DecodeValues: true, ValidateMatch: true}
+		for _, test := range tests {
+			// The raw value matches without validation.
+			if result, err := MustMatch(test.path, &Options{DecodeValues: true})(test.pathname); err != nil || result == nil {
+				t.Errorf("%s: "+testErrorFormat, test.pathname, result, "a match")
+			}
+
+			result, err := MustMatch(test.path, options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expect == nil {
+				if result != nil {
+					t.Errorf("%s: "+testErrorFormat, test.pathname, result, nil)
+				}
+			} else if result == nil || !reflect.DeepEqual(result.Params, test.expect) {
+				t.Errorf("%s: "+testErrorFormat, test.pathname, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should validate with the flags of the options", func(t *testing.T) {
+		decode := func(str string, token interface{}) (string, error) {
+			return strings.ToUpper(str), nil
+		}
+		match := MustMatch("/:code([a-z]+)", &Options{Decode: decode, ValidateMatch: true})
+		if result, err := match("/abc"); err != nil || result == nil {
+			t.Errorf(testErrorFormat, result, "a match")
+		}
+		match = MustMatch("/:code([a-z]+)", &Options{Decode: decode, ValidateMatch: true, Sensitive: true})
+		if result, err := match("/abc"); err != nil || result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should return a ValidationError", func(t *testing.T) {
+		options := &Options{DecodeValues: true, ValidateMatch: true, ValidateMatchError: true}
+		result, err := MustMatch("/files/:name", options)("/files/a%2Fb")
+		expect := &ValidationError{Token: "name", Pattern: "[^\\/#\\?]+?", Value: "a/b"}
+		if result != nil || !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+		message := `expected decoded "name" to match "[^\/#\?]+?", but got "a/b"`
+		if err == nil || err.Error() != message {
+			t.Errorf(testErrorFormat, err, message)
+		}
+	})
+}
+
+func TestSensitiveParams(t *testing.T) {
+	t.Run("should override the sensitivity of the params", func(t *testing.T) {
+		tests := []struct {
+			options  *Options
+			pathname string
+			expect   bool
+		}{
+			{&Options{SensitiveParams: []string{"serial"}}, "/devices/ABC1234/Owner/bob", true},
+			{&Options{SensitiveParams: []string{"serial"}}, "/DEVICES/ABC1234/owner/BOB", true},
+			{&Options{SensitiveParams: []string{"serial"}}, "/devices/abc1234/owner/bob", false},
+			{&Options{Sensitive: true, InsensitiveParams: []string{"owner"}}, "/devices/ABC1234/owner/BOB", true},
+			{&Options{Sensitive: true, InsensitiveParams: []string{"owner"}}, "/DEVICES/ABC1234/owner/bob", false},
+			{&Options{Sensitive: true, InsensitiveParams: []string{"owner"}}, "/devices/abc1234/owner/bob", false},
+			{&Options{SensitiveParams: []string{"0"}}, "/devices/ABC1234/owner/bob/X", true},
+			{&Options{SensitiveParams: []string{"0"}}, "/devices/ABC1234/owner/bob/x", false},
+		}
+		for _, test := range tests {
+			for _, engine := range []Engine{nil, StdEngine{}} {
+				o := test.options.Clone()
+				o.Engine = engine
+				result, err := MustMatch("/devices/:serial(ABC[0-9]{4})/owner/:owner([a-z]+)/([A-Z])?", o)(
+					test.pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (result != nil) != test.expect {
+					t.Errorf("%v %v: "+testErrorFormat, inspect(test.options), test.pathname, result, test.expect)
+				}
+			}
+		}
+	})
+
+	t.Run("should keep the tokens", func(t *testing.T) {
+		var tokens []Token
+		re, err := PathToRegexp("/:a(x)/:b(y)", &tokens, &Options{SensitiveParams: []string{"a"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source, expect := re.String(), "^(?:\\/((?-i:x)))(?:\\/(y))[\\/#\\?]?$"; source != expect {
+			t.Errorf(testErrorFormat, source, expect)
+		}
+		if tokens[0].Pattern != "x" {
+			t.Errorf(testErrorFormat, tokens[0].Pattern, "x")
+		}
+	})
+
+	t.Run("should validate the compiled values", func(t *testing.T) {
+		tests := []struct {
+			options *Options
+			data    m
+			expect  bool
+		}{
+			{&Options{SensitiveParams: []string{"serial"}}, m{"serial": "ABC1234", "owner": "BOB"}, true},
+			{&Options{SensitiveParams: []string{"serial"}}, m{"serial": "abc1234", "owner": "bob"}, false},
+			{&Options{Sensitive: true, InsensitiveParams: []string{"owner"}}, m{"serial": "ABC1234", "owner": "BOB"},
+				true},
+			{&Options{Sensitive: true, InsensitiveParams: []string{"owner"}}, m{"serial": "abc1234", "owner": "bob"},
+				false},
+		}
+		for _, test := range tests {
+			_, err := MustCompile("/devices/:serial(ABC[0-9]{4})/owner/:owner([a-z]+)", test.options)(test.data)
+			if (err == nil) != test.expect {
+				t.Errorf("%v: "+testErrorFormat, test.data, err, test.expect)
+			}
+		}
+	})
+}
+
+func TestExcludeChars(t *testing.T) {
+	t.Run("should default to the delimiter", func(t *testing.T) {
+		tests := []struct {
+			options *Options
+			expect  string
+		}{
+			{nil, "^(?:\\/([^\\/#\\?]+?))[\\/#\\?]?$"},
+			{&Options{Delimiter: "."}, "^(?:\\/([^\\.]+?))[\\.]?$"},
+			{&Options{Delimiter: ".", ExcludeChars: "./"}, "^(?:\\/([^\\.\\/]+?))[\\.]?$"},
+			{&Options{ExcludeChars: "/"}, "^(?:\\/([^\\/]+?))[\\/#\\?]?$"},
+		}
+		for _, test := range tests {
+			source, err := RouteSource("/:id", test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source != test.expect {
+				t.Errorf(testErrorFormat, source, test.expect)
+			}
+		}
+	})
+}
+
+func TestLowercasePath(t *testing.T) {
+	options := &Options{LowercasePath: true, Encoding: EncodingURIComponent}
+
+	t.Run("should lowercase the literals and the values", func(t *testing.T) {
+		tests := []struct {
+			path   string
+			data   interface{}
+			expect string
+		}{
+			{"/Users/:id", m{"id": "ABC"}, "/users/abc"},
+			{"/Docs/:path+", m{"path": []string{"Getting-Started", "FAQ"}}, "/docs/getting-started/faq"},
+			{"/Tags/:tag", m{"tag": "Café"}, "/tags/caf%C3%A9"},
+			{"/Items/:code([A-Z]+)", m{"code": "XYZ"}, "/items/xyz"},
+			{"/search?Q=:q", m{"q": "Go Lang"}, "/search?q=go+lang"},
+			{"/100%Sure", nil, "/100%sure"},
+		}
+		for _, test := range tests {
+			o := options
+			if strings.Contains(test.path, "?") {
+				o = &Options{LowercasePath: true, QueryParams: true}
+			}
+			path, err := MustCompile(test.path, o)(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should validate the values before", func(t *testing.T) {
+		o := &Options{LowercasePath: true, Sensitive: true}
+		if _, err := MustCompile("/Items/:code([a-z]+)", o)(m{"code": "XYZ"}); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+
+	t.Run("should not change matching", func(t *testing.T) {
+		path, err := MustCompile("/Users/:id", options)(m{"id": "Bob"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			options *Options
+			expect  bool
+		}{
+			{&Options{LowercasePath: true}, true},
+			{&Options{LowercasePath: true, Sensitive: true}, false},
+		}
+		for _, test := range tests {
+			result, err := MustMatch("/Users/:id", test.options)(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (result != nil) != test.expect {
+				t.Errorf(testErrorFormat, result, test.expect)
+			}
+		}
+	})
+}
+
+func TestASCIIOnly(t *testing.T) {
+	options := &Options{ASCIIOnly: true}
+
+	t.Run("should percent-encode the non-ASCII bytes", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			data    interface{}
+			expect  string
+		}{
+			{"/café", options, nil, "/caf%C3%A9"},
+			{"/:name", options, m{"name": "café"}, "/caf%C3%A9"},
+			{"/:name", options, m{"name": "caf%C3%A9"}, "/caf%C3%A9"},
+			{"/:name", options, m{"name": "100%"}, "/100%"},
+			{"/Crème/:path+", options, m{"path": []string{"brûlée", "x"}}, "/Cr%C3%A8me/br%C3%BBl%C3%A9e/x"},
+			{"/:name", &Options{ASCIIOnly: true, Encoding: EncodingURIComponent}, m{"name": "café"}, "/caf%C3%A9"},
+			{"/Café/:name", &Options{ASCIIOnly: true, LowercasePath: true}, m{"name": "É"}, "/caf%C3%A9/%C3%89"},
+			{"/café", nil, nil, "/café"},
+		}
+		for _, test := range tests {
+			path, err := MustCompile(test.path, test.options)(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should not change matching", func(t *testing.T) {
+		result, err := MustMatch("/café", options)("/café")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/café", Params: m{}, Groups: []string{"/café"}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+}
+
+func TestEncoding(t *testing.T) {
+	t.Run("should encode the values", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			encoding Encoding
+			params   m
+			expect   string
+		}{
+			{"/:test", EncodingURIComponent, m{"test": "a+b"}, "/a%2Bb"},
+			{"/:foo", EncodingURIComponent, m{"foo": "café"}, "/caf%C3%A9"},
+			{"/:foo", EncodingURIComponent, m{"foo": "a/b"}, "/a%2Fb"},
+			{"/:foo", EncodingURI, m{"foo": "a/b?c=é"}, "/a/b?c=%C3%A9"},
+			{"/:foo", EncodingNone, m{"foo": "a+b"}, "/a+b"},
+		}
+		for _, test := range tests {
+			options := &Options{Encoding: test.encoding, Validate: &falseValue}
+			path, err := MustCompile(test.path, options)(test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should encode the literals of the regexp", func(t *testing.T) {
+		result, err := MustMatch("/café", &Options{Encoding: EncodingURI})("/caf%C3%A9")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil {
+			t.Errorf(testErrorFormat, result, "a match")
+		}
+	})
+
+	t.Run("should prefer an explicit encode function", func(t *testing.T) {
+		path, err := MustCompile("/:foo", &Options{Encoding: EncodingURIComponent, Encode: identity})(m{"foo": "a+b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/a+b" {
+			t.Errorf(testErrorFormat, path, "/a+b")
+		}
+	})
+}
+
+func TestRequireValidUTF8(t *testing.T) {
+	options := &Options{Decode: decodeURIComponent, RequireValidUTF8: true}
+
+	t.Run("should reject overlong encodings when matching", func(t *testing.T) {
+		match := MustMatch("/:foo", options)
+		result, err := match("/ab%C0%AF")
+		expect := &UTF8Error{Token: "foo", Value: "ab%C0%AF", Offset: 2}
+		if result != nil || !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should reject truncated sequences in repeated params", func(t *testing.T) {
+		match := MustMatch("/:foo+", options)
+		_, err := match("/caf%C3%A9/caf%C3")
+		expect := &UTF8Error{Token: "foo", Value: "caf%C3", Offset: 3}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should accept valid UTF-8 when matching", func(t *testing.T) {
+		match := MustMatch("/:foo+", options)
+		result, err := match("/caf%C3%A9/%EF%BF%BD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := &MatchResult{Path: "/caf%C3%A9/%EF%BF%BD", Index: 0,
+			Params: m{"foo": []string{"café", "\uFFFD"}}}
+		if !expect.equals(result) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should decode invalid UTF-8 without the option", func(t *testing.T) {
+		match := MustMatch("/:foo", &Options{Decode: decodeURIComponent})
+		result, err := match("/%C0%AF")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Params["foo"] != "\xC0\xAF" {
+			t.Errorf(testErrorFormat, result.Params["foo"], "\xC0\xAF")
+		}
+	})
+
+	t.Run("should reject invalid UTF-8 when compiling", func(t *testing.T) {
+		toPath := MustCompile("/:foo/:bar*", &Options{Encode: encodeURIComponent, RequireValidUTF8: true})
+		_, err := toPath(m{"foo": "a\xC0\xAF"})
+		expect := &UTF8Error{Token: "foo", Value: "a\xC0\xAF", Offset: 1}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+
+		_, err = toPath(m{"foo": "café", "bar": []string{"x", "\xE2\x82"}})
+		expect = &UTF8Error{Token: "bar", Value: "\xE2\x82", Offset: 0}
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+
+		path, err := toPath(m{"foo": "café", "bar": []string{"x"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/caf%C3%A9/x" {
+			t.Errorf(testErrorFormat, path, "/caf%C3%A9/x")
+		}
+	})
+}
+
+func TestRejectTraversal(t *testing.T) {
+	noValidate := &Options{Validate: &falseValue, RejectTraversal: true}
+	encodeDots := func(uri string, token interface{}) string {
+		return strings.Replace(uri, ".", "%2e", -1)
+	}
+
+	tests := []a{
+		{"/files/:name", noValidate, m{"name": ".."}, nil},
+		{"/files/:name", noValidate, m{"name": "."}, nil},
+		{"/files/:name", noValidate, m{"name": "../../etc/passwd"}, nil},
+		{"/files/:name", noValidate, m{"name": "..%2Fx"}, nil},
+		{"/files/:name", noValidate, m{"name": "a/./b"}, nil},
+		{"/files/:name", noValidate, m{"name": "..\\x"}, nil},
+		{"/files/:name", &Options{Encode: encodeURIComponent, RejectTraversal: true}, m{"name": "../x"}, nil},
+		{"/files/:name", &Options{Encode: encodeDots, RejectTraversal: true}, m{"name": ".."}, nil},
+		{"/files/:name*", noValidate, m{"name": []string{"a", ".."}}, nil},
+		{"/files/:name", noValidate, m{"name": "v1.2.3"}, "/files/v1.2.3"},
+		{"/files/:name", noValidate, m{"name": "..."}, "/files/..."},
+		{"/files/:name", noValidate, m{"name": ".hidden"}, "/files/.hidden"},
+		{"/files/:name*", noValidate, m{"name": []string{"a", "b.c"}}, "/files/a/b.c"},
+		{"/files/:name", &Options{Encode: encodeDots, RejectTraversal: true}, m{"name": "v1.2"}, "/files/v1%2e2"},
+		{"/files/:name", &Options{Validate: &falseValue}, m{"name": "../x"}, "/files/../x"},
+	}
+	for _, test := range tests {
+		path, options, params, expect := test[0].(string), test[1].(*Options), test[2], test[3]
+		t.Run(inspect(params), func(t *testing.T) {
+			toPath := MustCompile(path, options)
+			result, err := toPath(params)
+			if expect == nil {
+				if err == nil {
+					t.Errorf(testErrorFormat, result, "error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != expect {
+				t.Errorf(testErrorFormat, result, expect)
+			}
+		})
+	}
+
+	t.Run("should name the token", func(t *testing.T) {
+		_, err := MustCompile("/files/:name", noValidate)(m{"name": ".."})
+		expect := errors.New(`expected "name" to not traverse paths, but got ".."`)
+		if !reflect.DeepEqual(err, expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+}
+
+func TestParseAll(t *testing.T) {
+	paths := []string{"/users/:id", "/posts/:post/:slug?"}
+
+	t.Run("should keep the tokens of each template", func(t *testing.T) {
+		groups, err := ParseAll(paths, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != len(paths) {
+			t.Fatalf(testErrorFormat, len(groups), len(paths))
+		}
+		for i, path := range paths {
+			tokens, _ := Parse(path, nil)
+			if !reflect.DeepEqual(groups[i], tokens) {
+				t.Errorf(testErrorFormat, groups[i], tokens)
+			}
+		}
+	})
+
+	t.Run("should report the index of the failing template", func(t *testing.T) {
+		_, err := ParseAll([]string{"/users/:id", "/posts/:post("}, nil)
+		var compileErr *CompileError
+		if !errors.As(err, &compileErr) || compileErr.Index != 1 || compileErr.Path != "/posts/:post(" {
+			t.Fatalf(testErrorFormat, err, "a *CompileError of index 1")
+		}
+		expect := `path 1 "/posts/:post(": unbalanced pattern at 12`
+		if err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should build the regexp of the templates", func(t *testing.T) {
+		groups, _ := ParseAll(paths, nil)
+		matcher, err := NewMatcher(TokenArray(groups), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := mustMatcher(paths, nil)
+		if matcher.RouteString() != expect.RouteString() {
+			t.Errorf(testErrorFormat, matcher.RouteString(), expect.RouteString())
+		}
+		result, err := matcher.Match("/posts/1/hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params := map[interface{}]interface{}{"post": "1", "slug": "hello"}; !reflect.DeepEqual(result.Params, params) {
+			t.Errorf(testErrorFormat, result.Params, params)
+		}
+
+		var alternatives []int
+		for _, binding := range matcher.GroupMap() {
+			alternatives = append(alternatives, binding.Alternative)
+		}
+		if expect := []int{0, 1, 1}; !reflect.DeepEqual(alternatives, expect) {
+			t.Errorf(testErrorFormat, alternatives, expect)
+		}
+	})
+}
+
+func TestArrayToRegexp(t *testing.T) {
+	t.Run("should leave out repeated alternatives", func(t *testing.T) {
+		tokens := &[]Token{}
+		r, err := PathToRegexp([]interface{}{"/user/:id", "/about", "/user/:id", "/post/:slug"}, tokens, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := Must(PathToRegexp([]string{"/user/:id", "/about", "/post/:slug"}, nil, nil))
+		if r.String() != expect.String() {
+			t.Errorf(testErrorFormat, r.String(), expect.String())
+		}
+
+		names := make([]interface{}, len(*tokens))
+		for i, token := range *tokens {
+			names[i] = token.Name
+		}
+		if !reflect.DeepEqual(names, []interface{}{"id", "slug"}) {
+			t.Errorf(testErrorFormat, names, []interface{}{"id", "slug"})
+		}
+
+		result, err := MustMatch([]string{"/user/:id", "/user/:id", "/post/:slug"}, nil)("/post/hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := &MatchResult{Path: "/post/hello", Index: 0, Params: m{"slug": "hello"}}
+		if !params.equals(result) {
+			t.Errorf(testErrorFormat, result, params)
+		}
+	})
+
+	t.Run("should handle slices of regexps like their elements", func(t *testing.T) {
+		first := regexp2.MustCompile("^\\/a\\/(\\d+)$", regexp2.None)
+		second := regexp2.MustCompile("^\\/b\\/(\\w+)$", regexp2.None)
+		placeholder := Token{Name: 0}
+		for _, path := range []interface{}{
+			[]*regexp2.Regexp{first, second},
+			[]interface{}{first, second},
+			[2]*regexp2.Regexp{first, second},
+		} {
+			tokens := []Token{}
+			r, err := PathToRegexp(path, &tokens, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect := "(?:^\\/a\\/(\\d+)$|^\\/b\\/(\\w+)$)"; r.String() != expect {
+				t.Errorf(testErrorFormat, r.String(), expect)
+			}
+			if expect := []Token{placeholder, placeholder}; !reflect.DeepEqual(tokens, expect) {
+				t.Errorf(testErrorFormat, tokens, expect)
+			}
+
+			match := MustMatch(path, nil)
+			for pathname, params := range map[string]m{"/a/12": {0: "12"}, "/b/x": {0: "x"}, "/c/x": nil} {
+				result, err := match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if params == nil {
+					if result != nil {
+						t.Errorf(testErrorFormat, result, nil)
+					}
+					continue
+				}
+				if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(params)) {
+					t.Errorf("%s: "+testErrorFormat, pathname, result, params)
+				}
+			}
+		}
+	})
+
+	t.Run("should fail with nil regexps", func(t *testing.T) {
+		if _, err := PathToRegexp([]*regexp2.Regexp{nil}, nil, nil); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+		if _, err := PathToRegexp((*regexp2.Regexp)(nil), nil, nil); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+	})
+
+	t.Run("should fail with invalid elements", func(t *testing.T) {
+		if _, err := PathToRegexp([]interface{}{"/a", nil}, nil, nil); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+		if _, err := PathToRegexp([]interface{}{"/a", 1}, nil, nil); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+	})
+}
+
+func TestTokensPath(t *testing.T) {
+	id := Token{Name: "id", Prefix: "/", Pattern: "\\d+"}
+	tab := Token{Name: "tab", Prefix: "/", Pattern: "[^\\/#\\?]+?", Modifier: "?"}
+	tests := []struct {
+		path     interface{}
+		template interface{}
+	}{
+		{[]interface{}{"/user", id, tab}, "/user/:id(\\d+)/:tab?"},
+		{[]Token{id, tab}, "/:id(\\d+)/:tab?"},
+		{[]interface{}{[]interface{}{"/user", id}, "/about", regexp2.MustCompile("^\\/post\\/(\\d+)", regexp2.None)},
+			[]interface{}{"/user/:id(\\d+)", "/about", regexp2.MustCompile("^\\/post\\/(\\d+)", regexp2.None)}},
+	}
+	pathnames := []string{"/user/1", "/user/1/posts", "/user/a", "/1/posts", "/about", "/post/2", "/USER/1/"}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("should match %v like %v", test.path, test.template), func(t *testing.T) {
+			var tokens, expectTokens []Token
+			re, err := PathToRegexp(test.path, &tokens, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := Must(PathToRegexp(test.template, &expectTokens, nil))
+			if re.String() != expect.String() {
+				t.Errorf(testErrorFormat, re.String(), expect.String())
+			}
+			if !reflect.DeepEqual(tokens, expectTokens) {
+				t.Errorf(testErrorFormat, tokens, expectTokens)
+			}
+
+			match, expectMatch := MustMatch(test.path, nil), MustMatch(test.template, nil)
+			for _, pathname := range pathnames {
+				result, err := match(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expectResult, err := expectMatch(pathname)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(result, expectResult) {
+					t.Errorf("%s: "+testErrorFormat, pathname, result, expectResult)
+				}
+			}
+		})
+	}
+
+	t.Run("should treat strings only as an array of templates", func(t *testing.T) {
+		re := Must(PathToRegexp([]interface{}{"/a", "/b"}, nil, nil))
+		expect := Must(PathToRegexp([]string{"/a", "/b"}, nil, nil))
+		if re.String() != expect.String() {
+			t.Errorf(testErrorFormat, re.String(), expect.String())
+		}
+	})
+}
+
+func TestStdRegexp(t *testing.T) {
+	t.Run("should use the flags of the options", func(t *testing.T) {
+		re := regexp.MustCompile(`^\/test$`)
+		for _, test := range []struct {
+			options *Options
+			expect  bool
+		}{
+			{nil, true},
+			{&Options{Sensitive: true}, false},
+		} {
+			result, err := MustMatch(re, test.options)("/TEST")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (result != nil) != test.expect {
+				t.Errorf(testErrorFormat, result, test.expect)
+			}
+		}
+	})
+
+	t.Run("should fail with a regexp regexp2 rejects", func(t *testing.T) {
+		re := regexp.MustCompile(`(?U)a+`)
+		_, err := PathToRegexp(re, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "isn't supported by regexp2") {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
+
+type testRoute string
+
+func (r testRoute) String() string {
+	return string(r)
+}
+
+type testResource struct {
+	name string
+}
+
+func (r testResource) String() string {
+	return "/" + r.name + "/:id"
+}
+
+func TestStringerPath(t *testing.T) {
+	tests := []struct {
+		path     interface{}
+		template interface{}
+	}{
+		{testRoute("/user/:id"), "/user/:id"},
+		{testResource{"posts"}, "/posts/:id"},
+		{[]interface{}{testRoute("/user/:id"), testResource{"posts"}}, []string{"/user/:id", "/posts/:id"}},
+		{[]testRoute{"/user/:id", "/about"}, []string{"/user/:id", "/about"}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("should match %v like %v", test.path, test.template), func(t *testing.T) {
+			var tokens, expectTokens []Token
+			re, err := PathToRegexp(test.path, &tokens, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect := Must(PathToRegexp(test.template, &expectTokens, nil))
+			if re.String() != expect.String() {
+				t.Errorf(testErrorFormat, re.String(), expect.String())
+			}
+			if !reflect.DeepEqual(tokens, expectTokens) {
+				t.Errorf(testErrorFormat, tokens, expectTokens)
+			}
+
+			matcher, err := NewMatcher(test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expect := mustMatcher(test.template, nil).Templates(); !reflect.DeepEqual(matcher.Templates(), expect) {
+				t.Errorf(testErrorFormat, matcher.Templates(), expect)
+			}
+		})
+	}
+
+	t.Run("should keep regexps before stringers", func(t *testing.T) {
+		re := regexp2.MustCompile("^\\/(\\d+)$", regexp2.None)
+		if r := Must(PathToRegexp(re, nil, nil)); r != re {
+			t.Errorf(testErrorFormat, r, re)
+		}
+	})
+}
+
+func TestMatchGroups(t *testing.T) {
+	tests := []struct {
+		path     interface{}
+		options  *Options
+		pathname string
+		expect   []string
+	}{
+		{"/files/:path+", nil, "/files/a/b", []string{"/files/a/b", "a/b"}},
+		{"/users/:id", &Options{DecodeValues: true}, "/users/a%20b", []string{"/users/a%20b", "a%20b"}},
+		{"/:a/:b?", nil, "/x", []string{"/x", "x", ""}},
+		{"/healthz", nil, "/healthz/", []string{"/healthz/"}},
+		{regexp2.MustCompile("^/(\\d+)/(\\w+)?$", regexp2.None), nil, "/42/", []string{"/42/", "42", ""}},
+		{regexp.MustCompile("^/(\\d+)-(\\w+)$"), nil, "/42-abc", []string{"/42-abc", "42", "abc"}},
+		{"/users/:id", &Options{Engine: StdEngine{}}, "/users/1", []string{"/users/1", "1"}},
+	}
+	for _, test := range tests {
+		t.Run("should return the raw groups of "+inspect(test.path), func(t *testing.T) {
+			result, err := MustMatch(test.path, test.options)(test.pathname)
+			if err != nil || result == nil {
+				t.Fatalf(testErrorFormat, result, test.expect)
+			}
+			if !reflect.DeepEqual(result.Groups, test.expect) {
+				t.Errorf(testErrorFormat, result.Groups, test.expect)
+			}
+			if re, ok := test.path.(*regexp2.Regexp); ok {
+				if groups := exec(re, test.pathname); !reflect.DeepEqual(result.Groups, groups) {
+					t.Errorf(testErrorFormat, result.Groups, groups)
+				}
+			}
+		})
+	}
+}
+
+func TestRegexpNamedGroups(t *testing.T) {
+	tests := []struct {
+		path     interface{}
+		pathname string
+		names    []interface{}
+		params   m
+	}{
+		{
+			regexp2.MustCompile("^\\/users\\/(?<user>\\d+)\\/posts\\/(?<post>\\d+)$", regexp2.None),
+			"/users/1/posts/2",
+			[]interface{}{"user", "post"},
+			m{"user": "1", "post": "2"},
+		},
+		{
+			regexp2.MustCompile("^\\/(?<lang>[a-z]{2})(?:\\/(\\w+))?\\/(\\d+)$", regexp2.None),
+			"/en/docs/3",
+			[]interface{}{0, 1, "lang"},
+			m{"lang": "en", 0: "docs", 1: "3"},
+		},
+		{
+			regexp.MustCompile(`^\/(?P<id>\d+)\.(\w+)$`),
+			"/12.json",
+			[]interface{}{0, "id"},
+			m{"id": "12", 0: "json"},
+		},
+		{
+			[]interface{}{
+				regexp2.MustCompile("^\\/a\\/(?<id>\\d+)$", regexp2.None),
+				"/b/:slug",
+				regexp2.MustCompile("^\\/c\\/(?<id>\\d+)\\/(\\d+)$", regexp2.None),
+			},
+			"/c/1/2",
+			[]interface{}{"slug", 0, "id"},
+			m{"id": "1", 0: "2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run("should name the params of "+inspect(test.path), func(t *testing.T) {
+			var tokens []Token
+			if _, err := PathToRegexp(test.path, &tokens, nil); err != nil {
+				t.Fatal(err)
+			}
+			names := make([]interface{}, len(tokens))
+			for i, token := range tokens {
+				names[i] = token.Name
+			}
+			if !reflect.DeepEqual(names, test.names) {
+				t.Errorf(testErrorFormat, names, test.names)
+			}
+
+			result, err := MustMatch(test.path, nil)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(test.params)) {
+				t.Errorf(testErrorFormat, result, test.params)
+			}
+		})
+	}
+}
+
+// The paths of the golden file of RouteSource, in order.
+var routeSourceFixtures = []struct {
+	path    interface{}
+	options *Options
+}{
+	{"/", nil},
+	{"/user/:id", nil},
+	{"/user/:id(\\d+)", nil},
+	{"/files/:path*", nil},
+	{"/tags/:tag+", nil},
+	{"/:from-:to", nil},
+	{"/{:lang/}?about", nil},
+	{"/caf\u00e9", &Options{Encode: encodeURIComponent}},
+	{"/user/:id", &Options{Strict: true}},
+	{"/user/:id", &Options{End: &falseValue}},
+	{"/user/:id", &Options{Start: &falseValue, Sensitive: true}},
+	{"/user/:id", &Options{Delimiter: "."}},
+	{[]string{"/a", "/b/:id"}, nil},
+	{regexp2.MustCompile("^\\/(\\d+)$", regexp2.None), nil},
+}
+
+func TestRouteSource(t *testing.T) {
+	t.Run("should match the golden file", func(t *testing.T) {
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "route_source.golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(golden), "\n"), "\n")
+		if len(lines) != len(routeSourceFixtures) {
+			t.Fatalf(testErrorFormat, len(lines), len(routeSourceFixtures))
+		}
+		for i, fixture := range routeSourceFixtures {
+			source, err := RouteSource(fixture.path, fixture.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source != lines[i] {
+				t.Errorf("%v: "+testErrorFormat, fixture.path, source, lines[i])
+			}
+		}
+	})
+
+	t.Run("should equal the source of the regexp", func(t *testing.T) {
+		for _, test := range tests {
+			var o *Options
+			if test[1] != nil {
+				o = test[1].(*Options)
+			}
+			source, err := RouteSource(test[0], o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if re := Must(PathToRegexp(test[0], nil, o)); source != re.String() {
+				t.Errorf("%v: "+testErrorFormat, test[0], source, re.String())
+			}
+		}
+	})
+
+	t.Run("should return the errors of PathToRegexp", func(t *testing.T) {
+		if _, err := RouteSource("/:foo(", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
+
+func TestTokenAccessors(t *testing.T) {
+	t.Run("should describe the named and unnamed tokens", func(t *testing.T) {
+		tokens, err := Parse("/:user/(\\d+)/:post?/(.*)", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		type accessors struct {
+			named bool
+			name  string
+			index int
+			ok    bool
+		}
+		var result []accessors
+		for _, token := range tokens {
+			if token, ok := token.(Token); ok {
+				index, ok := token.Index()
+				result = append(result, accessors{token.IsNamed(), token.NameString(), index, ok})
+			}
+		}
+		expect := []accessors{{true, "user", 0, false}, {false, "0", 0, true}, {true, "post", 0, false},
+			{false, "1", 1, true}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should compile the unnamed tokens by the decimal names", func(t *testing.T) {
+		toPath := MustCompile("/:user/(\\d+)/(.*)", nil)
+		path, err := toPath(map[interface{}]interface{}{"user": "a", 0: "1", "1": "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/a/1/b" {
+			t.Errorf(testErrorFormat, path, "/a/1/b")
+		}
+	})
+}
+
+func TestNumericKeys(t *testing.T) {
+	t.Run("should compile the unnamed params by either key", func(t *testing.T) {
+		tests := []struct {
+			data   interface{}
+			expect string
+		}{
+			{map[int]string{0: "a", 1: "b"}, "/a/b"},
+			{map[string]string{"0": "a", "1": "b"}, "/a/b"},
+			{map[int64]interface{}{0: "a", 1: 2}, "/a/2"},
+			{map[uint8]string{0: "a", 1: "b"}, "/a/b"},
+			{m{0: "a", "1": "b"}, "/a/b"},
+			{m{0: "a", "0": "x", "1": "b", 1: "y"}, "/a/y"},
+		}
+		toPath := MustCompile("/(\\w+)/(\\w+)", nil)
+		for _, test := range tests {
+			path, err := toPath(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should get the matched params by either key", func(t *testing.T) {
+		result, err := MustMatch("/:0/(\\w+)/(\\w+)?", nil)("/a/b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			name   interface{}
+			expect interface{}
+		}{
+			{0, "b"},
+			{"0", "a"},
+			{"00", nil},
+			{1, nil},
+			{"1", nil},
+			{"missing", nil},
+		}
+		for _, test := range tests {
+			if value := result.Param(test.name); value != test.expect {
+				t.Errorf("%v: "+testErrorFormat, test.name, value, test.expect)
+			}
+		}
+
+		result, err = MustMatch("/(\\w+)", nil)("/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := result.Param("0"); value != "a" {
+			t.Errorf(testErrorFormat, value, "a")
+		}
+		if value := (*MatchResult)(nil).Param(0); value != nil {
+			t.Errorf(testErrorFormat, value, nil)
+		}
+	})
+}
+
+func TestCompileRepeat(t *testing.T) {
+	toPath, err := Compile("/files/:path+.:ext", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments, expect := make([]interface{}, 50), "/files"
+	for i := range segments {
+		segments[i] = "s" + strconv.Itoa(i)
+		expect += "/s" + strconv.Itoa(i)
+	}
+	expect += ".json"
+
+	path, err := toPath(map[string]interface{}{"path": segments, "ext": "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != expect {
+		t.Errorf(testErrorFormat, path, expect)
+	}
+}
+
+func TestCompileValidators(t *testing.T) {
+	t.Run("should not compile validators when validate is false", func(t *testing.T) {
+		toPath, err := Compile("/:foo(a{2,1})", &Options{Validate: &falseValue})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path, err := toPath(map[string]interface{}{"foo": "aa"})
+		if err != nil || path != "/aa" {
+			t.Errorf(testErrorFormat, path, "/aa")
+		}
+	})
+
+	t.Run("should check the validators when compiling", func(t *testing.T) {
+		_, err := Compile("/:foo(a{2,1})", nil)
+		expect := "expected \"foo\" to match \"a{2,1}\": error parsing regexp: invalid repeat count in `^(?:a{2,1})$`"
+		if err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+
+		_, err = Compile("/:foo(a{2,1})", &Options{Engine: StdEngine{}})
+		expect = "expected \"foo\" to match \"a{2,1}\": error parsing regexp: invalid repeat count: `{2,1}`"
+		if err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should wrap the errors of the validators", func(t *testing.T) {
+		toPath := MustCompile("/:foo((?:a|aa)+)", &Options{MatchTimeout: 50 * time.Millisecond})
+		_, err := toPath(map[string]interface{}{"foo": strings.Repeat("a", 40) + "!"})
+		expect := `expected "foo" to match "(?:a|aa)+": `
+		if !errors.Is(err, ErrMatchTimeout) || !strings.HasPrefix(err.Error(), expect) {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		toPath := MustCompile("/:foo(\\d+)/:bar*", nil)
+		done := make(chan error)
+		for i := 0; i < 8; i++ {
+			go func(i int) {
+				_, err := toPath(map[string]interface{}{"foo": i, "bar": []string{"a", "b"}})
+				done <- err
+			}(i)
+		}
+		for i := 0; i < 8; i++ {
+			if err := <-done; err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
+
+func TestEncodeURIComponent(t *testing.T) {
+	// outputs of javascript's encodeURIComponent
+	tests := map[string]string{
+		"":                                 "",
+		"abcXYZ019":                        "abcXYZ019",
+		"-_.!~*'()":                        "-_.!~*'()",
+		" ":                                "%20",
+		"a+b":                              "a%2Bb",
+		";/?:@&=+$,#":                      "%3B%2F%3F%3A%40%26%3D%2B%24%2C%23",
+		"\"%<>[\\]^`{|}":                   "%22%25%3C%3E%5B%5C%5D%5E%60%7B%7C%7D",
+		"café":                             "caf%C3%A9",
+		"шеллы":                            "%D1%88%D0%B5%D0%BB%D0%BB%D1%8B",
+		"😀":                                "%F0%9F%98%80",
+		"\x00\n\x7f":                       "%00%0A%7F",
+		"http://example.com/a b?c=d&e=f#g": "http%3A%2F%2Fexample.com%2Fa%20b%3Fc%3Dd%26e%3Df%23g",
+	}
+	for k, v := range tests {
+		if result := EncodeURIComponent(k); result != v {
+			t.Errorf(testErrorFormat, result, v)
+		}
+	}
+}
+
+func TestEncodeURI(t *testing.T) {
+	// outputs of javascript's encodeURI
+	tests := map[string]string{
+		"":                                 "",
+		"abcXYZ019-_.!~*'()":               "abcXYZ019-_.!~*'()",
+		";/?:@&=+$,#":                      ";/?:@&=+$,#",
+		" ":                                "%20",
+		"\"%<>[\\]^`{|}":                   "%22%25%3C%3E%5B%5C%5D%5E%60%7B%7C%7D",
+		"café":                             "caf%C3%A9",
+		"😀":                                "%F0%9F%98%80",
+		"http://example.com/a b?c=d&e=f#g": "http://example.com/a%20b?c=d&e=f#g",
+	}
+	for k, v := range tests {
+		if result := encodeURI(k); result != v {
+			t.Errorf(testErrorFormat, result, v)
+		}
+	}
+}
+
+func TestDecodeURIComponent(t *testing.T) {
+	tests := map[string]string{
+		"a+b":          "a+b",
+		"a%2Bb":        "a+b",
+		"a%20b":        "a b",
+		"caf%C3%A9":    "café",
+		"%3A%2F%3F%23": ":/?#",
+	}
+	for k, v := range tests {
+		result, err := DecodeURIComponent(k)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if result != v {
+			t.Errorf(testErrorFormat, result, v)
+		}
+	}
+
+	t.Run("malformed URI sequence", func(t *testing.T) {
+		if _, err := DecodeURIComponent("%E0%A4%A"); err == nil {
+			t.Errorf(testErrorFormat, err, "error")
+		}
+	})
+
+	t.Run("should round trip the plus sign", func(t *testing.T) {
+		toPath := MustCompile("/:test", &Options{Encode: encodeURIComponent})
+		path, err := toPath(m{"test": "a+b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/a%2Bb" {
+			t.Errorf(testErrorFormat, path, "/a%2Bb")
+		}
+
+		match := MustMatch("/:test", &Options{Decode: decodeURIComponent})
+		for _, pathname := range []string{path, "/a+b"} {
+			result, err := match(pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Params["test"] != "a+b" {
+				t.Errorf(testErrorFormat, result.Params["test"], "a+b")
+			}
+		}
+	})
+}
 
 func TestDecodeURI(t *testing.T) {
 	tests := map[string]string{
 		"%3B%2F%3F%3A%40%26%3D%2B%24%2C%23": "%3B%2F%3F%3A%40%26%3D%2B%24%2C%23",
 		"http%3A%2F%2Fwww.example.com%2Fstring%20with%20%2B%20and%20%3F%20and%20%26%20and%20spaces": "http%3A%2F%2Fwww.example.com%2Fstring with %2B and %3F and %26 and spaces",
 		"https://developer.mozilla.org/ru/docs/JavaScript_%D1%88%D0%B5%D0%BB%D0%BB%D1%8B":           "https://developer.mozilla.org/ru/docs/JavaScript_шеллы",
+		"/search?q=a+b%20c": "/search?q=a+b c",
 	}
 	for k, v := range tests {
 		result, err := decodeURI(k)
@@ -3185,6 +5629,40 @@ func TestDecodeURI(t *testing.T) {
 	})
 }
 
+func TestEscapeString(t *testing.T) {
+	escapeRegexp := regexp2.MustCompile("([.+*?=^!:${}()[\\]|/\\\\])", regexp2.None)
+	tests := []string{
+		"",
+		"/user",
+		".+*?=^!:${}()[]|/\\",
+		"/api/v1.0/users(:id)",
+		"\\.\\/\\\\",
+		"/ru/docs/JavaScript_шеллы/café",
+		"/😀/[a-z]+?/{x}",
+		"#?&-,;'\"`~%@<>",
+	}
+	for _, str := range tests {
+		expect, err := escapeRegexp.Replace(str, "\\$1", -1, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := escapeString(str); result != expect {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	}
+}
+
+func TestEscapeCached(t *testing.T) {
+	for _, str := range []string{"/#?", "/", ".", "-|", "/#?"} {
+		if result, expect := escapeCached(str), escapeString(str); result != expect {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	}
+	if v, ok := escapedStrings.Load("-|"); !ok || v != "-\\|" {
+		t.Errorf(testErrorFormat, v, "-\\|")
+	}
+}
+
 func TestAnyString(t *testing.T) {
 	tests := map[string][]string{
 		"foo": {"", "", "foo", ""},
@@ -3279,19 +5757,67 @@ func BenchmarkPathToRegexp(b *testing.B) {
 		})
 	})
 
+	b.Run("large array", func(b *testing.B) {
+		paths := make([]string, 500)
+		for i := range paths {
+			paths[i] = "/resource" + strconv.Itoa(i) + "/:id"
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			PathToRegexp(paths, nil, nil)
+		}
+	})
+
 	b.Run("with end false", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			PathToRegexp("/foo/:bar", &[]Token{}, &Options{End: &falseValue})
 		}
 	})
+	b.Run("bulk", func(b *testing.B) {
+		paths := make([]string, 100)
+		for i := range paths {
+			paths[i] = "/api/resource" + strconv.Itoa(i) + "/:id/:action?"
+		}
+		options := &Options{EndsWith: "?#"}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, path := range paths {
+				PathToRegexp(path, nil, options)
+			}
+		}
+	})
 }
 
 func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		Parse("/foo/:bar/(.*)", nil)
 	}
 }
 
+func BenchmarkParseBulk(b *testing.B) {
+	paths := make([]string, 10000)
+	for i := range paths {
+		paths[i] = "/api/v" + strconv.Itoa(i%3) + "/resource" + strconv.Itoa(i) + "/:id(\\d+)/:action?"
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range paths {
+			Parse(path, nil)
+		}
+	}
+}
+
+func BenchmarkLexer(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lexer("/api/v2/organizations/:org/projects/:project(\\d+)/files/:path*.:ext(json|xml)", nil)
+	}
+}
+
 func BenchmarkCompile(b *testing.B) {
 	b.Run("simple", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -3311,6 +5837,86 @@ func BenchmarkMatch(b *testing.B) {
 	}
 }
 
+func BenchmarkCompileRepeat(b *testing.B) {
+	toPath := MustCompile("/files/:path+.:ext", nil)
+	segments := make([]interface{}, 50)
+	for i := range segments {
+		segments[i] = "segment" + strconv.Itoa(i)
+	}
+	data := map[string]interface{}{"path": segments, "ext": "json"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		toPath(data)
+	}
+}
+
+func BenchmarkMatchFunction(b *testing.B) {
+	b.Run("static", func(b *testing.B) {
+		match, _ := Match("/users", nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			match("/users")
+		}
+	})
+	b.Run("regexp", func(b *testing.B) {
+		match, _ := Match("/users", &Options{EndsWith: "?"})
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			match("/users")
+		}
+	})
+	b.Run("param", func(b *testing.B) {
+		match, _ := Match("/users/:id", nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			match("/users/123")
+		}
+	})
+	b.Run("repeat", func(b *testing.B) {
+		match, _ := Match("/files/:path+", nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			match("/files/readme")
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		match, _ := Match("/users/:id/posts/:post", &Options{Decode: decodeURIComponent})
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			match("/users/123/posts/hello%20world")
+		}
+	})
+}
+
+func BenchmarkEscapeString(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		escapeString("/api/v1.0/users/(id)/files")
+	}
+}
+
+func BenchmarkEncodeURI(b *testing.B) {
+	b.Run("ascii", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encodeURI("/users/123/posts?sort=desc&page=2")
+		}
+	})
+	b.Run("unicode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encodeURI("/ru/docs/JavaScript_шеллы/café au lait")
+		}
+	})
+}
+
 func exec(r *regexp2.Regexp, str string) []string {
 	m, _ := r.FindStringMatch(str)
 	if m == nil {
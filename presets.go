@@ -0,0 +1,64 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+// StrictOptions returns the options of exact API routes: case sensitive, up
+// to the end, and without an optional trailing delimiter.
+func StrictOptions() *Options {
+	end := true
+	return &Options{Sensitive: true, Strict: true, End: &end}
+}
+
+// PrefixOptions returns the options of mounted routes, matching the paths
+// the template prefixes up to a delimiter, e.g. `/api` matches `/api/users`
+// but not `/apis`, with an optional trailing delimiter.
+func PrefixOptions() *Options {
+	end := false
+	return &Options{Strict: false, End: &end}
+}
+
+// HostOptions returns the options of hostnames such as `:tenant.example.com`,
+// with `.` as the only delimiter and ignoring the case. Unlike NewHostMatcher,
+// the hosts aren't normalized before matching.
+func HostOptions() *Options {
+	return &Options{Delimiter: "."}
+}
+
+// Clone returns a copy of the options which doesn't share their pointer and
+// slice fields, so that it can be changed without changing the options. The
+// functions, the encoder, the decoder and the engine are shared.
+func (o *Options) Clone() *Options {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	for _, field := range []**bool{&c.End, &c.Start, &c.Validate} {
+		if *field != nil {
+			value := **field
+			*field = &value
+		}
+	}
+	if c.Prefixes != nil {
+		prefixes := *c.Prefixes
+		c.Prefixes = &prefixes
+	}
+	for _, field := range []*[]string{&c.SensitiveParams, &c.InsensitiveParams} {
+		if *field != nil {
+			*field = append([]string(nil), *field...)
+		}
+	}
+	if c.Limits != nil {
+		limits := *c.Limits
+		c.Limits = &limits
+	}
+	if c.Constraints != nil {
+		constraints := make(map[string]func(string) bool, len(c.Constraints))
+		for name, constraint := range c.Constraints {
+			constraints[name] = constraint
+		}
+		c.Constraints = constraints
+	}
+	return &c
+}
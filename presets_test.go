@@ -0,0 +1,83 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *Options
+		path     string
+		pathname string
+		expect   *MatchResult
+	}{
+		{"strict", StrictOptions(), "/users/:id", "/users/1", &MatchResult{Path: "/users/1", Params: m{"id": "1"},
+			Groups: []string{"/users/1", "1"}}},
+		{"strict", StrictOptions(), "/users/:id", "/users/1/", nil},
+		{"strict", StrictOptions(), "/users/:id", "/Users/1", nil},
+		{"strict", StrictOptions(), "/users/:id", "/users/1/posts", nil},
+		{"prefix", PrefixOptions(), "/api", "/api/users", &MatchResult{Path: "/api", Params: m{}, Groups: []string{"/api"}}},
+		{"prefix", PrefixOptions(), "/api", "/api/", &MatchResult{Path: "/api/", Params: m{}, Groups: []string{"/api/"}}},
+		{"prefix", PrefixOptions(), "/api", "/apis", nil},
+		{"prefix", PrefixOptions(), "/api/:version", "/API/v1/users", &MatchResult{Path: "/API/v1",
+			Params: m{"version": "v1"}, Groups: []string{"/API/v1", "v1"}}},
+		{"host", HostOptions(), ":tenant.example.com", "acme.example.com", &MatchResult{Path: "acme.example.com",
+			Params: m{"tenant": "acme"}, Groups: []string{"acme.example.com", "acme"}}},
+		{"host", HostOptions(), ":tenant.example.com", "Acme.Example.com", &MatchResult{Path: "Acme.Example.com",
+			Params: m{"tenant": "Acme"}, Groups: []string{"Acme.Example.com", "Acme"}}},
+		{"host", HostOptions(), ":tenant.example.com", "a.b.example.com", nil},
+	}
+	for _, test := range tests {
+		t.Run("should match "+test.pathname+" with the "+test.name+" options", func(t *testing.T) {
+			result, err := MustMatch(test.path, test.options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, test.expect) {
+				t.Errorf(testErrorFormat, result, test.expect)
+			}
+		})
+	}
+
+	t.Run("should return fresh options", func(t *testing.T) {
+		a, b := PrefixOptions(), PrefixOptions()
+		*a.End = true
+		if *b.End {
+			t.Errorf(testErrorFormat, *b.End, false)
+		}
+	})
+}
+
+func TestOptionsClone(t *testing.T) {
+	t.Run("should not share the pointer fields", func(t *testing.T) {
+		prefixes := "/"
+		o := &Options{End: &falseValue, Prefixes: &prefixes, Limits: &Limits{MaxTokens: 4}, Delimiter: "/",
+			SensitiveParams: []string{"id"}}
+		c := o.Clone()
+		if !reflect.DeepEqual(c, o) {
+			t.Errorf(testErrorFormat, c, o)
+		}
+
+		*c.End = true
+		*c.Prefixes = "."
+		c.Limits.MaxTokens = 8
+		c.Delimiter = "."
+		c.SensitiveParams[0] = "name"
+		if *o.End || *o.Prefixes != "/" || o.Limits.MaxTokens != 4 || o.Delimiter != "/" ||
+			o.SensitiveParams[0] != "id" {
+			t.Errorf(testErrorFormat, o, "unchanged options")
+		}
+	})
+
+	t.Run("should clone nil", func(t *testing.T) {
+		if c := (*Options)(nil).Clone(); c != nil {
+			t.Errorf(testErrorFormat, c, nil)
+		}
+	})
+}
@@ -0,0 +1,404 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryParam is a `key=value` pair of the query of a template parsed with
+// `Options.QueryParams`, the value being a literal or a single param.
+type queryParam struct {
+	key       string
+	literal   string
+	token     *Token
+	validator *validator
+}
+
+// Splits the template on its first `?` which isn't a modifier, i.e. which
+// doesn't follow a param, a pattern or a group, or which is followed by a
+// `key=` pair, e.g. in `/users/:id?tab=:tab`.
+func splitQuery(template string) (string, string, bool) {
+	afterParam := false
+	for i := 0; i < len(template); i++ {
+		switch c := template[i]; {
+		case c == '\\':
+			i++
+			afterParam = false
+		case c == '(':
+			for depth := 0; i < len(template); i++ {
+				if template[i] == '\\' {
+					i++
+				} else if template[i] == '(' {
+					depth++
+				} else if template[i] == ')' {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			afterParam = true
+		case c == ':':
+			for i+1 < len(template) && isNameByte(template[i+1]) {
+				i++
+			}
+			afterParam = true
+		case c == '}':
+			afterParam = true
+		case c == '?' && (!afterParam || startsWithKey(template[i+1:])):
+			return template[:i], template[i+1:], true
+		default:
+			afterParam = false
+		}
+	}
+	return template, "", false
+}
+
+// Reports whether the string starts with a query key followed by `=`.
+func startsWithKey(str string) bool {
+	i := strings.IndexByte(str, '=')
+	return i > 0 && !strings.ContainsAny(str[:i], "/?#&:(){}*+\\")
+}
+
+func isNameByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_'
+}
+
+// Parses the `key=value` pairs of the query of a template, the unnamed params
+// being numbered from `index`. A param without pattern matches any non empty
+// value, as the values may contain the delimiters.
+func parseQuery(query string, index int, options *Options) ([]queryParam, error) {
+	if query == "" {
+		return nil, nil
+	}
+	defaultPattern := paramPattern(options)
+
+	var params []queryParam
+	for _, pair := range strings.Split(query, "&") {
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("expected key=value in query, but got %q", pair)
+		}
+		key, err := url.QueryUnescape(pair[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid query key %q: %v", pair[:i], err)
+		}
+
+		tokens, err := Parse(pair[i+1:], options)
+		if err != nil {
+			return nil, fmt.Errorf("query param %q: %v", key, err)
+		}
+		param := queryParam{key: key}
+		switch {
+		case len(tokens) == 0:
+		case len(tokens) > 1:
+			return nil, fmt.Errorf("expected a literal or a single param for query key %q", key)
+		default:
+			if literal, ok := tokens[0].(string); ok {
+				param.literal = literal
+				break
+			}
+			token := tokens[0].(Token)
+			if token.Prefix != "" || token.Suffix != "" || token.Pattern == "" {
+				return nil, fmt.Errorf("expected a literal or a single param for query key %q", key)
+			}
+			if token.Pattern == defaultPattern {
+				token.Pattern = `[\s\S]+`
+			}
+			if _, ok := token.Index(); ok {
+				token.Name = index
+				index++
+			}
+			param.token = &token
+			param.validator = &validator{source: "^(?:" + token.Pattern + ")$", options: options}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// Wraps the match function of the matcher, when built from a string template
+// with `Options.QueryParams`, to match the query of the pathnames.
+func queryMatcher(m *Matcher, path interface{}, options *Options) (*Matcher, error) {
+	template, ok := path.(string)
+	if !ok || options == nil || !options.QueryParams {
+		return m, nil
+	}
+	_, query, _ := splitQuery(template)
+	unnamed := 0
+	for _, token := range m.tokens {
+		if _, ok := token.Index(); ok {
+			unnamed++
+		}
+	}
+	params, err := parseQuery(query, unnamed, options)
+	if err != nil {
+		return nil, err
+	}
+
+	equal := func(a, b string) bool { return a == b }
+	if ignoreCase(options) {
+		equal = equalFold
+	}
+	decode := queryDecoder(options).DecodeParam
+	match := m.match
+	m.match = func(pathname string) (*MatchResult, error) {
+		rawQuery := ""
+		if i := strings.IndexByte(pathname, '?'); i >= 0 {
+			pathname, rawQuery = pathname[:i], pathname[i+1:]
+		}
+		result, err := match(pathname)
+		if err != nil || result == nil || len(params) == 0 {
+			return result, err
+		}
+
+		values := rawQueryValues(rawQuery)
+		for _, param := range params {
+			found := values[param.key]
+			if len(found) == 0 {
+				if param.token != nil && (param.token.Modifier == "?" || param.token.Modifier == "*") {
+					continue
+				}
+				return nil, nil
+			}
+			if param.token == nil {
+				if literal, _ := url.QueryUnescape(found[0]); !equal(literal, param.literal) {
+					return nil, nil
+				}
+				continue
+			}
+
+			repeat := param.token.Modifier == "*" || param.token.Modifier == "+"
+			if !repeat {
+				found = found[:1]
+			}
+			for i, value := range found {
+				decoded, err := decode(value, *param.token, i)
+				if err != nil {
+					return nil, err
+				}
+				ok, err := param.validator.MatchString(decoded)
+				if err != nil {
+					return nil, validatorError(*param.token, err)
+				}
+				if !ok {
+					return nil, nil
+				}
+				found[i] = decoded
+			}
+			if repeat {
+				result.Params[param.token.Name] = found
+			} else {
+				result.Params[param.token.Name] = found[0]
+			}
+		}
+		return result, nil
+	}
+	return m, nil
+}
+
+// Returns the values of the raw query by key, the keys being unescaped and the
+// values kept for the decoder. The pairs failing to parse are skipped, like
+// `Request.URL.Query` does.
+func rawQueryValues(query string) map[string][]string {
+	values := make(map[string][]string)
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+		if _, err := url.QueryUnescape(value); err != nil {
+			continue
+		}
+		values[key] = append(values[key], value)
+	}
+	return values
+}
+
+// Returns the decoder of the query values, the decoder of the params when the
+// options set one, and query unescaping otherwise.
+func queryDecoder(options *Options) Decoder {
+	switch {
+	case options.Decoder != nil:
+		return options.Decoder
+	case options.Decode != nil:
+		return DecodeFunc(options.Decode)
+	case options.DecodeValues:
+		return DecodeFunc(decodeURIComponent)
+	}
+	return DecodeFunc(func(str string, token interface{}) (string, error) {
+		return url.QueryUnescape(str)
+	})
+}
+
+// Returns the path function of the template with `Options.QueryParams`, which
+// appends the query params to the path as a query string sorted by key. The
+// values are encoded by the encoder of the params when the options set one,
+// and query escaped otherwise.
+func queryFunction(str string, options *Options) (func(interface{}) (string, error), error) {
+	path, query, _ := splitQuery(str)
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return nil, err
+	}
+	toPath, err := tokensToFunction(tokens, options)
+	if err != nil {
+		return nil, err
+	}
+	unnamed := 0
+	for _, token := range tokens {
+		if token, ok := token.(Token); ok {
+			if _, ok := token.Index(); ok {
+				unnamed++
+			}
+		}
+	}
+	params, err := parseQuery(query, unnamed, options)
+	if err != nil {
+		return nil, err
+	}
+	validate := options.Validate == nil || *options.Validate
+	if validate {
+		for _, param := range params {
+			if err := param.validator.check(); err != nil {
+				return nil, validatorError(*param.token, err)
+			}
+		}
+	}
+	var encode func(value string, t Token, elem int) (string, error)
+	if options.Encoder != nil {
+		encode = options.Encoder.EncodeParam
+	} else if options.Encode != nil || options.Encoding != EncodingNone {
+		encode = EncodeFunc(encoder(options)).EncodeParam
+	}
+
+	return func(data interface{}) (string, error) {
+		str, err := toPath(data)
+		if err != nil || len(params) == 0 {
+			return str, err
+		}
+
+		values := make(map[string][]string)
+		for _, param := range params {
+			if param.token == nil {
+				values[param.key] = append(values[param.key], url.QueryEscape(param.literal))
+				continue
+			}
+
+			token := param.token
+			optional := token.Modifier == "?" || token.Modifier == "*"
+			repeat := token.Modifier == "*" || token.Modifier == "+"
+			found, err := queryValues(data, token.Name, repeat)
+			if err != nil {
+				return "", err
+			}
+			if len(found) == 0 {
+				if optional {
+					continue
+				}
+				return "", fmt.Errorf("expected query param \"%v\" to be set", token.Name)
+			}
+			if options.MaxRepeats > 0 && repeat && len(found) > options.MaxRepeats {
+				return "", &RepeatsError{Max: options.MaxRepeats, Token: token.Name, Len: len(found)}
+			}
+			for i, value := range found {
+				if encode != nil {
+					if value, err = encode(value, *token, i); err != nil {
+						return "", err
+					}
+				}
+				if validate {
+					ok, err := param.validator.MatchString(value)
+					if err != nil {
+						return "", validatorError(*token, err)
+					}
+					if !ok {
+						return "", fmt.Errorf("expected \"%v\" to match \"%v\", but got \"%v\"",
+							token.Name, token.Pattern, value)
+					}
+				}
+				if encode == nil {
+					value = url.QueryEscape(value)
+				}
+				values[param.key] = append(values[param.key], value)
+			}
+		}
+
+		if len(values) == 0 {
+			return str, nil
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, key := range keys {
+			for _, value := range values[key] {
+				if b.Len() > 0 {
+					b.WriteByte('&')
+				}
+				writeStrings(&b, url.QueryEscape(key), "=", value)
+			}
+		}
+		query := b.String()
+		if options.LowercasePath {
+			query = lowercasePath(query)
+		}
+		if options.ASCIIOnly {
+			query = asciiPath(query)
+		}
+		if str += "?" + query; options.MaxPathLen > 0 && len(str) > options.MaxPathLen {
+			return "", &PathLenError{Max: options.MaxPathLen, Len: len(str)}
+		}
+		return str, nil
+	}, nil
+}
+
+// Returns the values given for the query param in the data.
+func queryValues(data interface{}, name interface{}, repeat bool) ([]string, error) {
+	if data == nil || reflect.TypeOf(data).Kind() != reflect.Map {
+		return nil, nil
+	}
+	value := paramValue(toMap(data), name)
+	if value == nil {
+		return nil, nil
+	}
+
+	if k := reflect.TypeOf(value).Kind(); k == reflect.Slice || k == reflect.Array {
+		if !repeat {
+			return nil, fmt.Errorf("expected \"%v\" to not repeat, but got array", name)
+		}
+		var values []string
+		for _, v := range toSlice(value) {
+			values = append(values, fmt.Sprintf("%v", v))
+		}
+		return values, nil
+	}
+
+	switch value := value.(type) {
+	case string:
+		return []string{value}, nil
+	case int:
+		return []string{strconv.Itoa(value)}, nil
+	case int64:
+		return []string{strconv.FormatInt(value, 10)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQueryParams(t *testing.T) {
+	options := &Options{QueryParams: true}
+
+	t.Run("should match the query params", func(t *testing.T) {
+		tests := []struct {
+			template string
+			// pathname and params, nil params meaning no match
+			matches [][2]interface{}
+		}{
+			{"/search?type=:kind", [][2]interface{}{
+				{"/search?type=user", m{"kind": "user"}},
+				{"/search?q=go&type=user%2Fadmin", m{"kind": "user/admin"}},
+				{"/search/?type=a&type=b", m{"kind": "a"}},
+				{"/search", nil},
+				{"/search?kind=user", nil},
+				{"/search?type=", nil},
+			}},
+			{"/search?q=:q&page=:page(\\d+)?", [][2]interface{}{
+				{"/search?q=go", m{"q": "go"}},
+				{"/search?page=2&q=go", m{"q": "go", "page": "2"}},
+				{"/search?q=go&page=x", nil},
+				{"/search?page=2", nil},
+			}},
+			{"/users/:id?tab=posts&tag=:tags*&sort=(asc|desc)", [][2]interface{}{
+				{"/users/42?tab=posts&sort=asc", m{"id": "42", 0: "asc"}},
+				{"/users/42?sort=DESC&tab=Posts&tag=a&tag=b", m{"id": "42", "tags": []string{"a", "b"}, 0: "DESC"}},
+				{"/users/42?tab=comments&sort=asc", nil},
+				{"/users/42?tab=posts", nil},
+				{"/users?tab=posts&sort=asc", nil},
+			}},
+			{"/:foo?/bar?x=:x", [][2]interface{}{
+				{"/bar?x=1", m{"x": "1"}},
+				{"/a/bar?x=1", m{"foo": "a", "x": "1"}},
+			}},
+			{"/:id??x=:x", [][2]interface{}{
+				{"/?x=1", m{"x": "1"}},
+				{"/a?x=1", m{"id": "a", "x": "1"}},
+			}},
+			{"/files\\?name=:name", [][2]interface{}{
+				{"/files", nil},
+			}},
+			{"/items", [][2]interface{}{
+				{"/items?page=2", m{}},
+			}},
+		}
+
+		for _, test := range tests {
+			match, err := Match(test.template, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range test.matches {
+				result, err := match(v[0].(string))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var params interface{}
+				if result != nil {
+					params = m(result.Params)
+				}
+				if (params == nil) != (v[1] == nil) || (params != nil && !reflect.DeepEqual(params, v[1])) {
+					t.Errorf("%s %s: "+testErrorFormat, test.template, v[0], params, v[1])
+				}
+			}
+		}
+	})
+
+	t.Run("should compile the query string", func(t *testing.T) {
+		tests := []struct {
+			template string
+			data     interface{}
+			expect   string
+		}{
+			{"/search?type=:kind", m{"kind": "user/admin"}, "/search?type=user%2Fadmin"},
+			{"/search?q=:q&page=:page(\\d+)?", m{"q": "a b"}, "/search?q=a+b"},
+			{"/search?q=:q&page=:page(\\d+)?", m{"q": "go", "page": 2}, "/search?page=2&q=go"},
+			{"/users/:id?tab=posts&tag=:tags*&sort=(asc|desc)", m{"id": 1, "tags": []string{"b", "a"}, 0: "asc"},
+				"/users/1?sort=asc&tab=posts&tag=b&tag=a"},
+			{"/users/:id?tab=posts&tag=:tags*&sort=(asc|desc)", m{"id": 1, "0": "desc"},
+				"/users/1?sort=desc&tab=posts"},
+			{"/items?page=:page?", m{}, "/items"},
+		}
+		for _, test := range tests {
+			toPath, err := Compile(test.template, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, err := toPath(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf("%s: "+testErrorFormat, test.template, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should reject invalid query params values", func(t *testing.T) {
+		toPath := MustCompile("/search?q=:q&page=:page(\\d+)", options)
+		tests := []struct {
+			data    interface{}
+			message string
+		}{
+			{m{"q": "go"}, `expected query param "page" to be set`},
+			{m{"q": "go", "page": "x"}, `expected "page" to match "\d+", but got "x"`},
+			{m{"q": []string{"a"}, "page": 1}, `expected "q" to not repeat, but got array`},
+		}
+		for _, test := range tests {
+			_, err := toPath(test.data)
+			if err == nil || err.Error() != test.message {
+				t.Errorf(testErrorFormat, err, test.message)
+			}
+		}
+	})
+
+	t.Run("should decode the values like the path params", func(t *testing.T) {
+		tests := []struct {
+			options  *Options
+			pathname string
+			expect   m
+		}{
+			{options, "/search?q=a+b%2F", m{"q": "a b/"}},
+			{&Options{QueryParams: true, Decode: decodeURIComponent}, "/search?q=a+b%2F", m{"q": "a+b/"}},
+			{&Options{QueryParams: true, DecodeValues: true}, "/search?q=a+b%2F", m{"q": "a+b/"}},
+			{&Options{QueryParams: true, Decoder: indexCodec{}}, "/search?q=a&tag=b&tag=c",
+				m{"q": "a-0", "tags": []string{"b-0", "c-1"}}},
+		}
+		for _, test := range tests {
+			result, err := MustMatch("/search?q=:q&tag=:tags*", test.options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || !reflect.DeepEqual(m(result.Params), test.expect) {
+				t.Errorf(testErrorFormat, result, test.expect)
+			}
+		}
+
+		_, err := MustMatch("/search?q=:q", &Options{QueryParams: true, Decoder: indexCodec{}})("/search?q=bad")
+		if expect := `can't decode "bad" of "q"`; err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should encode the values like the path params", func(t *testing.T) {
+		identity := func(uri string, token interface{}) string { return uri }
+		tests := []struct {
+			options *Options
+			data    interface{}
+			expect  string
+		}{
+			{options, m{"q": "a b", "tags": []string{"é"}}, "/search?q=a+b&tag=%C3%A9"},
+			{&Options{QueryParams: true, Encode: encodeURIComponent}, m{"q": "a b"}, "/search?q=a%20b"},
+			{&Options{QueryParams: true, Encoding: EncodingURIComponent}, m{"q": "a/b"}, "/search?q=a%2Fb"},
+			{&Options{QueryParams: true, Encoder: indexCodec{}}, m{"q": "a", "tags": []string{"b", "c"}},
+				"/search?q=a-0&tag=b-0&tag=c-1"},
+			{&Options{QueryParams: true, Encode: identity}, m{"q": "café"}, "/search?q=café"},
+			{&Options{QueryParams: true, Encode: identity, ASCIIOnly: true}, m{"q": "café"}, "/search?q=caf%C3%A9"},
+		}
+		for _, test := range tests {
+			path, err := MustCompile("/search?q=:q&tag=:tags*", test.options)(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+
+		_, err := MustCompile("/search?q=:q", &Options{QueryParams: true, Encoder: indexCodec{}})(m{"q": "bad"})
+		if expect := `can't encode "bad" of "q"`; err == nil || err.Error() != expect {
+			t.Errorf(testErrorFormat, err, expect)
+		}
+	})
+
+	t.Run("should limit the repeated values", func(t *testing.T) {
+		toPath := MustCompile("/search?tag=:tags+", &Options{QueryParams: true, MaxRepeats: 2})
+		if path, err := toPath(m{"tags": []string{"a", "b"}}); err != nil || path != "/search?tag=a&tag=b" {
+			t.Errorf(testErrorFormat, path, "/search?tag=a&tag=b")
+		}
+		_, err := toPath(m{"tags": []string{"a", "b", "c"}})
+		var repeatsErr *RepeatsError
+		if !errors.As(err, &repeatsErr) || repeatsErr.Token != "tags" || repeatsErr.Len != 3 {
+			t.Errorf(testErrorFormat, err, "a *RepeatsError")
+		}
+	})
+
+	t.Run("should reject invalid query templates", func(t *testing.T) {
+		tests := [][2]string{
+			{"/search?type", `expected key=value in query, but got "type"`},
+			{"/search?=:q", `expected key=value in query, but got "=:q"`},
+			{"/search?type=a:b", `expected a literal or a single param for query key "type"`},
+			{"/search?type=:kind(", `query param "type": unbalanced pattern at 5`},
+		}
+		for _, test := range tests {
+			if _, err := NewMatcher(test[0], options); err == nil || err.Error() != test[1] {
+				t.Errorf(testErrorFormat, err, test[1])
+			}
+			if _, err := Compile(test[0], options); err == nil || err.Error() != test[1] {
+				t.Errorf(testErrorFormat, err, test[1])
+			}
+		}
+	})
+
+	t.Run("should keep the query out of the regexp", func(t *testing.T) {
+		var tokens []Token
+		re, err := PathToRegexp("/users/:id?tab=:tab", &tokens, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expect := mustMatcher("/users/:id", options).RouteString(); re.String() != expect {
+			t.Errorf(testErrorFormat, re.String(), expect)
+		}
+		if len(tokens) != 1 {
+			t.Errorf(testErrorFormat, tokens, "a single token")
+		}
+
+		if _, err := ExportRoutes([]*Matcher{mustMatcher("/users/:id?tab=:tab", options)}); err == nil {
+			t.Error("expect an error")
+		}
+
+		// The template is parsed as before without the option.
+		if _, err := Parse("/search?type=:kind", nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Rewrite returns a function translating the paths matching the src template
+// into paths of the dst template with the matched params, e.g. for redirects,
+// which reports false when the path doesn't match. The params of src missing
+// from dst are dropped, while a param of dst missing from src is an error.
+//
+// The matched params are decoded and encoded back with EncodeURIComponent,
+// unless the options have their own Decode or Encode, so that percent-encoded
+// values survive the rewrite.
+func Rewrite(src, dst string, options *Options) (func(string) (string, bool, error), error) {
+	return rewriteFunction(src, dst, options, false)
+}
+
+// RewriteQuery is like Rewrite but appends the params of src missing from dst
+// to the query string of the rewritten path, sorted by name.
+func RewriteQuery(src, dst string, options *Options) (func(string) (string, bool, error), error) {
+	return rewriteFunction(src, dst, options, true)
+}
+
+func rewriteFunction(src, dst string, options *Options, query bool) (func(string) (string, bool, error), error) {
+	var o Options
+	if options != nil {
+		o = *options
+	}
+	// The encoding applies to the values of dst, while the matcher would
+	// apply it to the literals of src.
+	matchOptions := o
+	matchOptions.Encode, matchOptions.Encoding = nil, EncodingNone
+	if matchOptions.Decode == nil {
+		matchOptions.DecodeValues = true
+	}
+	m, err := NewMatcher(src, &matchOptions)
+	if err != nil {
+		return nil, err
+	}
+	if o.Encode == nil && o.Encoding == EncodingNone {
+		o.Encoding = EncodingURIComponent
+	}
+	toPath, err := Compile(dst, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := Parse(dst, options)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[interface{}]bool)
+	for _, token := range m.tokens {
+		names[token.Name] = true
+	}
+	used := make(map[interface{}]bool)
+	for _, token := range tokens {
+		token, ok := token.(Token)
+		if !ok {
+			continue
+		}
+		if _, index := token.Index(); index || token.IsNamed() {
+			if !names[token.Name] {
+				return nil, fmt.Errorf("param \"%v\" of \"%v\" is missing from \"%v\"", token.Name, dst, src)
+			}
+			used[token.Name] = true
+		}
+	}
+
+	return func(pathname string) (string, bool, error) {
+		result, err := m.find(pathname)
+		if err != nil || result == nil {
+			return "", false, err
+		}
+		path, err := toPath(result.Params)
+		if err != nil {
+			return "", false, err
+		}
+		if query {
+			path += rewriteQuery(result.Params, used)
+		}
+		return path, true, nil
+	}, nil
+}
+
+// Returns the query string of the params which aren't used, with the leading
+// `?`, or an empty string.
+func rewriteQuery(params map[interface{}]interface{}, used map[interface{}]bool) string {
+	values := url.Values{}
+	for name, value := range params {
+		if used[name] || value == nil {
+			continue
+		}
+		key := Token{Name: name}.NameString()
+		if strs, ok := value.([]string); ok {
+			values[key] = append(values[key], strs...)
+		} else {
+			values.Add(key, fmt.Sprintf("%v", value))
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	// Encode sorts the values by key.
+	return "?" + values.Encode()
+}
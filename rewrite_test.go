@@ -0,0 +1,94 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	t.Run("should rewrite the matching paths", func(t *testing.T) {
+		tests := []struct {
+			src, dst string
+			options  *Options
+			pathname string
+			expect   string
+			ok       bool
+		}{
+			{"/old/:id/show", "/new/items/:id", nil, "/old/1/show", "/new/items/1", true},
+			{"/old/:id/show", "/new/items/:id", nil, "/OLD/1/show", "/new/items/1", true},
+			{"/old/:id/show", "/new/items/:id", nil, "/old/1", "", false},
+			{"/old/:id/show", "/new/items/:id", nil, "/old/a%20b%2Fc/show", "/new/items/a%20b%2Fc", true},
+			{"/old/:id/show", "/new/items/:id", nil, "/old/caf%C3%A9/show", "/new/items/caf%C3%A9", true},
+			{"/users/:user/posts/:post", "/posts/:post", nil, "/users/bob/posts/2", "/posts/2", true},
+			{"/:lang?/docs/:page", "/docs/:page/:lang?", nil, "/docs/intro", "/docs/intro", true},
+			{"/:lang?/docs/:page", "/docs/:page/:lang?", nil, "/en/docs/intro", "/docs/intro/en", true},
+			{"/files/:path*", "/static/:path*", nil, "/files/a/b%20c", "/static/a/b%20c", true},
+			{"/(\\d+)/:slug", "/posts/:slug/(\\d+)", nil, "/42/hello", "/posts/hello/42", true},
+			{"/old/:id", "/new/:id", &Options{Encoding: EncodingURI}, "/old/a%20b", "/new/a%20b", true},
+		}
+		for _, test := range tests {
+			rewrite, err := Rewrite(test.src, test.dst, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, ok, err := rewrite(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expect || ok != test.ok {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should append the dropped params to the query", func(t *testing.T) {
+		rewrite, err := RewriteQuery("/users/:user/posts/:post/:tags*", "/posts/:post", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			pathname string
+			expect   string
+		}{
+			{"/users/bob/posts/2", "/posts/2?user=bob"},
+			{"/users/a%20b/posts/2/x/y", "/posts/2?tags=x&tags=y&user=a+b"},
+		}
+		for _, test := range tests {
+			if path, ok, err := rewrite(test.pathname); err != nil || !ok || path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should return the errors", func(t *testing.T) {
+		tests := []struct {
+			src, dst string
+			expect   string
+		}{
+			{"/old/:id", "/new/:slug", `param "slug" of "/new/:slug" is missing from "/old/:id"`},
+			{"/old/:id", "/new/:id/(\\d+)", `param "0" of "/new/:id/(\d+)" is missing from "/old/:id"`},
+			{"/old/:id(", "/new/:id", "unbalanced pattern"},
+			{"/old/:id", "/new/:", "missing parameter name"},
+		}
+		for _, test := range tests {
+			_, err := Rewrite(test.src, test.dst, nil)
+			if err == nil || !strings.Contains(err.Error(), test.expect) {
+				t.Errorf(testErrorFormat, err, test.expect)
+			}
+		}
+	})
+
+	t.Run("should return the errors of the path function", func(t *testing.T) {
+		rewrite, err := Rewrite("/old/:id", "/new/:id(\\d+)", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := rewrite("/old/abc"); err == nil || ok {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// RoundTripError is returned by the path function of `Options.RoundTrip`
+// when the path it built doesn't match the template with the given params.
+type RoundTripError struct {
+	// The path built by the path function
+	Path string
+
+	// The name of the first diverging param, nil when the path doesn't match
+	Token interface{}
+
+	// The value given to the path function and the matched value, as strings
+	Expect, Actual interface{}
+}
+
+func (e *RoundTripError) Error() string {
+	if e.Token == nil {
+		return fmt.Sprintf("expected \"%v\" to match the template", e.Path)
+	}
+	return fmt.Sprintf("expected \"%v\" of \"%v\" to match %q, but got %q", e.Token, e.Path, e.Expect, e.Actual)
+}
+
+// SelfCheckError is returned by the path function of `Options.SelfCheck`
+// when the path it built doesn't match the template.
+type SelfCheckError struct {
+	Template string
+	Path     string
+}
+
+func (e *SelfCheckError) Error() string {
+	return fmt.Sprintf("expected \"%v\" to match \"%v\"", e.Path, e.Template)
+}
+
+// Returns the matcher of the template checking the paths built by the path
+// function. The encoding of the path function applies to the values, while
+// the matcher would apply it to the literals of the template.
+func checkMatcher(str string, options *Options) (*Matcher, error) {
+	o := *options
+	o.RoundTrip, o.SelfCheck, o.Encode, o.Encoding, o.Hooks = false, false, nil, EncodingNone, nil
+	return NewMatcher(str, &o)
+}
+
+// Returns the path function matching each path built by `toPath` against the
+// template, the matcher being compiled on first use.
+func selfCheckFunction(str string, toPath func(interface{}) (string, error), options *Options) func(
+	interface{}) (string, error) {
+	var once sync.Once
+	var m *Matcher
+	var matcherErr error
+
+	return func(data interface{}) (string, error) {
+		path, err := toPath(data)
+		if err != nil {
+			return "", err
+		}
+		once.Do(func() {
+			m, matcherErr = checkMatcher(str, options)
+		})
+		if matcherErr != nil {
+			return "", matcherErr
+		}
+		result, err := m.find(path)
+		if err != nil {
+			return "", err
+		}
+		if result == nil {
+			return "", &SelfCheckError{Template: str, Path: path}
+		}
+		return path, nil
+	}
+}
+
+// Returns the path function matching each path built by `toPath` against the
+// template, and failing when the matched params differ from the given ones.
+func roundTripFunction(str string, toPath func(interface{}) (string, error), options *Options) (
+	func(interface{}) (string, error), error) {
+	m, err := checkMatcher(str, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(data interface{}) (string, error) {
+		path, err := toPath(data)
+		if err != nil {
+			return "", err
+		}
+		result, err := m.find(path)
+		if err != nil {
+			return "", err
+		}
+		if result == nil {
+			return "", &RoundTripError{Path: path}
+		}
+
+		var given map[interface{}]interface{}
+		if data != nil && reflect.TypeOf(data).Kind() == reflect.Map {
+			given = toMap(data)
+		}
+		for _, name := range roundTripNames(m.tokens, result) {
+			actual := roundTripValue(result.Params[name], false)
+			_, repeat := actual.([]string)
+			expect := roundTripValue(paramValue(given, name), repeat)
+			if !reflect.DeepEqual(expect, actual) {
+				return "", &RoundTripError{Path: path, Token: name, Expect: expect, Actual: actual}
+			}
+		}
+		return path, nil
+	}, nil
+}
+
+// Returns the names of the tokens and of the matched params, in the order of
+// the tokens and then sorted.
+func roundTripNames(tokens []Token, result *MatchResult) []interface{} {
+	var names []interface{}
+	seen := make(map[interface{}]bool)
+	for _, token := range tokens {
+		if token.Matrix == nil {
+			names = append(names, token.Name)
+			seen[token.Name] = true
+		}
+	}
+	var extra []string
+	for name := range result.Params {
+		if name, ok := name.(string); ok && !seen[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Returns the value as compared by the round trip, nil, a string or the
+// strings of a repeated value. An empty array is nil, as an optional param
+// is dropped.
+func roundTripValue(value interface{}, repeat bool) interface{} {
+	if value == nil {
+		return nil
+	}
+	if k := reflect.TypeOf(value).Kind(); k == reflect.Slice || k == reflect.Array {
+		values := toSlice(value)
+		if len(values) == 0 {
+			return nil
+		}
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = roundTripString(v)
+		}
+		return strs
+	}
+	if repeat {
+		return []string{roundTripString(value)}
+	}
+	return roundTripString(value)
+}
+
+// Returns the value as formatted by the path function.
+func roundTripString(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	symmetric := &Options{RoundTrip: true, Encoding: EncodingURIComponent, DecodeValues: true}
+
+	t.Run("should pass for symmetric options", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			data    interface{}
+			expect  string
+		}{
+			{"/users/:id", &Options{RoundTrip: true}, m{"id": "1"}, "/users/1"},
+			{"/users/:id", &Options{RoundTrip: true}, map[string]int{"id": 1}, "/users/1"},
+			{"/users/:id", symmetric, m{"id": "café au lait"}, "/users/caf%C3%A9%20au%20lait"},
+			{"/:lang?/posts", symmetric, m{}, "/posts"},
+			{"/:lang?/posts", symmetric, m{"lang": "en"}, "/en/posts"},
+			{"/files/:path*", symmetric, m{"path": []string{}}, "/files"},
+			{"/files/:path*", symmetric, m{"path": []string{"a b", "c"}}, "/files/a%20b/c"},
+			{"/files/:path+", symmetric, m{"path": "a"}, "/files/a"},
+			{"/(\\d+)/:n(\\d+(?:\\.\\d+)?)", &Options{RoundTrip: true}, m{"0": 1, "n": 2.5}, "/1/2.5"},
+			{"/search?q=:q", &Options{RoundTrip: true, QueryParams: true}, m{"q": "a b"}, "/search?q=a+b"},
+		}
+		for _, test := range tests {
+			path, err := MustCompile(test.path, test.options)(test.data)
+			if err != nil {
+				t.Fatalf("%v: %v", test.path, err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should catch an asymmetric encode", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			data    interface{}
+			expect  *RoundTripError
+		}{
+			{"/users/:id", &Options{RoundTrip: true, Encoding: EncodingURIComponent}, m{"id": "a b"},
+				&RoundTripError{Path: "/users/a%20b", Token: "id", Expect: "a b", Actual: "a%20b"}},
+			{"/files/:path*", &Options{RoundTrip: true, Encoding: EncodingURIComponent},
+				m{"path": []string{"a", "b c"}}, &RoundTripError{Path: "/files/a/b%20c", Token: "path",
+					Expect: []string{"a", "b c"}, Actual: []string{"a", "b%20c"}}},
+			{"/users/:id", &Options{RoundTrip: true, Validate: &falseValue}, m{"id": "a/b"},
+				&RoundTripError{Path: "/users/a/b"}},
+			{"/users/:id", &Options{RoundTrip: true, LowercasePath: true}, m{"id": "Bob"},
+				&RoundTripError{Path: "/users/bob", Token: "id", Expect: "Bob", Actual: "bob"}},
+		}
+		for _, test := range tests {
+			_, err := MustCompile(test.path, test.options)(test.data)
+			if !reflect.DeepEqual(err, test.expect) {
+				t.Errorf(testErrorFormat, err, test.expect)
+			}
+		}
+	})
+
+	t.Run("should describe the divergence", func(t *testing.T) {
+		err := &RoundTripError{Path: "/users/a%20b", Token: "id", Expect: "a b", Actual: "a%20b"}
+		expect := `expected "id" of "/users/a%20b" to match "a b", but got "a%20b"`
+		if err.Error() != expect {
+			t.Errorf(testErrorFormat, err.Error(), expect)
+		}
+		err = &RoundTripError{Path: "/users/a/b"}
+		expect = `expected "/users/a/b" to match the template`
+		if err.Error() != expect {
+			t.Errorf(testErrorFormat, err.Error(), expect)
+		}
+	})
+
+	t.Run("should return the errors of the path function", func(t *testing.T) {
+		if _, err := MustCompile("/users/:id", &Options{RoundTrip: true})(m{}); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
+
+func TestSelfCheck(t *testing.T) {
+	slashes := func(uri string, token interface{}) string {
+		return strings.Replace(uri, "-", "/", -1)
+	}
+
+	t.Run("should pass the matching paths", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			data    interface{}
+			expect  string
+		}{
+			{"/users/:id", &Options{SelfCheck: true}, m{"id": "1"}, "/users/1"},
+			{"/users/:id", &Options{SelfCheck: true, Encoding: EncodingURIComponent}, m{"id": "a b"}, "/users/a%20b"},
+			{"/:a/:b", &Options{SelfCheck: true, Validate: &falseValue, Encode: slashes}, m{"a": "x", "b": "y"}, "/x/y"},
+			{"/files/:path*", &Options{SelfCheck: true, Validate: &falseValue, Encode: slashes},
+				m{"path": "a-b"}, "/files/a/b"},
+		}
+		for _, test := range tests {
+			path, err := MustCompile(test.path, test.options)(test.data)
+			if err != nil {
+				t.Fatalf("%v: %v", test.path, err)
+			}
+			if path != test.expect {
+				t.Errorf(testErrorFormat, path, test.expect)
+			}
+		}
+	})
+
+	t.Run("should reject the paths the template doesn't match", func(t *testing.T) {
+		tests := []struct {
+			path    string
+			options *Options
+			data    interface{}
+			expect  *SelfCheckError
+		}{
+			{"/:a/:b", &Options{SelfCheck: true, Validate: &falseValue, Encode: slashes}, m{"a": "x-z", "b": "y"},
+				&SelfCheckError{Template: "/:a/:b", Path: "/x/z/y"}},
+			{"/users/:id/posts", &Options{SelfCheck: true, Validate: &falseValue, Encode: slashes}, m{"id": "1-2"},
+				&SelfCheckError{Template: "/users/:id/posts", Path: "/users/1/2/posts"}},
+			{"/Users/:id", &Options{SelfCheck: true, Sensitive: true, LowercasePath: true}, m{"id": "1"},
+				&SelfCheckError{Template: "/Users/:id", Path: "/users/1"}},
+		}
+		for _, test := range tests {
+			// The values pass without the self check.
+			o := *test.options
+			o.SelfCheck = false
+			if _, err := MustCompile(test.path, &o)(test.data); err != nil {
+				t.Fatal(err)
+			}
+			_, err := MustCompile(test.path, test.options)(test.data)
+			if !reflect.DeepEqual(err, test.expect) {
+				t.Errorf(testErrorFormat, err, test.expect)
+			}
+		}
+		err := &SelfCheckError{Template: "/:a/:b", Path: "/x/z/y"}
+		if expect := `expected "/x/z/y" to match "/:a/:b"`; err.Error() != expect {
+			t.Errorf(testErrorFormat, err.Error(), expect)
+		}
+	})
+
+	t.Run("should return the errors of the path function", func(t *testing.T) {
+		if _, err := MustCompile("/users/:id", &Options{SelfCheck: true})(m{}); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+	})
+}
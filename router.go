@@ -0,0 +1,130 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Router is an http.Handler dispatching requests to the handler of the first
+// route matching their method and escaped path, the routes being tried from
+// the most specific one, see Score. The match result is put in the context of
+// the request, see ParamsFromContext.
+//
+// A request whose path only matches routes of other methods gets a 405 with
+// the `Allow` header, and a HEAD request is handled by a GET route when no
+// HEAD route matches.
+type Router struct {
+	// NotFound handles the requests matching no route, http.NotFound when nil.
+	NotFound http.Handler
+
+	mu      sync.RWMutex
+	options *Options
+	routes  []*route
+}
+
+type route struct {
+	method  string
+	matcher *Matcher
+	handler http.Handler
+	score   int
+}
+
+// NewRouter creates a Router whose routes use the options unless they're
+// registered with their own.
+func NewRouter(options *Options) *Router {
+	return &Router{options: options}
+}
+
+// Handle registers the handler for the method and the template, an empty
+// method matching any method. It panics if the template is invalid.
+func (r *Router) Handle(method, template string, h http.Handler) {
+	r.HandleOptions(method, template, r.options, h)
+}
+
+// HandleFunc registers the handler function for the method and the template.
+func (r *Router) HandleFunc(method, template string, f func(http.ResponseWriter, *http.Request)) {
+	r.Handle(method, template, http.HandlerFunc(f))
+}
+
+// HandleOptions is like Handle but the template is matched with the options
+// instead of the options of the router.
+func (r *Router) HandleOptions(method, template string, options *Options, h http.Handler) {
+	m, err := NewMatcher(template, options)
+	if err != nil {
+		panic(err)
+	}
+	score, err := Score(template, options)
+	if err != nil {
+		panic(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, &route{method: method, matcher: m, handler: h, score: score})
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return r.routes[i].score > r.routes[j].score
+	})
+}
+
+// ServeHTTP dispatches the request to the handler of the matched route.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h, result, allowed := r.lookup(req.Method, req.URL.EscapedPath())
+	if h == nil && req.Method == http.MethodHead {
+		h, result, _ = r.lookup(http.MethodGet, req.URL.EscapedPath())
+	}
+
+	switch {
+	case h != nil:
+		h.ServeHTTP(w, req.WithContext(ContextWithParams(req.Context(), result)))
+	case len(allowed) > 0:
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	case r.NotFound != nil:
+		r.NotFound.ServeHTTP(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Returns the handler of the first route matching the method and the path,
+// or the sorted methods of the routes matching the path otherwise.
+func (r *Router) lookup(method, path string) (http.Handler, *MatchResult, []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var allowed []string
+	for _, route := range r.routes {
+		if route.method != "" && route.method != method && containsString(allowed, route.method) {
+			continue
+		}
+		result, err := route.matcher.find(path)
+		if err != nil || result == nil {
+			continue
+		}
+		if route.method == "" || route.method == method {
+			return route.handler, result, nil
+		}
+		allowed = append(allowed, route.method)
+	}
+
+	if containsString(allowed, http.MethodGet) && !containsString(allowed, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	sort.Strings(allowed)
+	return nil, nil, allowed
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
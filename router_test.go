@@ -0,0 +1,139 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "%s %v", name, Params(req).Params)
+		}
+	}
+	serve := func(h http.Handler, method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	router := NewRouter(nil)
+	router.Handle(http.MethodGet, "/:all*", handler("all"))
+	router.Handle(http.MethodGet, "/users/:id", handler("user"))
+	router.HandleFunc(http.MethodGet, "/users/new", handler("new"))
+	router.Handle(http.MethodPost, "/users", handler("create"))
+	router.Handle(http.MethodGet, "/users", handler("list"))
+	router.Handle("", "/any/:x", handler("any"))
+	router.HandleOptions(http.MethodGet, "/files/:name", &Options{Decode: decodeURIComponent, Strict: true},
+		handler("file"))
+
+	t.Run("should dispatch to the most specific route", func(t *testing.T) {
+		tests := []struct {
+			method, target, body string
+		}{
+			{http.MethodGet, "/users/new", "new map[]"},
+			{http.MethodGet, "/users/42", "user map[id:42]"},
+			{http.MethodGet, "/users", "list map[]"},
+			{http.MethodPost, "/users", "create map[]"},
+			{http.MethodGet, "/a/b", "all map[all:[a b]]"},
+			{http.MethodDelete, "/any/1", "any map[x:1]"},
+			{http.MethodHead, "/users/42", "user map[id:42]"},
+		}
+		for _, test := range tests {
+			w := serve(router, test.method, test.target)
+			if w.Code != http.StatusOK || w.Body.String() != test.body {
+				t.Errorf("%s %s: "+testErrorFormat, test.method, test.target,
+					[]interface{}{w.Code, w.Body.String()}, []interface{}{http.StatusOK, test.body})
+			}
+		}
+	})
+
+	t.Run("should put the match result in the context", func(t *testing.T) {
+		var result *MatchResult
+		r := NewRouter(nil)
+		r.HandleFunc(http.MethodGet, "/users/:id", func(w http.ResponseWriter, req *http.Request) {
+			result = Params(req)
+		})
+		serve(r, http.MethodGet, "/users/a%20b")
+		expect := &MatchResult{Path: "/users/a%20b", Params: m{"id": "a%20b"}, Groups: []string{"/users/a%20b", "a%20b"}}
+		if !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+		if result := Params(httptest.NewRequest(http.MethodGet, "/", nil)); result != nil {
+			t.Errorf(testErrorFormat, result, nil)
+		}
+	})
+
+	t.Run("should use the options of the route", func(t *testing.T) {
+		w := serve(router, http.MethodGet, "/files/a%20b")
+		if body := "file map[name:a b]"; w.Body.String() != body {
+			t.Errorf(testErrorFormat, w.Body.String(), body)
+		}
+	})
+
+	t.Run("should reply 405 when only other methods match", func(t *testing.T) {
+		w := serve(router, http.MethodPut, "/users")
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf(testErrorFormat, w.Code, http.StatusMethodNotAllowed)
+		}
+		if allow := "GET, HEAD, POST"; w.Header().Get("Allow") != allow {
+			t.Errorf(testErrorFormat, w.Header().Get("Allow"), allow)
+		}
+
+		w = serve(router, http.MethodPut, "/users/42")
+		if allow := "GET, HEAD"; w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != allow {
+			t.Errorf(testErrorFormat, []interface{}{w.Code, w.Header().Get("Allow")},
+				[]interface{}{http.StatusMethodNotAllowed, allow})
+		}
+	})
+
+	t.Run("should follow the trailing slash options", func(t *testing.T) {
+		w := serve(router, http.MethodGet, "/users/new/")
+		if body := "new map[]"; w.Body.String() != body {
+			t.Errorf(testErrorFormat, w.Body.String(), body)
+		}
+
+		// The strict route doesn't match, the catch-all does.
+		w = serve(router, http.MethodGet, "/files/a/")
+		if body := "all map[all:[files a]]"; w.Body.String() != body {
+			t.Errorf(testErrorFormat, w.Body.String(), body)
+		}
+
+		strict := NewRouter(&Options{Strict: true})
+		strict.HandleFunc(http.MethodGet, "/users", handler("list"))
+		if w := serve(strict, http.MethodGet, "/users/"); w.Code != http.StatusNotFound {
+			t.Errorf(testErrorFormat, w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("should handle requests matching no route", func(t *testing.T) {
+		r := NewRouter(nil)
+		r.HandleFunc(http.MethodGet, "/users", handler("list"))
+		if w := serve(r, http.MethodGet, "/posts"); w.Code != http.StatusNotFound {
+			t.Errorf(testErrorFormat, w.Code, http.StatusNotFound)
+		}
+
+		r.NotFound = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		if w := serve(r, http.MethodGet, "/posts"); w.Code != http.StatusTeapot {
+			t.Errorf(testErrorFormat, w.Code, http.StatusTeapot)
+		}
+	})
+
+	t.Run("should panic on an invalid template", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expect a panic")
+			}
+		}()
+		NewRouter(nil).Handle(http.MethodGet, "/:foo(", handler("foo"))
+	})
+}
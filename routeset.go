@@ -0,0 +1,147 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// RouteOrder is the order in which a RouteSet tries its routes.
+type RouteOrder int
+
+const (
+	// InsertionOrder tries the routes in the order they were added, a
+	// replaced route keeping its position.
+	InsertionOrder RouteOrder = iota
+
+	// SpecificityOrder tries the routes from the most specific one, see
+	// Score, the routes of a same score in the order they were added.
+	SpecificityOrder
+
+	// LongestMatchOrder tries all the routes and picks the one matching the
+	// longest path, the first added one on a tie, see LongestMatch. It's
+	// meant for prefix routes, whose End option is false.
+	LongestMatchOrder
+)
+
+// RouteSet is a set of routes identified by id, which can be added and
+// removed while it's matching. It is safe for concurrent use: a change
+// copies the routes and swaps them atomically, so matching never waits for
+// the changes, which are serialized.
+type RouteSet struct {
+	order  RouteOrder
+	mu     sync.Mutex
+	seq    int
+	routes atomic.Value // []*setRoute
+}
+
+type setRoute struct {
+	id      string
+	matcher *Matcher
+	score   int
+	seq     int
+}
+
+// NewRouteSet creates an empty RouteSet trying its routes in the order.
+func NewRouteSet(order RouteOrder) *RouteSet {
+	s := &RouteSet{order: order}
+	s.routes.Store([]*setRoute(nil))
+	return s
+}
+
+// Add adds the route of the template with the id, replacing the route with
+// the same id if any. The set is left unchanged if the template is invalid.
+func (s *RouteSet) Add(id string, template string, options *Options) error {
+	m, err := NewMatcher(template, options)
+	if err != nil {
+		return err
+	}
+	score := 0
+	if s.order == SpecificityOrder {
+		if score, err = Score(template, options); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.routes.Load().([]*setRoute)
+	routes := make([]*setRoute, 0, len(old)+1)
+	r := &setRoute{id: id, matcher: m, score: score, seq: s.seq}
+	s.seq++
+	replaced := false
+	for _, route := range old {
+		if route.id == id {
+			r.seq, replaced = route.seq, true
+			route = r
+		}
+		routes = append(routes, route)
+	}
+	if !replaced {
+		routes = append(routes, r)
+	}
+	if s.order == SpecificityOrder {
+		sort.SliceStable(routes, func(i, j int) bool {
+			if routes[i].score != routes[j].score {
+				return routes[i].score > routes[j].score
+			}
+			return routes[i].seq < routes[j].seq
+		})
+	}
+	s.routes.Store(routes)
+	return nil
+}
+
+// Remove removes the route with the id, if any.
+func (s *RouteSet) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.routes.Load().([]*setRoute)
+	routes := make([]*setRoute, 0, len(old))
+	for _, route := range old {
+		if route.id != id {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) < len(old) {
+		s.routes.Store(routes)
+	}
+}
+
+// Len returns the number of routes.
+func (s *RouteSet) Len() int {
+	return len(s.routes.Load().([]*setRoute))
+}
+
+// Match returns the id and the result of the first route matching the
+// pathname, or false if none matches. A route failing to match, such as on a
+// match timeout, is skipped.
+func (s *RouteSet) Match(pathname string) (string, *MatchResult, bool) {
+	routes := s.routes.Load().([]*setRoute)
+	if s.order == LongestMatchOrder {
+		var id string
+		var best *MatchResult
+		for _, route := range routes {
+			result, err := route.matcher.find(pathname)
+			if err == nil && result != nil && (best == nil || len(result.Path) > len(best.Path)) {
+				id, best = route.id, result
+				if len(best.Path) == len(pathname) {
+					break
+				}
+			}
+		}
+		return id, best, best != nil
+	}
+
+	for _, route := range routes {
+		result, err := route.matcher.find(pathname)
+		if err == nil && result != nil {
+			return route.id, result, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRouteSet(t *testing.T) {
+	t.Run("should try the routes in the order", func(t *testing.T) {
+		tests := []struct {
+			order  RouteOrder
+			expect string
+		}{
+			{InsertionOrder, "user"},
+			{SpecificityOrder, "me"},
+			{LongestMatchOrder, "user"},
+		}
+		for _, test := range tests {
+			s := NewRouteSet(test.order)
+			if err := s.Add("user", "/users/:id", nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Add("me", "/users/me", nil); err != nil {
+				t.Fatal(err)
+			}
+			id, _, ok := s.Match("/users/me")
+			if !ok || id != test.expect {
+				t.Errorf(testErrorFormat, id, test.expect)
+			}
+		}
+	})
+
+	t.Run("should replace and remove routes", func(t *testing.T) {
+		s := NewRouteSet(InsertionOrder)
+		for _, route := range [][2]string{{"a", "/a/:id"}, {"b", "/:any/:id"}, {"a", "/b/:id"}} {
+			if err := s.Add(route[0], route[1], nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s.Len() != 2 {
+			t.Errorf(testErrorFormat, s.Len(), 2)
+		}
+
+		// The replaced route keeps its position.
+		id, result, ok := s.Match("/b/1")
+		expect := &MatchResult{Path: "/b/1", Params: m{"id": "1"}, Groups: []string{"/b/1", "1"}}
+		if !ok || id != "a" || !reflect.DeepEqual(result, expect) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+		if id, _, _ := s.Match("/a/1"); id != "b" {
+			t.Errorf(testErrorFormat, id, "b")
+		}
+
+		s.Remove("b")
+		s.Remove("missing")
+		if _, _, ok := s.Match("/a/1"); ok {
+			t.Errorf(testErrorFormat, ok, false)
+		}
+		if s.Len() != 1 {
+			t.Errorf(testErrorFormat, s.Len(), 1)
+		}
+	})
+
+	t.Run("should pick the longest match", func(t *testing.T) {
+		s := NewRouteSet(LongestMatchOrder)
+		for _, route := range [][2]string{{"api", "/api"}, {"users", "/api/v2/users"}, {"v2", "/api/v2"}} {
+			if err := s.Add(route[0], route[1], PrefixOptions()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for pathname, expect := range map[string]string{"/api/v2/users/1": "users", "/api/v2/posts": "v2",
+			"/api/v1": "api"} {
+			if id, _, ok := s.Match(pathname); !ok || id != expect {
+				t.Errorf(testErrorFormat, id, expect)
+			}
+		}
+		if id, _, ok := s.Match("/apis"); ok {
+			t.Errorf(testErrorFormat, id, "")
+		}
+	})
+
+	t.Run("should keep the set on an invalid template", func(t *testing.T) {
+		s := NewRouteSet(SpecificityOrder)
+		if err := s.Add("a", "/a", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add("a", "/:foo(", nil); err == nil {
+			t.Errorf(testErrorFormat, err, "an error")
+		}
+		if id, _, ok := s.Match("/a"); !ok || id != "a" {
+			t.Errorf(testErrorFormat, id, "a")
+		}
+	})
+
+	t.Run("should match while routes change", func(t *testing.T) {
+		s := NewRouteSet(SpecificityOrder)
+		if err := s.Add("static", "/static/:path*", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					id := "tenant" + strconv.Itoa(i)
+					if err := s.Add(id, "/"+id+"/:id", nil); err != nil {
+						t.Error(err)
+						return
+					}
+					s.Remove(id)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if id, _, ok := s.Match("/static/app.js"); !ok || id != "static" {
+						t.Errorf(testErrorFormat, id, "static")
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if s.Len() != 1 {
+			t.Errorf(testErrorFormat, s.Len(), 1)
+		}
+	})
+}
+
+func BenchmarkRouteSet(b *testing.B) {
+	s := NewRouteSet(SpecificityOrder)
+	for i := 0; i < 20; i++ {
+		id := strconv.Itoa(i)
+		if err := s.Add(id, "/tenant"+id+"/users/:id", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Match("/tenant19/users/42")
+		}
+	})
+}
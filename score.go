@@ -0,0 +1,111 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"sort"
+	"strings"
+)
+
+// The weights of the segments, from the least to the most specific.
+const (
+	scoreCatchAll = iota
+	scoreOptional
+	scoreEnd
+	scoreParam
+	scorePattern
+	scoreStatic
+	scoreBase
+)
+
+// The number of segments counted by Score.
+const scoreSegments = 10
+
+// Score returns the specificity of the path, a higher score means a more
+// specific path which should be tried first.
+//
+// The path is split in segments at the delimiters, a segment starting at each
+// delimiter of the static text and at each param prefixed by a delimiter. Each
+// segment weighs as its least specific part:
+//
+//   - 5: static text
+//   - 4: a param with a custom pattern
+//   - 3: a param with the default pattern
+//   - 2: no segment, the path has ended
+//   - 1: an optional or a repeated param, with the `?` or `+` modifier
+//   - 0: a catch-all, a param with the `*` modifier or the `.*` pattern
+//
+// The score is the number made of the weights of the first 10 segments in
+// base 6, so paths are compared segment by segment from the left, e.g.
+// `/users/new` scores higher than `/users/:id`, which scores higher than
+// `/:all*`. Segments after the 10th don't count.
+func Score(path string, options *Options) (int, error) {
+	tokens, err := Parse(path, options)
+	if err != nil {
+		return 0, err
+	}
+	if options == nil {
+		options = &Options{}
+	}
+	delimiter := anyString(options.Delimiter, defaultDelimiter)
+	defaultPattern := paramPattern(options)
+
+	var segments []int
+	add := func(weight int, start bool) {
+		if start || len(segments) == 0 {
+			segments = append(segments, weight)
+		} else if last := len(segments) - 1; weight < segments[last] {
+			segments[last] = weight
+		}
+	}
+	for _, token := range tokens {
+		if str, ok := token.(string); ok {
+			for _, r := range str {
+				add(scoreStatic, strings.ContainsRune(delimiter, r))
+			}
+			continue
+		}
+
+		token := token.(Token)
+		weight := scoreParam
+		switch {
+		case token.Modifier == "*" || token.Pattern == ".*":
+			weight = scoreCatchAll
+		case token.Modifier == "?" || token.Modifier == "+":
+			weight = scoreOptional
+		case token.Pattern != defaultPattern:
+			weight = scorePattern
+		}
+		start := token.Prefix != "" && strings.ContainsRune(delimiter, []rune(token.Prefix)[0])
+		add(weight, start)
+	}
+
+	score := 0
+	for i := 0; i < scoreSegments; i++ {
+		weight := scoreEnd
+		if i < len(segments) {
+			weight = segments[i]
+		}
+		score = score*scoreBase + weight
+	}
+	return score, nil
+}
+
+// SortBySpecificity sorts the paths by descending Score, the paths with the
+// same score keep their order.
+func SortBySpecificity(paths []string, options *Options) error {
+	scores := make(map[string]int, len(paths))
+	for _, path := range paths {
+		score, err := Score(path, options)
+		if err != nil {
+			return err
+		}
+		scores[path] = score
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return scores[paths[i]] > scores[paths[j]]
+	})
+	return nil
+}
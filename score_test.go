@@ -0,0 +1,103 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	t.Run("should weigh the segments", func(t *testing.T) {
+		tests := []struct {
+			path  string
+			score int
+		}{
+			{"", 24186470},
+			{"/", 54419558},
+			{"/users/new", 59458406},
+			{"/users/:id(\\d+)", 57778790},
+			{"/users/:id", 56099174},
+			{"/users", 54419558},
+			{"/users/:id?", 52739942},
+			{"/users/:path*", 51060326},
+			{"/:all*", 4031078},
+			{"/(.*)", 4031078},
+		}
+		for _, test := range tests {
+			score, err := Score(test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if score != test.score {
+				t.Errorf("%s: "+testErrorFormat, test.path, score, test.score)
+			}
+		}
+	})
+
+	t.Run("should weigh a segment as its least specific part", func(t *testing.T) {
+		a, _ := Score("/icon-:foo(\\d+).png", nil)
+		b, _ := Score("/:foo(\\d+)", nil)
+		if a != b {
+			t.Errorf(testErrorFormat, a, b)
+		}
+
+		a, _ = Score("/:attr1?{-:attr2}?{-:attr3}?", nil)
+		b, _ = Score("/:attr1?", nil)
+		if a != b {
+			t.Errorf(testErrorFormat, a, b)
+		}
+
+		a, _ = Score("/foo{/:bar}?", nil)
+		b, _ = Score("/foo/:bar?", nil)
+		if a != b {
+			t.Errorf(testErrorFormat, a, b)
+		}
+	})
+
+	t.Run("should use the delimiter", func(t *testing.T) {
+		a, _ := Score("a.b", &Options{Delimiter: "."})
+		b, _ := Score("a.:b", &Options{Delimiter: "."})
+		if a <= b {
+			t.Errorf(testErrorFormat, a, b)
+		}
+	})
+
+	t.Run("should return the parse error", func(t *testing.T) {
+		if _, err := Score("/:foo(", nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
+
+func TestSortBySpecificity(t *testing.T) {
+	t.Run("should sort the most specific paths first", func(t *testing.T) {
+		paths := []string{"/:all*", "/users/:id", "/users/:id/posts", "/users/new", "/users/:id(\\d+)", "/users"}
+		if err := SortBySpecificity(paths, nil); err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{"/users/new", "/users/:id(\\d+)", "/users/:id/posts", "/users/:id", "/users", "/:all*"}
+		if !reflect.DeepEqual(paths, expect) {
+			t.Errorf(testErrorFormat, paths, expect)
+		}
+	})
+
+	t.Run("should keep the order of ties", func(t *testing.T) {
+		paths := []string{"/b/:id", "/a/:id", "/c/:name"}
+		if err := SortBySpecificity(paths, nil); err != nil {
+			t.Fatal(err)
+		}
+		expect := []string{"/b/:id", "/a/:id", "/c/:name"}
+		if !reflect.DeepEqual(paths, expect) {
+			t.Errorf(testErrorFormat, paths, expect)
+		}
+	})
+
+	t.Run("should return the parse error", func(t *testing.T) {
+		if err := SortBySpecificity([]string{"/", "/:foo("}, nil); err == nil {
+			t.Error("expect an error")
+		}
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// ServeMuxOptions returns the options matching like the Go 1.22 http.ServeMux,
+// to use with the templates returned by FromServeMux: case sensitive, without
+// an optional trailing slash, and with `/` as the only delimiter.
+func ServeMuxOptions() *Options {
+	return &Options{Sensitive: true, Strict: true, Delimiter: "/"}
+}
+
+// FromServeMux translates a Go 1.22 http.ServeMux pattern such as
+// `GET /users/{id}` to its method, empty when matching any method, and a
+// template matching like the pattern with ServeMuxOptions:
+//
+//   - `{name}` is translated to `:name`, matching a non empty segment
+//   - `{name...}` is translated to `:name(.*)`, matching the rest of the path
+//   - `{$}` ends the template, so that it only matches up to its trailing slash
+//   - a trailing slash without `{$}` is followed by `(.*)`, as such a pattern
+//     matches the paths it prefixes
+//
+// The characters of the literal segments which are special in templates are
+// escaped. Patterns with a host aren't supported.
+func FromServeMux(pattern string) (method string, template string, err error) {
+	path := pattern
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		method, path = pattern[:i], strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	if path == "" {
+		return "", "", errors.New("empty pattern path")
+	}
+	if path[0] != '/' {
+		return "", "", fmt.Errorf("host in pattern %q isn't supported", pattern)
+	}
+
+	var b strings.Builder
+	names := map[string]bool{}
+	segments := strings.Split(path[1:], "/")
+	for i, segment := range segments {
+		b.WriteString("/")
+		last := i == len(segments)-1
+		if !strings.HasPrefix(segment, "{") {
+			if strings.ContainsAny(segment, "{}") {
+				return "", "", fmt.Errorf("bad wildcard segment %q (must be entire segment)", segment)
+			}
+			if last && segment == "" {
+				b.WriteString("(.*)")
+				break
+			}
+			for _, r := range segment {
+				if strings.ContainsRune(`:()*+?\{}`, r) {
+					b.WriteString(`\`)
+				}
+				b.WriteRune(r)
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(segment, "}") {
+			return "", "", fmt.Errorf("bad wildcard segment %q (must be entire segment)", segment)
+		}
+		name := segment[1 : len(segment)-1]
+		if name == "$" {
+			if !last {
+				return "", "", errors.New("{$} not at end")
+			}
+			break
+		}
+		rest := strings.HasSuffix(name, "...")
+		if rest {
+			if !last {
+				return "", "", errors.New("{...} wildcard not at end")
+			}
+			name = strings.TrimSuffix(name, "...")
+		}
+		if !token.IsIdentifier(name) {
+			return "", "", fmt.Errorf("bad wildcard name %q", name)
+		}
+		if names[name] {
+			return "", "", fmt.Errorf("duplicate wildcard name %q", name)
+		}
+		names[name] = true
+
+		b.WriteString(":" + name)
+		if rest {
+			b.WriteString("(.*)")
+		}
+	}
+
+	return method, b.String(), nil
+}
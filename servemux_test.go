@@ -0,0 +1,110 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromServeMux(t *testing.T) {
+	t.Run("should match like the ServeMux", func(t *testing.T) {
+		tests := []struct {
+			pattern  string
+			method   string
+			template string
+			// path and params, nil params meaning no match
+			matches [][2]interface{}
+		}{
+			{"/users/{id}", "", "/users/:id", [][2]interface{}{
+				{"/users/42", m{"id": "42"}},
+				{"/users/42/", nil},
+				{"/users/", nil},
+				{"/users", nil},
+				{"/Users/42", nil},
+				{"/users/42/posts", nil},
+			}},
+			{"GET /files/{path...}", "GET", "/files/:path(.*)", [][2]interface{}{
+				{"/files/", m{"path": ""}},
+				{"/files/a", m{"path": "a"}},
+				{"/files/a/b/", m{"path": "a/b/"}},
+				{"/files", nil},
+			}},
+			{"/static/", "", "/static/(.*)", [][2]interface{}{
+				{"/static/", m{0: ""}},
+				{"/static/css/site.css", m{0: "css/site.css"}},
+				{"/static", nil},
+			}},
+			{"POST /posts/{$}", "POST", "/posts/", [][2]interface{}{
+				{"/posts/", m{}},
+				{"/posts", nil},
+				{"/posts/1", nil},
+			}},
+			{"/", "", "/(.*)", [][2]interface{}{
+				{"/", m{0: ""}},
+				{"/anything/at/all", m{0: "anything/at/all"}},
+			}},
+			{"/{$}", "", "/", [][2]interface{}{
+				{"/", m{}},
+				{"/a", nil},
+			}},
+			{"/b/{bucket}/o/{objectname...}", "", "/b/:bucket/o/:objectname(.*)", [][2]interface{}{
+				{"/b/photos/o/2024/cat.jpg", m{"bucket": "photos", "objectname": "2024/cat.jpg"}},
+				{"/b//o/x", nil},
+			}},
+			{"DELETE\t/a:b(c)*/{x}", "DELETE", "/a\\:b\\(c\\)\\*/:x", [][2]interface{}{
+				{"/a:b(c)*/1", m{"x": "1"}},
+				{"/ab/1", nil},
+			}},
+		}
+
+		for _, test := range tests {
+			method, template, err := FromServeMux(test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method != test.method || template != test.template {
+				t.Errorf(testErrorFormat, []string{method, template}, []string{test.method, test.template})
+				continue
+			}
+
+			match := MustMatch(template, ServeMuxOptions())
+			for _, v := range test.matches {
+				result, err := match(v[0].(string))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var params interface{}
+				if result != nil {
+					params = m(result.Params)
+				}
+				if (params == nil) != (v[1] == nil) || (params != nil && !reflect.DeepEqual(params, v[1])) {
+					t.Errorf("%s %s: "+testErrorFormat, test.pattern, v[0], params, v[1])
+				}
+			}
+		}
+	})
+
+	t.Run("should reject invalid patterns", func(t *testing.T) {
+		tests := [][2]string{
+			{"", "empty pattern path"},
+			{"GET ", "empty pattern path"},
+			{"example.com/", `host in pattern "example.com/" isn't supported`},
+			{"/a{x}", `bad wildcard segment "a{x}" (must be entire segment)`},
+			{"/{x}a", `bad wildcard segment "{x}a" (must be entire segment)`},
+			{"/{$}/a", "{$} not at end"},
+			{"/{x...}/a", "{...} wildcard not at end"},
+			{"/{1x}", `bad wildcard name "1x"`},
+			{"/{}", `bad wildcard name ""`},
+			{"/{x}/{x}", `duplicate wildcard name "x"`},
+		}
+		for _, test := range tests {
+			_, _, err := FromServeMux(test[0])
+			if err == nil || err.Error() != test[1] {
+				t.Errorf("%s: "+testErrorFormat, test[0], err, test[1])
+			}
+		}
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import "strconv"
+
+// The patterns converted by `Options.TypedParams`, other patterns keep the
+// values as strings.
+var (
+	intPatterns = map[string]bool{
+		`\d+`: true, `[0-9]+`: true, `-?\d+`: true, `-?[0-9]+`: true, `[+-]?\d+`: true, `[-+]?\d+`: true,
+	}
+	floatPatterns = map[string]bool{
+		`\d+(?:\.\d+)?`: true, `\d+\.\d+`: true, `-?\d+(?:\.\d+)?`: true, `-?\d+\.\d+`: true,
+		`[0-9]+(?:\.[0-9]+)?`: true, `\d*\.?\d+`: true,
+	}
+)
+
+// Returns the typed form of the param values, an int64 or a float64
+// according to the pattern of the token, or nil if the values are kept as
+// strings.
+func paramConverter(token Token) func(string) (interface{}, bool) {
+	if token.Matrix != nil {
+		return nil
+	}
+	if intPatterns[token.Pattern] {
+		return func(str string) (interface{}, bool) {
+			v, err := strconv.ParseInt(str, 10, 64)
+			return v, err == nil
+		}
+	}
+	if floatPatterns[token.Pattern] {
+		return func(str string) (interface{}, bool) {
+			v, err := strconv.ParseFloat(str, 64)
+			return v, err == nil
+		}
+	}
+	return nil
+}
+
+// Converts the values of a repeated param, which are kept as strings unless
+// all of them can be converted.
+func convertParams(convert func(string) (interface{}, bool), arr []string) interface{} {
+	values := make([]interface{}, len(arr))
+	for i, str := range arr {
+		v, ok := convert(str)
+		if !ok {
+			return arr
+		}
+		values[i] = v
+	}
+
+	switch values[0].(type) {
+	case int64:
+		typed := make([]int64, len(values))
+		for i, v := range values {
+			typed[i] = v.(int64)
+		}
+		return typed
+	default:
+		typed := make([]float64, len(values))
+		for i, v := range values {
+			typed[i] = v.(float64)
+		}
+		return typed
+	}
+}
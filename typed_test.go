@@ -0,0 +1,101 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTypedParams(t *testing.T) {
+	options := &Options{TypedParams: true}
+
+	t.Run("should convert the params", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			pathname string
+			params   m
+		}{
+			{"/users/:id(\\d+)", "/users/42", m{"id": int64(42)}},
+			{"/users/:id([0-9]+)", "/users/007", m{"id": int64(7)}},
+			{"/offset/:n(-?\\d+)", "/offset/-3", m{"n": int64(-3)}},
+			{"/price/:value(\\d+(?:\\.\\d+)?)", "/price/9.99", m{"value": 9.99}},
+			{"/price/:value(\\d+(?:\\.\\d+)?)", "/price/10", m{"value": float64(10)}},
+			{"/(\\d+)/:name", "/1/a", m{0: int64(1), "name": "a"}},
+			{"/ids/:id(\\d+)+", "/ids/1/2/3", m{"id": []int64{1, 2, 3}}},
+			{"/ids/:id(\\d+)+", "/ids/1", m{"id": []int64{1}}},
+		}
+		for _, test := range tests {
+			result, err := MustMatch(test.path, options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(test.params)) {
+				t.Errorf(testErrorFormat, result, test.params)
+			}
+		}
+	})
+
+	t.Run("should keep the values out of range as strings", func(t *testing.T) {
+		tests := []struct {
+			path     string
+			pathname string
+			params   m
+		}{
+			{"/:id(\\d+)", "/9223372036854775808", m{"id": "9223372036854775808"}},
+			{"/:id(\\d+)+", "/1/9223372036854775808", m{"id": []string{"1", "9223372036854775808"}}},
+			{"/:n(\\d+(?:\\.\\d+)?)", "/1" + strings.Repeat("0", 400), m{"n": "1" + strings.Repeat("0", 400)}},
+		}
+		for _, test := range tests {
+			result, err := MustMatch(test.path, options)(test.pathname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(test.params)) {
+				t.Errorf(testErrorFormat, result, test.params)
+			}
+		}
+	})
+
+	t.Run("should keep the other patterns as strings", func(t *testing.T) {
+		result, err := MustMatch("/:name/:id(\\d{3})/:code([a-f\\d]+)", options)("/a/123/42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := m{"name": "a", "id": "123", "code": "42"}
+		if result == nil || !reflect.DeepEqual(result.Params, map[interface{}]interface{}(expect)) {
+			t.Errorf(testErrorFormat, result, expect)
+		}
+	})
+
+	t.Run("should keep the strings by default", func(t *testing.T) {
+		result, err := MustMatch("/users/:id(\\d+)", nil)("/users/42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil || result.Params["id"] != "42" {
+			t.Errorf(testErrorFormat, result, "42")
+		}
+	})
+
+	t.Run("should be kept by ExportRoutes", func(t *testing.T) {
+		data, err := ExportRoutes([]*Matcher{mustMatcher("/users/:id(\\d+)", options)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := ImportRoutes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := restored[0].Match("/users/42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil || result.Params["id"] != int64(42) {
+			t.Errorf(testErrorFormat, result, int64(42))
+		}
+	})
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateTypeScript returns a TypeScript declaration file with an interface
+// of the params of each route, named after the route in pascal case with a
+// `Params` suffix, and a `Routes` interface from the route names to their
+// params interfaces. The routes are sorted by name so the output is stable.
+//
+// A param is a `string`, or a `string[]` when repeated by `+` or `*`, and is
+// an optional member when it may be missing. Unnamed params are named `p0`,
+// `p1`, etc.
+func GenerateTypeScript(routes map[string]string, options *Options) ([]byte, error) {
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	typeNames := make(map[string]string, len(routes))
+	routesByType := make(map[string]string, len(routes))
+	for _, name := range names {
+		typeName := typeScriptTypeName(name)
+		if other, ok := routesByType[typeName]; ok {
+			return nil, fmt.Errorf("routes %q and %q have the same type name %s", other, name, typeName)
+		}
+		typeNames[name], routesByType[typeName] = typeName, name
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by pathtoregexp.GenerateTypeScript. DO NOT EDIT.\n")
+	for _, name := range names {
+		tokens, err := Parse(routes[name], options)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %v", name, err)
+		}
+
+		// A repeated name keeps its first position and the type of its last
+		// param, as the last param of a name wins when matching, and is only
+		// optional when all its params are.
+		var members []string
+		types := map[string]string{}
+		optional := map[string]bool{}
+		var params []Token
+		for _, token := range tokens {
+			token, ok := token.(Token)
+			if !ok || token.Pattern == "" {
+				continue
+			}
+			if token.Matrix != nil {
+				for _, param := range token.Matrix.Params {
+					if param.Token != nil {
+						params = append(params, *param.Token)
+					}
+				}
+				continue
+			}
+			params = append(params, token)
+		}
+		for _, token := range params {
+			member := fmt.Sprintf("%v", token.Name)
+			if index, ok := token.Index(); ok {
+				member = "p" + strconv.Itoa(index)
+			}
+			if _, ok := types[member]; !ok {
+				members = append(members, member)
+				optional[member] = true
+			}
+			types[member] = "string"
+			if token.Modifier == "+" || token.Modifier == "*" {
+				types[member] = "string[]"
+			}
+			optional[member] = optional[member] && (token.Modifier == "?" || token.Modifier == "*")
+		}
+
+		fmt.Fprintf(&b, "\n// %s\n", strconv.Quote(routes[name]))
+		if len(members) == 0 {
+			fmt.Fprintf(&b, "export interface %s {}\n", typeNames[name])
+			continue
+		}
+		fmt.Fprintf(&b, "export interface %s {\n", typeNames[name])
+		for _, member := range members {
+			mark := ""
+			if optional[member] {
+				mark = "?"
+			}
+			fmt.Fprintf(&b, "  %s%s: %s;\n", typeScriptMember(member), mark, types[member])
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\nexport interface Routes {\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: %s;\n", strconv.Quote(name), typeNames[name])
+	}
+	b.WriteString("}\n")
+
+	return b.Bytes(), nil
+}
+
+// Returns the pascal case name of the params interface of the route, e.g.
+// `UserPostsParams` for `user-posts`.
+func typeScriptTypeName(route string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range route {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteString("Route")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+		upper = false
+	}
+	b.WriteString("Params")
+	return b.String()
+}
+
+// Returns the member name, quoted unless it's an identifier.
+func typeScriptMember(name string) string {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == '$' || i > 0 && unicode.IsDigit(r)) {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
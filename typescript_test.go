@@ -0,0 +1,66 @@
+// Copyright 2019 Guoyao Wu. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package pathtoregexp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypeScript(t *testing.T) {
+	t.Run("should match the golden file", func(t *testing.T) {
+		source, err := GenerateTypeScript(map[string]string{
+			"home":         "/",
+			"user":         "/user/:id(\\d+)",
+			"files":        "/files/:path*",
+			"tags":         "/tags/:tag+",
+			"post-archive": "/:year/:month?/(.*)",
+			"2fa":          "/2fa/:1/:id/:id?",
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden, err := ioutil.ReadFile(filepath.Join("testdata", "typescript.golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, golden) {
+			t.Errorf(testErrorFormat, string(source), string(golden))
+		}
+	})
+
+	t.Run("should quote the names which aren't identifiers", func(t *testing.T) {
+		source, err := GenerateTypeScript(map[string]string{
+			"user": "/users/:user.id/:tab?",
+		}, &Options{DotNames: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := "export interface UserParams {\n  \"user.id\": string;\n  tab?: string;\n}\n"
+		if !strings.Contains(string(source), expect) {
+			t.Errorf(testErrorFormat, string(source), expect)
+		}
+	})
+
+	t.Run("should return an error", func(t *testing.T) {
+		tests := []struct {
+			routes  map[string]string
+			message string
+		}{
+			{map[string]string{"bad": "/:foo("}, "route \"bad\": unbalanced pattern at 5"},
+			{map[string]string{"user-id": "/a", "user_id": "/b"},
+				"routes \"user-id\" and \"user_id\" have the same type name UserIdParams"},
+		}
+		for _, test := range tests {
+			_, err := GenerateTypeScript(test.routes, nil)
+			if err == nil || err.Error() != test.message {
+				t.Errorf(testErrorFormat, err, test.message)
+			}
+		}
+	})
+}